@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"github.com/gavin/nftSync/internal/api"
+	"github.com/gavin/nftSync/internal/auth"
 	"github.com/gavin/nftSync/internal/config"
 	"github.com/gavin/nftSync/internal/middleware"
+	"github.com/gavin/nftSync/internal/push"
 	"github.com/gavin/nftSync/internal/service"
 	"github.com/gin-gonic/gin"
 	"log"
@@ -24,29 +26,123 @@ func main() {
 		// 推荐使用 gin.New()，避免重复注册默认中间件
 		middleware.InitLogger() // 初始化 zap 日志
 		r := gin.New()
+		// 只信任配置的反向代理转发的客户端 IP，防止伪造 X-Forwarded-For 绕过按 IP 限流
+		if err := r.SetTrustedProxies(bizCtx.Config.RateLimit.TrustedProxies); err != nil {
+			log.Fatalf("可信代理配置错误: %v", err)
+		}
 		// 注册业务中间件
 		r.Use(middleware.ZapLogger())
 		r.Use(middleware.ZapRecovery())
 
 		apiGroup := r.Group("/api")
+		// 机器客户端可通过 X-API-Key 访问 nft、行情与订单接口，按 Key 限流并计入每日配额
+		apiKeys := service.NewAPIKeyService(bizCtx)
+		// 按路由分组限流，注册在认证之后以便按 API Key 或用户计数，未登录时按 IP
+		limiter := middleware.NewRateLimiter(bizCtx.Redis, bizCtx.Config.RateLimit)
+		marketLimit := limiter.Group("market")
 		// 注册nft相关接口，添加权限校验
 		nftGroup := apiGroup.Group("/nft")
-		// 需要权限的接口单独注册 AuthMiddleware
-		nftGroup.GET("/detail", middleware.AuthMiddleware(), api.GetNFTDetail(bizCtx))
-		nftGroup.GET("/list", middleware.AuthMiddleware(), api.GetNFTListByOwner(bizCtx))
+		nftGroup.Use(middleware.AuthOrAPIKey(bizCtx.Auth, apiKeys, auth.ScopeNFT), limiter.Group("nft"),
+			middleware.RequirePermission(auth.PermNFTRead))
+		nftGroup.GET("/detail", api.GetNFTDetail(bizCtx))
+		nftGroup.GET("/list", api.GetNFTListByOwner(bizCtx))
+		nftGroup.GET("/offers", api.GetBestOfferHandler(bizCtx))
+		nftGroup.GET("/listing", api.GetCheapestListingHandler(bizCtx))
+
+		// 注册合集相关接口（公开行情数据），无需权限校验
+		collectionGroup := apiGroup.Group("/collection")
+		collectionGroup.Use(middleware.OptionalAPIKey(apiKeys, auth.ScopeMarket), limiter.Group("collection"))
+		collectionGroup.GET("/:address/sales", api.GetCollectionSalesHandler(bizCtx))
+		// 单个 NFT 的成交历史与合集成交同属公开行情数据，使用相同的可选 API Key 与限流分组
+		apiGroup.GET("/nft/sales", middleware.OptionalAPIKey(apiKeys, auth.ScopeMarket), limiter.Group("collection"),
+			api.GetTokenSalesHandler(bizCtx))
+		collectionGroup.GET("/:address/floor/history", api.GetFloorHistoryHandler(bizCtx))
+		collectionGroup.GET("/:address/orderbook", api.GetOrderBookHandler(bizCtx))
+		collectionGroup.GET("/:address/royalties", api.GetRoyaltyReportHandler(bizCtx))
+		collectionGroup.GET("/:address/stats", api.GetCollectionStatsHandler(bizCtx))
+		collectionGroup.POST("/:address/candles/backfill", middleware.AuthMiddleware(bizCtx.Auth),
+			middleware.RequirePermission(auth.PermCollectionManage), api.BackfillCandlesHandler(bizCtx))
+
+		// 注册钱包相关接口，添加权限校验，仅可访问已绑定的钱包
+		walletGroup := apiGroup.Group("/wallet")
+		walletGroup.Use(middleware.AuthMiddleware(bizCtx.Auth), limiter.Group("wallet"), middleware.RequirePermission(auth.PermWalletRead))
+		walletGroup.GET("/:address/portfolio", api.GetPortfolioHandler(bizCtx))
+
+		// 注册统计、排行榜与活动流接口（公开行情数据），无需权限校验
+		apiGroup.GET("/stats", middleware.OptionalAPIKey(apiKeys, auth.ScopeMarket), marketLimit, api.GetGlobalStatsHandler(bizCtx))
+		apiGroup.GET("/orders/stats", middleware.OptionalAPIKey(apiKeys, auth.ScopeMarket), marketLimit, api.GetOrderStatsHandler(bizCtx))
+		leaderboardGroup := apiGroup.Group("/leaderboard")
+		leaderboardGroup.Use(middleware.OptionalAPIKey(apiKeys, auth.ScopeMarket), marketLimit)
+		leaderboardGroup.GET("/collections", api.GetTopCollectionsHandler(bizCtx))
+		leaderboardGroup.GET("/traders", api.GetTopTradersHandler(bizCtx))
+		apiGroup.GET("/activity", middleware.OptionalAPIKey(apiKeys, auth.ScopeMarket), marketLimit, api.GetActivityHandler(bizCtx))
+
+		// 注册实时推送接口（公开行情数据），各实例经 Redis pub/sub 接收全部事件后按订阅条件下发
+		if bizCtx.Config.Push.Enabled {
+			hub := push.NewHub(bizCtx.Config.Push.SendBuffer)
+			go service.NewPushBroker(bizCtx).Run(context.Background(), hub)
+			streamGroup := apiGroup.Group("/stream")
+			streamGroup.Use(limiter.Group("stream"))
+			streamGroup.GET("/ws", api.StreamWebSocketHandler(bizCtx, hub))
+			streamGroup.GET("/sse", api.StreamSSEHandler(bizCtx, hub))
+		}
+
+		// 注册刷量检测审核接口，仅 operator/admin
+		washGroup := apiGroup.Group("/wash")
+		washGroup.Use(middleware.AuthMiddleware(bizCtx.Auth), limiter.Group("wash"), middleware.RequirePermission(auth.PermWashReview))
+		washGroup.GET("/flags", api.ListTradeFlagsHandler(bizCtx))
+		washGroup.POST("/flags/:id/review", api.ReviewTradeFlagHandler(bizCtx))
+
+		// 注册 webhook 管理接口，添加权限校验
+		webhookGroup := apiGroup.Group("/webhooks")
+		webhookGroup.Use(middleware.AuthMiddleware(bizCtx.Auth), limiter.Group("webhooks"), middleware.RequirePermission(auth.PermWebhookManage))
+		webhookGroup.POST("", api.CreateWebhookHandler(bizCtx))
+		webhookGroup.GET("", api.ListWebhooksHandler(bizCtx))
+		webhookGroup.DELETE("/:id", api.DeleteWebhookHandler(bizCtx))
+		webhookGroup.POST("/:id/enable", api.EnableWebhookHandler(bizCtx))
+		webhookGroup.POST("/:id/ping", api.PingWebhookHandler(bizCtx))
+		webhookGroup.GET("/:id/deliveries", api.ListWebhookDeliveriesHandler(bizCtx))
+		webhookGroup.POST("/:id/replay", api.ReplayWebhookHandler(bizCtx))
 
 		// 注册订单相关接口，添加权限校验
 		orderGroup := apiGroup.Group("/order")
-		orderGroup.Use(middleware.AuthMiddleware())
-		orderGroup.GET(":id", api.GetOrderHandler(bizCtx))
-		orderGroup.GET("/list", api.ListUserOrdersHandler(bizCtx))
+		orderGroup.Use(middleware.AuthOrAPIKey(bizCtx.Auth, apiKeys, auth.ScopeOrders), limiter.Group("order"))
+		orderGroup.GET(":id", middleware.RequirePermission(auth.PermOrderRead), api.GetOrderHandler(bizCtx))
+		orderGroup.GET("/list", middleware.RequirePermission(auth.PermOrderRead), api.ListUserOrdersHandler(bizCtx))
+		orderGroup.POST("", middleware.RequirePermission(auth.PermOrderWrite), api.SubmitOrderHandler(bizCtx))
+		orderGroup.POST("/cancel", middleware.RequirePermission(auth.PermOrderWrite), api.CancelOrderHandler(bizCtx))
 
 		// 注册用户相关接口，无需权限校验
 		userGroup := apiGroup.Group("/user")
+		userGroup.Use(limiter.Group("auth"))
 		userGroup.POST("/register", api.RegisterUserHandler(bizCtx))
 		userGroup.POST("/login", api.LoginUserHandler(bizCtx))
 		userGroup.GET("/exists", api.UserExistsHandler(bizCtx))
 
+		// 令牌刷新与注销、以太坊钱包登录（SIWE）
+		authGroup := apiGroup.Group("/auth")
+		authGroup.Use(limiter.Group("auth"))
+		authGroup.GET("/nonce", api.SIWENonceHandler(bizCtx))
+		authGroup.POST("/siwe", api.SIWELoginHandler(bizCtx))
+		authGroup.POST("/refresh", api.RefreshTokenHandler(bizCtx))
+		authGroup.POST("/logout", middleware.AuthMiddleware(bizCtx.Auth), api.LogoutHandler(bizCtx))
+		r.GET("/.well-known/jwks.json", api.JWKSHandler(bizCtx))
+
+		// 注册 API Key 管理接口，仅接受用户登录令牌
+		keyGroup := apiGroup.Group("/keys")
+		keyGroup.Use(middleware.AuthMiddleware(bizCtx.Auth), limiter.Group("keys"), middleware.RequirePermission(auth.PermAPIKeyManage))
+		keyGroup.POST("", api.CreateAPIKeyHandler(bizCtx))
+		keyGroup.GET("", api.ListAPIKeysHandler(bizCtx))
+		keyGroup.DELETE("/:id", api.RevokeAPIKeyHandler(bizCtx))
+		keyGroup.GET("/:id/usage", api.APIKeyUsageHandler(bizCtx))
+
+		// 注册管理接口，仅 admin
+		adminGroup := apiGroup.Group("/admin")
+		adminGroup.Use(middleware.AuthMiddleware(bizCtx.Auth), limiter.Group("admin"))
+		adminGroup.GET("/nodes", middleware.RequirePermission(auth.PermNodeStatus), api.NodeStatusHandler(bizCtx))
+		adminGroup.POST("/orderbook/reindex", middleware.RequirePermission(auth.PermReindex), api.ReindexOrderBookHandler(bizCtx))
+		adminGroup.PUT("/users/:id/role", middleware.RequirePermission(auth.PermUserManage), api.SetUserRoleHandler(bizCtx))
+
 		if err := r.Run(":8080"); err != nil {
 			log.Fatalf("API服务启动失败: %v", err)
 		}
@@ -86,9 +182,123 @@ func main() {
 		}
 	}()
 
+	// 启动公开市场成交同步 goroutine
+	go func() {
+		ticker := time.NewTicker(time.Duration(bizCtx.Config.Sync.OrderInterval) * time.Second)
+		defer ticker.Stop()
+		ctx := context.Background()
+		for {
+			<-ticker.C
+			multiNodeSyncService.SyncMarketplaceSalesPolling(ctx, bizCtx)
+		}
+	}()
+
+	// 启动挂单有效性校验 goroutine，启动时从 MySQL 重建订单簿索引，每轮校验后检测索引漂移
+	go func() {
+		validator := service.NewOrderValidatorService(bizCtx)
+		ctx := context.Background()
+		validator.OrderBook.RebuildAll(ctx)
+		ticker := time.NewTicker(time.Duration(bizCtx.Config.Sync.ValidateInterval) * time.Second)
+		defer ticker.Stop()
+		for {
+			<-ticker.C
+			validator.ValidateOrders(ctx)
+			validator.OrderBook.CheckDrift(ctx)
+		}
+	}()
+
+	// 启动订单撮合 goroutine
+	if bizCtx.Config.Matching.Enabled {
+		go func() {
+			matchingService := service.NewMatchingService(bizCtx)
+			ticker := time.NewTicker(time.Duration(bizCtx.Config.Matching.Interval) * time.Second)
+			defer ticker.Stop()
+			ctx := context.Background()
+			for {
+				<-ticker.C
+				matchingService.MatchAll(ctx)
+			}
+		}()
+	}
+
+	// 启动刷量检测 goroutine，标记结果在下一轮统计汇总时生效；资金转账索引随检测启用
+	if bizCtx.Config.WashTrading.Enabled {
+		go func() {
+			indexer := service.NewFundingIndexer(bizCtx)
+			ticker := time.NewTicker(time.Duration(bizCtx.Config.Sync.PollingInterval) * time.Second)
+			defer ticker.Stop()
+			ctx := context.Background()
+			for {
+				<-ticker.C
+				indexer.SyncOnce(ctx)
+			}
+		}()
+		go func() {
+			detector := service.NewWashTradeDetector(bizCtx)
+			interval := bizCtx.Config.WashTrading.Interval
+			if interval <= 0 {
+				interval = 600
+			}
+			ctx := context.Background()
+			ticker := time.NewTicker(time.Duration(interval) * time.Second)
+			defer ticker.Stop()
+			for {
+				if _, err := detector.Detect(ctx); err != nil {
+					log.Printf("[wash] 刷量检测失败: %v", err)
+				}
+				<-ticker.C
+			}
+		}()
+	}
+
+	// 启动成交统计汇总 goroutine，启动时先算一轮
+	go func() {
+		analyticsService := service.NewAnalyticsService(bizCtx)
+		interval := bizCtx.Config.Analytics.Interval
+		if interval <= 0 {
+			interval = 300
+		}
+		ctx := context.Background()
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for {
+			if err := analyticsService.Refresh(ctx); err != nil {
+				log.Printf("[analytics] 统计汇总失败: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+
+	// 启动 webhook 投递 goroutine：扫描新事件写入发件箱，投递到期记录
+	go func() {
+		dispatcher := service.NewWebhookDispatcher(bizCtx)
+		if err := dispatcher.EnsureSystemEndpoint(); err != nil {
+			log.Printf("[webhook] 系统级 webhook 注册失败: %v", err)
+		}
+		ctx := context.Background()
+		ticker := time.NewTicker(time.Duration(dispatcher.Config.Interval) * time.Second)
+		defer ticker.Stop()
+		for {
+			<-ticker.C
+			dispatcher.Run(ctx)
+		}
+	}()
+
+	// 启动发件箱中继 goroutine：订单变更同事务写入的地板价消息与领域事件按序发布到消息总线
+	go func() {
+		relay := service.NewOutboxRelay(bizCtx)
+		ctx := context.Background()
+		ticker := time.NewTicker(time.Duration(relay.Config.Interval) * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			<-ticker.C
+			relay.Run(ctx)
+		}
+	}()
+
 	//地板价消息消费
 	go func() {
-		service.NewFloorPriceService(bizCtx).StartKafkaConsumer(context.Background())
+		service.NewFloorPriceService(bizCtx).StartConsumer(context.Background())
 	}()
 
 	select {} // 阻塞主 goroutine，防止退出
@@ -0,0 +1,52 @@
+// 本地 webhook 接收端，用于联调签名校验、重试与自动停用
+//
+//	go run ./cmd/webhookReceiver -addr :9090 -secret <注册时返回的 secret>
+//	go run ./cmd/webhookReceiver -fail 5      # 前 5 次请求返回 500，验证指数退避重试
+//	go run ./cmd/webhookReceiver -status 503  # 始终失败，验证 endpoint 自动停用
+package main
+
+import (
+	"flag"
+	"github.com/gavin/nftSync/internal/webhook"
+	"io"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":9090", "监听地址")
+	secret := flag.String("secret", "", "签名密钥，为空时不校验签名")
+	fail := flag.Int64("fail", 0, "前 N 次请求返回 500")
+	status := flag.Int("status", http.StatusOK, "响应状态码")
+	flag.Parse()
+
+	var count int64
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&count, 1)
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		event, eventID, delivery := r.Header.Get(webhook.HeaderEvent), r.Header.Get(webhook.HeaderEventID), r.Header.Get(webhook.HeaderDelivery)
+		if *secret != "" {
+			err := webhook.Verify(*secret, r.Header.Get(webhook.HeaderTimestamp), r.Header.Get(webhook.HeaderSignature), body, 5*time.Minute)
+			if err != nil {
+				log.Printf("#%d 签名校验失败: delivery=%s, err=%v", n, delivery, err)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+		if n <= *fail {
+			log.Printf("#%d 模拟失败: event=%s, id=%s, delivery=%s", n, event, eventID, delivery)
+			http.Error(w, "simulated failure", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("#%d 收到事件: event=%s, id=%s, delivery=%s, body=%s", n, event, eventID, delivery, body)
+		w.WriteHeader(*status)
+	})
+	log.Printf("webhook 接收端已启动: %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
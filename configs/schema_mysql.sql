@@ -33,15 +33,33 @@ CREATE TABLE items (
 CREATE INDEX idx_items_nft_id ON items(nft_id);
 CREATE INDEX idx_items_trait_type ON items(trait_type);
 
+-- 链上原始金额（price/fee/royalty/value）以十进制字符串存为 VARCHAR(78)，可容纳 uint256 最大值；
+-- DECIMAL 最多 65 位，超出的金额写入失败。金额汇总在应用内按 big.Int 计算，不依赖 SQL SUM。
+-- 升级：ALTER TABLE orders MODIFY price VARCHAR(78) NOT NULL, ADD COLUMN fee VARCHAR(78) DEFAULT '0' AFTER price;
+--       ALTER TABLE trades MODIFY price VARCHAR(78) NOT NULL, MODIFY fee VARCHAR(78) DEFAULT '0',
+--         MODIFY royalty VARCHAR(78) DEFAULT '0', MODIFY expected_royalty VARCHAR(78) DEFAULT '0';
+--       ALTER TABLE fund_transfers MODIFY value VARCHAR(78) NOT NULL;
+--       ALTER TABLE activities MODIFY price VARCHAR(78) NOT NULL DEFAULT '0';
+
 -- 挂单表（可选，示例）
 CREATE TABLE orders (
     id BIGINT AUTO_INCREMENT PRIMARY KEY,
     nft_id BIGINT NOT NULL,
     nft_token VARCHAR(128) NOT NULL,
+    token_id VARCHAR(128), -- 合集出价为空
     seller VARCHAR(128) NOT NULL,
     buyer VARCHAR(128),
-    price VARCHAR(64) NOT NULL,
-    status VARCHAR(32) NOT NULL, -- listed, matched, completed, cancelled
+    price VARCHAR(78) NOT NULL,         -- 链上原始数值（uint256 十进制字符串）
+    fee VARCHAR(78) DEFAULT '0',
+    price_scaled DECIMAL(38,18),        -- 按币种 decimals 缩放
+    currency VARCHAR(128),              -- 支付币种地址，ETH为零地址
+    status VARCHAR(32) NOT NULL, -- listed, matched, completed, cancelled, expired, invalid
+    exchange VARCHAR(128),              -- 订单所属交易合约
+    start_time BIGINT DEFAULT 0,        -- 生效时间（秒）
+    end_time BIGINT DEFAULT 0,          -- 过期时间（秒），0表示永不过期
+    nonce VARCHAR(80),
+    signature VARCHAR(256),             -- 链下订单 EIP-712 签名，链上订单为空
+    criteria TEXT,                      -- 属性出价条件（JSON），仅合集出价使用
     tx_hash VARCHAR(128) NOT NULL,
     block_number BIGINT NOT NULL,
     block_time BIGINT NOT NULL,
@@ -54,17 +72,336 @@ CREATE INDEX idx_orders_nft_id ON orders(nft_id);
 CREATE INDEX idx_orders_seller ON orders(seller);
 CREATE INDEX idx_orders_buyer ON orders(buyer);
 CREATE INDEX idx_orders_status ON orders(status);
+CREATE INDEX idx_orders_end_time ON orders(end_time);
+-- 同一 maker 在同一交易合约下 nonce 唯一，无 nonce 的订单为 NULL 不受约束
+-- 升级时先清理：UPDATE orders SET nonce = NULL WHERE nonce = ''; 并处理已存在的重复 nonce 后再建索引
+CREATE UNIQUE INDEX uk_orders_maker_nonce ON orders(seller, exchange, nonce);
 
--- 交易表（可选，示例）
+-- 成交表（销售账本）
 CREATE TABLE trades (
     id BIGINT AUTO_INCREMENT PRIMARY KEY,
-    order_id BIGINT NOT NULL,
+    order_id VARCHAR(128),              -- 订单ID或市场订单哈希
+    marketplace VARCHAR(64),
+    collection VARCHAR(128) NOT NULL,
+    token_id VARCHAR(128) NOT NULL,
+    seller VARCHAR(128) NOT NULL,
     buyer VARCHAR(128) NOT NULL,
-    price VARCHAR(64) NOT NULL,
-    created_at BIGINT,
-    updated_at BIGINT,
-    deleted_at BIGINT,
-    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+    price VARCHAR(78) NOT NULL,         -- 链上原始数值（uint256 十进制字符串）
+    price_scaled DECIMAL(38,18),        -- 按币种 decimals 缩放
+    price_base DECIMAL(38,18),          -- 写入时按当时汇率折算的基础币种价格，NULL 表示尚未折算
+    currency VARCHAR(128) NOT NULL,     -- 支付币种地址，ETH为零地址
+    fee VARCHAR(78) DEFAULT '0',
+    royalty VARCHAR(78) DEFAULT '0',    -- 实际支付的版税
+    expected_royalty VARCHAR(78) DEFAULT '0', -- ERC-2981 或版税登记计算的应付版税
+    royalty_receiver VARCHAR(128),
+    royalty_source VARCHAR(32),         -- erc2981, registry
+    royalty_status VARCHAR(32),         -- none, paid, underpaid, skipped, unknown
+    tx_hash VARCHAR(128) NOT NULL,
+    log_index INT NOT NULL,
+    block_number BIGINT NOT NULL,
+    block_time BIGINT NOT NULL,
+    source VARCHAR(32) NOT NULL,        -- order, marketplace, transfer
+    created_at DATETIME
 );
-CREATE INDEX idx_trades_order_id ON trades(order_id);
+CREATE UNIQUE INDEX uk_trades_tx_token ON trades(tx_hash, log_index, collection, token_id);
+CREATE INDEX idx_trades_collection ON trades(collection);
+CREATE INDEX idx_trades_seller ON trades(seller);
 CREATE INDEX idx_trades_buyer ON trades(buyer);
+CREATE INDEX idx_trades_block_time ON trades(block_time);
+CREATE INDEX idx_trades_price_base ON trades(price_base);
+
+-- 支付币种表
+CREATE TABLE currencies (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    address VARCHAR(128) NOT NULL,
+    symbol VARCHAR(32),
+    decimals TINYINT UNSIGNED NOT NULL
+);
+CREATE UNIQUE INDEX uk_currencies_address ON currencies(address);
+
+-- NFT转移记录表（含铸造与销毁）
+CREATE TABLE nft_transfers (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    collection VARCHAR(128) NOT NULL,
+    token_id VARCHAR(128) NOT NULL,
+    from_addr VARCHAR(128) NOT NULL,
+    to_addr VARCHAR(128) NOT NULL,
+    tx_hash VARCHAR(128) NOT NULL,
+    log_index INT NOT NULL,
+    block_number BIGINT NOT NULL,
+    block_time BIGINT NOT NULL,
+    created_at DATETIME
+);
+CREATE UNIQUE INDEX uk_transfers_tx_log ON nft_transfers(tx_hash, log_index);
+CREATE INDEX idx_transfers_token ON nft_transfers(collection, token_id);
+CREATE INDEX idx_transfers_from ON nft_transfers(from_addr);
+CREATE INDEX idx_transfers_to ON nft_transfers(to_addr);
+
+-- 地板价变动历史
+CREATE TABLE floor_price_histories (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    collection VARCHAR(128) NOT NULL,
+    price VARCHAR(80) NOT NULL,         -- 已折算为基础币种，空字符串表示合集已无挂单
+    currency VARCHAR(128) NOT NULL,
+    trigger_event VARCHAR(32),          -- order_created, order_cancelled, order_filled, order_expired, order_invalid, transfer
+    timestamp BIGINT NOT NULL
+);
+CREATE INDEX idx_floor_history_collection_time ON floor_price_histories(collection, timestamp);
+
+-- 成交K线（5m/1h/1d/1w）
+CREATE TABLE sale_candles (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    collection VARCHAR(128) NOT NULL,
+    interval_key VARCHAR(8) NOT NULL,
+    bucket_start BIGINT NOT NULL,
+    open DECIMAL(38,18) NOT NULL,
+    high DECIMAL(38,18) NOT NULL,
+    low DECIMAL(38,18) NOT NULL,
+    close DECIMAL(38,18) NOT NULL,
+    volume DECIMAL(38,18) NOT NULL,
+    count BIGINT NOT NULL,
+    open_time BIGINT NOT NULL DEFAULT 0,  -- 首笔成交时间，乱序写入时确定开盘价
+    close_time BIGINT NOT NULL DEFAULT 0  -- 末笔成交时间
+);
+CREATE UNIQUE INDEX uk_candles_bucket ON sale_candles(collection, interval_key, bucket_start);
+
+-- 合集成交时间桶汇总（5 分钟一桶，collection 为 * 表示全市场），由成交账本增量重算
+-- 升级时旧成交的 price_base 为 NULL，统计服务首轮按当前汇率补写
+CREATE TABLE trade_rollups (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    collection VARCHAR(128) NOT NULL,
+    bucket_start BIGINT NOT NULL,
+    volume DECIMAL(38,18) NOT NULL,     -- 基础币种
+    sales BIGINT NOT NULL,
+    min_price DECIMAL(38,18) NOT NULL,
+    max_price DECIMAL(38,18) NOT NULL
+);
+CREATE UNIQUE INDEX uk_trade_rollups ON trade_rollups(collection, bucket_start);
+CREATE INDEX idx_trade_rollups_bucket ON trade_rollups(bucket_start);
+
+-- 地址成交时间桶汇总
+CREATE TABLE trader_rollups (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    address VARCHAR(128) NOT NULL,
+    bucket_start BIGINT NOT NULL,
+    buy_volume DECIMAL(38,18) NOT NULL,
+    sell_volume DECIMAL(38,18) NOT NULL,
+    buys BIGINT NOT NULL,
+    sells BIGINT NOT NULL
+);
+CREATE UNIQUE INDEX uk_trader_rollups ON trader_rollups(address, bucket_start);
+CREATE INDEX idx_trader_rollups_bucket ON trader_rollups(bucket_start);
+
+-- 地址在合集中最近一次成交时间，用于窗口内独立买家/卖家数与地址交易过的合集数
+CREATE TABLE trade_participants (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    collection VARCHAR(128) NOT NULL,   -- * 表示全市场
+    address VARCHAR(128) NOT NULL,
+    role VARCHAR(8) NOT NULL,           -- buyer, seller
+    last_trade_time BIGINT NOT NULL
+);
+CREATE UNIQUE INDEX uk_trade_participants ON trade_participants(collection, address, role);
+CREATE INDEX idx_trade_participants_time ON trade_participants(last_trade_time);
+
+-- 统计汇总扫描进度
+CREATE TABLE analytics_cursors (
+    source VARCHAR(64) PRIMARY KEY,     -- trades, trade_flags（id）, trade_flag_reviews（审核时间）
+    position BIGINT NOT NULL
+);
+
+-- 合集成交统计汇总表（按统计窗口由时间桶汇总，collection 为 * 表示全市场）
+-- 中位数在数据库内排序计算，需要 MySQL 8.0
+CREATE TABLE collection_stats (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    collection VARCHAR(128) NOT NULL,
+    window_key VARCHAR(8) NOT NULL,     -- 1h, 24h, 7d, 30d, all
+    volume DECIMAL(38,18) NOT NULL,     -- 基础币种
+    sales BIGINT NOT NULL,
+    avg_price DECIMAL(38,18) NOT NULL,
+    median_price DECIMAL(38,18) NOT NULL,
+    min_price DECIMAL(38,18) NOT NULL,
+    max_price DECIMAL(38,18) NOT NULL,
+    unique_buyers BIGINT NOT NULL,
+    unique_sellers BIGINT NOT NULL,
+    window_start BIGINT NOT NULL,       -- 按 5 分钟时间桶对齐
+    last_computed_at DATETIME
+);
+CREATE UNIQUE INDEX uk_collection_stats ON collection_stats(collection, window_key);
+CREATE INDEX idx_collection_stats_volume ON collection_stats(window_key, volume);
+
+-- 地址成交统计汇总表
+CREATE TABLE trader_stats (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    address VARCHAR(128) NOT NULL,
+    window_key VARCHAR(8) NOT NULL,
+    volume DECIMAL(38,18) NOT NULL,     -- 买入与卖出合计，基础币种
+    buy_volume DECIMAL(38,18) NOT NULL,
+    sell_volume DECIMAL(38,18) NOT NULL,
+    buys BIGINT NOT NULL,
+    sells BIGINT NOT NULL,
+    collections BIGINT NOT NULL,
+    last_computed_at DATETIME
+);
+CREATE UNIQUE INDEX uk_trader_stats ON trader_stats(address, window_key);
+CREATE INDEX idx_trader_stats_volume ON trader_stats(window_key, volume);
+
+-- 已索引的 ETH/WETH 资金转账（转入近期买卖双方的独立转账，不含成交结算），用于刷量检测追溯资金来源
+-- 旧版本从成交交易中写入的结算转账会产生误报，升级时清理：
+-- DELETE FROM fund_transfers WHERE tx_hash IN (SELECT tx_hash FROM trades);
+CREATE TABLE fund_transfers (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    from_addr VARCHAR(128) NOT NULL,
+    to_addr VARCHAR(128) NOT NULL,
+    token VARCHAR(128) NOT NULL,        -- ETH为零地址
+    value VARCHAR(78) NOT NULL,         -- uint256 十进制字符串
+    tx_hash VARCHAR(128) NOT NULL,
+    log_index INT NOT NULL,             -- 原生 ETH 转账为 -1
+    block_number BIGINT NOT NULL,
+    block_time BIGINT NOT NULL,
+    created_at DATETIME
+);
+CREATE UNIQUE INDEX uk_fund_transfers_tx_log ON fund_transfers(tx_hash, log_index);
+CREATE INDEX idx_fund_transfers_from ON fund_transfers(from_addr);
+CREATE INDEX idx_fund_transfers_to ON fund_transfers(to_addr);
+
+-- 可疑成交标记表
+CREATE TABLE trade_flags (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    trade_id BIGINT NOT NULL,
+    reason VARCHAR(32) NOT NULL,        -- self_trade, common_funder, round_trip, price_deviation, rapid_flip
+    collection VARCHAR(128) NOT NULL,
+    token_id VARCHAR(128) NOT NULL,
+    tx_hash VARCHAR(128) NOT NULL,
+    detail VARCHAR(512),
+    status VARCHAR(16) NOT NULL,        -- pending, confirmed, dismissed
+    reviewed_by VARCHAR(128),
+    review_note VARCHAR(512),
+    reviewed_at BIGINT DEFAULT 0,
+    created_at DATETIME
+);
+CREATE UNIQUE INDEX uk_trade_flags_reason ON trade_flags(trade_id, reason);
+CREATE INDEX idx_trade_flags_collection ON trade_flags(collection);
+
+-- 统一活动表，合并铸造、转移、销毁、成交、订单事件与元数据更新
+CREATE TABLE activities (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    type VARCHAR(32) NOT NULL,          -- mint, transfer, burn, sale, order_created, order_cancelled, order_filled, metadata_update
+    collection VARCHAR(128) NOT NULL,
+    token_id VARCHAR(128) NOT NULL DEFAULT '', -- 合集出价为空
+    from_addr VARCHAR(128) NOT NULL DEFAULT '',
+    to_addr VARCHAR(128) NOT NULL DEFAULT '',
+    order_id VARCHAR(128) NOT NULL DEFAULT '',
+    price VARCHAR(78) NOT NULL DEFAULT '0', -- uint256 十进制字符串
+    currency VARCHAR(128),
+    marketplace VARCHAR(32),
+    tx_hash VARCHAR(128) NOT NULL DEFAULT '', -- 链下签名订单为空
+    log_index INT NOT NULL,             -- 链下签名订单为 -1
+    block_number BIGINT NOT NULL,       -- 链下签名订单为 0
+    block_time BIGINT NOT NULL,         -- 链下签名订单为写入时间，活动按 block_time, block_number, log_index 排序
+    created_at DATETIME
+);
+CREATE UNIQUE INDEX uk_activities_event ON activities(type, tx_hash, log_index, collection, token_id, order_id);
+CREATE INDEX idx_activities_collection ON activities(collection);
+CREATE INDEX idx_activities_from_addr ON activities(from_addr);
+CREATE INDEX idx_activities_to_addr ON activities(to_addr);
+CREATE INDEX idx_activities_block ON activities(block_number, log_index);
+CREATE INDEX idx_activities_time ON activities(block_time, block_number, log_index);
+
+-- 用户注册的 webhook，过滤条件逗号分隔，空值不过滤
+CREATE TABLE webhook_endpoints (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id VARCHAR(64) NOT NULL,       -- 系统级 webhook 为 system
+    url VARCHAR(1024) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    collections TEXT,
+    wallets TEXT,
+    types VARCHAR(512),
+    enabled TINYINT(1) NOT NULL DEFAULT 1,
+    consecutive_failures INT NOT NULL DEFAULT 0,
+    disabled_reason VARCHAR(512),
+    disabled_at BIGINT DEFAULT 0,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE INDEX idx_webhook_endpoints_user_id ON webhook_endpoints(user_id);
+
+-- webhook 投递发件箱
+CREATE TABLE webhook_deliveries (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    endpoint_id BIGINT NOT NULL,
+    event_id VARCHAR(64) NOT NULL,      -- activity:{id}、floor:{id}、ping:{ns}
+    event_type VARCHAR(32) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,        -- pending, delivered, failed
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at BIGINT NOT NULL,
+    last_status_code INT DEFAULT 0,
+    last_error VARCHAR(1024),
+    delivered_at BIGINT DEFAULT 0,
+    created_at DATETIME
+);
+CREATE UNIQUE INDEX uk_webhook_deliveries_event ON webhook_deliveries(endpoint_id, event_id);
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+
+-- webhook 事件扫描进度
+CREATE TABLE webhook_cursors (
+    source VARCHAR(64) PRIMARY KEY,     -- activities, floor_price_histories
+    last_id BIGINT NOT NULL
+);
+
+-- Kafka 发件箱，与订单变更同事务写入，由中继按 id 顺序发布
+CREATE TABLE kafka_outbox (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    kind VARCHAR(32) NOT NULL,          -- floor_price, event, match
+    msg_key VARCHAR(128) NOT NULL,      -- Kafka 消息 key（合集地址）
+    payload TEXT,
+    headers TEXT,                       -- JSON 消息头
+    attempts INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(512),
+    published_at BIGINT NOT NULL DEFAULT 0, -- 0 表示待发布
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_kafka_outbox_pending ON kafka_outbox(published_at);
+
+-- 用户
+CREATE TABLE users (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    email VARCHAR(255),
+    password_hash VARCHAR(255),
+    wallet_addr VARCHAR(42),
+    role VARCHAR(16) NOT NULL DEFAULT 'user', -- user, operator, admin
+    created_at DATETIME
+);
+CREATE UNIQUE INDEX uk_users_email ON users(email);
+CREATE UNIQUE INDEX uk_users_wallet_addr ON users(wallet_addr);
+
+-- 刷新令牌，仅存哈希；同一登录会话的轮换链共享 family_id
+CREATE TABLE refresh_tokens (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    family_id VARCHAR(64) NOT NULL,
+    expires_at BIGINT NOT NULL,
+    revoked_at BIGINT NOT NULL DEFAULT 0, -- 0 表示有效
+    replaced_by BIGINT NOT NULL DEFAULT 0, -- 轮换后的新令牌 id
+    created_at DATETIME
+);
+CREATE UNIQUE INDEX uk_refresh_tokens_hash ON refresh_tokens(token_hash);
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family_id);
+
+-- 机器客户端 API Key，仅存哈希
+CREATE TABLE api_keys (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(64),
+    prefix VARCHAR(16) NOT NULL,        -- 明文前缀，便于在列表中识别
+    key_hash CHAR(64) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,       -- 逗号分隔：nft, market, orders
+    rate_limit INT NOT NULL DEFAULT 0,  -- 每分钟请求数，0 使用默认值
+    daily_quota INT NOT NULL DEFAULT 0, -- 每日请求数，0 使用默认值
+    revoked_at BIGINT NOT NULL DEFAULT 0,
+    created_at DATETIME
+);
+CREATE UNIQUE INDEX uk_api_keys_hash ON api_keys(key_hash);
+CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
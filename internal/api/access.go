@@ -0,0 +1,22 @@
+package api
+
+import (
+	"errors"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/middleware"
+	"github.com/gavin/nftSync/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// walletAccess 校验当前用户能否读取钱包私有数据，不能时返回响应状态码与错误
+func walletAccess(c *gin.Context, ctx *config.Context, wallet string) (int, error) {
+	err := service.NewService(ctx).CheckWalletAccess(contextUserID(c), middleware.ContextRole(c), wallet)
+	switch {
+	case err == nil:
+		return http.StatusOK, nil
+	case errors.Is(err, service.ErrWalletForbidden):
+		return http.StatusForbidden, err
+	}
+	return http.StatusInternalServerError, err
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"errors"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// 活动流，按区块与日志序号倒序，可按 token、钱包、合集与类型过滤
+// GET /api/activity?collection=xxx&token_id=xxx&wallet=xxx&type=sale,transfer&cursor=xxx&limit=20
+
+type ActivityReq struct {
+	Collection string `form:"collection"`
+	TokenID    string `form:"token_id"`
+	Wallet     string `form:"wallet"`
+	Type       string `form:"type"` // 逗号分隔
+	Cursor     string `form:"cursor"`
+	Limit      int    `form:"limit"`
+}
+
+type ActivityResp struct {
+	Data  *service.ActivityPageDTO `json:"data,omitempty"`
+	Error string                   `json:"error,omitempty"`
+}
+
+func activityErrorStatus(err error) int {
+	if errors.Is(err, service.ErrInvalidCursor) || errors.Is(err, service.ErrInvalidActivityType) ||
+		errors.Is(err, service.ErrInvalidTokenID) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func GetActivityHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ActivityReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ActivityResp{Error: "参数错误"})
+			return
+		}
+		if req.TokenID != "" && req.Collection == "" {
+			c.JSON(http.StatusBadRequest, ActivityResp{Error: "token_id 需配合 collection 使用"})
+			return
+		}
+		if req.Limit < 1 {
+			req.Limit = defaultPageSize
+		}
+		if req.Limit > maxPageSize {
+			req.Limit = maxPageSize
+		}
+		query := service.ActivityQuery{
+			Collection: req.Collection,
+			TokenID:    req.TokenID,
+			Wallet:     req.Wallet,
+		}
+		for _, t := range strings.Split(req.Type, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				query.Types = append(query.Types, t)
+			}
+		}
+		data, err := service.NewService(ctx).ListActivities(query, req.Cursor, req.Limit)
+		if err != nil {
+			c.JSON(activityErrorStatus(err), ActivityResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, ActivityResp{Data: data})
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// 管理接口，仅 admin
+// GET  /api/admin/nodes               节点池状态
+// POST /api/admin/orderbook/reindex   {"collection":"0x..."}，collection 为空时重建全部合集
+// PUT  /api/admin/users/:id/role      {"role":"operator"}
+
+type NodeStatusResp struct {
+	Nodes []service.NodeStatusDTO `json:"nodes"`
+	Error string                  `json:"error,omitempty"`
+}
+
+type ReindexReq struct {
+	Collection string `json:"collection"`
+}
+
+type SetUserRoleReq struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type AdminActionResp struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+func NodeStatusHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, NodeStatusResp{Nodes: service.NewService(ctx).NodeStatuses(c.Request.Context())})
+	}
+}
+
+func ReindexOrderBookHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ReindexReq
+		if c.Request.ContentLength > 0 && c.ShouldBindJSON(&req) != nil {
+			c.JSON(http.StatusBadRequest, AdminActionResp{Error: "参数错误"})
+			return
+		}
+		if req.Collection != "" {
+			if !common.IsHexAddress(req.Collection) {
+				c.JSON(http.StatusBadRequest, AdminActionResp{Error: "合集地址格式错误"})
+				return
+			}
+			req.Collection = common.HexToAddress(req.Collection).Hex()
+		}
+		if err := service.NewService(ctx).ReindexOrderBook(c.Request.Context(), req.Collection); err != nil {
+			c.JSON(http.StatusInternalServerError, AdminActionResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, AdminActionResp{Success: true})
+	}
+}
+
+func SetUserRoleHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, AdminActionResp{Error: "参数错误"})
+			return
+		}
+		var req SetUserRoleReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, AdminActionResp{Error: "参数错误"})
+			return
+		}
+		if err := service.NewService(ctx).SetUserRole(id, req.Role); err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, service.ErrInvalidRole):
+				status = http.StatusBadRequest
+			case errors.Is(err, service.ErrUserNotFound):
+				status = http.StatusNotFound
+			}
+			c.JSON(status, AdminActionResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, AdminActionResp{Success: true})
+	}
+}
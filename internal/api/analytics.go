@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// 合集成交统计（1h/24h/7d/30d/all 各窗口）
+// GET /api/collection/:address/stats
+
+type CollectionStatsResp struct {
+	Data  *service.CollectionStatsDTO `json:"data,omitempty"`
+	Error string                      `json:"error,omitempty"`
+}
+
+func GetCollectionStatsHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := service.NewService(ctx).GetCollectionStats(c.Param("address"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, CollectionStatsResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, CollectionStatsResp{Data: data})
+	}
+}
+
+// 全市场成交统计
+// GET /api/stats
+
+func GetGlobalStatsHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := service.NewService(ctx).GetCollectionStats(dao.GlobalCollection)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, CollectionStatsResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, CollectionStatsResp{Data: data})
+	}
+}
+
+// 排行榜参数
+// ?window=24h&sort=volume&limit=20
+type LeaderboardReq struct {
+	Window string `form:"window"`
+	Sort   string `form:"sort"`
+	Limit  int    `form:"limit"`
+}
+
+// normalize 填充默认值并校验窗口
+func (r *LeaderboardReq) normalize() error {
+	if r.Window == "" {
+		r.Window = "24h"
+	}
+	if r.Sort == "" {
+		r.Sort = "volume"
+	}
+	if r.Limit < 1 {
+		r.Limit = defaultPageSize
+	}
+	if r.Limit > maxPageSize {
+		r.Limit = maxPageSize
+	}
+	return service.ValidAnalyticsWindow(r.Window)
+}
+
+func leaderboardErrorStatus(err error) int {
+	if errors.Is(err, service.ErrUnsupportedSort) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// 合集排行榜，sort 可选 volume/sales/avg_price/unique_buyers/unique_sellers
+// GET /api/leaderboard/collections?window=24h&sort=volume&limit=20
+
+type TopCollectionsResp struct {
+	Window string                      `json:"window"`
+	Data   []service.CollectionRankDTO `json:"data,omitempty"`
+	Error  string                      `json:"error,omitempty"`
+}
+
+func GetTopCollectionsHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req LeaderboardReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, TopCollectionsResp{Error: "参数错误"})
+			return
+		}
+		if err := req.normalize(); err != nil {
+			c.JSON(http.StatusBadRequest, TopCollectionsResp{Error: err.Error()})
+			return
+		}
+		data, err := service.NewService(ctx).GetTopCollections(req.Window, req.Sort, req.Limit)
+		if err != nil {
+			c.JSON(leaderboardErrorStatus(err), TopCollectionsResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, TopCollectionsResp{Window: req.Window, Data: data})
+	}
+}
+
+// 地址排行榜，sort 可选 volume/buy_volume/sell_volume/buys/sells
+// GET /api/leaderboard/traders?window=7d&sort=buy_volume&limit=20
+
+type TopTradersResp struct {
+	Window string                  `json:"window"`
+	Data   []service.TraderRankDTO `json:"data,omitempty"`
+	Error  string                  `json:"error,omitempty"`
+}
+
+func GetTopTradersHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req LeaderboardReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, TopTradersResp{Error: "参数错误"})
+			return
+		}
+		if err := req.normalize(); err != nil {
+			c.JSON(http.StatusBadRequest, TopTradersResp{Error: err.Error()})
+			return
+		}
+		data, err := service.NewService(ctx).GetTopTraders(req.Window, req.Sort, req.Limit)
+		if err != nil {
+			c.JSON(leaderboardErrorStatus(err), TopTradersResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, TopTradersResp{Window: req.Window, Data: data})
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+	"github.com/gavin/nftSync/internal/auth"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/middleware"
+	"github.com/gavin/nftSync/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// API Key 管理，需用户登录（JWT），Key 明文只在创建时返回一次
+// POST   /api/keys               {"name":"indexer","scopes":["nft","market"],"rate_limit":60,"daily_quota":10000}
+// GET    /api/keys
+// DELETE /api/keys/:id
+// GET    /api/keys/:id/usage?days=7
+// 调用方通过 X-API-Key 请求头携带 Key
+
+type APIKeyResp struct {
+	Key   *service.APIKeyDTO `json:"key,omitempty"`
+	Error string             `json:"error,omitempty"`
+}
+
+type ListAPIKeysResp struct {
+	Keys  []service.APIKeyDTO `json:"keys"`
+	Error string              `json:"error,omitempty"`
+}
+
+type APIKeyUsageReq struct {
+	Days int `form:"days"`
+}
+
+type APIKeyUsageResp struct {
+	Usage *service.APIKeyUsageReport `json:"usage,omitempty"`
+	Error string                     `json:"error,omitempty"`
+}
+
+type RevokeAPIKeyResp struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+func apiKeyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrAPIKeyLimit), errors.Is(err, service.ErrAPIKeyQuotaRange),
+		errors.Is(err, auth.ErrInvalidScope):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// apiKeyParams 解析当前用户与路径中的 Key ID
+func apiKeyParams(c *gin.Context) (int64, int64, bool) {
+	userID, err := strconv.ParseInt(contextUserID(c), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	return userID, id, err == nil
+}
+
+func CreateAPIKeyHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req service.CreateAPIKeyReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, APIKeyResp{Error: "参数错误"})
+			return
+		}
+		userID, err := strconv.ParseInt(contextUserID(c), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, APIKeyResp{Error: "unauthorized"})
+			return
+		}
+		key, err := service.NewAPIKeyService(ctx).CreateAPIKey(userID, middleware.ContextRole(c), req)
+		if err != nil {
+			c.JSON(apiKeyErrorStatus(err), APIKeyResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, APIKeyResp{Key: key})
+	}
+}
+
+func ListAPIKeysHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseInt(contextUserID(c), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, ListAPIKeysResp{Error: "unauthorized"})
+			return
+		}
+		keys, err := service.NewAPIKeyService(ctx).ListAPIKeys(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ListAPIKeysResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, ListAPIKeysResp{Keys: keys})
+	}
+}
+
+func RevokeAPIKeyHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, id, ok := apiKeyParams(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, RevokeAPIKeyResp{Error: "参数错误"})
+			return
+		}
+		if err := service.NewAPIKeyService(ctx).RevokeAPIKey(c.Request.Context(), userID, id); err != nil {
+			c.JSON(apiKeyErrorStatus(err), RevokeAPIKeyResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, RevokeAPIKeyResp{Success: true})
+	}
+}
+
+func APIKeyUsageHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, id, ok := apiKeyParams(c)
+		var req APIKeyUsageReq
+		if !ok || c.ShouldBindQuery(&req) != nil {
+			c.JSON(http.StatusBadRequest, APIKeyUsageResp{Error: "参数错误"})
+			return
+		}
+		usage, err := service.NewAPIKeyService(ctx).GetAPIKeyUsage(c.Request.Context(), userID, id, req.Days)
+		if err != nil {
+			c.JSON(apiKeyErrorStatus(err), APIKeyUsageResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, APIKeyUsageResp{Usage: usage})
+	}
+}
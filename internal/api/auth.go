@@ -0,0 +1,161 @@
+package api
+
+import (
+	"errors"
+	"github.com/gavin/nftSync/internal/auth"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/middleware"
+	"github.com/gavin/nftSync/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// 令牌刷新与注销
+// POST /api/auth/refresh   {"refresh_token":"..."}，返回新的令牌对，旧刷新令牌失效
+// POST /api/auth/logout    需登录 {"refresh_token":"...","all":false}，吊销当前访问令牌与会话
+// GET  /.well-known/jwks.json  RS256/EdDSA 公钥
+// GET  /api/auth/nonce     SIWE 一次性 nonce
+// POST /api/auth/siwe      {"message":"<EIP-4361 文本>","signature":"0x..."}，携带访问令牌时将钱包绑定到当前账户
+
+type RefreshTokenReq struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type TokenResp struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	*service.TokenPairDTO
+}
+
+type LogoutReq struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"` // 吊销该用户全部会话
+}
+
+type LogoutResp struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+func RefreshTokenHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RefreshTokenReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, TokenResp{Error: "参数错误"})
+			return
+		}
+		tokens, err := service.NewService(ctx).RefreshTokens(req.RefreshToken)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+				status = http.StatusUnauthorized
+			}
+			c.JSON(status, TokenResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, TokenResp{Success: true, TokenPairDTO: tokens})
+	}
+}
+
+func LogoutHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req LogoutReq
+		if c.Request.ContentLength > 0 && c.ShouldBindJSON(&req) != nil {
+			c.JSON(http.StatusBadRequest, LogoutResp{Error: "参数错误"})
+			return
+		}
+		v, _ := c.Get(middleware.ClaimsKey)
+		claims, ok := v.(*auth.Claims)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, LogoutResp{Error: "unauthorized"})
+			return
+		}
+		if err := service.NewService(ctx).Logout(c.Request.Context(), claims, req.RefreshToken, req.All); err != nil {
+			c.JSON(http.StatusInternalServerError, LogoutResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, LogoutResp{Success: true})
+	}
+}
+
+func JWKSHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, ctx.Auth.JWKS())
+	}
+}
+
+type SIWENonceResp struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	*service.SIWENonceDTO
+}
+
+type SIWELoginReq struct {
+	Message   string `json:"message" binding:"required"`
+	Signature string `json:"signature" binding:"required"`
+}
+
+type SIWELoginResp struct {
+	Success    bool   `json:"success"`
+	UserID     int64  `json:"user_id,omitempty"`
+	WalletAddr string `json:"wallet_addr,omitempty"`
+	Error      string `json:"error,omitempty"`
+	*service.TokenPairDTO
+}
+
+func SIWENonceHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		nonce, err := service.NewService(ctx).SIWENonce(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, SIWENonceResp{Error: err.Error()})
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, SIWENonceResp{Success: true, SIWENonceDTO: nonce})
+	}
+}
+
+func SIWELoginHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SIWELoginReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, SIWELoginResp{Error: "参数错误"})
+			return
+		}
+		// 可选登录态：携带有效访问令牌时绑定钱包
+		var linkUserID int64
+		if token := middleware.BearerToken(c); token != "" {
+			claims, err := ctx.Auth.Verify(c.Request.Context(), token)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, SIWELoginResp{Error: "unauthorized: invalid token"})
+				return
+			}
+			linkUserID, _ = strconv.ParseInt(claims.Subject, 10, 64)
+		}
+		svc := service.NewService(ctx)
+		user, err := svc.SIWELogin(c.Request.Context(), req.Message, req.Signature, linkUserID)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, auth.ErrInvalidSIWE), errors.Is(err, service.ErrSIWESignature), errors.Is(err, service.ErrSIWENonce):
+				status = http.StatusUnauthorized
+			case errors.Is(err, service.ErrWalletLinked), errors.Is(err, service.ErrUserHasWallet):
+				status = http.StatusConflict
+			}
+			c.JSON(status, SIWELoginResp{Error: err.Error()})
+			return
+		}
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, SIWELoginResp{Error: "用户不存在"})
+			return
+		}
+		tokens, err := svc.IssueTokens(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, SIWELoginResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, SIWELoginResp{Success: true, UserID: user.ID, WalletAddr: user.WalletAddr, TokenPairDTO: tokens})
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// 地板价历史与成交 K 线
+// GET /api/collection/:address/floor/history?interval=1h&from=1700000000&to=1700100000
+
+type FloorHistoryReq struct {
+	Interval string `form:"interval"`
+	From     int64  `form:"from"`
+	To       int64  `form:"to"`
+}
+
+type FloorHistoryResp struct {
+	Data  *service.FloorHistoryDTO `json:"data,omitempty"`
+	Error string                   `json:"error,omitempty"`
+}
+
+func GetFloorHistoryHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req FloorHistoryReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, FloorHistoryResp{Error: "参数错误"})
+			return
+		}
+		if req.Interval == "" {
+			req.Interval = "1h"
+		}
+		if err := service.ValidCandleInterval(req.Interval); err != nil {
+			c.JSON(http.StatusBadRequest, FloorHistoryResp{Error: err.Error()})
+			return
+		}
+		data, err := service.NewService(ctx).GetFloorHistory(c.Param("address"), req.Interval, req.From, req.To)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, FloorHistoryResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, FloorHistoryResp{Data: data})
+	}
+}
+
+// 从销售账本回填 K 线
+// POST /api/collection/:address/candles/backfill
+
+type BackfillCandlesResp struct {
+	Candles int    `json:"candles"`
+	Error   string `json:"error,omitempty"`
+}
+
+func BackfillCandlesHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		n, err := service.NewService(ctx).BackfillCandles(c.Request.Context(), c.Param("address"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, BackfillCandlesResp{Candles: n, Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, BackfillCandlesResp{Candles: n})
+	}
+}
+
+// 合集版税执行报告，按市场统计足额、少付与未付版税的成交
+// GET /api/collection/:address/royalties
+
+type RoyaltyReportResp struct {
+	Data  *service.RoyaltyReportDTO `json:"data,omitempty"`
+	Error string                    `json:"error,omitempty"`
+}
+
+func GetRoyaltyReportHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := service.NewService(ctx).GetRoyaltyReport(c.Request.Context(), c.Param("address"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, RoyaltyReportResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, RoyaltyReportResp{Data: data})
+	}
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"errors"
+	"github.com/gavin/nftSync/internal/blockchain"
 	"github.com/gavin/nftSync/internal/config"
 	"github.com/gavin/nftSync/internal/service"
 	"github.com/gin-gonic/gin"
@@ -36,7 +38,7 @@ func GetOrderHandler(ctx *config.Context) gin.HandlerFunc {
 }
 
 // 用户订单列表查询请求结构体
-// 支持按 owner 查询
+// 支持按 owner 查询，owner 须为当前用户绑定的钱包（operator/admin 不限）
 // GET /api/order/list?owner=xxx
 
 type ListUserOrdersReq struct {
@@ -57,6 +59,11 @@ func ListUserOrdersHandler(ctx *config.Context) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, resp)
 			return
 		}
+		// 只能查询已绑定钱包的订单
+		if status, err := walletAccess(c, ctx, req.Owner); err != nil {
+			c.JSON(status, ListUserOrdersResp{Error: err.Error()})
+			return
+		}
 		orders, err := service.NewService(ctx).ListUserOrders(req.Owner)
 		if err != nil {
 			resp := ListUserOrdersResp{Error: err.Error()}
@@ -72,14 +79,15 @@ func ListUserOrdersHandler(ctx *config.Context) gin.HandlerFunc {
 // GET /api/orders/stats
 
 type GetOrderStatsResp struct {
-	Total       int64   `json:"total"`
-	TotalAmount float64 `json:"total_amount"`
-	Error       string  `json:"error,omitempty"`
+	Total       int64  `json:"total"`
+	TotalAmount string `json:"total_amount"` // 基础币种，十进制字符串
+	Currency    string `json:"currency"`
+	Error       string `json:"error,omitempty"`
 }
 
 func GetOrderStatsHandler(ctx *config.Context) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		stats, err := service.NewService(ctx).GetOrderStats()
+		stats, err := service.NewService(ctx).GetOrderStats(c.Request.Context())
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, GetOrderStatsResp{Error: err.Error()})
 			return
@@ -87,6 +95,85 @@ func GetOrderStatsHandler(ctx *config.Context) gin.HandlerFunc {
 		c.JSON(http.StatusOK, GetOrderStatsResp{
 			Total:       stats.Total,
 			TotalAmount: stats.TotalAmount,
+			Currency:    stats.Currency,
 		})
 	}
 }
+
+// 提交链下签名订单
+// POST /api/order
+// {"order":{"maker":"0x..","collection":"0x..","token_id":"1","is_bid":false,"is_collection_bid":false,
+//   "currency":"0x00..","price":"1000000000000000000","start_time":"0","end_time":"1735689600","nonce":"1",
+//   "criteria":""},
+//  "signature":"0x..."}
+
+type SubmitOrderReq struct {
+	Order     blockchain.SignedOrderMessage `json:"order" binding:"required"`
+	Signature string                        `json:"signature" binding:"required"`
+}
+
+type SubmitOrderResp struct {
+	Order *service.OrderDTO `json:"order,omitempty"`
+	Error string            `json:"error,omitempty"`
+}
+
+func SubmitOrderHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SubmitOrderReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, SubmitOrderResp{Error: "参数错误"})
+			return
+		}
+		order, err := service.NewService(ctx).SubmitSignedOrder(c.Request.Context(), req.Order, req.Signature)
+		if err != nil {
+			c.JSON(orderErrorStatus(err), SubmitOrderResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, SubmitOrderResp{Order: order})
+	}
+}
+
+// 取消链下签名订单，签名内容为 CancelOrder(bytes32 orderHash)
+// POST /api/order/cancel
+// {"order_id":"0x...","signature":"0x..."}
+
+type CancelOrderReq struct {
+	OrderID   string `json:"order_id" binding:"required"`
+	Signature string `json:"signature" binding:"required"`
+}
+
+type CancelOrderResp struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+func CancelOrderHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CancelOrderReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, CancelOrderResp{Error: "参数错误"})
+			return
+		}
+		if err := service.NewService(ctx).CancelSignedOrder(c.Request.Context(), req.OrderID, req.Signature); err != nil {
+			c.JSON(orderErrorStatus(err), CancelOrderResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, CancelOrderResp{Success: true})
+	}
+}
+
+// orderErrorStatus 订单提交与取消错误对应的 HTTP 状态码
+func orderErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrDuplicateNonce):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrInvalidSignature):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrOrderRejected):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
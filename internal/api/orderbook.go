@@ -0,0 +1,96 @@
+package api
+
+import (
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// 合集订单簿深度
+// GET /api/collection/:address/orderbook?levels=20
+
+type OrderBookReq struct {
+	Levels int `form:"levels"`
+}
+
+type OrderBookResp struct {
+	Data  *service.OrderBookDTO `json:"data,omitempty"`
+	Error string                `json:"error,omitempty"`
+}
+
+func GetOrderBookHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req OrderBookReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, OrderBookResp{Error: "参数错误"})
+			return
+		}
+		if req.Levels <= 0 {
+			req.Levels = 20
+		}
+		if req.Levels > 100 {
+			req.Levels = 100
+		}
+		data, err := service.NewService(ctx).GetOrderBook(c.Request.Context(), c.Param("address"), req.Levels)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, OrderBookResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, OrderBookResp{Data: data})
+	}
+}
+
+// 单个 token 的订单查询参数
+type TokenOrderReq struct {
+	Contract string `form:"contract" binding:"required"`
+	TokenID  string `form:"token_id" binding:"required"`
+}
+
+// 单个 token 的最高出价（含合集出价）
+// GET /api/nft/offers?contract=xxx&token_id=xxx
+
+type BestOfferResp struct {
+	Data  *service.BestOfferDTO `json:"data,omitempty"`
+	Error string                `json:"error,omitempty"`
+}
+
+func GetBestOfferHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TokenOrderReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, BestOfferResp{Error: "contract and token_id required"})
+			return
+		}
+		data, err := service.NewService(ctx).GetBestOffer(c.Request.Context(), req.Contract, req.TokenID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, BestOfferResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, BestOfferResp{Data: data})
+	}
+}
+
+// 单个 token 当前最低的有效挂单
+// GET /api/nft/listing?contract=xxx&token_id=xxx
+
+type ListingResp struct {
+	Data  *service.ListingDTO `json:"data,omitempty"`
+	Error string              `json:"error,omitempty"`
+}
+
+func GetCheapestListingHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TokenOrderReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ListingResp{Error: "contract and token_id required"})
+			return
+		}
+		data, err := service.NewService(ctx).GetCheapestListing(c.Request.Context(), req.Contract, req.TokenID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ListingResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, ListingResp{Data: data})
+	}
+}
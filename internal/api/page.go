@@ -0,0 +1,28 @@
+package api
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// PageReq 通用分页参数
+// ?page=1&page_size=20
+type PageReq struct {
+	Page     int `form:"page"`
+	PageSize int `form:"page_size"`
+}
+
+// LimitOffset 转换为 limit/offset，并做边界修正
+func (p PageReq) LimitOffset() (int, int) {
+	page, size := p.Page, p.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = defaultPageSize
+	}
+	if size > maxPageSize {
+		size = maxPageSize
+	}
+	return size, (page - 1) * size
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// 单个 token 成交历史
+// GET /api/nft/sales?contract=xxx&token_id=xxx&page=1&page_size=20
+
+type TokenSalesReq struct {
+	Contract string `form:"contract" binding:"required"`
+	TokenID  string `form:"token_id" binding:"required"`
+	PageReq
+}
+
+type SalesResp struct {
+	Sales []service.SaleDTO `json:"sales"`
+	Error string            `json:"error,omitempty"`
+}
+
+func GetTokenSalesHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TokenSalesReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, SalesResp{Error: "contract and token_id required"})
+			return
+		}
+		limit, offset := req.LimitOffset()
+		sales, err := service.NewService(ctx).ListTokenSales(req.Contract, req.TokenID, limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, SalesResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, SalesResp{Sales: sales})
+	}
+}
+
+// 合集成交历史
+// GET /api/collection/:address/sales?page=1&page_size=20
+func GetCollectionSalesHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PageReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, SalesResp{Error: "参数错误"})
+			return
+		}
+		limit, offset := req.LimitOffset()
+		sales, err := service.NewService(ctx).ListCollectionSales(c.Param("address"), limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, SalesResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, SalesResp{Sales: sales})
+	}
+}
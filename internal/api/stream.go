@@ -0,0 +1,268 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/push"
+	"github.com/gavin/nftSync/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 实时推送：按合集、token、钱包或事件类型订阅铸造、转移、订单与地板价变化
+// GET /api/stream/ws?collection=xxx&token=collection:tokenId&wallet=xxx&type=mint,floor_changed&last_event_id=123
+// GET /api/stream/sse?...（续传也可使用 Last-Event-ID 请求头）
+//
+// 多个值以逗号分隔。历史已裁剪无法完整续传时先推送 resync 通知，客户端应改为全量拉取
+// WebSocket 连接可发送 {"action":"subscribe","collections":[],"tokens":[],"wallets":[],"types":[]} 替换订阅条件
+
+const streamWriteTimeout = 10 * time.Second
+
+// StreamErrorResp 建立推送连接前的错误响应
+type StreamErrorResp struct {
+	Error string `json:"error,omitempty"`
+}
+
+// streamNotice 推送通道的控制消息
+type streamNotice struct {
+	Type  string `json:"type"` // resync / error
+	Error string `json:"error,omitempty"`
+}
+
+// streamSubscribeMsg WebSocket 客户端消息
+type streamSubscribeMsg struct {
+	Action string `json:"action"`
+	service.PushQuery
+}
+
+func splitParam(v string) []string {
+	var res []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// parseStreamReq 解析订阅条件与续传位置
+func parseStreamReq(c *gin.Context) (*push.Filter, int64, error) {
+	filter, err := service.BuildPushFilter(service.PushQuery{
+		Collections: splitParam(c.Query("collection")),
+		Tokens:      splitParam(c.Query("token")),
+		Wallets:     splitParam(c.Query("wallet")),
+		Types:       splitParam(c.Query("type")),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	last := c.Query("last_event_id")
+	if last == "" {
+		last = c.GetHeader("Last-Event-ID")
+	}
+	if last == "" {
+		return filter, 0, nil
+	}
+	lastID, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || lastID < 0 {
+		return nil, 0, errors.New("last_event_id 格式错误")
+	}
+	return filter, lastID, nil
+}
+
+func pingInterval(ctx *config.Context) time.Duration {
+	if sec := ctx.Config.Push.PingInterval; sec > 0 {
+		return time.Duration(sec) * time.Second
+	}
+	return 25 * time.Second
+}
+
+func StreamSSEHandler(ctx *config.Context, hub *push.Hub) gin.HandlerFunc {
+	broker := service.NewPushBroker(ctx)
+	return func(c *gin.Context) {
+		filter, lastID, err := parseStreamReq(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, StreamErrorResp{Error: err.Error()})
+			return
+		}
+		sub, replay, complete, err := broker.Resume(c.Request.Context(), hub, filter, lastID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, StreamErrorResp{Error: err.Error()})
+			return
+		}
+		defer hub.Unsubscribe(sub)
+
+		w := c.Writer
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		writeEvent := func(e *push.Event) error {
+			payload, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, payload)
+			return err
+		}
+		writeNotice := func(n streamNotice) {
+			payload, _ := json.Marshal(n)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", n.Type, payload)
+			w.Flush()
+		}
+
+		if !complete {
+			writeNotice(streamNotice{Type: "resync"})
+		}
+		for i := range replay {
+			if err := writeEvent(&replay[i]); err != nil {
+				return
+			}
+		}
+		w.Flush()
+
+		ticker := time.NewTicker(pingInterval(ctx))
+		defer ticker.Stop()
+		for {
+			select {
+			case e := <-sub.Events():
+				if sub.Replayed(&e) {
+					continue
+				}
+				if err := writeEvent(&e); err != nil {
+					return
+				}
+				w.Flush()
+			case <-sub.Dropped():
+				writeNotice(streamNotice{Type: "error", Error: "slow consumer"})
+				return
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+				w.Flush()
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+var streamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	// 推送内容均为公开行情数据，允许跨域连接
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func StreamWebSocketHandler(ctx *config.Context, hub *push.Hub) gin.HandlerFunc {
+	broker := service.NewPushBroker(ctx)
+	return func(c *gin.Context) {
+		filter, lastID, err := parseStreamReq(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, StreamErrorResp{Error: err.Error()})
+			return
+		}
+		sub, replay, complete, err := broker.Resume(c.Request.Context(), hub, filter, lastID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, StreamErrorResp{Error: err.Error()})
+			return
+		}
+		defer hub.Unsubscribe(sub)
+		conn, err := streamUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			log.Printf("[push] WebSocket 升级失败: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		ping := pingInterval(ctx)
+		// 读协程处理订阅变更与心跳回应，连接写操作均在当前协程完成
+		closed := make(chan struct{})
+		notices := make(chan streamNotice, 1)
+		conn.SetReadLimit(4096)
+		conn.SetReadDeadline(time.Now().Add(2 * ping))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * ping))
+		})
+		go func() {
+			defer close(closed)
+			for {
+				var msg streamSubscribeMsg
+				if err := conn.ReadJSON(&msg); err != nil {
+					var syntaxErr *json.SyntaxError
+					if errors.As(err, &syntaxErr) {
+						continue
+					}
+					return
+				}
+				if msg.Action != "subscribe" {
+					continue
+				}
+				f, err := service.BuildPushFilter(msg.PushQuery)
+				if err != nil {
+					select {
+					case notices <- streamNotice{Type: "error", Error: err.Error()}:
+					default:
+					}
+					continue
+				}
+				sub.SetFilter(f)
+			}
+		}()
+
+		write := func(v interface{}) error {
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			return conn.WriteJSON(v)
+		}
+		if !complete {
+			if err := write(streamNotice{Type: "resync"}); err != nil {
+				return
+			}
+		}
+		for i := range replay {
+			if err := write(&replay[i]); err != nil {
+				return
+			}
+		}
+
+		ticker := time.NewTicker(ping)
+		defer ticker.Stop()
+		for {
+			select {
+			case e := <-sub.Events():
+				if sub.Replayed(&e) {
+					continue
+				}
+				if err := write(&e); err != nil {
+					return
+				}
+			case n := <-notices:
+				if err := write(n); err != nil {
+					return
+				}
+			case <-sub.Dropped():
+				_ = write(streamNotice{Type: "error", Error: "slow consumer"})
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "slow consumer"),
+					time.Now().Add(time.Second))
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)); err != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	}
+}
@@ -46,10 +46,12 @@ type LoginUserReq struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// 登录成功返回访问令牌与刷新令牌，访问令牌通过 Authorization: Bearer 携带
 type LoginUserResp struct {
 	Success bool   `json:"success"`
 	UserID  int64  `json:"user_id,omitempty"`
 	Error   string `json:"error,omitempty"`
+	*service.TokenPairDTO
 }
 
 func LoginUserHandler(ctx *config.Context) gin.HandlerFunc {
@@ -59,12 +61,18 @@ func LoginUserHandler(ctx *config.Context) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, LoginUserResp{Success: false, Error: "参数错误"})
 			return
 		}
-		user, err := service.NewService(ctx).LoginUser(req.Email, req.Password)
+		svc := service.NewService(ctx)
+		user, err := svc.LoginUser(req.Email, req.Password)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, LoginUserResp{Success: false, Error: err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, LoginUserResp{Success: true, UserID: user.ID})
+		tokens, err := svc.IssueTokens(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, LoginUserResp{Success: false, Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, LoginUserResp{Success: true, UserID: user.ID, TokenPairDTO: tokens})
 	}
 }
 
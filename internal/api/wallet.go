@@ -0,0 +1,46 @@
+package api
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// 钱包持仓估值、成本与盈亏，附每日持仓价值序列，仅限当前用户绑定的钱包（operator/admin 不限）
+// GET /api/wallet/:address/portfolio?days=30
+
+type PortfolioReq struct {
+	Days int `form:"days"`
+}
+
+type PortfolioResp struct {
+	Data  *service.PortfolioDTO `json:"data,omitempty"`
+	Error string                `json:"error,omitempty"`
+}
+
+func GetPortfolioHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PortfolioReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, PortfolioResp{Error: "参数错误"})
+			return
+		}
+		address := c.Param("address")
+		if !common.IsHexAddress(address) {
+			c.JSON(http.StatusBadRequest, PortfolioResp{Error: "地址格式错误"})
+			return
+		}
+		if status, err := walletAccess(c, ctx, address); err != nil {
+			c.JSON(status, PortfolioResp{Error: err.Error()})
+			return
+		}
+		data, err := service.NewService(ctx).GetPortfolio(c.Request.Context(), address, req.Days)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, PortfolioResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, PortfolioResp{Data: data})
+	}
+}
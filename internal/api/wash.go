@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// 可疑成交标记列表
+// GET /api/wash/flags?collection=0x..&reason=self_trade&status=pending&page=1&page_size=20
+
+type ListTradeFlagsReq struct {
+	PageReq
+	Collection string `form:"collection"`
+	Reason     string `form:"reason"`
+	Status     string `form:"status"`
+}
+
+type ListTradeFlagsResp struct {
+	Data  *service.TradeFlagListDTO `json:"data,omitempty"`
+	Error string                    `json:"error,omitempty"`
+}
+
+func ListTradeFlagsHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ListTradeFlagsReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ListTradeFlagsResp{Error: "参数错误"})
+			return
+		}
+		limit, offset := req.LimitOffset()
+		filter := dao.TradeFlagFilter{Collection: req.Collection, Reason: req.Reason, Status: req.Status}
+		data, err := service.NewService(ctx).ListTradeFlags(filter, limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ListTradeFlagsResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, ListTradeFlagsResp{Data: data})
+	}
+}
+
+// 审核可疑成交标记，dismissed 的成交重新计入统计
+// POST /api/wash/flags/:id/review
+// {"status":"dismissed","note":"OTC 成交，已核实"}
+
+type ReviewTradeFlagReq struct {
+	Status string `json:"status" binding:"required"`
+	Note   string `json:"note"`
+}
+
+type ReviewTradeFlagResp struct {
+	Flag  *dao.TradeFlag `json:"flag,omitempty"`
+	Error string         `json:"error,omitempty"`
+}
+
+func ReviewTradeFlagHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ReviewTradeFlagResp{Error: "参数错误"})
+			return
+		}
+		var req ReviewTradeFlagReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ReviewTradeFlagResp{Error: "参数错误"})
+			return
+		}
+		reviewer := ""
+		if v, ok := c.Get("user_id"); ok && v != nil {
+			reviewer = fmt.Sprint(v)
+		}
+		flag, err := service.NewService(ctx).ReviewTradeFlag(id, req.Status, reviewer, req.Note)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, service.ErrFlagNotFound):
+				status = http.StatusNotFound
+			case errors.Is(err, service.ErrInvalidFlagStatus):
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, ReviewTradeFlagResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, ReviewTradeFlagResp{Flag: flag})
+	}
+}
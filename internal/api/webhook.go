@@ -0,0 +1,201 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// 用户 webhook 管理，均需登录，仅能操作自己的 webhook
+// POST   /api/webhooks                      注册 {"url":"https://...","collections":[],"wallets":[],"types":["sale"]}
+// GET    /api/webhooks                      列表
+// DELETE /api/webhooks/:id                  删除
+// POST   /api/webhooks/:id/enable           重新启用（自动停用后）
+// POST   /api/webhooks/:id/ping             发送测试事件
+// GET    /api/webhooks/:id/deliveries       投递记录 ?status=failed&page=1&page_size=20
+// POST   /api/webhooks/:id/replay           重放 {"delivery_ids":[1,2]} 或 {"status":"failed","since":1700000000}
+
+type WebhookResp struct {
+	Webhook *service.WebhookEndpointDTO `json:"webhook,omitempty"`
+	Error   string                      `json:"error,omitempty"`
+}
+
+type WebhookListResp struct {
+	Webhooks []service.WebhookEndpointDTO `json:"webhooks"`
+	Error    string                       `json:"error,omitempty"`
+}
+
+type WebhookDeliveriesReq struct {
+	Status string `form:"status"`
+	PageReq
+}
+
+type WebhookDeliveriesResp struct {
+	Deliveries []dao.WebhookDelivery `json:"deliveries"`
+	Total      int64                 `json:"total"`
+	Error      string                `json:"error,omitempty"`
+}
+
+type WebhookDeliveryResp struct {
+	Delivery *dao.WebhookDelivery `json:"delivery,omitempty"`
+	Error    string               `json:"error,omitempty"`
+}
+
+type WebhookReplayResp struct {
+	Replayed int64  `json:"replayed"`
+	Error    string `json:"error,omitempty"`
+}
+
+type WebhookActionResp struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// contextUserID 读取 AuthMiddleware 注入的用户 ID
+func contextUserID(c *gin.Context) string {
+	if v, ok := c.Get("user_id"); ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+func webhookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrWebhookNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidWebhookURL), errors.Is(err, service.ErrInvalidActivityType),
+		errors.Is(err, service.ErrInvalidTokenID):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// webhookParams 解析当前用户与路径中的 webhook ID
+func webhookParams(c *gin.Context) (string, int64, bool) {
+	userID := contextUserID(c)
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	return userID, id, err == nil && userID != ""
+}
+
+func CreateWebhookHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req service.WebhookEndpointReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResp{Error: "参数错误"})
+			return
+		}
+		userID := contextUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, WebhookResp{Error: "unauthorized"})
+			return
+		}
+		webhook, err := service.NewService(ctx).CreateWebhook(userID, req)
+		if err != nil {
+			c.JSON(webhookErrorStatus(err), WebhookResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResp{Webhook: webhook})
+	}
+}
+
+func ListWebhooksHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := contextUserID(c)
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, WebhookListResp{Error: "unauthorized"})
+			return
+		}
+		list, err := service.NewService(ctx).ListWebhooks(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, WebhookListResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookListResp{Webhooks: list})
+	}
+}
+
+func DeleteWebhookHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, id, ok := webhookParams(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, WebhookActionResp{Error: "参数错误"})
+			return
+		}
+		if err := service.NewService(ctx).DeleteWebhook(userID, id); err != nil {
+			c.JSON(webhookErrorStatus(err), WebhookActionResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookActionResp{Success: true})
+	}
+}
+
+func EnableWebhookHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, id, ok := webhookParams(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, WebhookActionResp{Error: "参数错误"})
+			return
+		}
+		if err := service.NewService(ctx).EnableWebhook(userID, id); err != nil {
+			c.JSON(webhookErrorStatus(err), WebhookActionResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookActionResp{Success: true})
+	}
+}
+
+func PingWebhookHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, id, ok := webhookParams(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, WebhookDeliveryResp{Error: "参数错误"})
+			return
+		}
+		delivery, err := service.NewService(ctx).PingWebhook(userID, id)
+		if err != nil {
+			c.JSON(webhookErrorStatus(err), WebhookDeliveryResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookDeliveryResp{Delivery: delivery})
+	}
+}
+
+func ListWebhookDeliveriesHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, id, ok := webhookParams(c)
+		var req WebhookDeliveriesReq
+		if !ok || c.ShouldBindQuery(&req) != nil {
+			c.JSON(http.StatusBadRequest, WebhookDeliveriesResp{Error: "参数错误"})
+			return
+		}
+		limit, offset := req.LimitOffset()
+		list, total, err := service.NewService(ctx).ListWebhookDeliveries(userID, id, req.Status, limit, offset)
+		if err != nil {
+			c.JSON(webhookErrorStatus(err), WebhookDeliveriesResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookDeliveriesResp{Deliveries: list, Total: total})
+	}
+}
+
+func ReplayWebhookHandler(ctx *config.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, id, ok := webhookParams(c)
+		var req service.WebhookReplayReq
+		if !ok || c.ShouldBindJSON(&req) != nil {
+			c.JSON(http.StatusBadRequest, WebhookReplayResp{Error: "参数错误"})
+			return
+		}
+		n, err := service.NewService(ctx).ReplayWebhookDeliveries(userID, id, req)
+		if err != nil {
+			c.JSON(webhookErrorStatus(err), WebhookReplayResp{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookReplayResp{Replayed: n})
+	}
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// APIKey 接口分组，API Key 只能访问创建时声明的分组
+const (
+	ScopeNFT    = "nft"    // /api/nft
+	ScopeMarket = "market" // 合集行情、统计、排行榜、活动流
+	ScopeOrders = "orders" // /api/order
+)
+
+var validScopes = map[string]bool{ScopeNFT: true, ScopeMarket: true, ScopeOrders: true}
+
+const (
+	apiKeyPrefix    = "nsk_"
+	apiKeyShownSize = 12 // 列表中展示的明文前缀长度
+)
+
+var (
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	ErrInvalidScope  = errors.New("invalid api key scope")
+)
+
+// APIKeyPrincipal API Key 校验通过后的调用方，权限取 Key 所属用户的角色
+type APIKeyPrincipal struct {
+	KeyID      int64    `json:"key_id"`
+	UserID     int64    `json:"user_id"`
+	Role       string   `json:"role"`
+	Scopes     []string `json:"scopes"`
+	RateLimit  int      `json:"rate_limit"`
+	DailyQuota int      `json:"daily_quota"`
+}
+
+// HasScope 判断 Key 是否声明了接口分组
+func (p *APIKeyPrincipal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// 配额拒绝原因
+const (
+	QuotaRateLimited = "rate_limited"    // 超过每分钟请求数
+	QuotaExhausted   = "quota_exhausted" // 超过每日配额
+)
+
+// QuotaDecision API Key 单次请求的配额检查结果
+type QuotaDecision struct {
+	Allowed        bool
+	Reason         string
+	RetryAfter     time.Duration
+	DailyLimit     int
+	DailyRemaining int
+	DailyReset     time.Time
+}
+
+// GenerateAPIKey 生成明文 Key，返回明文、展示前缀与入库哈希；明文只在创建时返回一次
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:apiKeyShownSize], HashAPIKey(key), nil
+}
+
+// HashAPIKey Key 为高熵随机串，SHA-256 即可防止库泄露后被还原
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseScopes 校验并去重接口分组
+func ParseScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool, len(scopes))
+	out := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if !validScopes[s] {
+			return nil, fmt.Errorf("%w: 未知的接口分组 %q", ErrInvalidScope, s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%w: 至少声明一个接口分组", ErrInvalidScope)
+	}
+	return out, nil
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+const denylistPrefix = "nftsync:auth:deny:"
+
+// Denylist 已吊销访问令牌的 jti，保留到令牌过期为止
+type Denylist struct {
+	client *redis.Client
+}
+
+func NewDenylist(client *redis.Client) *Denylist {
+	return &Denylist{client: client}
+}
+
+// Revoke 吊销令牌，已过期的令牌无需记录
+func (d *Denylist) Revoke(ctx context.Context, claims *Claims) error {
+	if claims.ExpiresAt == nil {
+		return nil
+	}
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+	return d.client.Set(ctx, denylistPrefix+claims.ID, 1, ttl).Err()
+}
+
+// Revoked 判断令牌是否已吊销
+func (d *Denylist) Revoked(ctx context.Context, jti string) (bool, error) {
+	n, err := d.client.Exists(ctx, denylistPrefix+jti).Result()
+	return n > 0, err
+}
+
+// Verify 校验令牌并检查吊销
+func (m *Manager) Verify(ctx context.Context, tokenStr string) (*Claims, error) {
+	claims, err := m.Parse(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if m.Denylist != nil {
+		revoked, err := m.Denylist.Revoked(ctx, claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return claims, nil
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// loadPrivateKey 读取 PEM 私钥，RS256 支持 PKCS#1 与 PKCS#8，EdDSA 仅支持 PKCS#8
+func loadPrivateKey(path, alg string) (crypto.Signer, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%s 需要配置 auth.private_key_file", alg)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("私钥不是 PEM 格式")
+	}
+	var key interface{}
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if alg == AlgRS256 {
+			return k, nil
+		}
+	case ed25519.PrivateKey:
+		if alg == AlgEdDSA {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("私钥类型与签名算法 %s 不匹配", alg)
+}
+
+// JWK 公钥，字段按 RFC 7517/8037
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	N   string `json:"n,omitempty"`   // RSA
+	E   string `json:"e,omitempty"`   // RSA
+	Crv string `json:"crv,omitempty"` // OKP
+	X   string `json:"x,omitempty"`   // OKP
+}
+
+// JWKS 公钥集合
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 返回验证访问令牌的公钥集合，HS256 不公开密钥，返回空集合
+func (m *Manager) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	enc := base64.RawURLEncoding
+	switch pub := m.publicKey().(type) {
+	case *rsa.PublicKey:
+		set.Keys = append(set.Keys, JWK{
+			Kty: "RSA", Use: "sig", Alg: AlgRS256, Kid: m.opts.KeyID,
+			N: enc.EncodeToString(pub.N.Bytes()),
+			E: enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		})
+	case ed25519.PublicKey:
+		set.Keys = append(set.Keys, JWK{
+			Kty: "OKP", Use: "sig", Alg: AlgEdDSA, Kid: m.opts.KeyID,
+			Crv: "Ed25519", X: enc.EncodeToString(pub),
+		})
+	}
+	return set
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+const noncePrefix = "nftsync:auth:nonce:"
+
+// NonceStore SIWE 一次性 nonce，签发后在 ttl 内有效，校验时删除
+type NonceStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewNonceStore(client *redis.Client, ttl time.Duration) *NonceStore {
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	return &NonceStore{client: client, ttl: ttl}
+}
+
+// TTL nonce 有效期
+func (s *NonceStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue 生成 nonce，32 位十六进制满足 EIP-4361 的字母数字要求
+func (s *NonceStore) Issue(ctx context.Context) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	nonce := hex.EncodeToString(b)
+	if err := s.client.Set(ctx, noncePrefix+nonce, 1, s.ttl).Err(); err != nil {
+		return "", err
+	}
+	return nonce, nil
+}
+
+// Consume 消费 nonce，DEL 保证并发请求中只有一个成功
+func (s *NonceStore) Consume(ctx context.Context, nonce string) (bool, error) {
+	n, err := s.client.Del(ctx, noncePrefix+nonce).Result()
+	return n > 0, err
+}
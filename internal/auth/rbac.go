@@ -0,0 +1,57 @@
+package auth
+
+// 用户角色，写入 users.role 与访问令牌的 role 声明
+const (
+	RoleUser     = "user"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// Permission 路由权限
+type Permission string
+
+const (
+	PermNFTRead          Permission = "nft:read"
+	PermOrderRead        Permission = "order:read"
+	PermOrderWrite       Permission = "order:write"
+	PermWalletRead       Permission = "wallet:read"     // 读取已绑定钱包的私有数据
+	PermWalletReadAny    Permission = "wallet:read_any" // 读取任意钱包的私有数据
+	PermWebhookManage    Permission = "webhook:manage"
+	PermWashReview       Permission = "wash:review"
+	PermCollectionManage Permission = "collection:manage" // K 线回填等合集维护操作
+	PermReindex          Permission = "system:reindex"
+	PermNodeStatus       Permission = "system:nodes"
+	PermUserManage       Permission = "user:manage"
+	PermAPIKeyManage     Permission = "apikey:manage"
+)
+
+var userPermissions = []Permission{
+	PermNFTRead, PermOrderRead, PermOrderWrite, PermWalletRead, PermWebhookManage, PermAPIKeyManage,
+}
+
+// rolePermissions 角色权限表，admin 拥有全部权限
+var rolePermissions = map[string]map[Permission]bool{
+	RoleUser:     permissionSet(userPermissions),
+	RoleOperator: permissionSet(append([]Permission{PermWashReview, PermWalletReadAny}, userPermissions...)),
+}
+
+func permissionSet(perms []Permission) map[Permission]bool {
+	set := make(map[Permission]bool, len(perms))
+	for _, p := range perms {
+		set[p] = true
+	}
+	return set
+}
+
+// ValidRole 判断角色是否存在
+func ValidRole(role string) bool {
+	return role == RoleAdmin || rolePermissions[role] != nil
+}
+
+// HasPermission 判断角色是否拥有权限，未知角色没有任何权限
+func HasPermission(role string, perm Permission) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	return rolePermissions[role][perm]
+}
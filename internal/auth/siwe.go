@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSIWE SIWE 消息格式错误或校验不通过
+var ErrInvalidSIWE = errors.New("invalid siwe message")
+
+const siweHeaderSuffix = " wants you to sign in with your Ethereum account:"
+
+var siweNoncePattern = regexp.MustCompile(`^[a-zA-Z0-9]{8,}$`)
+
+// SIWEMessage EIP-4361 登录消息
+type SIWEMessage struct {
+	Domain         string
+	Address        common.Address
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// ParseSIWEMessage 按 EIP-4361 解析消息文本，地址须为 EIP-55 校验和格式
+func ParseSIWEMessage(text string) (*SIWEMessage, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) < 2 || !strings.HasSuffix(lines[0], siweHeaderSuffix) {
+		return nil, fmt.Errorf("%w: bad header", ErrInvalidSIWE)
+	}
+	msg := &SIWEMessage{Domain: strings.TrimSuffix(lines[0], siweHeaderSuffix)}
+	if msg.Domain == "" {
+		return nil, fmt.Errorf("%w: missing domain", ErrInvalidSIWE)
+	}
+	addr := lines[1]
+	if !common.IsHexAddress(addr) || common.HexToAddress(addr).Hex() != addr {
+		return nil, fmt.Errorf("%w: address must be EIP-55 checksummed", ErrInvalidSIWE)
+	}
+	msg.Address = common.HexToAddress(addr)
+
+	// 地址与 URI 之间为空行及可选的 statement
+	i := 2
+	var statement []string
+	for ; i < len(lines) && !strings.HasPrefix(lines[i], "URI: "); i++ {
+		if lines[i] != "" {
+			statement = append(statement, lines[i])
+		}
+	}
+	msg.Statement = strings.Join(statement, "\n")
+
+	var err error
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if line == "" {
+			continue
+		}
+		if line == "Resources:" {
+			for i++; i < len(lines) && strings.HasPrefix(lines[i], "- "); i++ {
+				msg.Resources = append(msg.Resources, strings.TrimPrefix(lines[i], "- "))
+			}
+			i--
+			continue
+		}
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			return nil, fmt.Errorf("%w: bad line %q", ErrInvalidSIWE, line)
+		}
+		switch key {
+		case "URI":
+			msg.URI = value
+		case "Version":
+			msg.Version = value
+		case "Chain ID":
+			if msg.ChainID, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return nil, fmt.Errorf("%w: bad chain id", ErrInvalidSIWE)
+			}
+		case "Nonce":
+			msg.Nonce = value
+		case "Issued At":
+			if msg.IssuedAt, err = time.Parse(time.RFC3339, value); err != nil {
+				return nil, fmt.Errorf("%w: bad issued-at", ErrInvalidSIWE)
+			}
+		case "Expiration Time":
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: bad expiration-time", ErrInvalidSIWE)
+			}
+			msg.ExpirationTime = &t
+		case "Not Before":
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: bad not-before", ErrInvalidSIWE)
+			}
+			msg.NotBefore = &t
+		case "Request ID":
+			msg.RequestID = value
+		default:
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidSIWE, key)
+		}
+	}
+
+	switch {
+	case msg.URI == "":
+		return nil, fmt.Errorf("%w: missing uri", ErrInvalidSIWE)
+	case msg.Version != "1":
+		return nil, fmt.Errorf("%w: unsupported version", ErrInvalidSIWE)
+	case msg.ChainID == 0:
+		return nil, fmt.Errorf("%w: missing chain id", ErrInvalidSIWE)
+	case !siweNoncePattern.MatchString(msg.Nonce):
+		return nil, fmt.Errorf("%w: bad nonce", ErrInvalidSIWE)
+	case msg.IssuedAt.IsZero():
+		return nil, fmt.Errorf("%w: missing issued-at", ErrInvalidSIWE)
+	}
+	return msg, nil
+}
+
+// Validate 校验域名、链 ID 与时间窗口，允许 leeway 内的时钟偏差；maxAge 大于 0 时限制 issued-at 距今时长
+func (m *SIWEMessage) Validate(domains []string, chainID int64, now time.Time, leeway, maxAge time.Duration) error {
+	allowed := false
+	for _, d := range domains {
+		if strings.EqualFold(d, m.Domain) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("%w: domain %s not allowed", ErrInvalidSIWE, m.Domain)
+	}
+	if m.ChainID != chainID {
+		return fmt.Errorf("%w: chain id mismatch", ErrInvalidSIWE)
+	}
+	if now.Add(leeway).Before(m.IssuedAt) {
+		return fmt.Errorf("%w: issued in the future", ErrInvalidSIWE)
+	}
+	if maxAge > 0 && now.Add(-leeway).Sub(m.IssuedAt) > maxAge {
+		return fmt.Errorf("%w: message too old", ErrInvalidSIWE)
+	}
+	if m.ExpirationTime != nil && !now.Add(-leeway).Before(*m.ExpirationTime) {
+		return fmt.Errorf("%w: message expired", ErrInvalidSIWE)
+	}
+	if m.NotBefore != nil && now.Add(leeway).Before(*m.NotBefore) {
+		return fmt.Errorf("%w: message not valid yet", ErrInvalidSIWE)
+	}
+	return nil
+}
+
+// SIWEHash EIP-191 personal_sign 摘要，EOA 与 ERC-1271 合约钱包均对该摘要签名
+func SIWEHash(text string) common.Hash {
+	return common.BytesToHash(accounts.TextHash([]byte(text)))
+}
+
+// RecoverSIWESigner 从 65 字节 personal_sign 签名恢复 EOA 地址，v 兼容 0/1 与 27/28
+func RecoverSIWESigner(text string, sig []byte) (common.Address, error) {
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, errors.New("签名长度错误")
+	}
+	sig = append([]byte{}, sig...)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(SIWEHash(text).Bytes(), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
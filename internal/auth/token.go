@@ -0,0 +1,183 @@
+// Package auth 访问令牌签发与校验：HS256 使用共享密钥，RS256/EdDSA 使用私钥签名并通过 JWKS 公开公钥
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"time"
+)
+
+// 签名算法
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// minSecretLen HS256 密钥最小长度（字节），与 SHA-256 输出等长
+const minSecretLen = 32
+
+// placeholderSecret 示例配置中的占位密钥，禁止用于签发令牌
+const placeholderSecret = "change-me"
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenRevoked = errors.New("token revoked")
+)
+
+// Options 令牌配置，为 0 的项使用默认值
+type Options struct {
+	Algorithm      string
+	Secret         string // HS256
+	PrivateKeyFile string // RS256 / EdDSA，PEM 格式
+	KeyID          string
+	Issuer         string
+	Audience       string
+	AccessTTL      time.Duration // 默认 15 分钟
+	Leeway         time.Duration // 校验 exp/nbf/iat 时允许的时钟偏差，默认 30 秒
+}
+
+// Claims 访问令牌声明，sub 为用户 ID，jti 用于吊销
+type Claims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// valid 校验时间相关声明，允许 leeway 内的时钟偏差；解析阶段跳过 jwt 库的默认校验，由 Manager.Parse 调用
+func (c *Claims) valid(now time.Time, leeway time.Duration) error {
+	if c.ExpiresAt == nil || !now.Add(-leeway).Before(c.ExpiresAt.Time) {
+		return fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+	if c.NotBefore != nil && now.Add(leeway).Before(c.NotBefore.Time) {
+		return fmt.Errorf("%w: token not valid yet", ErrInvalidToken)
+	}
+	if c.IssuedAt != nil && now.Add(leeway).Before(c.IssuedAt.Time) {
+		return fmt.Errorf("%w: token used before issued", ErrInvalidToken)
+	}
+	return nil
+}
+
+// Manager 签发与校验访问令牌，Denylist 为空时不检查吊销
+type Manager struct {
+	opts      Options
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+	Denylist  *Denylist
+	now       func() time.Time
+}
+
+func NewManager(opts Options, denylist *Denylist) (*Manager, error) {
+	if opts.Algorithm == "" {
+		opts.Algorithm = AlgHS256
+	}
+	if opts.AccessTTL <= 0 {
+		opts.AccessTTL = 15 * time.Minute
+	}
+	if opts.Leeway <= 0 {
+		opts.Leeway = 30 * time.Second
+	}
+	m := &Manager{opts: opts, Denylist: denylist, now: time.Now}
+	switch opts.Algorithm {
+	case AlgHS256:
+		if opts.Secret == "" {
+			return nil, errors.New("auth.secret 未配置")
+		}
+		if opts.Secret == placeholderSecret {
+			return nil, errors.New("auth.secret 仍为示例占位值，请替换为随机密钥")
+		}
+		if len(opts.Secret) < minSecretLen {
+			return nil, fmt.Errorf("auth.secret 长度不足 %d 字节", minSecretLen)
+		}
+		m.method = jwt.SigningMethodHS256
+		m.signKey = []byte(opts.Secret)
+		m.verifyKey = m.signKey
+	case AlgRS256, AlgEdDSA:
+		key, err := loadPrivateKey(opts.PrivateKeyFile, opts.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+		m.method = jwt.GetSigningMethod(opts.Algorithm)
+		m.signKey = key
+		m.verifyKey = key.Public()
+	default:
+		return nil, fmt.Errorf("不支持的签名算法: %s", opts.Algorithm)
+	}
+	return m, nil
+}
+
+// AccessTTL 访问令牌有效期
+func (m *Manager) AccessTTL() time.Duration {
+	return m.opts.AccessTTL
+}
+
+// Issue 签发访问令牌
+func (m *Manager) Issue(subject, role string) (string, *Claims, error) {
+	now := m.now()
+	claims := &Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        randomID(),
+			Subject:   subject,
+			Issuer:    m.opts.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.opts.AccessTTL)),
+		},
+	}
+	if m.opts.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{m.opts.Audience}
+	}
+	token := jwt.NewWithClaims(m.method, claims)
+	if m.opts.KeyID != "" {
+		token.Header["kid"] = m.opts.KeyID
+	}
+	signed, err := token.SignedString(m.signKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
+}
+
+// Parse 校验签名、算法、时间、签发方与受众，返回声明；不检查吊销
+func (m *Manager) Parse(tokenStr string) (*Claims, error) {
+	claims := &Claims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{m.method.Alg()}), jwt.WithoutClaimsValidation())
+	token, err := parser.ParseWithClaims(tokenStr, claims, func(*jwt.Token) (interface{}, error) {
+		return m.verifyKey, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err := claims.valid(m.now(), m.opts.Leeway); err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" || claims.ID == "" {
+		return nil, fmt.Errorf("%w: missing sub or jti", ErrInvalidToken)
+	}
+	if m.opts.Issuer != "" && !claims.VerifyIssuer(m.opts.Issuer, true) {
+		return nil, fmt.Errorf("%w: issuer mismatch", ErrInvalidToken)
+	}
+	if m.opts.Audience != "" && !claims.VerifyAudience(m.opts.Audience, true) {
+		return nil, fmt.Errorf("%w: audience mismatch", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// publicKey 非对称算法的公钥，HS256 返回 nil
+func (m *Manager) publicKey() crypto.PublicKey {
+	if m.opts.Algorithm == AlgHS256 {
+		return nil
+	}
+	return m.verifyKey
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
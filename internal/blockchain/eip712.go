@@ -0,0 +1,132 @@
+package blockchain
+
+import (
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// OrderDomain 链下订单 EIP-712 域
+type OrderDomain struct {
+	Name              string
+	Version           string
+	ChainID           int64
+	VerifyingContract string // 交易合约地址
+}
+
+// SignedOrderMessage 链下订单签名内容，uint256 字段使用十进制字符串
+type SignedOrderMessage struct {
+	Maker           string `json:"maker"`
+	Collection      string `json:"collection"`
+	TokenID         string `json:"token_id"` // 合集出价填 0
+	IsBid           bool   `json:"is_bid"`
+	IsCollectionBid bool   `json:"is_collection_bid"`
+	Currency        string `json:"currency"` // 支付币种地址，ETH为零地址
+	Price           string `json:"price"`
+	StartTime       string `json:"start_time"`
+	EndTime         string `json:"end_time"`
+	Nonce           string `json:"nonce"`
+	Criteria        string `json:"criteria"` // 属性出价条件（JSON），无条件时为空字符串
+}
+
+var eip712DomainType = []apitypes.Type{
+	{Name: "name", Type: "string"},
+	{Name: "version", Type: "string"},
+	{Name: "chainId", Type: "uint256"},
+	{Name: "verifyingContract", Type: "address"},
+}
+
+var orderType = []apitypes.Type{
+	{Name: "maker", Type: "address"},
+	{Name: "collection", Type: "address"},
+	{Name: "tokenId", Type: "uint256"},
+	{Name: "isBid", Type: "bool"},
+	{Name: "isCollectionBid", Type: "bool"},
+	{Name: "currency", Type: "address"},
+	{Name: "price", Type: "uint256"},
+	{Name: "startTime", Type: "uint256"},
+	{Name: "endTime", Type: "uint256"},
+	{Name: "nonce", Type: "uint256"},
+	{Name: "criteria", Type: "string"},
+}
+
+var cancelOrderType = []apitypes.Type{
+	{Name: "orderHash", Type: "bytes32"},
+}
+
+func (d OrderDomain) typedDataDomain() apitypes.TypedDataDomain {
+	return apitypes.TypedDataDomain{
+		Name:              d.Name,
+		Version:           d.Version,
+		ChainId:           math.NewHexOrDecimal256(d.ChainID),
+		VerifyingContract: common.HexToAddress(d.VerifyingContract).Hex(),
+	}
+}
+
+// OrderTypedData 构造订单的 EIP-712 结构化数据
+func OrderTypedData(domain OrderDomain, msg SignedOrderMessage) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": eip712DomainType,
+			"Order":        orderType,
+		},
+		PrimaryType: "Order",
+		Domain:      domain.typedDataDomain(),
+		Message: apitypes.TypedDataMessage{
+			"maker":           msg.Maker,
+			"collection":      msg.Collection,
+			"tokenId":         msg.TokenID,
+			"isBid":           msg.IsBid,
+			"isCollectionBid": msg.IsCollectionBid,
+			"currency":        msg.Currency,
+			"price":           msg.Price,
+			"startTime":       msg.StartTime,
+			"endTime":         msg.EndTime,
+			"nonce":           msg.Nonce,
+			"criteria":        msg.Criteria,
+		},
+	}
+}
+
+// CancelTypedData 构造撤单的 EIP-712 结构化数据
+func CancelTypedData(domain OrderDomain, orderHash string) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": eip712DomainType,
+			"CancelOrder":  cancelOrderType,
+		},
+		PrimaryType: "CancelOrder",
+		Domain:      domain.typedDataDomain(),
+		Message: apitypes.TypedDataMessage{
+			"orderHash": orderHash,
+		},
+	}
+}
+
+// RecoverTypedDataSigner 恢复 EIP-712 签名者，返回签名者地址与签名摘要
+// 签名为 65 字节 r||s||v，v 兼容 0/1 与 27/28
+func RecoverTypedDataSigner(typedData apitypes.TypedData, signature string) (common.Address, common.Hash, error) {
+	digest, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return common.Address{}, common.Hash{}, err
+	}
+	sig, err := hexutil.Decode(signature)
+	if err != nil {
+		return common.Address{}, common.Hash{}, err
+	}
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, common.Hash{}, errors.New("签名长度错误")
+	}
+	sig = append([]byte{}, sig...)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(digest, sig)
+	if err != nil {
+		return common.Address{}, common.Hash{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), common.BytesToHash(digest), nil
+}
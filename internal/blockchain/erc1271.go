@@ -0,0 +1,41 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"strings"
+)
+
+// ERC1271MagicValue isValidSignature(bytes32,bytes) 校验通过时的返回值
+var ERC1271MagicValue = [4]byte{0x16, 0x26, 0xba, 0x7e}
+
+const erc1271ABI = `[{"inputs":[{"name":"hash","type":"bytes32"},{"name":"signature","type":"bytes"}],"name":"isValidSignature","outputs":[{"name":"magicValue","type":"bytes4"}],"stateMutability":"view","type":"function"}]`
+
+var erc1271ParsedABI, _ = abi.JSON(strings.NewReader(erc1271ABI))
+
+// IsContract 判断地址是否部署了合约代码
+func (e *EthClient) IsContract(ctx context.Context, addr string) (bool, error) {
+	code, err := e.client.CodeAt(ctx, common.HexToAddress(addr), nil)
+	if err != nil {
+		return false, err
+	}
+	return len(code) > 0, nil
+}
+
+// IsValidERC1271Signature 按 ERC-1271 由合约钱包校验签名，合约 revert 视为签名无效
+func (e *EthClient) IsValidERC1271Signature(ctx context.Context, wallet string, hash common.Hash, sig []byte) (bool, error) {
+	bound := bind.NewBoundContract(common.HexToAddress(wallet), erc1271ParsedABI, e.client, nil, nil)
+	var out []interface{}
+	if err := bound.Call(&bind.CallOpts{Context: ctx}, &out, "isValidSignature", hash, sig); err != nil {
+		var revert rpc.DataError
+		if errors.As(err, &revert) {
+			return false, nil
+		}
+		return false, err
+	}
+	return *abi.ConvertType(out[0], new([4]byte)).(*[4]byte) == ERC1271MagicValue, nil
+}
@@ -0,0 +1,80 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"strings"
+)
+
+// ERC20 元数据、余额与授权额度查询所需的最小 ABI
+const erc20MetaABI = `[{"constant":true,"inputs":[{"name":"owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"constant":true,"inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"}],"name":"allowance","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"decimals","outputs":[{"name":"","type":"uint8"}],"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"symbol","outputs":[{"name":"","type":"string"}],"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"name","outputs":[{"name":"","type":"string"}],"stateMutability":"view","type":"function"}]`
+
+var erc20ParsedABI, _ = abi.JSON(strings.NewReader(erc20MetaABI))
+
+// GetERC20Metadata 查询 ERC20 代币的 symbol 与 decimals
+func (e *EthClient) GetERC20Metadata(ctx context.Context, token string) (string, uint8, error) {
+	contract := bind.NewBoundContract(common.HexToAddress(token), erc20ParsedABI, e.client, nil, nil)
+	opts := &bind.CallOpts{Context: ctx}
+
+	var out []interface{}
+	if err := contract.Call(opts, &out, "decimals"); err != nil {
+		return "", 0, err
+	}
+	decimals := *abi.ConvertType(out[0], new(uint8)).(*uint8)
+
+	symbol, err := e.callERC20String(ctx, token, "symbol")
+	if err != nil {
+		return "", 0, err
+	}
+	return symbol, decimals, nil
+}
+
+// callERC20String 调用 symbol()/name() 等字符串方法
+// 早期代币（如 MKR、SAI）按 bytes32 返回，ABI string 解码失败时按 bytes32 解析并去掉末尾的零字节
+func (e *EthClient) callERC20String(ctx context.Context, token, method string) (string, error) {
+	to := common.HexToAddress(token)
+	ret, err := e.client.CallContract(ctx, ethereum.CallMsg{To: &to, Data: erc20ParsedABI.Methods[method].ID}, nil)
+	if err != nil {
+		return "", err
+	}
+	return decodeERC20String(method, ret)
+}
+
+func decodeERC20String(method string, ret []byte) (string, error) {
+	if len(ret) < 32 {
+		return "", errors.New(method + "() 返回为空")
+	}
+	if len(ret) > 32 {
+		if out, err := erc20ParsedABI.Unpack(method, ret); err == nil {
+			return *abi.ConvertType(out[0], new(string)).(*string), nil
+		}
+	}
+	return string(bytes.TrimRight(ret[:32], "\x00")), nil
+}
+
+// GetERC20Balance 查询 owner 的代币余额
+func (e *EthClient) GetERC20Balance(ctx context.Context, token, owner string) (*big.Int, error) {
+	contract := bind.NewBoundContract(common.HexToAddress(token), erc20ParsedABI, e.client, nil, nil)
+	var out []interface{}
+	if err := contract.Call(&bind.CallOpts{Context: ctx}, &out, "balanceOf", common.HexToAddress(owner)); err != nil {
+		return nil, err
+	}
+	return *abi.ConvertType(out[0], new(*big.Int)).(**big.Int), nil
+}
+
+// GetERC20Allowance 查询 owner 授权给 spender 的代币额度
+func (e *EthClient) GetERC20Allowance(ctx context.Context, token, owner, spender string) (*big.Int, error) {
+	contract := bind.NewBoundContract(common.HexToAddress(token), erc20ParsedABI, e.client, nil, nil)
+	var out []interface{}
+	if err := contract.Call(&bind.CallOpts{Context: ctx}, &out, "allowance",
+		common.HexToAddress(owner), common.HexToAddress(spender)); err != nil {
+		return nil, err
+	}
+	return *abi.ConvertType(out[0], new(*big.Int)).(**big.Int), nil
+}
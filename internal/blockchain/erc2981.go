@@ -0,0 +1,40 @@
+package blockchain
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"strings"
+)
+
+// ERC2981InterfaceID ERC-2981 royaltyInfo 接口 ID
+var ERC2981InterfaceID = [4]byte{0x2a, 0x55, 0x20, 0x5a}
+
+// ERC-2981 版税查询所需的最小 ABI
+const erc2981ABI = `[{"inputs":[{"name":"interfaceId","type":"bytes4"}],"name":"supportsInterface","outputs":[{"name":"","type":"bool"}],"stateMutability":"view","type":"function"},{"inputs":[{"name":"tokenId","type":"uint256"},{"name":"salePrice","type":"uint256"}],"name":"royaltyInfo","outputs":[{"name":"receiver","type":"address"},{"name":"royaltyAmount","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+
+var erc2981ParsedABI, _ = abi.JSON(strings.NewReader(erc2981ABI))
+
+// SupportsERC2981 查询合约是否声明支持 ERC-2981，未实现 ERC-165 的合约返回错误
+func (e *EthClient) SupportsERC2981(ctx context.Context, contract string) (bool, error) {
+	bound := bind.NewBoundContract(common.HexToAddress(contract), erc2981ParsedABI, e.client, nil, nil)
+	var out []interface{}
+	if err := bound.Call(&bind.CallOpts{Context: ctx}, &out, "supportsInterface", ERC2981InterfaceID); err != nil {
+		return false, err
+	}
+	return *abi.ConvertType(out[0], new(bool)).(*bool), nil
+}
+
+// GetRoyaltyInfo 按 ERC-2981 查询指定成交价的版税收款地址与金额
+func (e *EthClient) GetRoyaltyInfo(ctx context.Context, contract string, tokenId, salePrice *big.Int) (common.Address, *big.Int, error) {
+	bound := bind.NewBoundContract(common.HexToAddress(contract), erc2981ParsedABI, e.client, nil, nil)
+	var out []interface{}
+	if err := bound.Call(&bind.CallOpts{Context: ctx}, &out, "royaltyInfo", tokenId, salePrice); err != nil {
+		return common.Address{}, nil, err
+	}
+	receiver := *abi.ConvertType(out[0], new(common.Address)).(*common.Address)
+	amount := *abi.ConvertType(out[1], new(*big.Int)).(**big.Int)
+	return receiver, amount, nil
+}
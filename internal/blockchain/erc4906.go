@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// EIP-4906 元数据更新事件的 topic，两个事件的参数均不带 indexed，tokenId 在 data 中
+var (
+	metadataUpdateTopic      = crypto.Keccak256Hash([]byte("MetadataUpdate(uint256)"))
+	batchMetadataUpdateTopic = crypto.Keccak256Hash([]byte("BatchMetadataUpdate(uint256,uint256)"))
+)
+
+// MetadataUpdateEvent EIP-4906 元数据更新事件，单个 token 更新时 FromTokenID 与 ToTokenID 相同
+type MetadataUpdateEvent struct {
+	Contract    string
+	FromTokenID *big.Int
+	ToTokenID   *big.Int // 包含
+	BlockNumber uint64
+	TxHash      string
+	LogIndex    uint
+	BlockTime   int64
+}
+
+// FetchMetadataUpdateEvents 拉取指定区块范围内的 MetadataUpdate 与 BatchMetadataUpdate 事件
+func (e *EthClient) FetchMetadataUpdateEvents(ctx context.Context, contract string, startBlock, endBlock *big.Int) ([]MetadataUpdateEvent, error) {
+	query := ethereum.FilterQuery{
+		FromBlock: startBlock,
+		ToBlock:   endBlock,
+		Addresses: []common.Address{common.HexToAddress(contract)},
+		Topics:    [][]common.Hash{{metadataUpdateTopic, batchMetadataUpdateTopic}},
+	}
+	logs, err := e.client.FilterLogs(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	blockTimes := map[uint64]int64{}
+	var events []MetadataUpdateEvent
+	for _, vLog := range logs {
+		event := MetadataUpdateEvent{
+			Contract:    vLog.Address.Hex(),
+			BlockNumber: vLog.BlockNumber,
+			TxHash:      vLog.TxHash.Hex(),
+			LogIndex:    vLog.Index,
+		}
+		switch {
+		case vLog.Topics[0] == metadataUpdateTopic && len(vLog.Data) == 32:
+			event.FromTokenID = new(big.Int).SetBytes(vLog.Data)
+			event.ToTokenID = event.FromTokenID
+		case vLog.Topics[0] == batchMetadataUpdateTopic && len(vLog.Data) == 64:
+			event.FromTokenID = new(big.Int).SetBytes(vLog.Data[:32])
+			event.ToTokenID = new(big.Int).SetBytes(vLog.Data[32:])
+		default:
+			continue // 同名但参数带 indexed 的非标准事件
+		}
+		blockTime, ok := blockTimes[vLog.BlockNumber]
+		if !ok {
+			if block, err := e.client.BlockByNumber(ctx, new(big.Int).SetUint64(vLog.BlockNumber)); err == nil {
+				blockTime = int64(block.Time())
+			}
+			blockTimes[vLog.BlockNumber] = blockTime
+		}
+		event.BlockTime = blockTime
+		events = append(events, event)
+	}
+	return events, nil
+}
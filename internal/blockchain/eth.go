@@ -3,6 +3,7 @@ package blockchain
 import (
 	"context"
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -10,6 +11,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/gavin/nftSync/internal/blockchain/erc721"
 	"math/big"
+	"strings"
 )
 
 // EthClient 封装以太坊客户端
@@ -41,6 +43,7 @@ type TransferEvent struct {
 	Contract    string
 	BlockNumber uint64
 	TxHash      string
+	LogIndex    uint
 	BlockTime   int64
 }
 
@@ -76,6 +79,7 @@ func (e *EthClient) FetchTransferEvents(ctx context.Context, contract string, st
 			Contract:    vLog.Address.Hex(),
 			BlockNumber: vLog.BlockNumber,
 			TxHash:      vLog.TxHash.Hex(),
+			LogIndex:    vLog.Index,
 			BlockTime:   blockTime,
 		}
 		events = append(events, event)
@@ -97,6 +101,31 @@ func (e *EthClient) GetTokenURI(ctx context.Context, contract string, tokenId *b
 	return uri, nil
 }
 
+// GetOwnerOf 查询 NFT 当前持有人
+func (e *EthClient) GetOwnerOf(ctx context.Context, contract string, tokenId *big.Int) (common.Address, error) {
+	instance, err := erc721.NewErc721(common.HexToAddress(contract), e.client)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return instance.OwnerOf(&bind.CallOpts{Context: ctx}, tokenId)
+}
+
+// ERC721 isApprovedForAll 最小 ABI（abigen 生成的绑定中未包含）
+const erc721ApprovalABI = `[{"constant":true,"inputs":[{"name":"owner","type":"address"},{"name":"operator","type":"address"}],"name":"isApprovedForAll","outputs":[{"name":"","type":"bool"}],"stateMutability":"view","type":"function"}]`
+
+var erc721ApprovalParsedABI, _ = abi.JSON(strings.NewReader(erc721ApprovalABI))
+
+// IsApprovedForAll 查询 owner 是否已授权 operator 操作其全部 NFT
+func (e *EthClient) IsApprovedForAll(ctx context.Context, contract, owner, operator string) (bool, error) {
+	bound := bind.NewBoundContract(common.HexToAddress(contract), erc721ApprovalParsedABI, e.client, nil, nil)
+	var out []interface{}
+	if err := bound.Call(&bind.CallOpts{Context: ctx}, &out, "isApprovedForAll",
+		common.HexToAddress(owner), common.HexToAddress(operator)); err != nil {
+		return false, err
+	}
+	return *abi.ConvertType(out[0], new(bool)).(*bool), nil
+}
+
 type OrderFilledEvent struct {
 	TokenID     string
 	Seller      string
@@ -124,4 +153,106 @@ func (e *EthClient) FetchOrderEvents(ctx context.Context, contract string, start
 	return logs, nil
 }
 
+// GetTransaction 查询交易及其发送方
+func (e *EthClient) GetTransaction(ctx context.Context, txHash string) (*types.Transaction, common.Address, error) {
+	tx, _, err := e.client.TransactionByHash(ctx, common.HexToHash(txHash))
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	return tx, sender, nil
+}
+
+// GetTransactionReceipt 查询交易回执
+func (e *EthClient) GetTransactionReceipt(ctx context.Context, txHash string) (*types.Receipt, error) {
+	return e.client.TransactionReceipt(ctx, common.HexToHash(txHash))
+}
+
+// ERC20Transfer ERC20 Transfer 事件
+type ERC20Transfer struct {
+	Token string
+	From  string
+	To    string
+	Value *big.Int
+}
+
+// ParseERC20Transfers 从日志中解析 ERC20 Transfer 事件（3个topic，金额在data中，与ERC721的4个topic区分）
+func ParseERC20Transfers(logs []*types.Log) []ERC20Transfer {
+	var transfers []ERC20Transfer
+	for _, vLog := range logs {
+		if len(vLog.Topics) != 3 || vLog.Topics[0].Hex() != transferEventTopic {
+			continue
+		}
+		transfers = append(transfers, ERC20Transfer{
+			Token: vLog.Address.Hex(),
+			From:  common.HexToAddress(vLog.Topics[1].Hex()).Hex(),
+			To:    common.HexToAddress(vLog.Topics[2].Hex()).Hex(),
+			Value: new(big.Int).SetBytes(vLog.Data),
+		})
+	}
+	return transfers
+}
+
+// ParseERC721Transfers 从日志中解析 ERC721 Transfer 事件
+func ParseERC721Transfers(logs []*types.Log) []TransferEvent {
+	var events []TransferEvent
+	for _, vLog := range logs {
+		if len(vLog.Topics) != 4 || vLog.Topics[0].Hex() != transferEventTopic {
+			continue
+		}
+		events = append(events, TransferEvent{
+			From:        common.HexToAddress(vLog.Topics[1].Hex()).Hex(),
+			To:          common.HexToAddress(vLog.Topics[2].Hex()).Hex(),
+			TokenID:     vLog.Topics[3].Hex(),
+			Contract:    vLog.Address.Hex(),
+			BlockNumber: vLog.BlockNumber,
+			TxHash:      vLog.TxHash.Hex(),
+			LogIndex:    vLog.Index,
+		})
+	}
+	return events
+}
+
+// ERC1155 转移事件的topic
+var (
+	transferSingleTopic = crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)")).Hex()
+	transferBatchTopic  = crypto.Keccak256Hash([]byte("TransferBatch(address,address,address,uint256[],uint256[])")).Hex()
+)
+
+// FetchERC20TransfersTo 拉取指定代币转入给定地址的 Transfer 事件
+func (e *EthClient) FetchERC20TransfersTo(ctx context.Context, token string, recipients []common.Address, startBlock, endBlock *big.Int) ([]types.Log, error) {
+	to := make([]common.Hash, 0, len(recipients))
+	for _, addr := range recipients {
+		to = append(to, common.BytesToHash(addr.Bytes()))
+	}
+	query := ethereum.FilterQuery{
+		FromBlock: startBlock,
+		ToBlock:   endBlock,
+		Addresses: []common.Address{common.HexToAddress(token)},
+		Topics:    [][]common.Hash{{common.HexToHash(transferEventTopic)}, nil, to},
+	}
+	return e.client.FilterLogs(ctx, query)
+}
+
+// HasNFTTransfer 日志中是否包含 ERC721 或 ERC1155 转移事件
+func HasNFTTransfer(logs []*types.Log) bool {
+	for _, vLog := range logs {
+		if len(vLog.Topics) == 0 {
+			continue
+		}
+		switch vLog.Topics[0].Hex() {
+		case transferEventTopic:
+			if len(vLog.Topics) == 4 {
+				return true
+			}
+		case transferSingleTopic, transferBatchTopic:
+			return true
+		}
+	}
+	return false
+}
+
 // TODO: 添加事件监听与合约交互方法
@@ -0,0 +1,106 @@
+package marketplace
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const ProtocolBlur = "blur"
+
+// Blur Exchange OrdersMatched 事件 ABI
+const blurEventABI = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"maker","type":"address"},{"indexed":true,"name":"taker","type":"address"},{"components":[{"name":"trader","type":"address"},{"name":"side","type":"uint8"},{"name":"matchingPolicy","type":"address"},{"name":"collection","type":"address"},{"name":"tokenId","type":"uint256"},{"name":"amount","type":"uint256"},{"name":"paymentToken","type":"address"},{"name":"price","type":"uint256"},{"name":"listingTime","type":"uint256"},{"name":"expirationTime","type":"uint256"},{"components":[{"name":"rate","type":"uint16"},{"name":"recipient","type":"address"}],"name":"fees","type":"tuple[]"},{"name":"salt","type":"uint256"},{"name":"extraParams","type":"bytes"}],"indexed":false,"name":"sell","type":"tuple"},{"indexed":false,"name":"sellHash","type":"bytes32"},{"components":[{"name":"trader","type":"address"},{"name":"side","type":"uint8"},{"name":"matchingPolicy","type":"address"},{"name":"collection","type":"address"},{"name":"tokenId","type":"uint256"},{"name":"amount","type":"uint256"},{"name":"paymentToken","type":"address"},{"name":"price","type":"uint256"},{"name":"listingTime","type":"uint256"},{"name":"expirationTime","type":"uint256"},{"components":[{"name":"rate","type":"uint16"},{"name":"recipient","type":"address"}],"name":"fees","type":"tuple[]"},{"name":"salt","type":"uint256"},{"name":"extraParams","type":"bytes"}],"indexed":false,"name":"buy","type":"tuple"},{"indexed":false,"name":"buyHash","type":"bytes32"}],"name":"OrdersMatched","type":"event"}]`
+
+type blurFee struct {
+	Rate      uint16
+	Recipient common.Address
+}
+
+type blurOrder struct {
+	Trader         common.Address
+	Side           uint8
+	MatchingPolicy common.Address
+	Collection     common.Address
+	TokenId        *big.Int
+	Amount         *big.Int
+	PaymentToken   common.Address
+	Price          *big.Int
+	ListingTime    *big.Int
+	ExpirationTime *big.Int
+	Fees           []blurFee
+	Salt           *big.Int
+	ExtraParams    []byte
+}
+
+type blurOrdersMatched struct {
+	Sell     blurOrder
+	SellHash [32]byte
+	Buy      blurOrder
+	BuyHash  [32]byte
+}
+
+type blurDecoder struct {
+	base
+	abi   abi.ABI
+	topic common.Hash
+}
+
+func init() {
+	Register(ProtocolBlur, newBlurDecoder)
+}
+
+func newBlurDecoder(opts Options) (Decoder, error) {
+	parsed, err := abi.JSON(strings.NewReader(blurEventABI))
+	if err != nil {
+		return nil, err
+	}
+	return &blurDecoder{
+		base:  newBase(opts),
+		abi:   parsed,
+		topic: parsed.Events["OrdersMatched"].ID,
+	}, nil
+}
+
+func (d *blurDecoder) Protocol() string { return ProtocolBlur }
+
+func (d *blurDecoder) Topics() []common.Hash { return []common.Hash{d.topic} }
+
+func (d *blurDecoder) Decode(logs []types.Log) ([]Sale, error) {
+	var sales []Sale
+	var errs []error
+	for _, vLog := range logs {
+		if len(vLog.Topics) == 0 || vLog.Topics[0] != d.topic {
+			continue
+		}
+		var ev blurOrdersMatched
+		if err := d.abi.UnpackIntoInterface(&ev, "OrdersMatched", vLog.Data); err != nil {
+			errs = append(errs, fmt.Errorf("blur 日志解码失败 tx=%s index=%d: %w", vLog.TxHash.Hex(), vLog.Index, err))
+			continue
+		}
+		sale := d.newSale(ProtocolBlur, vLog)
+		sale.OrderHash = common.BytesToHash(ev.SellHash[:]).Hex()
+		sale.Collection = ev.Sell.Collection.Hex()
+		sale.TokenID = TokenIDHex(ev.Sell.TokenId)
+		sale.Amount = ev.Sell.Amount
+		sale.Seller = ev.Sell.Trader.Hex()
+		sale.Buyer = ev.Buy.Trader.Hex()
+		sale.Price = ev.Sell.Price
+		sale.Currency = ev.Sell.PaymentToken.Hex()
+		// Blur 订单中的 fees 为卖单附带的费用，收款方为市场地址时计为手续费，其余按收款地址记录
+		for _, f := range ev.Sell.Fees {
+			amount := bpsOf(ev.Sell.Price, int64(f.Rate))
+			if d.isFeeRecipient(f.Recipient) {
+				sale.Fee.Add(sale.Fee, amount)
+			} else {
+				addPayout(sale.Payouts, f.Recipient, amount)
+			}
+		}
+		sales = append(sales, sale)
+	}
+	return sales, errors.Join(errs...)
+}
@@ -0,0 +1,124 @@
+package marketplace
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const ProtocolLooksRare = "looksrare"
+
+// LooksRare Exchange TakerBid / TakerAsk / RoyaltyPayment 事件 ABI
+const looksRareEventABI = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"orderHash","type":"bytes32"},{"indexed":false,"name":"orderNonce","type":"uint256"},{"indexed":true,"name":"taker","type":"address"},{"indexed":true,"name":"maker","type":"address"},{"indexed":true,"name":"strategy","type":"address"},{"indexed":false,"name":"currency","type":"address"},{"indexed":false,"name":"collection","type":"address"},{"indexed":false,"name":"tokenId","type":"uint256"},{"indexed":false,"name":"amount","type":"uint256"},{"indexed":false,"name":"price","type":"uint256"}],"name":"TakerBid","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"name":"orderHash","type":"bytes32"},{"indexed":false,"name":"orderNonce","type":"uint256"},{"indexed":true,"name":"taker","type":"address"},{"indexed":true,"name":"maker","type":"address"},{"indexed":true,"name":"strategy","type":"address"},{"indexed":false,"name":"currency","type":"address"},{"indexed":false,"name":"collection","type":"address"},{"indexed":false,"name":"tokenId","type":"uint256"},{"indexed":false,"name":"amount","type":"uint256"},{"indexed":false,"name":"price","type":"uint256"}],"name":"TakerAsk","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"name":"collection","type":"address"},{"indexed":true,"name":"tokenId","type":"uint256"},{"indexed":true,"name":"royaltyRecipient","type":"address"},{"indexed":false,"name":"currency","type":"address"},{"indexed":false,"name":"amount","type":"uint256"}],"name":"RoyaltyPayment","type":"event"}]`
+
+type looksRareTaker struct {
+	OrderHash  [32]byte
+	OrderNonce *big.Int
+	Currency   common.Address
+	Collection common.Address
+	TokenId    *big.Int
+	Amount     *big.Int
+	Price      *big.Int
+}
+
+type looksRareRoyalty struct {
+	Currency common.Address
+	Amount   *big.Int
+}
+
+type looksRareDecoder struct {
+	base
+	abi          abi.ABI
+	takerBid     common.Hash
+	takerAsk     common.Hash
+	royaltyTopic common.Hash
+}
+
+func init() {
+	Register(ProtocolLooksRare, newLooksRareDecoder)
+}
+
+func newLooksRareDecoder(opts Options) (Decoder, error) {
+	parsed, err := abi.JSON(strings.NewReader(looksRareEventABI))
+	if err != nil {
+		return nil, err
+	}
+	return &looksRareDecoder{
+		base:         newBase(opts),
+		abi:          parsed,
+		takerBid:     parsed.Events["TakerBid"].ID,
+		takerAsk:     parsed.Events["TakerAsk"].ID,
+		royaltyTopic: parsed.Events["RoyaltyPayment"].ID,
+	}, nil
+}
+
+func (d *looksRareDecoder) Protocol() string { return ProtocolLooksRare }
+
+func (d *looksRareDecoder) Topics() []common.Hash {
+	return []common.Hash{d.takerBid, d.takerAsk, d.royaltyTopic}
+}
+
+// Decode RoyaltyPayment 在同一交易中先于 TakerBid/TakerAsk 发出，按 (tx, collection, tokenId) 暂存后合并
+func (d *looksRareDecoder) Decode(logs []types.Log) ([]Sale, error) {
+	var sales []Sale
+	var errs []error
+	royalties := map[string]map[common.Address]*big.Int{}
+	for _, vLog := range logs {
+		if len(vLog.Topics) < 4 {
+			continue
+		}
+		switch vLog.Topics[0] {
+		case d.royaltyTopic:
+			var ev looksRareRoyalty
+			if err := d.abi.UnpackIntoInterface(&ev, "RoyaltyPayment", vLog.Data); err != nil {
+				errs = append(errs, fmt.Errorf("looksrare 版税日志解码失败 tx=%s index=%d: %w", vLog.TxHash.Hex(), vLog.Index, err))
+				continue
+			}
+			key := looksRareRoyaltyKey(vLog.TxHash, common.BytesToAddress(vLog.Topics[1].Bytes()), vLog.Topics[2].Big())
+			if royalties[key] == nil {
+				royalties[key] = map[common.Address]*big.Int{}
+			}
+			addPayout(royalties[key], common.BytesToAddress(vLog.Topics[3].Bytes()), ev.Amount)
+		case d.takerBid, d.takerAsk:
+			name := "TakerBid"
+			if vLog.Topics[0] == d.takerAsk {
+				name = "TakerAsk"
+			}
+			var ev looksRareTaker
+			if err := d.abi.UnpackIntoInterface(&ev, name, vLog.Data); err != nil {
+				errs = append(errs, fmt.Errorf("looksrare 日志解码失败 tx=%s index=%d: %w", vLog.TxHash.Hex(), vLog.Index, err))
+				continue
+			}
+			taker := common.BytesToAddress(vLog.Topics[1].Bytes())
+			maker := common.BytesToAddress(vLog.Topics[2].Bytes())
+			sale := d.newSale(ProtocolLooksRare, vLog)
+			sale.OrderHash = common.BytesToHash(ev.OrderHash[:]).Hex()
+			sale.Collection = ev.Collection.Hex()
+			sale.TokenID = TokenIDHex(ev.TokenId)
+			sale.Amount = ev.Amount
+			sale.Price = ev.Price
+			sale.Currency = ev.Currency.Hex()
+			// TakerBid：taker 购买 maker 的挂单；TakerAsk：taker 接受 maker 的出价
+			if name == "TakerBid" {
+				sale.Buyer, sale.Seller = taker.Hex(), maker.Hex()
+			} else {
+				sale.Buyer, sale.Seller = maker.Hex(), taker.Hex()
+			}
+			sale.Fee = bpsOf(ev.Price, d.opts.FeeBps)
+			if r, ok := royalties[looksRareRoyaltyKey(vLog.TxHash, ev.Collection, ev.TokenId)]; ok {
+				sale.Payouts = r
+			}
+			sales = append(sales, sale)
+		}
+	}
+	return sales, errors.Join(errs...)
+}
+
+func looksRareRoyaltyKey(txHash common.Hash, collection common.Address, tokenID *big.Int) string {
+	return txHash.Hex() + ":" + collection.Hex() + ":" + tokenID.String()
+}
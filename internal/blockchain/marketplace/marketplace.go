@@ -0,0 +1,134 @@
+package marketplace
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ZeroAddress 原生 ETH 支付时使用的币种地址
+const ZeroAddress = "0x0000000000000000000000000000000000000000"
+
+// Sale 标准化成交记录
+// 不同市场协议的成交事件统一映射为该结构，价格、手续费、版税均为链上原始数值（未按 decimals 缩放）
+type Sale struct {
+	Marketplace string   // 市场名称（配置中的 name）
+	Protocol    string   // 协议类型：seaport / blur / looksrare
+	Contract    string   // 市场合约地址
+	OrderHash   string   // 市场订单哈希
+	Collection  string   // NFT 合集合约地址
+	TokenID     string   // tokenId，32字节 hex，与 dao.NFT.TokenID 格式一致
+	Amount      *big.Int // 成交数量（ERC721 为 1）
+	Seller      string   // 卖家
+	Buyer       string   // 买家
+	Price       *big.Int // 买家支付总额（含手续费与版税）
+	Currency    string   // 支付币种合约地址，原生 ETH 为零地址
+	Fee         *big.Int // 市场手续费
+	// Payouts 支付给卖家与市场手续费地址以外的款项（收款地址 -> 金额），
+	// 由上层与版税收款地址核对，匹配的部分才计为实付版税
+	Payouts     map[common.Address]*big.Int
+	TxHash      string
+	BlockNumber uint64
+	LogIndex    uint
+}
+
+// Options 单个市场的解码配置
+type Options struct {
+	Name          string   // 市场名称
+	Contracts     []string // 市场合约地址
+	FeeRecipients []string // 市场手续费收款地址，用于区分手续费与版税
+	FeeBps        int64    // 协议手续费（万分比），事件中不含手续费明细时使用
+}
+
+// Decoder 市场协议解码器
+type Decoder interface {
+	Name() string
+	Protocol() string
+	Contracts() []common.Address
+	Topics() []common.Hash
+	// Decode 解码同一市场合约按区块顺序排列的日志，单条日志失败不影响其他日志
+	Decode(logs []types.Log) ([]Sale, error)
+}
+
+// Factory 根据配置创建解码器
+type Factory func(opts Options) (Decoder, error)
+
+var registry = map[string]Factory{}
+
+// Register 注册市场协议解码器，通常在协议实现文件的 init 中调用
+func Register(protocol string, factory Factory) {
+	registry[strings.ToLower(protocol)] = factory
+}
+
+// NewDecoder 按协议名称创建解码器
+func NewDecoder(protocol string, opts Options) (Decoder, error) {
+	factory, ok := registry[strings.ToLower(protocol)]
+	if !ok {
+		return nil, fmt.Errorf("未注册的市场协议: %s", protocol)
+	}
+	if len(opts.Contracts) == 0 {
+		return nil, fmt.Errorf("市场 %s 未配置合约地址", opts.Name)
+	}
+	return factory(opts)
+}
+
+// base 解码器公共部分
+type base struct {
+	opts          Options
+	contracts     []common.Address
+	feeRecipients map[common.Address]bool
+}
+
+func newBase(opts Options) base {
+	b := base{opts: opts, feeRecipients: map[common.Address]bool{}}
+	for _, c := range opts.Contracts {
+		b.contracts = append(b.contracts, common.HexToAddress(c))
+	}
+	for _, r := range opts.FeeRecipients {
+		b.feeRecipients[common.HexToAddress(r)] = true
+	}
+	return b
+}
+
+func (b base) Name() string { return b.opts.Name }
+
+func (b base) Contracts() []common.Address { return b.contracts }
+
+func (b base) isFeeRecipient(addr common.Address) bool { return b.feeRecipients[addr] }
+
+// newSale 填充日志相关的公共字段
+func (b base) newSale(protocol string, vLog types.Log) Sale {
+	return Sale{
+		Marketplace: b.opts.Name,
+		Protocol:    protocol,
+		Contract:    vLog.Address.Hex(),
+		Amount:      big.NewInt(1),
+		Fee:         new(big.Int),
+		Payouts:     map[common.Address]*big.Int{},
+		TxHash:      vLog.TxHash.Hex(),
+		BlockNumber: vLog.BlockNumber,
+		LogIndex:    vLog.Index,
+	}
+}
+
+// addPayout 累计支付给某地址的款项
+func addPayout(payouts map[common.Address]*big.Int, recipient common.Address, amount *big.Int) {
+	if payouts[recipient] == nil {
+		payouts[recipient] = new(big.Int)
+	}
+	payouts[recipient].Add(payouts[recipient], amount)
+}
+
+// TokenIDHex tokenId 统一为 32 字节 hex，与 Transfer 事件 topic 格式保持一致
+func TokenIDHex(id *big.Int) string {
+	return common.BigToHash(id).Hex()
+}
+
+// bpsOf 计算 amount * bps / 10000
+func bpsOf(amount *big.Int, bps int64) *big.Int {
+	v := new(big.Int).Mul(amount, big.NewInt(bps))
+	return v.Div(v, big.NewInt(10000))
+}
@@ -0,0 +1,275 @@
+package marketplace
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const ProtocolSeaport = "seaport"
+
+// Seaport OrderFulfilled 事件 ABI（1.1 ~ 1.6 一致）
+const seaportEventABI = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"orderHash","type":"bytes32"},{"indexed":true,"name":"offerer","type":"address"},{"indexed":true,"name":"zone","type":"address"},{"indexed":false,"name":"recipient","type":"address"},{"components":[{"name":"itemType","type":"uint8"},{"name":"token","type":"address"},{"name":"identifier","type":"uint256"},{"name":"amount","type":"uint256"}],"indexed":false,"name":"offer","type":"tuple[]"},{"components":[{"name":"itemType","type":"uint8"},{"name":"token","type":"address"},{"name":"identifier","type":"uint256"},{"name":"amount","type":"uint256"},{"name":"recipient","type":"address"}],"indexed":false,"name":"consideration","type":"tuple[]"}],"name":"OrderFulfilled","type":"event"}]`
+
+// Seaport ItemType
+const (
+	seaportItemNative = iota
+	seaportItemERC20
+	seaportItemERC721
+	seaportItemERC1155
+	seaportItemERC721WithCriteria
+	seaportItemERC1155WithCriteria
+)
+
+type seaportSpentItem struct {
+	ItemType   uint8
+	Token      common.Address
+	Identifier *big.Int
+	Amount     *big.Int
+}
+
+type seaportReceivedItem struct {
+	ItemType   uint8
+	Token      common.Address
+	Identifier *big.Int
+	Amount     *big.Int
+	Recipient  common.Address
+}
+
+type seaportOrderFulfilled struct {
+	OrderHash     [32]byte
+	Recipient     common.Address
+	Offer         []seaportSpentItem
+	Consideration []seaportReceivedItem
+}
+
+type seaportDecoder struct {
+	base
+	abi   abi.ABI
+	topic common.Hash
+}
+
+func init() {
+	Register(ProtocolSeaport, newSeaportDecoder)
+}
+
+func newSeaportDecoder(opts Options) (Decoder, error) {
+	parsed, err := abi.JSON(strings.NewReader(seaportEventABI))
+	if err != nil {
+		return nil, err
+	}
+	return &seaportDecoder{
+		base:  newBase(opts),
+		abi:   parsed,
+		topic: parsed.Events["OrderFulfilled"].ID,
+	}, nil
+}
+
+func (d *seaportDecoder) Protocol() string { return ProtocolSeaport }
+
+func (d *seaportDecoder) Topics() []common.Hash { return []common.Hash{d.topic} }
+
+// seaportLog 已解码的 OrderFulfilled 日志
+type seaportLog struct {
+	vLog    types.Log
+	offerer common.Address
+	ev      seaportOrderFulfilled
+}
+
+// errSeaportMixedCurrency 同一订单的支付项使用了多个币种，无法折算为单一成交价
+var errSeaportMixedCurrency = errors.New("支付项包含多个币种")
+
+func (d *seaportDecoder) Decode(logs []types.Log) ([]Sale, error) {
+	var sales []Sale
+	var errs []error
+	var parsed []*seaportLog
+	byTx := map[common.Hash][]*seaportLog{} // matchOrders 的撮合双方在同一交易中，用于补全 recipient 为零地址的一方
+	for _, vLog := range logs {
+		if len(vLog.Topics) < 3 || vLog.Topics[0] != d.topic {
+			continue
+		}
+		l := &seaportLog{vLog: vLog, offerer: common.BytesToAddress(vLog.Topics[1].Bytes())}
+		if err := d.abi.UnpackIntoInterface(&l.ev, "OrderFulfilled", vLog.Data); err != nil {
+			errs = append(errs, fmt.Errorf("seaport 日志解码失败 tx=%s index=%d: %w", vLog.TxHash.Hex(), vLog.Index, err))
+			continue
+		}
+		parsed = append(parsed, l)
+		byTx[vLog.TxHash] = append(byTx[vLog.TxHash], l)
+	}
+	for _, l := range parsed {
+		res, err := d.decodeLog(l, byTx[l.vLog.TxHash])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("seaport 日志解码失败 tx=%s index=%d: %w", l.vLog.TxHash.Hex(), l.vLog.Index, err))
+			continue
+		}
+		sales = append(sales, res...)
+	}
+	return sales, errors.Join(errs...)
+}
+
+// decodeLog 将一条 OrderFulfilled 映射为成交，siblings 为同一交易中的全部 OrderFulfilled（含自身）
+// recipient 为零地址（matchOrders）时，从撮合对手订单推导买家或卖家；仍无法确定时保留零地址，由上层按交易发送方补全。
+// 同一笔撮合只由以 offer 提供 NFT 的订单记录成交。支付项混用多个币种时无法给出单一价格，整条日志报错跳过
+func (d *seaportDecoder) decodeLog(l *seaportLog, siblings []*seaportLog) ([]Sale, error) {
+	ev := &l.ev
+	var nfts []seaportSpentItem
+	var seller, buyer common.Address
+	price := new(big.Int)
+	fee := new(big.Int)
+	payouts := map[common.Address]*big.Int{}
+	var currency *common.Address
+	addPayment := func(token common.Address, amount *big.Int) error {
+		if currency != nil && *currency != token {
+			return errSeaportMixedCurrency
+		}
+		currency = &token
+		price.Add(price, amount)
+		return nil
+	}
+
+	listing := false
+	if offerNFTs := seaportNFTs(ev.Offer); len(offerNFTs) > 0 {
+		// 挂单被购买：卖家为 offerer，买家为 recipient，对价全部由买家支付
+		listing = true
+		nfts = offerNFTs
+		seller, buyer = l.offerer, ev.Recipient
+		for _, item := range ev.Consideration {
+			if !seaportIsPayment(item.ItemType) {
+				continue
+			}
+			if err := addPayment(item.Token, item.Amount); err != nil {
+				return nil, err
+			}
+			switch {
+			case item.Recipient == seller:
+			case d.isFeeRecipient(item.Recipient):
+				fee.Add(fee, item.Amount)
+			default:
+				addPayout(payouts, item.Recipient, item.Amount)
+			}
+		}
+	} else {
+		// 出价被接受：买家为 offerer，卖家为 recipient，价格为出价金额，手续费和版税从中扣除
+		for _, item := range ev.Consideration {
+			if seaportIsNFT(item.ItemType) {
+				nfts = append(nfts, seaportSpentItem{ItemType: item.ItemType, Token: item.Token, Identifier: item.Identifier, Amount: item.Amount})
+			}
+		}
+		if len(nfts) == 0 {
+			// 纯支付订单（如 matchOrders 的另一侧），不构成成交
+			return nil, nil
+		}
+		buyer, seller = l.offerer, ev.Recipient
+		for _, item := range ev.Offer {
+			if seaportIsPayment(item.ItemType) {
+				if err := addPayment(item.Token, item.Amount); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, item := range ev.Consideration {
+			if !seaportIsPayment(item.ItemType) || item.Recipient == seller {
+				continue
+			}
+			if currency != nil && item.Token != *currency {
+				return nil, errSeaportMixedCurrency
+			}
+			if d.isFeeRecipient(item.Recipient) {
+				fee.Add(fee, item.Amount)
+			} else {
+				addPayout(payouts, item.Recipient, item.Amount)
+			}
+		}
+	}
+	if currency == nil {
+		currency = &common.Address{}
+	}
+
+	// 批量成交时按 NFT 数量均分价格
+	n := big.NewInt(int64(len(nfts)))
+	sales := make([]Sale, 0, len(nfts))
+	for _, nft := range nfts {
+		nftBuyer, nftSeller := buyer, seller
+		if (common.Address{}) == ev.Recipient {
+			if listing {
+				nftBuyer = seaportNFTReceiver(nft, l, siblings)
+			} else if nftSeller = seaportNFTSender(nft, l, siblings); nftSeller != (common.Address{}) {
+				// 对手订单以 offer 提供该 NFT，成交由对手订单一侧记录，避免重复
+				continue
+			}
+		}
+		sale := d.newSale(ProtocolSeaport, l.vLog)
+		sale.OrderHash = common.BytesToHash(ev.OrderHash[:]).Hex()
+		sale.Collection = nft.Token.Hex()
+		sale.TokenID = TokenIDHex(nft.Identifier)
+		sale.Amount = nft.Amount
+		sale.Seller = nftSeller.Hex()
+		sale.Buyer = nftBuyer.Hex()
+		sale.Currency = currency.Hex()
+		sale.Price = new(big.Int).Div(price, n)
+		sale.Fee = new(big.Int).Div(fee, n)
+		for recipient, amount := range payouts {
+			sale.Payouts[recipient] = new(big.Int).Div(amount, n)
+		}
+		sales = append(sales, sale)
+	}
+	return sales, nil
+}
+
+// seaportNFTReceiver 挂单经 matchOrders 成交时，在对手订单的 consideration 中查找该 NFT 的接收方
+func seaportNFTReceiver(nft seaportSpentItem, self *seaportLog, siblings []*seaportLog) common.Address {
+	for _, other := range siblings {
+		if other == self {
+			continue
+		}
+		for _, item := range other.ev.Consideration {
+			if seaportIsNFT(item.ItemType) && item.Token == nft.Token && item.Identifier.Cmp(nft.Identifier) == 0 &&
+				item.Recipient != (common.Address{}) {
+				return item.Recipient
+			}
+		}
+	}
+	return common.Address{}
+}
+
+// seaportNFTSender 出价经 matchOrders 成交时，在对手订单的 offer 中查找提供该 NFT 的 offerer
+func seaportNFTSender(nft seaportSpentItem, self *seaportLog, siblings []*seaportLog) common.Address {
+	for _, other := range siblings {
+		if other == self {
+			continue
+		}
+		for _, item := range other.ev.Offer {
+			if seaportIsNFT(item.ItemType) && item.Token == nft.Token && item.Identifier.Cmp(nft.Identifier) == 0 {
+				return other.offerer
+			}
+		}
+	}
+	return common.Address{}
+}
+
+func seaportNFTs(items []seaportSpentItem) []seaportSpentItem {
+	var nfts []seaportSpentItem
+	for _, item := range items {
+		if seaportIsNFT(item.ItemType) {
+			nfts = append(nfts, item)
+		}
+	}
+	return nfts
+}
+
+func seaportIsNFT(itemType uint8) bool {
+	switch itemType {
+	case seaportItemERC721, seaportItemERC1155, seaportItemERC721WithCriteria, seaportItemERC1155WithCriteria:
+		return true
+	}
+	return false
+}
+
+func seaportIsPayment(itemType uint8) bool {
+	return itemType == seaportItemNative || itemType == seaportItemERC20
+}
@@ -0,0 +1,90 @@
+// Package bus 消息总线抽象，后端可选 Kafka、Redis Streams 与进程内通道，
+// 本地开发与测试无需外部 broker 即可跑通同步 -> 地板价全链路
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+// 后端类型
+const (
+	BackendKafka  = "kafka"
+	BackendRedis  = "redis"
+	BackendMemory = "memory"
+)
+
+var ErrUnknownBackend = errors.New("未知的消息总线后端")
+
+// Message 总线消息，相同 Key 的消息保证有序（Kafka 按 key 分区，其余后端单分区）
+type Message struct {
+	Topic   string
+	Key     string
+	Value   []byte
+	Headers map[string]string
+
+	ack func()
+}
+
+// Ack 确认消息已处理，至少一次语义：未确认的消息在重启或再均衡后重新投递
+func (m *Message) Ack() {
+	if m.ack != nil {
+		m.ack()
+	}
+}
+
+// Publisher 消息发布者
+type Publisher interface {
+	Publish(ctx context.Context, msg *Message) error
+	Close() error
+}
+
+// Handler 处理一个有序单元（Kafka 分区、Redis Stream）的消息，msgs 关闭表示该单元被回收，
+// 此时应处理完已收到的消息并确认后返回
+type Handler func(ctx context.Context, msgs <-chan *Message)
+
+// Subscriber 消息订阅者，同一消费组内的多个实例分摊消息
+type Subscriber interface {
+	// Subscribe 阻塞消费 topic 直到 ctx 结束，后端不可用时持续重试
+	Subscribe(ctx context.Context, topic string, handler Handler) error
+	Close() error
+}
+
+// Options 总线配置
+type Options struct {
+	Backend       string
+	Brokers       []string      // Kafka
+	GroupID       string        // 消费组
+	Consumer      string        // Redis 消费者名，须在重启间保持不变，默认主机名
+	ClaimIdle     time.Duration // Redis 认领其他消费者空闲待确认消息的阈值，默认 1 分钟
+	InitialOffset string        // Kafka 消费组无已提交位点时的起点：oldest / newest
+	Redis         *redis.Client // Redis Streams
+	StreamMaxLen  int64         // Redis Stream 近似保留条数
+	BufferSize    int           // 进程内通道与投递队列长度
+}
+
+// New 按配置创建发布者与订阅者，创建时不连接 broker，不可用时在使用时重试
+func New(opts Options) (Publisher, Subscriber, error) {
+	if opts.GroupID == "" {
+		opts.GroupID = "nftsync"
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 256
+	}
+	switch opts.Backend {
+	case "", BackendKafka:
+		return NewKafkaPublisher(opts.Brokers), NewKafkaSubscriber(opts.Brokers, opts.GroupID, opts.InitialOffset, opts.BufferSize), nil
+	case BackendRedis:
+		if opts.Redis == nil {
+			return nil, nil, fmt.Errorf("%w: redis 后端缺少 Redis 客户端", ErrUnknownBackend)
+		}
+		return NewRedisPublisher(opts.Redis, opts.StreamMaxLen), NewRedisSubscriber(opts.Redis, opts.GroupID, opts.Consumer, opts.ClaimIdle, opts.BufferSize), nil
+	case BackendMemory:
+		m := NewMemory(opts.BufferSize)
+		return m, m, nil
+	}
+	return nil, nil, fmt.Errorf("%w: %s", ErrUnknownBackend, opts.Backend)
+}
@@ -0,0 +1,236 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"github.com/IBM/sarama"
+	"log"
+	"sync"
+	"time"
+)
+
+const kafkaRetryInterval = 5 * time.Second
+
+// KafkaPublisher 按消息 key 哈希分区，同一 key 进入同一分区；首次发布时连接 broker，失败时下次发布重试
+type KafkaPublisher struct {
+	brokers  []string
+	mu       sync.Mutex
+	producer sarama.SyncProducer
+}
+
+func NewKafkaPublisher(brokers []string) *KafkaPublisher {
+	return &KafkaPublisher{brokers: brokers}
+}
+
+func (p *KafkaPublisher) get() (sarama.SyncProducer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.producer != nil {
+		return p.producer, nil
+	}
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	config.Producer.Partitioner = sarama.NewHashPartitioner
+	producer, err := sarama.NewSyncProducer(p.brokers, config)
+	if err != nil {
+		return nil, err
+	}
+	p.producer = producer
+	return producer, nil
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, msg *Message) error {
+	producer, err := p.get()
+	if err != nil {
+		return err
+	}
+	pm := &sarama.ProducerMessage{
+		Topic: msg.Topic,
+		Value: sarama.ByteEncoder(msg.Value),
+	}
+	if msg.Key != "" {
+		pm.Key = sarama.StringEncoder(msg.Key)
+	}
+	for k, v := range msg.Headers {
+		pm.Headers = append(pm.Headers, sarama.RecordHeader{Key: []byte(k), Value: []byte(v)})
+	}
+	_, _, err = producer.SendMessage(pm)
+	return err
+}
+
+func (p *KafkaPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.producer == nil {
+		return nil
+	}
+	err := p.producer.Close()
+	p.producer = nil
+	return err
+}
+
+// KafkaSubscriber 消费者组：覆盖全部分区，实例增减时自动再均衡，确认后提交位点
+type KafkaSubscriber struct {
+	brokers       []string
+	groupID       string
+	initialOffset string
+	bufferSize    int
+	mu            sync.Mutex
+	group         sarama.ConsumerGroup
+}
+
+func NewKafkaSubscriber(brokers []string, groupID, initialOffset string, bufferSize int) *KafkaSubscriber {
+	return &KafkaSubscriber{brokers: brokers, groupID: groupID, initialOffset: initialOffset, bufferSize: bufferSize}
+}
+
+func (s *KafkaSubscriber) connect() (sarama.ConsumerGroup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.group != nil {
+		return s.group, nil
+	}
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+	config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategySticky()}
+	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	if s.initialOffset == "oldest" {
+		config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	}
+	group, err := sarama.NewConsumerGroup(s.brokers, s.groupID, config)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for err := range group.Errors() {
+			log.Printf("[bus] Kafka消费错误: %v", err)
+		}
+	}()
+	s.group = group
+	return group, nil
+}
+
+func (s *KafkaSubscriber) Subscribe(ctx context.Context, topic string, handler Handler) error {
+	h := &kafkaGroupHandler{handler: handler, bufferSize: s.bufferSize}
+	for ctx.Err() == nil {
+		group, err := s.connect()
+		if err != nil {
+			log.Printf("[bus] Kafka消费者组连接失败，稍后重试: %v", err)
+			sleepCtx(ctx, kafkaRetryInterval)
+			continue
+		}
+		// Consume 在再均衡时返回，需要循环调用以获取新的分区分配
+		if err := group.Consume(ctx, []string{topic}, h); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return err
+			}
+			log.Printf("[bus] 消费者组异常: %v", err)
+			sleepCtx(ctx, time.Second)
+		}
+	}
+	return nil
+}
+
+func (s *KafkaSubscriber) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.group == nil {
+		return nil
+	}
+	return s.group.Close()
+}
+
+// kafkaGroupHandler 每个分区一个 ConsumeClaim 协程，分区内消息交给 Handler，按确认进度提交位点
+type kafkaGroupHandler struct {
+	handler    Handler
+	bufferSize int
+}
+
+func (h *kafkaGroupHandler) Setup(sess sarama.ConsumerGroupSession) error {
+	log.Printf("[bus] 分区分配: %v", sess.Claims())
+	return nil
+}
+
+func (h *kafkaGroupHandler) Cleanup(sess sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *kafkaGroupHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	tracker := &offsetTracker{}
+	msgs := make(chan *Message, h.bufferSize)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.handler(sess.Context(), msgs)
+	}()
+	defer func() {
+		// 分区被回收：等待 Handler 处理完已收到的消息，确认的位点随会话结束提交
+		close(msgs)
+		<-done
+	}()
+	for {
+		select {
+		case cm, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			msg := &Message{Topic: cm.Topic, Key: string(cm.Key), Value: cm.Value, Headers: map[string]string{}}
+			for _, header := range cm.Headers {
+				msg.Headers[string(header.Key)] = string(header.Value)
+			}
+			offset := cm.Offset
+			tracker.deliver(offset)
+			msg.ack = func() {
+				if next, ok := tracker.ack(offset); ok {
+					sess.MarkOffset(claim.Topic(), claim.Partition(), next, "")
+				}
+			}
+			select {
+			case msgs <- msg:
+			case <-sess.Context().Done():
+				return nil
+			}
+		case <-sess.Context().Done():
+			return nil
+		}
+	}
+}
+
+// offsetTracker 分区内的确认进度：Handler 可乱序确认（如按合集合并），
+// 提交位点只推进到连续已确认的最后一条之后，未处理的消息重启后会重新消费
+type offsetTracker struct {
+	mu        sync.Mutex
+	delivered []int64 // 已投递未提交的 offset，递增
+	acked     map[int64]bool
+}
+
+func (t *offsetTracker) deliver(offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.delivered = append(t.delivered, offset)
+}
+
+// ack 返回可提交的下一个 offset 及是否推进
+func (t *offsetTracker) ack(offset int64) (int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.acked == nil {
+		t.acked = map[int64]bool{}
+	}
+	t.acked[offset] = true
+	var next int64
+	advanced := false
+	for len(t.delivered) > 0 && t.acked[t.delivered[0]] {
+		delete(t.acked, t.delivered[0])
+		next = t.delivered[0] + 1
+		t.delivered = t.delivered[1:]
+		advanced = true
+	}
+	return next, advanced
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+	}
+}
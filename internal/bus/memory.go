@@ -0,0 +1,103 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrQueueFull 进程内队列已满，订阅者消费跟不上；发布方按失败处理并稍后重试
+var ErrQueueFull = errors.New("消息队列已满")
+
+// Memory 进程内消息总线，同时实现 Publisher 与 Subscriber，用于单进程部署与测试。
+// 每个 topic 一个有界队列，订阅前发布的消息会暂存，同一 topic 的多个订阅者竞争消费。
+// 发布从不阻塞：没有订阅者的 topic 写满后丢弃最旧的消息，有订阅者时返回 ErrQueueFull；
+// 进程退出时未消费的消息丢失
+type Memory struct {
+	mu          sync.Mutex
+	size        int
+	topics      map[string]chan *Message
+	subscribers map[string]int
+}
+
+func NewMemory(bufferSize int) *Memory {
+	if bufferSize <= 0 {
+		bufferSize = 256
+	}
+	return &Memory{size: bufferSize, topics: map[string]chan *Message{}, subscribers: map[string]int{}}
+}
+
+// queue 需持有 m.mu
+func (m *Memory) queue(topic string) chan *Message {
+	q := m.topics[topic]
+	if q == nil {
+		q = make(chan *Message, m.size)
+		m.topics[topic] = q
+	}
+	return q
+}
+
+func (m *Memory) Publish(ctx context.Context, msg *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	copied := *msg
+	copied.ack = nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q := m.queue(msg.Topic)
+	for {
+		select {
+		case q <- &copied:
+			return nil
+		default:
+		}
+		if m.subscribers[msg.Topic] > 0 {
+			return ErrQueueFull
+		}
+		// 无人订阅：丢弃最旧的消息，保留最近 size 条供之后的订阅者消费
+		select {
+		case <-q:
+		default:
+		}
+	}
+}
+
+func (m *Memory) Subscribe(ctx context.Context, topic string, handler Handler) error {
+	m.mu.Lock()
+	q := m.queue(topic)
+	m.subscribers[topic]++
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.subscribers[topic]--
+		m.mu.Unlock()
+	}()
+
+	msgs := make(chan *Message)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler(ctx, msgs)
+	}()
+	defer func() {
+		close(msgs)
+		<-done
+	}()
+	for {
+		select {
+		case msg := <-q:
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				return nil
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,71 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryPublishWithoutSubscriberDropsOldest(t *testing.T) {
+	m := NewMemory(2)
+	ctx := context.Background()
+	for _, v := range []string{"a", "b", "c"} {
+		if err := m.Publish(ctx, &Message{Topic: "t", Key: v, Value: []byte(v)}); err != nil {
+			t.Fatalf("publish %s: %v", v, err)
+		}
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	got := make(chan string, 3)
+	go m.Subscribe(subCtx, "t", func(ctx context.Context, msgs <-chan *Message) {
+		for msg := range msgs {
+			got <- string(msg.Value)
+			msg.Ack()
+		}
+	})
+	for _, want := range []string{"b", "c"} {
+		select {
+		case v := <-got:
+			if v != want {
+				t.Fatalf("got %s, want %s", v, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %s", want)
+		}
+	}
+}
+
+func TestMemoryPublishQueueFullWithSubscriber(t *testing.T) {
+	m := NewMemory(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	block := make(chan struct{})
+	defer close(block)
+	received := make(chan struct{}, 1)
+	go m.Subscribe(ctx, "t", func(ctx context.Context, msgs <-chan *Message) {
+		for range msgs {
+			received <- struct{}{}
+			<-block
+		}
+	})
+
+	// 订阅者阻塞在第一条消息上，之后一条占满队列、一条转发中，再发布应立即返回 ErrQueueFull
+	var err error
+	deadline := time.After(time.Second)
+	for i := 0; err == nil; i++ {
+		select {
+		case <-deadline:
+			t.Fatal("publish never reported a full queue")
+		default:
+		}
+		err = m.Publish(ctx, &Message{Topic: "t", Value: []byte{byte(i)}})
+		if i == 0 {
+			<-received
+		}
+	}
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("err = %v, want ErrQueueFull", err)
+	}
+}
@@ -0,0 +1,220 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	redisReadCount = 100
+	redisReadBlock = 2 * time.Second
+	// redisClaimIdle 默认认领阈值：其他消费者超过该时长未确认的消息转由当前消费者处理
+	redisClaimIdle = time.Minute
+)
+
+// Stream 条目字段
+const (
+	fieldKey     = "key"
+	fieldValue   = "value"
+	fieldHeaders = "headers"
+)
+
+// RedisPublisher 基于 Redis Streams 发布，每个 topic 一个 stream，按 MAXLEN 近似裁剪
+type RedisPublisher struct {
+	client *redis.Client
+	maxLen int64
+}
+
+func NewRedisPublisher(client *redis.Client, maxLen int64) *RedisPublisher {
+	if maxLen <= 0 {
+		maxLen = 100000
+	}
+	return &RedisPublisher{client: client, maxLen: maxLen}
+}
+
+func (p *RedisPublisher) Publish(ctx context.Context, msg *Message) error {
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return err
+	}
+	return p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: msg.Topic,
+		MaxLen: p.maxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			fieldKey:     msg.Key,
+			fieldValue:   msg.Value,
+			fieldHeaders: string(headers),
+		},
+	}).Err()
+}
+
+func (p *RedisPublisher) Close() error {
+	return nil
+}
+
+// RedisSubscriber 基于 Redis Streams 消费组，确认后 XACK。消费者名固定（默认主机名），
+// 重启后先重新投递本消费者未确认的消息；并定期用 XAUTOCLAIM 认领空闲超过 claimIdle 的待确认消息，
+// 已下线或改名的消费者遗留的消息不会永久挂起。stream 不按 key 分区，多实例时同一 key 的顺序不保证，
+// 需要严格有序时每个消费组只部署一个实例
+type RedisSubscriber struct {
+	client     *redis.Client
+	group      string
+	consumer   string
+	claimIdle  time.Duration
+	bufferSize int
+}
+
+// NewRedisSubscriber consumer 为空时使用主机名，claimIdle 不大于 0 时默认 1 分钟
+func NewRedisSubscriber(client *redis.Client, group, consumer string, claimIdle time.Duration, bufferSize int) *RedisSubscriber {
+	if consumer == "" {
+		consumer, _ = os.Hostname()
+	}
+	if consumer == "" {
+		consumer = group
+	}
+	if claimIdle <= 0 {
+		claimIdle = redisClaimIdle
+	}
+	return &RedisSubscriber{
+		client:     client,
+		group:      group,
+		consumer:   consumer,
+		claimIdle:  claimIdle,
+		bufferSize: bufferSize,
+	}
+}
+
+func (s *RedisSubscriber) Subscribe(ctx context.Context, topic string, handler Handler) error {
+	msgs := make(chan *Message, s.bufferSize)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler(ctx, msgs)
+	}()
+	defer func() {
+		close(msgs)
+		<-done
+	}()
+	deliver := func(entries []redis.XMessage) bool {
+		for _, entry := range entries {
+			select {
+			case msgs <- s.toMessage(topic, entry):
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	}
+	ready := false
+	start := "0" // 先读取本消费者未确认的消息，读完后切换为 ">" 读取新消息
+	var lastClaim time.Time
+	for ctx.Err() == nil {
+		if !ready {
+			err := s.client.XGroupCreateMkStream(ctx, topic, s.group, "$").Err()
+			if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+				log.Printf("[bus] Redis 消费组创建失败，稍后重试: %v", err)
+				sleepCtx(ctx, kafkaRetryInterval)
+				continue
+			}
+			ready = true
+		}
+		if start == ">" && time.Since(lastClaim) >= s.claimIdle {
+			lastClaim = time.Now()
+			if !deliver(s.claimIdleEntries(ctx, topic)) {
+				return nil
+			}
+		}
+		streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: s.consumer,
+			Streams:  []string{topic, start},
+			Count:    redisReadCount,
+			Block:    redisReadBlock,
+		}).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[bus] Redis Stream 读取失败: %v", err)
+				sleepCtx(ctx, time.Second)
+			}
+			continue
+		}
+		count := 0
+		for _, stream := range streams {
+			count += len(stream.Messages)
+			if start != ">" && len(stream.Messages) > 0 {
+				start = stream.Messages[len(stream.Messages)-1].ID
+			}
+			if !deliver(stream.Messages) {
+				return nil
+			}
+		}
+		if start != ">" && count == 0 {
+			start = ">"
+		}
+	}
+	return nil
+}
+
+// claimIdleEntries 认领消费组内空闲超过 claimIdle 的待确认消息，包括已下线消费者遗留的消息
+func (s *RedisSubscriber) claimIdleEntries(ctx context.Context, topic string) []redis.XMessage {
+	var claimed []redis.XMessage
+	cursor := "0-0"
+	for {
+		entries, next, err := s.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   topic,
+			Group:    s.group,
+			Consumer: s.consumer,
+			MinIdle:  s.claimIdle,
+			Start:    cursor,
+			Count:    redisReadCount,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[bus] Redis 待确认消息认领失败: topic=%s, err=%v", topic, err)
+			}
+			return claimed
+		}
+		claimed = append(claimed, entries...)
+		if next == "" || next == "0-0" {
+			break
+		}
+		cursor = next
+	}
+	if len(claimed) > 0 {
+		log.Printf("[bus] 认领空闲待确认消息: topic=%s, consumer=%s, count=%d", topic, s.consumer, len(claimed))
+	}
+	return claimed
+}
+
+func (s *RedisSubscriber) toMessage(topic string, entry redis.XMessage) *Message {
+	msg := &Message{Topic: topic, Headers: map[string]string{}}
+	if v, ok := entry.Values[fieldKey].(string); ok {
+		msg.Key = v
+	}
+	if v, ok := entry.Values[fieldValue].(string); ok {
+		msg.Value = []byte(v)
+	}
+	if v, ok := entry.Values[fieldHeaders].(string); ok && v != "" {
+		_ = json.Unmarshal([]byte(v), &msg.Headers)
+	}
+	id := entry.ID
+	msg.ack = func() {
+		if err := s.client.XAck(context.Background(), topic, s.group, id).Err(); err != nil {
+			log.Printf("[bus] Redis Stream 确认失败: id=%s, err=%v", id, err)
+		}
+	}
+	return msg
+}
+
+func (s *RedisSubscriber) Close() error {
+	return nil
+}
@@ -10,10 +10,13 @@ type NodeConfig struct {
 	URL  string `yaml:"url"`
 }
 type SyncConfig struct {
-	RealtimeInterval int `yaml:"realtime_interval"`
-	PollingInterval  int `yaml:"polling_interval"`
-	ConfirmBlocks    int `yaml:"confirm_blocks"`
-	OrderInterval    int `yaml:"order_interval"`
+	RealtimeInterval int   `yaml:"realtime_interval"`
+	PollingInterval  int   `yaml:"polling_interval"`
+	ConfirmBlocks    int   `yaml:"confirm_blocks"`
+	OrderInterval    int   `yaml:"order_interval"`
+	ValidateInterval int   `yaml:"validate_interval"`
+	StartBlock       int64 `yaml:"start_block"`     // 市场成交首次同步的起始区块，为 0 时从当前安全区块开始
+	MaxBlockRange    int64 `yaml:"max_block_range"` // 单次 eth_getLogs 的最大区块跨度，默认 2000
 }
 type RedisConfig struct {
 	Addr     string `yaml:"addr"`
@@ -29,18 +32,199 @@ type AppConfig struct {
 	Sync            SyncConfig            `yaml:"sync"`
 	Redis           RedisConfig           `yaml:"redis"`
 	FloorPriceKafka FloorPriceKafkaConfig `yaml:"floor_price_kafka"`
+	Marketplaces    []MarketplaceConfig   `yaml:"marketplaces"`
+	WETHAddress     string                `yaml:"weth_address"` // 推导成交时识别 WETH 支付
+	Pricing         PricingConfig         `yaml:"pricing"`
+	OrderSigning    OrderSigningConfig    `yaml:"order_signing"`
+	Matching        MatchingConfig        `yaml:"matching"`
+	Royalties       []RoyaltyOverride     `yaml:"royalties"` // 版税覆盖登记，合约未实现 ERC-2981 时使用
+	Analytics       AnalyticsConfig       `yaml:"analytics"`
+	WashTrading     WashTradingConfig     `yaml:"wash_trading"`
+	Push            PushConfig            `yaml:"push"`
+	Notify          NotifyConfig          `yaml:"notify"`
+	Outbox          OutboxConfig          `yaml:"outbox"`
+	Events          EventsConfig          `yaml:"events"`
+	Bus             BusConfig             `yaml:"bus"`
+	Auth            AuthConfig            `yaml:"auth"`
+	APIKeys         APIKeyConfig          `yaml:"api_keys"`
+	RateLimit       RateLimitConfig       `yaml:"rate_limit"`
 }
 
+// NotifyConfig 外部通知配置，webhook 投递参数为 0 时使用默认值
 type NotifyConfig struct {
-	WebhookURL string `yaml:"webhook_url"`
-	MQTopic    string `yaml:"mq_topic"`
+	WebhookURL    string `yaml:"webhook_url"`    // 系统级 webhook，接收全部事件，为空不注册
+	WebhookSecret string `yaml:"webhook_secret"` // 系统级 webhook 签名密钥
+	MQTopic       string `yaml:"mq_topic"`       // 事件通知 topic，经发件箱发布到消息总线，消息体与 webhook 一致，为空不发布
+	Interval      int    `yaml:"interval"`       // 事件扫描与投递周期（秒），默认 5
+	Timeout       int    `yaml:"timeout"`        // 单次投递超时（秒），默认 10
+	MaxAttempts   int    `yaml:"max_attempts"`   // 单条投递最大尝试次数，默认 8
+	BackoffBase   int    `yaml:"backoff_base"`   // 首次重试间隔（秒），之后指数增长，默认 10
+	BackoffMax    int    `yaml:"backoff_max"`    // 重试间隔上限（秒），默认 3600
+	DisableAfter  int    `yaml:"disable_after"`  // endpoint 连续失败次数达到该值时自动停用，默认 20
+	Retention     int64  `yaml:"retention"`      // 已投递记录保留时长（秒），默认 7 天
 }
 
+// FloorPriceKafkaConfig 地板价消息配置，为 0 的项使用默认值；topic 与消费组对所有总线后端生效
 type FloorPriceKafkaConfig struct {
-	Brokers []string `yaml:"brokers"`
-	Topic   string   `yaml:"topic"`
+	Brokers       []string `yaml:"brokers"`
+	Topic         string   `yaml:"topic"`
+	GroupID       string   `yaml:"group_id"`       // 消费者组，默认 nftsync-floor-price
+	InitialOffset string   `yaml:"initial_offset"` // 消费者组无已提交位点时的起点：oldest / newest，默认 newest
+	Debounce      int      `yaml:"debounce"`       // 同一合集静默多久后重算（毫秒），默认 500
+	MaxDelay      int      `yaml:"max_delay"`      // 持续有更新时最长延迟（毫秒），默认 5000
 }
 
+// MarketplaceConfig 公开市场配置，protocol 决定使用的事件解码器
+type MarketplaceConfig struct {
+	Name          string   `yaml:"name"`
+	Protocol      string   `yaml:"protocol"` // seaport / blur / looksrare
+	Contracts     []string `yaml:"contracts"`
+	FeeRecipients []string `yaml:"fee_recipients"` // 市场手续费收款地址
+	FeeBps        int64    `yaml:"fee_bps"`        // 协议手续费（万分比），事件不含手续费明细时使用
+}
+
+// PricingConfig 计价配置，地板价与成交量统一折算为 base_currency
+type PricingConfig struct {
+	BaseCurrency string            `yaml:"base_currency"` // 基础币种地址，ETH 为零地址
+	Feed         string            `yaml:"feed"`          // static / file
+	Rates        map[string]string `yaml:"rates"`         // static 汇率：币种地址 -> 1单位折合基础币种
+	RatesFile    string            `yaml:"rates_file"`    // file 汇率文件路径
+}
+
+// OrderSigningConfig 链下签名订单的 EIP-712 域配置
+type OrderSigningConfig struct {
+	Name     string `yaml:"name"`
+	Version  string `yaml:"version"`
+	ChainID  int64  `yaml:"chain_id"`
+	Exchange string `yaml:"exchange"` // 交易合约地址，即 verifyingContract 与授权校验的 operator
+}
+
+// MatchingConfig 撮合引擎配置
+type MatchingConfig struct {
+	Enabled           bool             `yaml:"enabled"`
+	Interval          int              `yaml:"interval"` // 撮合周期（秒）
+	Topic             string           `yaml:"topic"`    // 撮合结果 topic
+	ProtocolFeeBps    int64            `yaml:"protocol_fee_bps"`
+	DefaultRoyaltyBps int64            `yaml:"default_royalty_bps"`
+	RoyaltyBps        map[string]int64 `yaml:"royalty_bps"` // 合集地址 -> 版税万分比
+}
+
+// RoyaltyOverride 合集版税登记
+type RoyaltyOverride struct {
+	Collection string `yaml:"collection"`
+	Receiver   string `yaml:"receiver"`
+	Bps        int64  `yaml:"bps"`      // 版税万分比
+	Override   bool   `yaml:"override"` // 为 true 时优先于 ERC-2981
+}
+
+// AnalyticsConfig 成交统计汇总配置
+type AnalyticsConfig struct {
+	Interval       int  `yaml:"interval"`        // 汇总重算周期（秒），0 表示使用默认 300 秒
+	IncludeFlagged bool `yaml:"include_flagged"` // 是否计入被标记为可疑的成交，默认排除
+}
+
+// WashTradingConfig 刷量检测配置，阈值为 0 时使用默认值
+type WashTradingConfig struct {
+	Enabled          bool     `yaml:"enabled"`
+	Interval         int      `yaml:"interval"`           // 检测周期（秒）
+	Lookback         int64    `yaml:"lookback"`           // 每轮检测的成交时间范围（秒）
+	FundingHops      int      `yaml:"funding_hops"`       // 资金来源追溯层数
+	MaxFunderFanout  int64    `yaml:"max_funder_fanout"`  // 转出对象超过该数量的地址视为公共资金源（交易所等），不参与共同资金判定
+	IgnoreFunders    []string `yaml:"ignore_funders"`     // 不参与共同资金判定的地址
+	FundingMaxBlocks int64    `yaml:"funding_max_blocks"` // 资金转账索引每轮最多处理的区块数
+	RoundTripWindow  int64    `yaml:"round_trip_window"`  // 循环成交判定时间窗口（秒）
+	MaxLoopAddresses int      `yaml:"max_loop_addresses"` // 循环涉及地址数不超过该值时标记
+	DeviationHigh    float64  `yaml:"deviation_high"`     // 成交价高于地板价的倍数阈值
+	DeviationLow     float64  `yaml:"deviation_low"`      // 成交价低于地板价的比例阈值
+	FlipSeconds      int64    `yaml:"flip_seconds"`       // 买入后转手的时间阈值（秒）
+}
+
+// PushConfig 实时推送配置，多个 API 实例经 Redis pub/sub 扇出，为 0 的项使用默认值
+type PushConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	Channel      string `yaml:"channel"`       // Redis 频道，默认 nftsync:push
+	HistorySize  int    `yaml:"history_size"`  // 供断线续传保留的最近事件数，默认 1000
+	SendBuffer   int    `yaml:"send_buffer"`   // 每个连接的发送队列长度，写满视为慢消费者并断开，默认 256
+	PingInterval int    `yaml:"ping_interval"` // 心跳间隔（秒），默认 25
+}
+
+// OutboxConfig Kafka 发件箱中继配置，为 0 的项使用默认值
+type OutboxConfig struct {
+	Interval  int   `yaml:"interval"`   // 轮询周期（毫秒），默认 500
+	BatchSize int   `yaml:"batch_size"` // 每轮发布条数上限，默认 500
+	LockTTL   int   `yaml:"lock_ttl"`   // 中继租约（秒），同一时刻仅一个实例发布以保证顺序，默认 30
+	Retention int64 `yaml:"retention"`  // 已发布消息保留时长（秒），默认 1 天
+}
+
+// AuthConfig 访问令牌配置：HS256 使用 secret，RS256/EdDSA 使用私钥并通过 /.well-known/jwks.json 公开公钥
+type AuthConfig struct {
+	Algorithm      string     `yaml:"algorithm"`        // HS256 / RS256 / EdDSA，默认 HS256
+	Secret         string     `yaml:"secret"`           // HS256 密钥，至少 32 字节；环境变量 NFTSYNC_AUTH_SECRET 优先
+	PrivateKeyFile string     `yaml:"private_key_file"` // RS256 / EdDSA 私钥 PEM
+	KeyID          string     `yaml:"key_id"`           // JWKS kid
+	Issuer         string     `yaml:"issuer"`
+	Audience       string     `yaml:"audience"`
+	AccessTTL      int        `yaml:"access_ttl"`  // 访问令牌有效期（秒），默认 900
+	RefreshTTL     int        `yaml:"refresh_ttl"` // 刷新令牌有效期（秒），默认 30 天
+	Leeway         int        `yaml:"leeway"`      // 时间校验允许的时钟偏差（秒），默认 30
+	SIWE           SIWEConfig `yaml:"siwe"`
+}
+
+// SIWEConfig Sign-In with Ethereum（EIP-4361）登录配置
+type SIWEConfig struct {
+	Domains  []string `yaml:"domains"`   // 允许的消息 domain，为空时拒绝 SIWE 登录
+	ChainID  int64    `yaml:"chain_id"`  // 为 0 时使用 order_signing.chain_id
+	NonceTTL int      `yaml:"nonce_ttl"` // nonce 有效期（秒），默认 300
+	MaxAge   int      `yaml:"max_age"`   // issued-at 距今最长时长（秒），默认与 nonce_ttl 相同
+}
+
+// APIKeyConfig 机器客户端 API Key 配置，为 0 的项使用默认值
+type APIKeyConfig struct {
+	RateLimit      int `yaml:"rate_limit"`      // 默认每分钟请求数，默认 60；普通用户创建的 Key 不能超过该值
+	DailyQuota     int `yaml:"daily_quota"`     // 默认每日请求数，默认 10000；普通用户创建的 Key 不能超过该值
+	MaxPerUser     int `yaml:"max_per_user"`    // 每个用户有效 Key 上限，默认 10
+	CacheTTL       int `yaml:"cache_ttl"`       // Key 校验结果缓存（秒），默认 60，吊销时立即清除
+	UsageRetention int `yaml:"usage_retention"` // 用量统计保留天数，默认 30
+}
+
+// RateLimitConfig 接口限流配置（GCRA），按路由分组生效，未单独配置的分组使用 default
+type RateLimitConfig struct {
+	Enabled bool                     `yaml:"enabled"`
+	Default RateLimitRule            `yaml:"default"`
+	Groups  map[string]RateLimitRule `yaml:"groups"` // 分组名见 cmd/nftSync/main.go 路由注册
+	// TrustedProxies 可信反向代理的 IP 或 CIDR，仅来自这些地址的 X-Forwarded-For 用于确定客户端 IP；
+	// 为空时不信任任何转发头，按 IP 限流使用连接来源地址
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// RateLimitRule 单个分组的限流规则
+type RateLimitRule struct {
+	Rate   int    `yaml:"rate"`   // 每个周期允许的请求数，为 0 时不限流
+	Period int    `yaml:"period"` // 周期（秒），默认 60
+	Burst  int    `yaml:"burst"`  // 突发容量，默认等于 rate
+	KeyBy  string `yaml:"key_by"` // ip / user / api_key / auto，默认 auto：API Key > 用户 > IP
+}
+
+// BusConfig 消息总线配置，Kafka 连接与消费组参数沿用 floor_price_kafka
+type BusConfig struct {
+	Backend      string `yaml:"backend"`        // kafka / redis / memory，默认 kafka；memory 仅限单进程部署与测试
+	StreamMaxLen int64  `yaml:"stream_max_len"` // redis: 每个 stream 近似保留条数，默认 100000
+	Consumer     string `yaml:"consumer"`       // redis: 消费者名，须在重启间保持不变，默认主机名
+	ClaimIdle    int    `yaml:"claim_idle"`     // redis: 认领空闲待确认消息的阈值（秒），默认 60
+	BufferSize   int    `yaml:"buffer_size"`    // 投递队列长度，默认 256
+}
+
+// EventsConfig 领域事件流配置，事件经 Kafka 发件箱发布，topic 默认为 nftsync.<type>.v<version>
+type EventsConfig struct {
+	Enabled  bool              `yaml:"enabled"`
+	Encoding string            `yaml:"encoding"` // json / protobuf，默认 json
+	ChainID  int64             `yaml:"chain_id"` // 为 0 时使用 order_signing.chain_id
+	Topics   map[string]string `yaml:"topics"`   // 事件类型 -> topic 覆盖
+}
+
+// EnvAuthSecret 覆盖 auth.secret 的环境变量
+const EnvAuthSecret = "NFTSYNC_AUTH_SECRET"
+
 func LoadAppConfig(path string) (*AppConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -50,5 +234,9 @@ func LoadAppConfig(path string) (*AppConfig, error) {
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, err
 	}
+	// 密钥不落配置文件：环境变量优先于 auth.secret
+	if secret := os.Getenv(EnvAuthSecret); secret != "" {
+		cfg.Auth.Secret = secret
+	}
 	return &cfg, nil
 }
@@ -1,24 +1,28 @@
 package config
 
 import (
-	"github.com/IBM/sarama"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/gavin/nftSync/internal/middleware"
+	"github.com/gavin/nftSync/internal/auth"
+	"github.com/gavin/nftSync/internal/bus"
+	"github.com/gavin/nftSync/internal/pricefeed"
 	"github.com/go-redis/redis/v8"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"time"
 )
 
 // Context 只包含基础资源，业务对象由 service 层组合
 // 生产级别建议避免 config 包依赖 dao/service
 
 type Context struct {
-	Config             *AppConfig
-	Db                 *gorm.DB
-	Redis              *redis.Client
-	MultiNode          *MultiNodeEthClient
-	FloorPriceProducer *middleware.KafkaProducer
-	FloorPriceConsumer sarama.PartitionConsumer
+	Config     *AppConfig
+	Db         *gorm.DB
+	Redis      *redis.Client
+	MultiNode  *MultiNodeEthClient
+	Publisher  bus.Publisher  // 地板价消息、领域事件与撮合结果
+	Subscriber bus.Subscriber // 地板价消息消费
+	PriceFeed  pricefeed.PriceFeed
+	Auth       *auth.Manager // 访问令牌签发与校验
 }
 
 type MultiNodeEthClient struct {
@@ -50,35 +54,71 @@ func NewContext(configPath string) (*Context, error) {
 		return nil, err
 	}
 
-	floorPriceProducer, err := middleware.NewKafkaProducer(cfg.FloorPriceKafka.Brokers, cfg.FloorPriceKafka.Topic)
+	// 消息总线：创建时不连接 broker，Kafka 不可用时不影响启动，发布与消费时重试
+	groupID := cfg.FloorPriceKafka.GroupID
+	if groupID == "" {
+		groupID = "nftsync-floor-price"
+	}
+	publisher, subscriber, err := bus.New(bus.Options{
+		Backend:       cfg.Bus.Backend,
+		Brokers:       cfg.FloorPriceKafka.Brokers,
+		GroupID:       groupID,
+		InitialOffset: cfg.FloorPriceKafka.InitialOffset,
+		Redis:         redisClient,
+		StreamMaxLen:  cfg.Bus.StreamMaxLen,
+		Consumer:      cfg.Bus.Consumer,
+		ClaimIdle:     time.Duration(cfg.Bus.ClaimIdle) * time.Second,
+		BufferSize:    cfg.Bus.BufferSize,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	config := sarama.NewConfig()
-	config.Consumer.Return.Errors = true
-	consumer, err := sarama.NewConsumer(cfg.FloorPriceKafka.Brokers, config)
+	authManager, err := auth.NewManager(auth.Options{
+		Algorithm:      cfg.Auth.Algorithm,
+		Secret:         cfg.Auth.Secret,
+		PrivateKeyFile: cfg.Auth.PrivateKeyFile,
+		KeyID:          cfg.Auth.KeyID,
+		Issuer:         cfg.Auth.Issuer,
+		Audience:       cfg.Auth.Audience,
+		AccessTTL:      time.Duration(cfg.Auth.AccessTTL) * time.Second,
+		Leeway:         time.Duration(cfg.Auth.Leeway) * time.Second,
+	}, auth.NewDenylist(redisClient))
 	if err != nil {
 		return nil, err
 	}
-	floorPriceConsumer, err := consumer.ConsumePartition(cfg.FloorPriceKafka.Topic, 0, sarama.OffsetNewest)
+
+	priceFeed, err := pricefeed.New(pricefeed.Options{
+		Kind:         cfg.Pricing.Feed,
+		BaseCurrency: cfg.Pricing.BaseCurrency,
+		Rates:        cfg.Pricing.Rates,
+		File:         cfg.Pricing.RatesFile,
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	ctx := &Context{
-		Config:             cfg,
-		Db:                 db,
-		Redis:              redisClient,
-		MultiNode:          multiNode,
-		FloorPriceProducer: floorPriceProducer,
-		FloorPriceConsumer: floorPriceConsumer,
+		Config:     cfg,
+		Db:         db,
+		Redis:      redisClient,
+		MultiNode:  multiNode,
+		Publisher:  publisher,
+		Subscriber: subscriber,
+		PriceFeed:  priceFeed,
+		Auth:       authManager,
 	}
 	return ctx, nil
 }
 
 // Close 优雅关闭资源（如 Redis），DB 由 gorm 管理
 func (c *Context) Close() {
+	if c.Publisher != nil {
+		_ = c.Publisher.Close()
+	}
+	if c.Subscriber != nil {
+		_ = c.Subscriber.Close()
+	}
 	if c.Redis != nil {
 		_ = c.Redis.Close()
 	}
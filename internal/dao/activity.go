@@ -0,0 +1,113 @@
+package dao
+
+import (
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// 活动类型
+const (
+	ActivityMint           = "mint"
+	ActivityTransfer       = "transfer"
+	ActivityBurn           = "burn"
+	ActivitySale           = "sale"
+	ActivityOrderCreated   = "order_created"
+	ActivityOrderCancelled = "order_cancelled"
+	ActivityOrderFilled    = "order_filled"
+	ActivityMetadataUpdate = "metadata_update"
+)
+
+// ActivityOffchainLogIndex 链下签名订单没有日志，使用 -1 作为日志序号，区块高度记为 0，按写入时间参与排序
+const ActivityOffchainLogIndex = -1
+
+// Activity 统一活动记录，合并转移、成交、订单与元数据变更，按区块时间、区块高度与日志序号排序
+// 以时间为首要排序键，链下签名订单活动无需依赖节点即可与链上活动交错排列
+// 转移与成交的 From/To 为卖方/买方，订单事件的 From 为挂单人
+type Activity struct {
+	ID          int64           `gorm:"primaryKey;column:id" json:"id"`
+	Type        string          `gorm:"column:type;uniqueIndex:uk_activities_event,priority:1" json:"type"`
+	Collection  string          `gorm:"column:collection;uniqueIndex:uk_activities_event,priority:4;index:idx_activities_collection" json:"collection"`
+	TokenID     string          `gorm:"column:token_id;uniqueIndex:uk_activities_event,priority:5" json:"token_id,omitempty"` // 合集出价为空
+	From        string          `gorm:"column:from_addr;index" json:"from,omitempty"`
+	To          string          `gorm:"column:to_addr;index" json:"to,omitempty"`
+	OrderID     string          `gorm:"column:order_id;uniqueIndex:uk_activities_event,priority:6" json:"order_id,omitempty"`
+	Price       decimal.Decimal `gorm:"type:varchar(78);column:price" json:"price"`
+	Currency    string          `gorm:"column:currency" json:"currency,omitempty"`
+	Marketplace string          `gorm:"column:marketplace" json:"marketplace,omitempty"`
+	TxHash      string          `gorm:"column:tx_hash;uniqueIndex:uk_activities_event,priority:2" json:"tx_hash,omitempty"`
+	LogIndex    int             `gorm:"column:log_index;uniqueIndex:uk_activities_event,priority:3;index:idx_activities_block,priority:2;index:idx_activities_time,priority:3" json:"log_index"`
+	BlockNumber uint64          `gorm:"column:block_number;index:idx_activities_block,priority:1;index:idx_activities_time,priority:2" json:"block_number"`
+	BlockTime   int64           `gorm:"column:block_time;index:idx_activities_time,priority:1" json:"block_time"` // 链下签名订单为写入时间
+	CreatedAt   time.Time       `gorm:"autoCreateTime;column:created_at" json:"-"`
+}
+
+func (Activity) TableName() string {
+	return "activities"
+}
+
+// 写入活动记录，重复事件自动跳过，返回是否为新记录
+func (r *Dao) CreateActivityIgnoreConflict(a *Activity) (bool, error) {
+	result := r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(a)
+	return result.RowsAffected > 0, result.Error
+}
+
+// 删除交易中某 token 的成交活动，事件成交替换推导成交时使用
+func (r *Dao) DeleteSaleActivities(txHash, collection, tokenID string) error {
+	return r.DB.Where("type = ? AND tx_hash = ? AND collection = ? AND token_id = ?", ActivitySale, txHash, collection, tokenID).
+		Delete(&Activity{}).Error
+}
+
+// ActivityFilter 活动查询条件，空值不过滤
+type ActivityFilter struct {
+	Collection string
+	TokenID    string
+	Wallet     string // 作为 From 或 To 出现
+	Types      []string
+}
+
+// ActivityCursor 游标位置，查询该位置之前（更早）的活动
+type ActivityCursor struct {
+	BlockTime   int64
+	BlockNumber uint64
+	LogIndex    int
+	ID          int64
+}
+
+// 按区块时间、区块高度、日志序号倒序查询活动
+func (r *Dao) ListActivities(filter ActivityFilter, cursor *ActivityCursor, limit int) ([]Activity, error) {
+	query := r.DB.Model(&Activity{})
+	if filter.Collection != "" {
+		query = query.Where("collection = ?", filter.Collection)
+	}
+	if filter.TokenID != "" {
+		query = query.Where("token_id = ?", filter.TokenID)
+	}
+	if filter.Wallet != "" {
+		query = query.Where("from_addr = ? OR to_addr = ?", filter.Wallet, filter.Wallet)
+	}
+	if len(filter.Types) > 0 {
+		query = query.Where("type IN ?", filter.Types)
+	}
+	if cursor != nil {
+		query = query.Where("block_time < ? OR (block_time = ? AND (block_number < ? OR (block_number = ? AND (log_index < ? OR (log_index = ? AND id < ?)))))",
+			cursor.BlockTime, cursor.BlockTime, cursor.BlockNumber, cursor.BlockNumber, cursor.LogIndex, cursor.LogIndex, cursor.ID)
+	}
+	var list []Activity
+	err := query.Order("block_time DESC, block_number DESC, log_index DESC, id DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// 查询 ID 之后的活动，按 ID 正序
+func (r *Dao) ListActivitiesAfterID(afterID int64, limit int) ([]Activity, error) {
+	var list []Activity
+	err := r.DB.Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// 查询活动最大 ID，无记录返回 0
+func (r *Dao) MaxActivityID() (int64, error) {
+	var id int64
+	err := r.DB.Model(&Activity{}).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
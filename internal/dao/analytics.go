@@ -0,0 +1,514 @@
+package dao
+
+import (
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
+)
+
+// GlobalCollection 全市场汇总行的合集字段
+const GlobalCollection = "*"
+
+// CollectionStats 合集成交统计汇总，按统计窗口预计算，金额已折算为基础币种
+type CollectionStats struct {
+	ID             int64           `gorm:"primaryKey;column:id" json:"-"`
+	Collection     string          `gorm:"column:collection;uniqueIndex:uk_collection_stats,priority:1" json:"collection"`
+	Window         string          `gorm:"column:window_key;uniqueIndex:uk_collection_stats,priority:2" json:"window"` // 1h / 24h / 7d / 30d / all
+	Volume         decimal.Decimal `gorm:"type:decimal(38,18);column:volume" json:"volume"`
+	Sales          int64           `gorm:"column:sales" json:"sales"`
+	AvgPrice       decimal.Decimal `gorm:"type:decimal(38,18);column:avg_price" json:"avg_price"`
+	MedianPrice    decimal.Decimal `gorm:"type:decimal(38,18);column:median_price" json:"median_price"`
+	MinPrice       decimal.Decimal `gorm:"type:decimal(38,18);column:min_price" json:"min_price"`
+	MaxPrice       decimal.Decimal `gorm:"type:decimal(38,18);column:max_price" json:"max_price"`
+	UniqueBuyers   int64           `gorm:"column:unique_buyers" json:"unique_buyers"`
+	UniqueSellers  int64           `gorm:"column:unique_sellers" json:"unique_sellers"`
+	WindowStart    int64           `gorm:"column:window_start" json:"window_start"` // 统计起始时间，all 为 0
+	LastComputedAt time.Time       `gorm:"column:last_computed_at" json:"last_computed_at"`
+}
+
+func (CollectionStats) TableName() string {
+	return "collection_stats"
+}
+
+// TraderStats 地址成交统计汇总，按统计窗口预计算，金额已折算为基础币种
+type TraderStats struct {
+	ID             int64           `gorm:"primaryKey;column:id" json:"-"`
+	Address        string          `gorm:"column:address;uniqueIndex:uk_trader_stats,priority:1" json:"address"`
+	Window         string          `gorm:"column:window_key;uniqueIndex:uk_trader_stats,priority:2" json:"window"`
+	Volume         decimal.Decimal `gorm:"type:decimal(38,18);column:volume" json:"volume"` // 买入与卖出合计
+	BuyVolume      decimal.Decimal `gorm:"type:decimal(38,18);column:buy_volume" json:"buy_volume"`
+	SellVolume     decimal.Decimal `gorm:"type:decimal(38,18);column:sell_volume" json:"sell_volume"`
+	Buys           int64           `gorm:"column:buys" json:"buys"`
+	Sells          int64           `gorm:"column:sells" json:"sells"`
+	Collections    int64           `gorm:"column:collections" json:"collections"` // 交易过的合集数
+	LastComputedAt time.Time       `gorm:"column:last_computed_at" json:"last_computed_at"`
+}
+
+func (TraderStats) TableName() string {
+	return "trader_stats"
+}
+
+// 成交参与方角色
+const (
+	ParticipantBuyer  = "buyer"
+	ParticipantSeller = "seller"
+)
+
+// 统计扫描进度的来源
+const (
+	AnalyticsCursorTrades      = "trades"             // 成交 id
+	AnalyticsCursorFlags       = "trade_flags"        // 可疑标记 id
+	AnalyticsCursorFlagReviews = "trade_flag_reviews" // 标记审核时间
+)
+
+// TradeRollup 合集按时间桶的成交汇总，collection 为 * 表示全市场，金额为成交时折算的基础币种
+type TradeRollup struct {
+	ID          int64           `gorm:"primaryKey;column:id" json:"-"`
+	Collection  string          `gorm:"column:collection;uniqueIndex:uk_trade_rollups,priority:1" json:"collection"`
+	BucketStart int64           `gorm:"column:bucket_start;uniqueIndex:uk_trade_rollups,priority:2" json:"bucket_start"`
+	Volume      decimal.Decimal `gorm:"type:decimal(38,18);column:volume" json:"volume"`
+	Sales       int64           `gorm:"column:sales" json:"sales"`
+	MinPrice    decimal.Decimal `gorm:"type:decimal(38,18);column:min_price" json:"min_price"`
+	MaxPrice    decimal.Decimal `gorm:"type:decimal(38,18);column:max_price" json:"max_price"`
+}
+
+func (TradeRollup) TableName() string {
+	return "trade_rollups"
+}
+
+// TraderRollup 地址按时间桶的成交汇总
+type TraderRollup struct {
+	ID          int64           `gorm:"primaryKey;column:id" json:"-"`
+	Address     string          `gorm:"column:address;uniqueIndex:uk_trader_rollups,priority:1" json:"address"`
+	BucketStart int64           `gorm:"column:bucket_start;uniqueIndex:uk_trader_rollups,priority:2" json:"bucket_start"`
+	BuyVolume   decimal.Decimal `gorm:"type:decimal(38,18);column:buy_volume" json:"buy_volume"`
+	SellVolume  decimal.Decimal `gorm:"type:decimal(38,18);column:sell_volume" json:"sell_volume"`
+	Buys        int64           `gorm:"column:buys" json:"buys"`
+	Sells       int64           `gorm:"column:sells" json:"sells"`
+}
+
+func (TraderRollup) TableName() string {
+	return "trader_rollups"
+}
+
+// TradeParticipant 地址在合集中的最近一次成交时间，用于统计窗口内的独立买家/卖家与地址交易过的合集数
+type TradeParticipant struct {
+	ID            int64  `gorm:"primaryKey;column:id" json:"-"`
+	Collection    string `gorm:"column:collection;uniqueIndex:uk_trade_participants,priority:1" json:"collection"`
+	Address       string `gorm:"column:address;uniqueIndex:uk_trade_participants,priority:2" json:"address"`
+	Role          string `gorm:"column:role;uniqueIndex:uk_trade_participants,priority:3" json:"role"` // buyer / seller
+	LastTradeTime int64  `gorm:"column:last_trade_time" json:"last_trade_time"`
+}
+
+func (TradeParticipant) TableName() string {
+	return "trade_participants"
+}
+
+// AnalyticsCursor 统计汇总的扫描进度
+type AnalyticsCursor struct {
+	Source   string `gorm:"primaryKey;column:source"`
+	Position int64  `gorm:"column:position"`
+}
+
+func (AnalyticsCursor) TableName() string {
+	return "analytics_cursors"
+}
+
+// FlaggedTrade 标记变更涉及的成交时间
+type FlaggedTrade struct {
+	FlagID     int64 `gorm:"column:flag_id"`
+	ReviewedAt int64 `gorm:"column:reviewed_at"`
+	BlockTime  int64 `gorm:"column:block_time"`
+}
+
+// ParticipantCount 窗口内合集各角色的独立地址数
+type ParticipantCount struct {
+	Collection string `gorm:"column:collection"`
+	Role       string `gorm:"column:role"`
+	Count      int64  `gorm:"column:count"`
+}
+
+// TraderCollectionCount 窗口内地址交易过的合集数
+type TraderCollectionCount struct {
+	Address string `gorm:"column:address"`
+	Count   int64  `gorm:"column:count"`
+}
+
+// CollectionMedian 窗口内合集成交价中位数
+type CollectionMedian struct {
+	Collection string          `gorm:"column:collection"`
+	Median     decimal.Decimal `gorm:"column:median"`
+}
+
+// 查询统计扫描进度，无记录返回 0
+func (r *Dao) GetAnalyticsCursor(source string) (int64, error) {
+	var c AnalyticsCursor
+	if err := r.DB.Where("source = ?", source).First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return c.Position, nil
+}
+
+// 保存统计扫描进度
+func (r *Dao) SaveAnalyticsCursor(source string, position int64) error {
+	return r.DB.Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(&AnalyticsCursor{Source: source, Position: position}).Error
+}
+
+// 按 ID 分批扫描成交账本
+func (r *Dao) ListTradesAfterID(afterID int64, limit int) ([]Trade, error) {
+	var trades []Trade
+	err := r.DB.Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&trades).Error
+	return trades, err
+}
+
+// 查询已扫描范围内尚未折算基础币种价格的成交
+func (r *Dao) ListTradesMissingPriceBase(maxID int64, limit int) ([]Trade, error) {
+	var trades []Trade
+	err := r.DB.Where("id <= ? AND price_base IS NULL", maxID).Order("id ASC").Limit(limit).Find(&trades).Error
+	return trades, err
+}
+
+// 补写成交的基础币种价格
+func (r *Dao) UpdateTradePriceBase(id int64, price decimal.Decimal) error {
+	return r.DB.Model(&Trade{}).Where("id = ?", id).Update("price_base", price).Error
+}
+
+// 查询 id 之后新增的可疑标记所属成交的时间
+func (r *Dao) ListFlaggedTradesAfterID(afterID int64, limit int) ([]FlaggedTrade, error) {
+	var list []FlaggedTrade
+	err := r.DB.Table("trade_flags f").
+		Select("f.id AS flag_id, f.reviewed_at, t.block_time").
+		Joins("JOIN trades t ON t.id = f.trade_id").
+		Where("f.id > ?", afterID).Order("f.id ASC").Limit(limit).Scan(&list).Error
+	return list, err
+}
+
+// 查询审核时间不早于 since 的可疑标记所属成交的时间
+// 同一秒内的审核可能跨两轮扫描，按 >= 重复读取，桶重算是幂等的
+func (r *Dao) ListFlaggedTradesReviewedSince(since int64) ([]FlaggedTrade, error) {
+	var list []FlaggedTrade
+	err := r.DB.Table("trade_flags f").
+		Select("f.id AS flag_id, f.reviewed_at, t.block_time").
+		Joins("JOIN trades t ON t.id = f.trade_id").
+		Where("f.reviewed_at >= ? AND f.reviewed_at > 0", since).Scan(&list).Error
+	return list, err
+}
+
+// rollupTrades 计入统计的成交：已折算基础币种价格，按需排除可疑成交
+func rollupTrades(tx *gorm.DB, excludeFlagged bool) *gorm.DB {
+	query := tx.Table("trades").Where("trades.price_base IS NOT NULL")
+	if excludeFlagged {
+		query = query.Scopes(excludeFlaggedTrades)
+	}
+	return query
+}
+
+// 按成交账本重算一个时间桶的合集汇总、地址汇总与参与方最近成交时间
+// 桶内数据整体按账本覆盖，重复执行结果一致；成交被标记、驳回或被事件成交替换时重算所在的桶即可
+func (r *Dao) RebuildRollupBucket(bucketStart, bucketSize int64, excludeFlagged bool) error {
+	bucketEnd := bucketStart + bucketSize
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		inBucket := func() *gorm.DB {
+			return rollupTrades(tx, excludeFlagged).Where("trades.block_time >= ? AND trades.block_time < ?", bucketStart, bucketEnd)
+		}
+		var collections []TradeRollup
+		if err := inBucket().
+			Select("trades.collection, SUM(trades.price_base) AS volume, COUNT(*) AS sales, MIN(trades.price_base) AS min_price, MAX(trades.price_base) AS max_price").
+			Group("trades.collection").Scan(&collections).Error; err != nil {
+			return err
+		}
+		if len(collections) > 0 {
+			var global TradeRollup
+			if err := inBucket().
+				Select("SUM(trades.price_base) AS volume, COUNT(*) AS sales, MIN(trades.price_base) AS min_price, MAX(trades.price_base) AS max_price").
+				Scan(&global).Error; err != nil {
+				return err
+			}
+			global.Collection = GlobalCollection
+			collections = append(collections, global)
+		}
+		names := make([]string, 0, len(collections))
+		for i := range collections {
+			collections[i].ID = 0
+			collections[i].BucketStart = bucketStart
+			names = append(names, collections[i].Collection)
+		}
+		if err := upsertTradeRollups(tx, bucketStart, collections, names); err != nil {
+			return err
+		}
+
+		var buys, sells []TraderRollup
+		if err := inBucket().Select("trades.buyer AS address, SUM(trades.price_base) AS buy_volume, COUNT(*) AS buys").
+			Group("trades.buyer").Scan(&buys).Error; err != nil {
+			return err
+		}
+		if err := inBucket().Select("trades.seller AS address, SUM(trades.price_base) AS sell_volume, COUNT(*) AS sells").
+			Group("trades.seller").Scan(&sells).Error; err != nil {
+			return err
+		}
+		if err := upsertTraderRollups(tx, bucketStart, mergeTraderRollups(bucketStart, buys, sells)); err != nil {
+			return err
+		}
+		return rebuildParticipants(tx, bucketStart, bucketEnd, excludeFlagged)
+	})
+}
+
+func upsertTradeRollups(tx *gorm.DB, bucketStart int64, rows []TradeRollup, keep []string) error {
+	if len(rows) > 0 {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "collection"}, {Name: "bucket_start"}},
+			DoUpdates: clause.AssignmentColumns([]string{"volume", "sales", "min_price", "max_price"}),
+		}).CreateInBatches(rows, 500).Error; err != nil {
+			return err
+		}
+	}
+	stale := tx.Where("bucket_start = ?", bucketStart)
+	if len(keep) > 0 {
+		stale = stale.Where("collection NOT IN ?", keep)
+	}
+	return stale.Delete(&TradeRollup{}).Error
+}
+
+func mergeTraderRollups(bucketStart int64, buys, sells []TraderRollup) []TraderRollup {
+	byAddress := make(map[string]*TraderRollup, len(buys)+len(sells))
+	rows := make([]TraderRollup, 0, len(buys)+len(sells))
+	for _, list := range [][]TraderRollup{buys, sells} {
+		for _, item := range list {
+			key := strings.ToLower(item.Address)
+			row, ok := byAddress[key]
+			if !ok {
+				rows = append(rows, TraderRollup{Address: item.Address, BucketStart: bucketStart})
+				row = &rows[len(rows)-1]
+				byAddress[key] = row
+			}
+			row.BuyVolume = row.BuyVolume.Add(item.BuyVolume)
+			row.SellVolume = row.SellVolume.Add(item.SellVolume)
+			row.Buys += item.Buys
+			row.Sells += item.Sells
+		}
+	}
+	return rows
+}
+
+func upsertTraderRollups(tx *gorm.DB, bucketStart int64, rows []TraderRollup) error {
+	keep := make([]string, 0, len(rows))
+	for _, row := range rows {
+		keep = append(keep, row.Address)
+	}
+	if len(rows) > 0 {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "address"}, {Name: "bucket_start"}},
+			DoUpdates: clause.AssignmentColumns([]string{"buy_volume", "sell_volume", "buys", "sells"}),
+		}).CreateInBatches(rows, 500).Error; err != nil {
+			return err
+		}
+	}
+	stale := tx.Where("bucket_start = ?", bucketStart)
+	if len(keep) > 0 {
+		stale = stale.Where("address NOT IN ?", keep)
+	}
+	return stale.Delete(&TraderRollup{}).Error
+}
+
+// rebuildParticipants 重算桶内出现的地址（含已被标记或删除的成交对应的旧记录）在各合集的最近成交时间
+func rebuildParticipants(tx *gorm.DB, bucketStart, bucketEnd int64, excludeFlagged bool) error {
+	var touched []TradeParticipant
+	if err := tx.Where("last_trade_time >= ? AND last_trade_time < ?", bucketStart, bucketEnd).Find(&touched).Error; err != nil {
+		return err
+	}
+	var traded []struct {
+		Buyer  string
+		Seller string
+	}
+	if err := tx.Table("trades").Select("DISTINCT buyer, seller").
+		Where("block_time >= ? AND block_time < ?", bucketStart, bucketEnd).Scan(&traded).Error; err != nil {
+		return err
+	}
+	addresses := map[string][]string{ParticipantBuyer: nil, ParticipantSeller: nil}
+	seen := map[string]bool{}
+	addAddress := func(role, address string) {
+		key := role + ":" + strings.ToLower(address)
+		if !seen[key] {
+			seen[key] = true
+			addresses[role] = append(addresses[role], address)
+		}
+	}
+	for _, t := range traded {
+		addAddress(ParticipantBuyer, t.Buyer)
+		addAddress(ParticipantSeller, t.Seller)
+	}
+	for _, p := range touched {
+		addAddress(p.Role, p.Address)
+	}
+
+	fresh := map[string]bool{}
+	for _, role := range []string{ParticipantBuyer, ParticipantSeller} {
+		if len(addresses[role]) == 0 {
+			continue
+		}
+		var rows []TradeParticipant
+		if err := rollupTrades(tx, excludeFlagged).
+			Select("trades.collection, trades."+role+" AS address, MAX(trades.block_time) AS last_trade_time").
+			Where("trades."+role+" IN ?", addresses[role]).
+			Group("trades.collection, trades." + role).Scan(&rows).Error; err != nil {
+			return err
+		}
+		var global []TradeParticipant
+		if err := rollupTrades(tx, excludeFlagged).
+			Select("trades."+role+" AS address, MAX(trades.block_time) AS last_trade_time").
+			Where("trades."+role+" IN ?", addresses[role]).
+			Group("trades." + role).Scan(&global).Error; err != nil {
+			return err
+		}
+		for i := range global {
+			global[i].Collection = GlobalCollection
+		}
+		rows = append(rows, global...)
+		for i := range rows {
+			rows[i].Role = role
+			fresh[participantKey(&rows[i])] = true
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "collection"}, {Name: "address"}, {Name: "role"}},
+			DoUpdates: clause.AssignmentColumns([]string{"last_trade_time"}),
+		}).CreateInBatches(rows, 500).Error; err != nil {
+			return err
+		}
+	}
+	// 成交全部被排除的参与方删除
+	for i := range touched {
+		if fresh[participantKey(&touched[i])] {
+			continue
+		}
+		if err := tx.Where("id = ?", touched[i].ID).Delete(&TradeParticipant{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func participantKey(p *TradeParticipant) string {
+	return strings.ToLower(p.Collection) + ":" + strings.ToLower(p.Address) + ":" + p.Role
+}
+
+// 汇总 since 之后各时间桶的合集成交，结果只含 volume/sales/min_price/max_price
+func (r *Dao) SumTradeRollups(since int64) ([]CollectionStats, error) {
+	var list []CollectionStats
+	err := r.DB.Model(&TradeRollup{}).
+		Select("collection, SUM(volume) AS volume, SUM(sales) AS sales, MIN(min_price) AS min_price, MAX(max_price) AS max_price").
+		Where("bucket_start >= ?", since).Group("collection").Scan(&list).Error
+	return list, err
+}
+
+// 汇总 since 之后各时间桶的地址成交，结果只含买卖金额与笔数
+func (r *Dao) SumTraderRollups(since int64) ([]TraderStats, error) {
+	var list []TraderStats
+	err := r.DB.Model(&TraderRollup{}).
+		Select("address, SUM(buy_volume) AS buy_volume, SUM(sell_volume) AS sell_volume, SUM(buys) AS buys, SUM(sells) AS sells").
+		Where("bucket_start >= ?", since).Group("address").Scan(&list).Error
+	return list, err
+}
+
+// 统计 since 之后有成交的独立买家与卖家数
+func (r *Dao) CountParticipants(since int64) ([]ParticipantCount, error) {
+	var list []ParticipantCount
+	err := r.DB.Model(&TradeParticipant{}).
+		Select("collection, role, COUNT(*) AS count").
+		Where("last_trade_time >= ?", since).Group("collection, role").Scan(&list).Error
+	return list, err
+}
+
+// 统计 since 之后地址交易过的合集数
+func (r *Dao) CountTraderCollections(since int64) ([]TraderCollectionCount, error) {
+	var list []TraderCollectionCount
+	err := r.DB.Model(&TradeParticipant{}).
+		Select("address, COUNT(DISTINCT collection) AS count").
+		Where("last_trade_time >= ? AND collection <> ?", since, GlobalCollection).
+		Group("address").Scan(&list).Error
+	return list, err
+}
+
+// 计算 since 之后各合集及全市场的成交价中位数，在数据库内排序（需要 MySQL 8.0 窗口函数）
+func (r *Dao) MedianPrices(since int64, excludeFlagged bool) ([]CollectionMedian, error) {
+	var list []CollectionMedian
+	for _, global := range []bool{false, true} {
+		collection, partition := "trades.collection", "PARTITION BY trades.collection "
+		if global {
+			collection, partition = "'"+GlobalCollection+"'", ""
+		}
+		ranked := rollupTrades(r.DB, excludeFlagged).
+			Select(collection+" AS collection, trades.price_base, "+
+				"ROW_NUMBER() OVER ("+partition+"ORDER BY trades.price_base) AS rn, "+
+				"COUNT(*) OVER ("+partition+") AS cnt").
+			Where("trades.block_time >= ?", since)
+		var rows []CollectionMedian
+		if err := r.DB.Table("(?) AS ranked", ranked).
+			Select("collection, AVG(price_base) AS median").
+			Where("rn IN (FLOOR((cnt + 1) / 2), FLOOR((cnt + 2) / 2))").
+			Group("collection").Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		list = append(list, rows...)
+	}
+	return list, nil
+}
+
+// 写入统计窗口的合集汇总：逐行 upsert，再删除本轮未出现（已移出窗口）的合集
+func (r *Dao) UpsertCollectionStats(window string, rows []CollectionStats, computedAt time.Time) error {
+	if len(rows) > 0 {
+		if err := r.DB.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "collection"}, {Name: "window_key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"volume", "sales", "avg_price", "median_price", "min_price",
+				"max_price", "unique_buyers", "unique_sellers", "window_start", "last_computed_at"}),
+		}).CreateInBatches(rows, 500).Error; err != nil {
+			return err
+		}
+	}
+	return r.DB.Where("window_key = ? AND last_computed_at < ?", window, computedAt).Delete(&CollectionStats{}).Error
+}
+
+// 写入统计窗口的地址汇总
+func (r *Dao) UpsertTraderStats(window string, rows []TraderStats, computedAt time.Time) error {
+	if len(rows) > 0 {
+		if err := r.DB.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "address"}, {Name: "window_key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"volume", "buy_volume", "sell_volume", "buys", "sells",
+				"collections", "last_computed_at"}),
+		}).CreateInBatches(rows, 500).Error; err != nil {
+			return err
+		}
+	}
+	return r.DB.Where("window_key = ? AND last_computed_at < ?", window, computedAt).Delete(&TraderStats{}).Error
+}
+
+// 查询合集各统计窗口的汇总
+func (r *Dao) ListCollectionStats(collection string) ([]CollectionStats, error) {
+	var list []CollectionStats
+	err := r.DB.Where("collection = ?", collection).Find(&list).Error
+	return list, err
+}
+
+// 合集排行，orderBy 需由上层白名单校验
+func (r *Dao) ListTopCollections(window, orderBy string, limit int) ([]CollectionStats, error) {
+	var list []CollectionStats
+	err := r.DB.Where("window_key = ? AND collection <> ?", window, GlobalCollection).
+		Order(orderBy + " DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// 地址排行，orderBy 需由上层白名单校验
+func (r *Dao) ListTopTraders(window, orderBy string, limit int) ([]TraderStats, error) {
+	var list []TraderStats
+	err := r.DB.Where("window_key = ?", window).
+		Order(orderBy + " DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
@@ -0,0 +1,73 @@
+package dao
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// APIKey 机器客户端 API Key，仅保存哈希；Scopes 为逗号分隔的接口分组
+type APIKey struct {
+	ID         int64     `gorm:"primaryKey;column:id" json:"id"`
+	UserID     int64     `gorm:"column:user_id;index" json:"user_id"`
+	Name       string    `gorm:"column:name" json:"name"`
+	Prefix     string    `gorm:"column:prefix" json:"prefix"` // 明文前缀，便于识别
+	KeyHash    string    `gorm:"column:key_hash;uniqueIndex" json:"-"`
+	Scopes     string    `gorm:"column:scopes" json:"scopes"`
+	RateLimit  int       `gorm:"column:rate_limit" json:"rate_limit"`           // 每分钟请求数，0 使用默认值
+	DailyQuota int       `gorm:"column:daily_quota" json:"daily_quota"`         // 每日请求数，0 使用默认值
+	RevokedAt  int64     `gorm:"column:revoked_at" json:"revoked_at,omitempty"` // 0 表示有效
+	CreatedAt  time.Time `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// 写入 API Key
+func (r *Dao) CreateAPIKey(k *APIKey) error {
+	return r.DB.Create(k).Error
+}
+
+// 按哈希查询 API Key，不存在返回 nil
+func (r *Dao) GetAPIKeyByHash(hash string) (*APIKey, error) {
+	var k APIKey
+	if err := r.DB.Where("key_hash = ?", hash).First(&k).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+// 按 ID 查询用户的 API Key，不存在返回 nil
+func (r *Dao) GetUserAPIKey(userID, id int64) (*APIKey, error) {
+	var k APIKey
+	if err := r.DB.Where("id = ? AND user_id = ?", id, userID).First(&k).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+// 用户的全部 API Key，按创建时间倒序
+func (r *Dao) ListUserAPIKeys(userID int64) ([]APIKey, error) {
+	var keys []APIKey
+	err := r.DB.Where("user_id = ?", userID).Order("id DESC").Find(&keys).Error
+	return keys, err
+}
+
+// 统计用户有效的 API Key 数
+func (r *Dao) CountActiveAPIKeys(userID int64) (int64, error) {
+	var n int64
+	err := r.DB.Model(&APIKey{}).Where("user_id = ? AND revoked_at = 0", userID).Count(&n).Error
+	return n, err
+}
+
+// 吊销 API Key
+func (r *Dao) RevokeAPIKey(id int64) error {
+	return r.DB.Model(&APIKey{}).Where("id = ? AND revoked_at = 0", id).
+		Update("revoked_at", time.Now().Unix()).Error
+}
@@ -0,0 +1,63 @@
+package dao
+
+import (
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaleCandle 成交 K 线（OHLC + 成交量），价格已折算为基础币种
+type SaleCandle struct {
+	ID          int64           `gorm:"primaryKey;column:id" json:"-"`
+	Collection  string          `gorm:"column:collection;uniqueIndex:uk_candles_bucket,priority:1" json:"collection"`
+	Interval    string          `gorm:"column:interval_key;uniqueIndex:uk_candles_bucket,priority:2" json:"interval"` // 5m / 1h / 1d / 1w
+	BucketStart int64           `gorm:"column:bucket_start;uniqueIndex:uk_candles_bucket,priority:3" json:"bucket_start"`
+	Open        decimal.Decimal `gorm:"type:decimal(38,18);column:open" json:"open"`
+	High        decimal.Decimal `gorm:"type:decimal(38,18);column:high" json:"high"`
+	Low         decimal.Decimal `gorm:"type:decimal(38,18);column:low" json:"low"`
+	Close       decimal.Decimal `gorm:"type:decimal(38,18);column:close" json:"close"`
+	Volume      decimal.Decimal `gorm:"type:decimal(38,18);column:volume" json:"volume"`
+	Count       int64           `gorm:"column:count" json:"count"`
+	OpenTime    int64           `gorm:"column:open_time" json:"-"`  // 首笔成交时间，成交乱序到达时用于确定开盘价
+	CloseTime   int64           `gorm:"column:close_time" json:"-"` // 末笔成交时间
+}
+
+// 写入或覆盖 K 线（同一合集、周期、起始时间唯一）
+func (r *Dao) UpsertCandle(c *SaleCandle) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "collection"}, {Name: "interval_key"}, {Name: "bucket_start"}},
+		DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "volume", "count", "open_time", "close_time"}),
+	}).Create(c).Error
+}
+
+// 单笔成交并入 K 线：最高/最低取极值，成交量与笔数累加，开盘/收盘按成交时间取最早/最晚
+// MySQL 按书写顺序执行赋值，open/close 需在 open_time/close_time 更新前比较
+func (r *Dao) MergeCandle(c *SaleCandle) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "collection"}, {Name: "interval_key"}, {Name: "bucket_start"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "open"}, Value: gorm.Expr("IF(VALUES(open_time) < open_time, VALUES(open), open)")},
+			{Column: clause.Column{Name: "open_time"}, Value: gorm.Expr("LEAST(open_time, VALUES(open_time))")},
+			{Column: clause.Column{Name: "close"}, Value: gorm.Expr("IF(VALUES(close_time) >= close_time, VALUES(close), close)")},
+			{Column: clause.Column{Name: "close_time"}, Value: gorm.Expr("GREATEST(close_time, VALUES(close_time))")},
+			{Column: clause.Column{Name: "high"}, Value: gorm.Expr("GREATEST(high, VALUES(high))")},
+			{Column: clause.Column{Name: "low"}, Value: gorm.Expr("LEAST(low, VALUES(low))")},
+			{Column: clause.Column{Name: "volume"}, Value: gorm.Expr("volume + VALUES(volume)")},
+			{Column: clause.Column{Name: "count"}, Value: gorm.Expr("count + VALUES(count)")},
+		},
+	}).Create(c).Error
+}
+
+// 删除 K 线
+func (r *Dao) DeleteCandle(collection, interval string, bucketStart int64) error {
+	return r.DB.Where("collection = ? AND interval_key = ? AND bucket_start = ?", collection, interval, bucketStart).
+		Delete(&SaleCandle{}).Error
+}
+
+// 查询时间范围内的 K 线，按时间正序
+func (r *Dao) ListCandles(collection, interval string, from, to int64, limit int) ([]SaleCandle, error) {
+	var list []SaleCandle
+	err := r.DB.Where("collection = ? AND interval_key = ? AND bucket_start >= ? AND bucket_start < ?", collection, interval, from, to).
+		Order("bucket_start ASC").Limit(limit).Find(&list).Error
+	return list, err
+}
@@ -0,0 +1,34 @@
+package dao
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Currency 支付币种元数据（ERC20 symbol/decimals），原生 ETH 使用零地址
+type Currency struct {
+	ID       int64  `gorm:"primaryKey;column:id" json:"id"`
+	Address  string `gorm:"uniqueIndex;column:address" json:"address"`
+	Symbol   string `gorm:"column:symbol" json:"symbol"`
+	Decimals uint8  `gorm:"column:decimals" json:"decimals"`
+}
+
+// 查询币种元数据
+func (r *Dao) GetCurrency(address string) (*Currency, error) {
+	var currency Currency
+	if err := r.DB.Where("address = ?", address).First(&currency).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &currency, nil
+}
+
+// 保存币种元数据，已存在则更新
+func (r *Dao) SaveCurrency(currency *Currency) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "address"}},
+		DoUpdates: clause.AssignmentColumns([]string{"symbol", "decimals"}),
+	}).Create(currency).Error
+}
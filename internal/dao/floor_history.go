@@ -0,0 +1,50 @@
+package dao
+
+// FloorPriceHistory 地板价变动记录
+// 每次地板价变化写入一条，price 已折算为基础币种
+type FloorPriceHistory struct {
+	ID         int64  `gorm:"primaryKey;column:id" json:"id"`
+	Collection string `gorm:"column:collection;index:idx_floor_history_collection_time,priority:1" json:"collection"`
+	Price      string `gorm:"column:price" json:"price"`
+	Currency   string `gorm:"column:currency" json:"currency"`
+	Trigger    string `gorm:"column:trigger_event" json:"trigger"` // 触发事件：order_created、order_cancelled、transfer 等
+	Timestamp  int64  `gorm:"column:timestamp;index:idx_floor_history_collection_time,priority:2" json:"timestamp"`
+}
+
+// 写入地板价变动记录
+func (r *Dao) CreateFloorPriceHistory(h *FloorPriceHistory) error {
+	return r.DB.Create(h).Error
+}
+
+// 查询时间范围内的地板价变动，按时间正序
+func (r *Dao) ListFloorPriceHistory(collection string, from, to int64, limit int) ([]FloorPriceHistory, error) {
+	var list []FloorPriceHistory
+	err := r.DB.Where("collection = ? AND timestamp >= ? AND timestamp < ?", collection, from, to).
+		Order("timestamp ASC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// 查询指定时间点生效的地板价（该时间之前最后一次变动），无记录时返回 nil
+func (r *Dao) GetFloorPriceAt(collection string, ts int64) (*FloorPriceHistory, error) {
+	var h FloorPriceHistory
+	err := r.DB.Where("collection = ? AND timestamp <= ?", collection, ts).
+		Order("timestamp DESC").Limit(1).Find(&h).Error
+	if err != nil || h.ID == 0 {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// 查询 ID 之后的地板价变动，按 ID 正序
+func (r *Dao) ListFloorPriceHistoryAfterID(afterID int64, limit int) ([]FloorPriceHistory, error) {
+	var list []FloorPriceHistory
+	err := r.DB.Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// 查询地板价变动最大 ID，无记录返回 0
+func (r *Dao) MaxFloorPriceHistoryID() (int64, error) {
+	var id int64
+	err := r.DB.Model(&FloorPriceHistory{}).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
@@ -1,6 +1,8 @@
 package dao
 
 import (
+	"time"
+
 	"gorm.io/gorm/clause"
 )
 
@@ -13,28 +15,45 @@ import (
 type FloorPrice struct {
 	ID         int64  `gorm:"primaryKey;column:id" json:"id"`
 	Collection string `gorm:"uniqueIndex;column:collection" json:"collection"`
-	Price      string `gorm:"column:price" json:"price"`
+	Price      string `gorm:"column:price" json:"price"`       // 已折算为基础币种
+	Currency   string `gorm:"column:currency" json:"currency"` // 基础币种地址
 }
 
 // UpdateFloorPrice 更新地板价
-func (r *Dao) UpdateFloorPrice(collection string, price string) error {
-	fp := FloorPrice{Collection: collection, Price: price}
+func (r *Dao) UpdateFloorPrice(collection string, price string, currency string) error {
+	fp := FloorPrice{Collection: collection, Price: price, Currency: currency}
 	// 插入或更新地板价（如果已存在则更新）
 	return r.DB.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "collection"}},       // 以 collection 唯一约束
-		DoUpdates: clause.AssignmentColumns([]string{"price"}), // 只更新 price 字段
+		Columns:   []clause.Column{{Name: "collection"}},                   // 以 collection 唯一约束
+		DoUpdates: clause.AssignmentColumns([]string{"price", "currency"}), // 只更新价格和币种
 	}).Create(&fp).Error
 }
 
-// ListOrdersByCollection 查询某合集所有挂单订单
+// ClearFloorPrice 合集已无挂单时删除地板价
+func (r *Dao) ClearFloorPrice(collection string) error {
+	return r.DB.Where("collection = ?", collection).Delete(&FloorPrice{}).Error
+}
+
+// ListOrdersByCollection 查询某合集所有挂单订单（排除未生效和已过期但尚未被校验任务标记的订单）
 func (r *Dao) ListOrdersByCollection(collection string) ([]Order, error) {
 	var orders []Order
-	if err := r.DB.Where("nft_token = ? AND status = ?", collection, OrderStatusListed).Find(&orders).Error; err != nil {
+	now := time.Now().Unix()
+	if err := r.DB.Where("nft_token = ? AND status = ?", collection, OrderStatusListed).
+		Where("start_time <= ? AND (end_time = 0 OR end_time > ?)", now, now).
+		Find(&orders).Error; err != nil {
 		return nil, err
 	}
 	return orders, nil
 }
 
+// ListCollectionsWithActiveOrders 查询存在有效订单的合集
+func (r *Dao) ListCollectionsWithActiveOrders() ([]string, error) {
+	var collections []string
+	err := r.DB.Model(&Order{}).Where("status = ?", OrderStatusListed).
+		Distinct().Pluck("nft_token", &collections).Error
+	return collections, err
+}
+
 // GetFloorPrice 查询地板价
 func (r *Dao) GetFloorPrice(collection string) (string, error) {
 	var fp FloorPrice
@@ -43,3 +62,10 @@ func (r *Dao) GetFloorPrice(collection string) (string, error) {
 	}
 	return fp.Price, nil
 }
+
+// ListFloorPrices 批量查询合集地板价
+func (r *Dao) ListFloorPrices(collections []string) ([]FloorPrice, error) {
+	var list []FloorPrice
+	err := r.DB.Where("collection IN ?", collections).Find(&list).Error
+	return list, err
+}
@@ -0,0 +1,58 @@
+package dao
+
+import (
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// FundTransferNativeLogIndex 原生 ETH 转账（交易 value）没有日志，使用 -1 作为日志序号
+const FundTransferNativeLogIndex = -1
+
+// FundTransfer 已索引的 ETH/WETH 资金转账，用于追溯地址的资金来源
+// 仅覆盖转入近期买卖双方及其资金来源的独立转账（不含 NFT 成交结算），并非全链资金流水
+type FundTransfer struct {
+	ID          int64           `gorm:"primaryKey;column:id" json:"id"`
+	From        string          `gorm:"column:from_addr;index" json:"from"`
+	To          string          `gorm:"column:to_addr;index" json:"to"`
+	Token       string          `gorm:"column:token" json:"token"` // 币种地址，ETH为零地址
+	Value       decimal.Decimal `gorm:"type:varchar(78);column:value" json:"value"`
+	TxHash      string          `gorm:"column:tx_hash;uniqueIndex:uk_fund_transfers_tx_log,priority:1" json:"tx_hash"`
+	LogIndex    int             `gorm:"column:log_index;uniqueIndex:uk_fund_transfers_tx_log,priority:2" json:"log_index"`
+	BlockNumber uint64          `gorm:"column:block_number" json:"block_number"`
+	BlockTime   int64           `gorm:"column:block_time" json:"block_time"`
+	CreatedAt   time.Time       `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+}
+
+// 批量写入资金转账，重复记录自动跳过
+func (r *Dao) CreateFundTransfersIgnoreConflict(list []FundTransfer) error {
+	if len(list) == 0 {
+		return nil
+	}
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&list).Error
+}
+
+// 查询向指定地址转入资金的地址对（去重）
+func (r *Dao) ListFunders(addresses []string) ([]FundTransfer, error) {
+	var list []FundTransfer
+	err := r.DB.Select("DISTINCT from_addr, to_addr").
+		Where("to_addr IN ?", addresses).
+		Find(&list).Error
+	return list, err
+}
+
+// 统计地址转出资金的不同接收方数量，用于识别交易所、路由合约等公共资金源
+func (r *Dao) CountFundedAddresses(from string) (int64, error) {
+	var count int64
+	err := r.DB.Model(&FundTransfer{}).Where("from_addr = ?", from).
+		Distinct("to_addr").Count(&count).Error
+	return count, err
+}
+
+// 查询 since 之后成交的买卖双方地址（去重）
+func (r *Dao) ListTraderAddresses(since int64) ([]string, error) {
+	var list []string
+	err := r.DB.Raw("SELECT buyer FROM trades WHERE block_time >= ? UNION SELECT seller FROM trades WHERE block_time >= ?", since, since).
+		Scan(&list).Error
+	return list, err
+}
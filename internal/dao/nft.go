@@ -94,3 +94,53 @@ func (d *Dao) GetNFTListByOwner(owner string) ([]NFT, error) {
 	}
 	return nfts, nil
 }
+
+// 更新 NFT 当前持有人
+func (d *Dao) UpdateNFTOwner(contract, tokenID, owner string) error {
+	return d.DB.Model(&NFT{}).Where("contract = ? AND token_id = ?", contract, tokenID).Update("owner", owner).Error
+}
+
+// 更新 NFT 的 tokenURI、元数据与属性，属性整体替换
+func (d *Dao) UpdateNFTMetadata(id uint, tokenURI, metadata string, items []Item) error {
+	return d.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&NFT{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"token_uri": tokenURI,
+			"metadata":  metadata,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("nft_id = ?", id).Delete(&Item{}).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].NFTID = id
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.Create(&items).Error
+	})
+}
+
+// 查询合约下已入库的全部 tokenId
+func (d *Dao) ListNFTTokenIDs(contract string) ([]string, error) {
+	var ids []string
+	err := d.DB.Model(&NFT{}).Where("contract = ?", contract).Pluck("token_id", &ids).Error
+	return ids, err
+}
+
+// GetNFTTraits 查询 NFT 属性（trait_type -> value），NFT 不存在时返回空
+func (d *Dao) GetNFTTraits(contract, tokenID string) (map[string]string, error) {
+	var items []Item
+	err := d.DB.Joins("JOIN nfts ON nfts.id = items.nft_id").
+		Where("nfts.contract = ? AND nfts.token_id = ?", contract, tokenID).
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	traits := make(map[string]string, len(items))
+	for _, item := range items {
+		traits[item.TraitType] = item.Value
+	}
+	return traits, nil
+}
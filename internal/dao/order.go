@@ -1,8 +1,10 @@
 package dao
 
 import (
+	"errors"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -14,6 +16,8 @@ const (
 	OrderStatusMatched   = "matched"   // 已撮合
 	OrderStatusCompleted = "completed" // 已成交
 	OrderStatusCancelled = "cancelled" // 已取消
+	OrderStatusExpired   = "expired"   // 已过期
+	OrderStatusInvalid   = "invalid"   // 已失效（卖家不再持有或撤销授权）
 )
 
 // 订单类型常量
@@ -30,20 +34,33 @@ type Order struct {
 	OrderID  string `gorm:"uniqueIndex;column:order_id" json:"order_id"` // 订单唯一键
 	NFTID    int64  `gorm:"column:nft_id" json:"nft_id"`
 	NFTToken string `gorm:"column:nft_token" json:"nft_token"`
+	TokenID  string `gorm:"column:token_id" json:"token_id"` // 合集出价为空
 	Seller   string `gorm:"column:seller" json:"seller"`
 	Buyer    string `gorm:"column:buyer" json:"buyer"`
 	// OrderType 字段已加入，所有方法已同步
-	Price       decimal.Decimal `gorm:"type:decimal(38,18);column:price" json:"price"`
-	Fee         decimal.Decimal `gorm:"type:decimal(38,18);column:fee" json:"fee"`
+	// Price/Fee 为链上原始数值（uint256），PriceScaled 为按币种 decimals 缩放后的价格
+	Price       decimal.Decimal `gorm:"type:varchar(78);column:price" json:"price"`
+	Fee         decimal.Decimal `gorm:"type:varchar(78);column:fee" json:"fee"`
+	Currency    string          `gorm:"column:currency" json:"currency"` // 支付币种地址，ETH为零地址
+	PriceScaled decimal.Decimal `gorm:"type:decimal(38,18);column:price_scaled" json:"price_scaled"`
 	Status      string          `gorm:"column:status" json:"status"`
 	CreatedAt   time.Time       `gorm:"autoCreateTime;column:created_at" json:"created_at"`
 	UpdatedAt   time.Time       `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
 	TxHash      string          `gorm:"column:tx_hash" json:"tx_hash"`
 	BlockNumber uint64          `gorm:"column:block_number" json:"block_number"`
 	BlockTime   int64           `gorm:"column:block_time" json:"block_time"`
-	OrderType   string          `gorm:"column:order_type" json:"order_type"` // 订单类型
+	OrderType   string          `gorm:"column:order_type" json:"order_type"`    // 订单类型
+	Exchange    string          `gorm:"column:exchange" json:"exchange"`        // 订单所属交易合约，授权校验的 operator
+	StartTime   int64           `gorm:"column:start_time" json:"start_time"`    // 生效时间（秒），0表示立即生效
+	EndTime     int64           `gorm:"column:end_time;index" json:"end_time"`  // 过期时间（秒），0表示永不过期
+	Nonce       string          `gorm:"column:nonce;default:null" json:"nonce"` // (seller, exchange, nonce) 唯一，无 nonce 的链上订单写入 NULL
+	Signature   string          `gorm:"column:signature" json:"signature"`      // 链下订单 EIP-712 签名，链上订单为空
+	Criteria    string          `gorm:"column:criteria" json:"criteria"`        // 属性出价条件（JSON：trait_type -> value），仅合集出价使用
 }
 
+// errOrderNotListed 撮合事务中订单状态已变化，用于回滚
+var errOrderNotListed = errors.New("order not listed")
+
 // 创建订单
 func (r *Dao) CreateOrder(order *Order) error {
 	return r.DB.Create(order).Error
@@ -80,14 +97,38 @@ func (r *Dao) ListOrders(nftID int64, status string) ([]Order, error) {
 	return orders, nil
 }
 
-// 原子撮合订单，更新状态为matched，记录买家和时间
-func (r *Dao) UpdateOrderMatched(id int64, buyer string) error {
-	return r.DB.Model(&Order{}).Where("id = ? AND status = ?", id, OrderStatusListed).
+// 原子撮合订单，更新状态为matched，记录买家和时间；订单已不是 listed 时返回 false
+func (r *Dao) UpdateOrderMatched(id int64, buyer string) (bool, error) {
+	res := r.DB.Model(&Order{}).Where("id = ? AND status = ?", id, OrderStatusListed).
 		Updates(map[string]interface{}{
 			"status":     OrderStatusMatched,
 			"buyer":      buyer,
 			"updated_at": gorm.Expr("NOW()"),
-		}).Error
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+// MatchOrderPair 在同一事务中将挂单与出价置为 matched，任一订单已不是 listed 时整体回滚并返回 false
+func (r *Dao) MatchOrderPair(listingID, bidID int64, buyer string) (bool, error) {
+	matched := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		txDao := New(tx)
+		for _, id := range []int64{listingID, bidID} {
+			ok, err := txDao.UpdateOrderMatched(id, buyer)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errOrderNotListed
+			}
+		}
+		matched = true
+		return nil
+	})
+	if err == errOrderNotListed {
+		return false, nil
+	}
+	return matched, err
 }
 
 // 用户订单列表查询，按 owner 查询（卖家或买家）
@@ -99,22 +140,25 @@ func (r *Dao) ListUserOrders(owner string) ([]Order, error) {
 	return orders, nil
 }
 
-// 订单统计（生产级，统计已成交订单数和总金额）
-type OrderStats struct {
-	Total       int64
-	TotalAmount float64
+// CurrencyAmount 按币种汇总的金额（已按 decimals 缩放）
+type CurrencyAmount struct {
+	Currency string
+	Total    int64
+	Amount   decimal.Decimal
 }
 
-func (r *Dao) GetOrderStats() (*OrderStats, error) {
-	var stats OrderStats
+// 按币种统计已成交订单数和缩放后的总金额，由上层折算为基础币种
+func (r *Dao) SumCompletedOrdersByCurrency() ([]CurrencyAmount, error) {
+	var rows []CurrencyAmount
 	err := r.DB.Model(&Order{}).
-		Select("COUNT(*) as total, COALESCE(SUM(price),0) as total_amount").
+		Select("currency, COUNT(*) as total, COALESCE(SUM(price_scaled),0) as amount").
 		Where("status = ?", OrderStatusCompleted).
-		Scan(&stats).Error
+		Group("currency").
+		Scan(&rows).Error
 	if err != nil {
 		return nil, err
 	}
-	return &stats, nil
+	return rows, nil
 }
 
 // 根据OrderID查询订单
@@ -133,3 +177,136 @@ func (r *Dao) GetOrderByOrderID(orderId string) (*Order, error) {
 func (r *Dao) UpdateOrderStatusByOrderID(orderId string, status string) error {
 	return r.DB.Model(&Order{}).Where("order_id = ?", orderId).Update("status", status).Error
 }
+
+// 根据OrderID标记订单成交，记录买家
+func (r *Dao) UpdateOrderFilledByOrderID(orderId string, buyer string) error {
+	return r.DB.Model(&Order{}).Where("order_id = ?", orderId).
+		Updates(map[string]interface{}{
+			"status": OrderStatusCompleted,
+			"buyer":  buyer,
+		}).Error
+}
+
+// 批量标记已过期的有效订单，返回受影响的订单
+func (r *Dao) ExpireOrders(now int64) ([]Order, error) {
+	var orders []Order
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("status = ? AND end_time > 0 AND end_time <= ?", OrderStatusListed, now).
+			Find(&orders).Error; err != nil {
+			return err
+		}
+		if len(orders) == 0 {
+			return nil
+		}
+		ids := make([]int64, 0, len(orders))
+		for _, o := range orders {
+			ids = append(ids, o.ID)
+		}
+		return tx.Model(&Order{}).Where("id IN ? AND status = ?", ids, OrderStatusListed).
+			Update("status", OrderStatusExpired).Error
+	})
+	return orders, err
+}
+
+// 分页查询有效挂单（按ID递增游标）
+func (r *Dao) ListActiveListings(afterID int64, limit int) ([]Order, error) {
+	var orders []Order
+	err := r.DB.Where("id > ? AND status = ? AND order_type = ?", afterID, OrderStatusListed, OrderTypeListing).
+		Order("id ASC").Limit(limit).Find(&orders).Error
+	return orders, err
+}
+
+// 查询在 (from, to] 内到达生效时间的有效订单，用于生效时写入订单簿索引
+func (r *Dao) ListOrdersActivatedBetween(from, to int64) ([]Order, error) {
+	var orders []Order
+	err := r.DB.Where("status = ? AND start_time > ? AND start_time <= ?", OrderStatusListed, from, to).
+		Where("end_time = 0 OR end_time > ?", to).
+		Order("id ASC").Find(&orders).Error
+	return orders, err
+}
+
+// 有效订单置为指定状态（仅在仍为 listed 时更新，避免覆盖成交/取消）
+func (r *Dao) InvalidateOrder(id int64, status string) (bool, error) {
+	res := r.DB.Model(&Order{}).Where("id = ? AND status = ?", id, OrderStatusListed).Update("status", status)
+	return res.RowsAffected > 0, res.Error
+}
+
+// token 转移后，原持有人的有效挂单置为失效，返回被失效的挂单
+func (r *Dao) InvalidateListingsOnTransfer(collection, tokenID, newOwner string) ([]Order, error) {
+	var orders []Order
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("nft_token = ? AND token_id = ? AND order_type = ? AND status = ? AND seller <> ?",
+			collection, tokenID, OrderTypeListing, OrderStatusListed, newOwner).
+			Find(&orders).Error; err != nil {
+			return err
+		}
+		if len(orders) == 0 {
+			return nil
+		}
+		ids := make([]int64, 0, len(orders))
+		for _, o := range orders {
+			ids = append(ids, o.ID)
+		}
+		return tx.Model(&Order{}).Where("id IN ? AND status = ?", ids, OrderStatusListed).
+			Update("status", OrderStatusInvalid).Error
+	})
+	return orders, err
+}
+
+// 按 OrderID 批量查询订单
+func (r *Dao) GetOrdersByOrderIDs(orderIds []string) ([]Order, error) {
+	var orders []Order
+	if len(orderIds) == 0 {
+		return orders, nil
+	}
+	err := r.DB.Where("order_id IN ?", orderIds).Find(&orders).Error
+	return orders, err
+}
+
+// ListActiveListingsByToken 查询单个 token 当前有效的挂单
+func (r *Dao) ListActiveListingsByToken(collection, tokenID string) ([]Order, error) {
+	var orders []Order
+	now := time.Now().Unix()
+	err := r.DB.Where("nft_token = ? AND token_id = ? AND order_type = ? AND status = ?",
+		collection, tokenID, OrderTypeListing, OrderStatusListed).
+		Where("start_time <= ? AND (end_time = 0 OR end_time > ?)", now, now).
+		Find(&orders).Error
+	return orders, err
+}
+
+// ListActiveBidsByToken 查询可成交单个 token 的有效出价（单品出价与合集出价，不含属性出价）
+func (r *Dao) ListActiveBidsByToken(collection, tokenID string) ([]Order, error) {
+	var orders []Order
+	now := time.Now().Unix()
+	err := r.DB.Where("nft_token = ? AND status = ?", collection, OrderStatusListed).
+		Where("(order_type = ? AND token_id = ?) OR (order_type = ? AND (criteria IS NULL OR criteria = ''))",
+			OrderTypeItemBid, tokenID, OrderTypeCollectionBid).
+		Where("start_time <= ? AND (end_time = 0 OR end_time > ?)", now, now).
+		Find(&orders).Error
+	return orders, err
+}
+
+// CreateSignedOrder 写入链下签名订单，同一 maker 在同一交易合约下 nonce 已被使用时返回 false
+// 由唯一索引 uk_orders_maker_nonce 保证并发提交时只有一笔写入
+func (r *Dao) CreateSignedOrder(order *Order) (bool, error) {
+	if err := r.DB.Create(order).Error; err != nil {
+		if isDuplicateKey(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// isDuplicateKey 判断是否为 MySQL 唯一键冲突
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+// CancelListedOrder 取消仍处于挂单状态的订单，返回是否发生变更
+func (r *Dao) CancelListedOrder(orderId string) (bool, error) {
+	res := r.DB.Model(&Order{}).Where("order_id = ? AND status = ?", orderId, OrderStatusListed).
+		Update("status", OrderStatusCancelled)
+	return res.RowsAffected > 0, res.Error
+}
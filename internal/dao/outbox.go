@@ -0,0 +1,96 @@
+package dao
+
+import (
+	"encoding/json"
+	"gorm.io/gorm"
+	"time"
+)
+
+// 发件箱消息类别，由中继决定发布的 topic
+const (
+	OutboxKindFloorPrice = "floor_price" // 地板价更新
+	OutboxKindEvent      = "event"       // 领域事件，payload 为 JSON 信封，topic 由事件类型决定
+	OutboxKindMatch      = "match"       // 撮合结果，payload 为 JSON，发布到撮合结果 topic
+	OutboxKindNotify     = "notify"      // 外部事件通知，payload 与 webhook 请求体一致，发布到 notify.mq_topic
+)
+
+// OutboxMessage 消息总线发件箱，与业务变更在同一事务中写入，由中继按 ID 顺序发布
+type OutboxMessage struct {
+	ID          int64     `gorm:"primaryKey;column:id" json:"id"`
+	Kind        string    `gorm:"column:kind" json:"kind"`
+	MsgKey      string    `gorm:"column:msg_key" json:"msg_key"` // 消息 key，同一 key 保证有序
+	Payload     string    `gorm:"type:text;column:payload" json:"payload"`
+	Headers     string    `gorm:"type:text;column:headers" json:"headers"` // JSON 对象，消息头
+	Attempts    int       `gorm:"column:attempts" json:"attempts"`
+	LastError   string    `gorm:"column:last_error" json:"last_error,omitempty"`
+	PublishedAt int64     `gorm:"column:published_at;index:idx_kafka_outbox_pending,priority:1" json:"published_at"` // 0 表示待发布
+	CreatedAt   time.Time `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "kafka_outbox"
+}
+
+// HeaderMap 解析消息头
+func (m *OutboxMessage) HeaderMap() map[string]string {
+	headers := map[string]string{}
+	if m.Headers != "" {
+		_ = json.Unmarshal([]byte(m.Headers), &headers)
+	}
+	return headers
+}
+
+// FloorPriceOutbox 地板价更新消息：key 与消息体均为合集地址，触发事件放在消息头 trigger
+func FloorPriceOutbox(collection, trigger string) OutboxMessage {
+	headers, _ := json.Marshal(map[string]string{"trigger": trigger})
+	return OutboxMessage{
+		Kind:    OutboxKindFloorPrice,
+		MsgKey:  collection,
+		Payload: collection,
+		Headers: string(headers),
+	}
+}
+
+// Transaction 在同一事务中执行业务变更与发件箱写入，fn 内须使用传入的 txDao
+func (r *Dao) Transaction(fn func(txDao *Dao) error) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return fn(New(tx))
+	})
+}
+
+// 写入发件箱消息
+func (r *Dao) EnqueueOutbox(msgs ...OutboxMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	return r.DB.Create(&msgs).Error
+}
+
+// 按 ID 顺序查询待发布消息
+func (r *Dao) ListPendingOutbox(limit int) ([]OutboxMessage, error) {
+	var list []OutboxMessage
+	err := r.DB.Where("published_at = 0").Order("id ASC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// 标记消息已发布
+func (r *Dao) MarkOutboxPublished(ids []int64, publishedAt int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.DB.Model(&OutboxMessage{}).Where("id IN ?", ids).Update("published_at", publishedAt).Error
+}
+
+// 记录发布失败
+func (r *Dao) MarkOutboxFailed(id int64, errMsg string) error {
+	return r.DB.Model(&OutboxMessage{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": errMsg,
+	}).Error
+}
+
+// 删除早于指定时间的已发布消息
+func (r *Dao) DeletePublishedOutbox(before int64) (int64, error) {
+	result := r.DB.Where("published_at > 0 AND published_at < ?", before).Delete(&OutboxMessage{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,76 @@
+package dao
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+// errRefreshTokenRevoked 轮换事务中旧令牌已被吊销，用于回滚
+var errRefreshTokenRevoked = errors.New("refresh token revoked")
+
+// RefreshToken 刷新令牌，仅保存哈希；每次刷新轮换为同一 family 的新令牌，
+// 已轮换的旧令牌再次出现视为泄露，吊销整个 family
+type RefreshToken struct {
+	ID         int64     `gorm:"primaryKey;column:id" json:"id"`
+	UserID     int64     `gorm:"column:user_id;index" json:"user_id"`
+	TokenHash  string    `gorm:"column:token_hash;uniqueIndex" json:"-"`
+	FamilyID   string    `gorm:"column:family_id;index" json:"family_id"`
+	ExpiresAt  int64     `gorm:"column:expires_at" json:"expires_at"`
+	RevokedAt  int64     `gorm:"column:revoked_at" json:"revoked_at,omitempty"` // 0 表示有效
+	ReplacedBy int64     `gorm:"column:replaced_by" json:"replaced_by,omitempty"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+}
+
+// 写入刷新令牌
+func (r *Dao) CreateRefreshToken(t *RefreshToken) error {
+	return r.DB.Create(t).Error
+}
+
+// 按哈希查询刷新令牌，不存在返回 nil
+func (r *Dao) GetRefreshTokenByHash(hash string) (*RefreshToken, error) {
+	var t RefreshToken
+	if err := r.DB.Where("token_hash = ?", hash).First(&t).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// RotateRefreshToken 吊销旧令牌并写入新令牌，旧令牌已被吊销（并发刷新或重放）时返回 false
+func (r *Dao) RotateRefreshToken(oldID int64, next *RefreshToken) (bool, error) {
+	rotated := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&RefreshToken{}).Where("id = ? AND revoked_at = 0", oldID).
+			Updates(map[string]interface{}{"revoked_at": time.Now().Unix(), "replaced_by": next.ID})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errRefreshTokenRevoked
+		}
+		rotated = true
+		return nil
+	})
+	if err == errRefreshTokenRevoked {
+		return false, nil
+	}
+	return rotated, err
+}
+
+// 吊销 family 内全部有效令牌
+func (r *Dao) RevokeRefreshTokenFamily(familyID string) error {
+	return r.DB.Model(&RefreshToken{}).Where("family_id = ? AND revoked_at = 0", familyID).
+		Update("revoked_at", time.Now().Unix()).Error
+}
+
+// 吊销用户全部有效令牌
+func (r *Dao) RevokeUserRefreshTokens(userID int64) error {
+	return r.DB.Model(&RefreshToken{}).Where("user_id = ? AND revoked_at = 0", userID).
+		Update("revoked_at", time.Now().Unix()).Error
+}
@@ -0,0 +1,220 @@
+package dao
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 成交来源
+const (
+	TradeSourceOrder       = "order"       // 自有订单合约 OrderFilled 事件
+	TradeSourceMarketplace = "marketplace" // 公开市场成交事件
+	TradeSourceTransfer    = "transfer"    // 由 Transfer 与同交易 ETH/WETH 转账推导
+)
+
+// 版税执行状态
+const (
+	RoyaltyStatusNone      = "none"      // 合集未设置版税
+	RoyaltyStatusPaid      = "paid"      // 已足额支付
+	RoyaltyStatusUnderpaid = "underpaid" // 少付
+	RoyaltyStatusSkipped   = "skipped"   // 未支付
+	RoyaltyStatusUnknown   = "unknown"   // 无法判断（如 ETH 推导成交的内部转账不可见）
+)
+
+// 版税来源
+const (
+	RoyaltySourceERC2981  = "erc2981"
+	RoyaltySourceRegistry = "registry"
+)
+
+// MarketplaceNative 自有订单合约的市场名称
+const MarketplaceNative = "native"
+
+// Trade 成交记录（销售账本）
+// Price/Fee/Royalty 为链上原始数值，PriceScaled 为按币种 decimals 缩放后的价格
+// PriceBase 在写入时折算，统计汇总使用该值，之后的汇率变化不影响历史成交
+type Trade struct {
+	ID              int64               `gorm:"primaryKey;column:id" json:"id"`
+	OrderID         string              `gorm:"column:order_id" json:"order_id"` // 订单ID或市场订单哈希
+	Marketplace     string              `gorm:"column:marketplace" json:"marketplace"`
+	Collection      string              `gorm:"column:collection;uniqueIndex:uk_trades_tx_token,priority:3;index:idx_trades_collection" json:"collection"`
+	TokenID         string              `gorm:"column:token_id;uniqueIndex:uk_trades_tx_token,priority:4" json:"token_id"`
+	Seller          string              `gorm:"column:seller;index" json:"seller"`
+	Buyer           string              `gorm:"column:buyer;index" json:"buyer"`
+	Price           decimal.Decimal     `gorm:"type:varchar(78);column:price" json:"price"`
+	PriceScaled     decimal.Decimal     `gorm:"type:decimal(38,18);column:price_scaled" json:"price_scaled"`
+	PriceBase       decimal.NullDecimal `gorm:"type:decimal(38,18);column:price_base" json:"-"` // 按成交写入时的汇率折算的基础币种价格，折算失败为 NULL
+	Currency        string              `gorm:"column:currency" json:"currency"`                // 支付币种地址，ETH为零地址
+	Fee             decimal.Decimal     `gorm:"type:varchar(78);column:fee" json:"fee"`
+	Royalty         decimal.Decimal     `gorm:"type:varchar(78);column:royalty" json:"royalty"`                   // 实际支付的版税
+	ExpectedRoyalty decimal.Decimal     `gorm:"type:varchar(78);column:expected_royalty" json:"expected_royalty"` // 按 ERC-2981 或版税登记计算的应付版税
+	RoyaltyReceiver string              `gorm:"column:royalty_receiver" json:"royalty_receiver"`
+	RoyaltySource   string              `gorm:"column:royalty_source" json:"royalty_source"`
+	RoyaltyStatus   string              `gorm:"column:royalty_status" json:"royalty_status"`
+	TxHash          string              `gorm:"column:tx_hash;uniqueIndex:uk_trades_tx_token,priority:1" json:"tx_hash"`
+	LogIndex        uint                `gorm:"column:log_index;uniqueIndex:uk_trades_tx_token,priority:2" json:"log_index"`
+	BlockNumber     uint64              `gorm:"column:block_number" json:"block_number"`
+	BlockTime       int64               `gorm:"column:block_time" json:"block_time"`
+	Source          string              `gorm:"column:source" json:"source"`
+	CreatedAt       time.Time           `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+}
+
+// 写入成交记录，重复事件自动跳过，返回是否新写入
+func (r *Dao) CreateTradeIgnoreConflict(trade *Trade) (bool, error) {
+	result := r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(trade)
+	return result.RowsAffected > 0, result.Error
+}
+
+// SaveEventTrade 写入由成交事件解析的记录，并删除同交易同 token 的推导成交，事件数据优先
+// 返回是否新写入，以及是否替换了推导成交
+func (r *Dao) SaveEventTrade(trade *Trade) (inserted, replaced bool, err error) {
+	err = r.DB.Transaction(func(tx *gorm.DB) error {
+		deleted := tx.Where("tx_hash = ? AND collection = ? AND token_id = ? AND source = ?",
+			trade.TxHash, trade.Collection, trade.TokenID, TradeSourceTransfer).Delete(&Trade{})
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(trade)
+		if created.Error != nil {
+			return created.Error
+		}
+		inserted, replaced = created.RowsAffected > 0, deleted.RowsAffected > 0
+		return nil
+	})
+	return inserted, replaced, err
+}
+
+// 判断交易中某 token 是否已有成交记录
+func (r *Dao) TradeExists(txHash, collection, tokenID string) (bool, error) {
+	var count int64
+	err := r.DB.Model(&Trade{}).
+		Where("tx_hash = ? AND collection = ? AND token_id = ?", txHash, collection, tokenID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// 查询单个 token 的成交历史，按区块倒序
+func (r *Dao) ListTradesByToken(collection, tokenID string, limit, offset int) ([]Trade, error) {
+	var trades []Trade
+	err := r.DB.Where("collection = ? AND token_id = ?", collection, tokenID).
+		Order("block_number DESC, log_index DESC").
+		Limit(limit).Offset(offset).
+		Find(&trades).Error
+	return trades, err
+}
+
+// 查询合集成交历史，按区块倒序
+func (r *Dao) ListTradesByCollection(collection string, limit, offset int) ([]Trade, error) {
+	var trades []Trade
+	err := r.DB.Where("collection = ?", collection).
+		Order("block_number DESC, log_index DESC").
+		Limit(limit).Offset(offset).
+		Find(&trades).Error
+	return trades, err
+}
+
+// 查询合集在时间范围内的成交，按成交顺序
+func (r *Dao) ListTradesInRange(collection string, from, to int64) ([]Trade, error) {
+	var trades []Trade
+	err := r.DB.Where("collection = ? AND block_time >= ? AND block_time < ?", collection, from, to).
+		Order("block_number ASC, log_index ASC").
+		Find(&trades).Error
+	return trades, err
+}
+
+// 查询合集首笔与最后一笔成交时间
+func (r *Dao) GetTradeTimeRange(collection string) (int64, int64, error) {
+	var res struct {
+		MinTime int64
+		MaxTime int64
+	}
+	err := r.DB.Model(&Trade{}).
+		Select("COALESCE(MIN(block_time),0) as min_time, COALESCE(MAX(block_time),0) as max_time").
+		Where("collection = ?", collection).
+		Scan(&res).Error
+	return res.MinTime, res.MaxTime, err
+}
+
+// RoyaltyReportRow 按市场、币种、版税状态汇总的成交
+type RoyaltyReportRow struct {
+	Marketplace   string
+	Currency      string
+	RoyaltyStatus string
+	Sales         int64
+	Expected      decimal.Decimal
+	Paid          decimal.Decimal
+}
+
+// 按市场汇总合集的版税执行情况，金额为链上原始数值
+// 金额列为 VARCHAR，SQL SUM 会按浮点数累加丢失精度，因此逐行读取后在应用内精确累加
+func (r *Dao) RoyaltyReportByMarketplace(collection string) ([]RoyaltyReportRow, error) {
+	rows, err := r.DB.Model(&Trade{}).
+		Select("marketplace, currency, royalty_status, expected_royalty, royalty").
+		Where("collection = ?", collection).
+		Order("marketplace, currency, royalty_status").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report []RoyaltyReportRow
+	index := make(map[[3]string]int)
+	for rows.Next() {
+		var marketplace, currency, status sql.NullString
+		var expected, paid decimal.NullDecimal
+		if err := rows.Scan(&marketplace, &currency, &status, &expected, &paid); err != nil {
+			return nil, err
+		}
+		key := [3]string{marketplace.String, currency.String, status.String}
+		i, ok := index[key]
+		if !ok {
+			i = len(report)
+			index[key] = i
+			report = append(report, RoyaltyReportRow{
+				Marketplace:   key[0],
+				Currency:      key[1],
+				RoyaltyStatus: key[2],
+			})
+		}
+		row := &report[i]
+		row.Sales++
+		if expected.Valid {
+			row.Expected = row.Expected.Add(expected.Decimal)
+		}
+		if paid.Valid {
+			row.Paid = row.Paid.Add(paid.Decimal)
+		}
+	}
+	return report, rows.Err()
+}
+
+// 按 ID 分批扫描指定时间之后的成交
+func (r *Dao) ListTradesSince(since, afterID int64, limit int) ([]Trade, error) {
+	var trades []Trade
+	err := r.DB.Where("block_time >= ? AND id > ?", since, afterID).
+		Order("id ASC").Limit(limit).Find(&trades).Error
+	return trades, err
+}
+
+// 查询地址作为买方或卖方的全部成交，按成交顺序
+func (r *Dao) ListTradesByWallet(address string) ([]Trade, error) {
+	var trades []Trade
+	err := r.DB.Where("buyer = ? OR seller = ?", address, address).
+		Order("block_number ASC, log_index ASC").
+		Find(&trades).Error
+	return trades, err
+}
+
+// 查询合集内指定 token 的成交，按区块倒序
+func (r *Dao) ListTradesByTokens(collection string, tokenIDs []string) ([]Trade, error) {
+	var trades []Trade
+	err := r.DB.Where("collection = ? AND token_id IN ?", collection, tokenIDs).
+		Order("block_number DESC, log_index DESC").
+		Find(&trades).Error
+	return trades, err
+}
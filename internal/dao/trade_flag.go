@@ -0,0 +1,102 @@
+package dao
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// 可疑成交标记原因
+const (
+	FlagReasonSelfTrade      = "self_trade"      // 买卖双方为同一地址
+	FlagReasonCommonFunder   = "common_funder"   // 买卖双方资金来自同一地址
+	FlagReasonRoundTrip      = "round_trip"      // token 在少数地址间循环成交
+	FlagReasonPriceDeviation = "price_deviation" // 成交价严重偏离地板价
+	FlagReasonRapidFlip      = "rapid_flip"      // 买入后短时间内转手
+)
+
+// 标记审核状态，dismissed 的成交重新计入统计
+const (
+	FlagStatusPending   = "pending"
+	FlagStatusConfirmed = "confirmed"
+	FlagStatusDismissed = "dismissed"
+)
+
+// TradeFlag 可疑成交标记，同一成交的每个原因一条记录
+type TradeFlag struct {
+	ID         int64     `gorm:"primaryKey;column:id" json:"id"`
+	TradeID    int64     `gorm:"column:trade_id;uniqueIndex:uk_trade_flags_reason,priority:1" json:"trade_id"`
+	Reason     string    `gorm:"column:reason;uniqueIndex:uk_trade_flags_reason,priority:2" json:"reason"`
+	Collection string    `gorm:"column:collection;index:idx_trade_flags_collection" json:"collection"`
+	TokenID    string    `gorm:"column:token_id" json:"token_id"`
+	TxHash     string    `gorm:"column:tx_hash" json:"tx_hash"`
+	Detail     string    `gorm:"column:detail" json:"detail"` // 触发规则的说明，如共同资金来源地址、偏离倍数
+	Status     string    `gorm:"column:status" json:"status"`
+	ReviewedBy string    `gorm:"column:reviewed_by" json:"reviewed_by,omitempty"`
+	ReviewNote string    `gorm:"column:review_note" json:"review_note,omitempty"`
+	ReviewedAt int64     `gorm:"column:reviewed_at" json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+}
+
+// 批量写入标记，同一成交同一原因已存在时跳过，保留人工审核结果
+func (r *Dao) CreateTradeFlagsIgnoreConflict(flags []TradeFlag) error {
+	if len(flags) == 0 {
+		return nil
+	}
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&flags).Error
+}
+
+// TradeFlagFilter 标记查询条件，空值不过滤
+type TradeFlagFilter struct {
+	Collection string
+	Reason     string
+	Status     string
+}
+
+// 分页查询标记，按创建倒序
+func (r *Dao) ListTradeFlags(filter TradeFlagFilter, limit, offset int) ([]TradeFlag, int64, error) {
+	query := r.DB.Model(&TradeFlag{})
+	if filter.Collection != "" {
+		query = query.Where("collection = ?", filter.Collection)
+	}
+	if filter.Reason != "" {
+		query = query.Where("reason = ?", filter.Reason)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []TradeFlag
+	err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&list).Error
+	return list, total, err
+}
+
+// 审核标记
+func (r *Dao) ReviewTradeFlag(id int64, status, reviewer, note string) error {
+	return r.DB.Model(&TradeFlag{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":      status,
+		"reviewed_by": reviewer,
+		"review_note": note,
+		"reviewed_at": time.Now().Unix(),
+	}).Error
+}
+
+// 查询标记
+func (r *Dao) GetTradeFlag(id int64) (*TradeFlag, error) {
+	var flag TradeFlag
+	if err := r.DB.Where("id = ?", id).First(&flag).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &flag, nil
+}
+
+// excludeFlaggedTrades 排除存在未驳回标记的成交
+func excludeFlaggedTrades(db *gorm.DB) *gorm.DB {
+	return db.Where("NOT EXISTS (SELECT 1 FROM trade_flags f WHERE f.trade_id = trades.id AND f.status <> ?)", FlagStatusDismissed)
+}
@@ -0,0 +1,55 @@
+package dao
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Transfer NFT 转移记录（含铸造与销毁），用于成交推导和持有历史
+type Transfer struct {
+	ID          int64     `gorm:"primaryKey;column:id" json:"id"`
+	Collection  string    `gorm:"column:collection;index:idx_transfers_token,priority:1" json:"collection"`
+	TokenID     string    `gorm:"column:token_id;index:idx_transfers_token,priority:2" json:"token_id"`
+	From        string    `gorm:"column:from_addr;index" json:"from"`
+	To          string    `gorm:"column:to_addr;index" json:"to"`
+	TxHash      string    `gorm:"column:tx_hash;uniqueIndex:uk_transfers_tx_log,priority:1" json:"tx_hash"`
+	LogIndex    uint      `gorm:"column:log_index;uniqueIndex:uk_transfers_tx_log,priority:2" json:"log_index"`
+	BlockNumber uint64    `gorm:"column:block_number" json:"block_number"`
+	BlockTime   int64     `gorm:"column:block_time" json:"block_time"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+}
+
+func (Transfer) TableName() string {
+	return "nft_transfers"
+}
+
+// 写入转移记录，重复事件自动跳过
+func (r *Dao) CreateTransferIgnoreConflict(transfer *Transfer) error {
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(transfer).Error
+}
+
+// 查询 token 最近一次转移
+func (r *Dao) GetLatestTransfer(collection, tokenID string) (*Transfer, error) {
+	var transfer Transfer
+	err := r.DB.Where("collection = ? AND token_id = ?", collection, tokenID).
+		Order("block_number DESC, log_index DESC").
+		First(&transfer).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// 查询地址转入与转出的全部转移记录，按区块正序
+func (r *Dao) ListTransfersByWallet(address string) ([]Transfer, error) {
+	var transfers []Transfer
+	err := r.DB.Where("from_addr = ? OR to_addr = ?", address, address).
+		Order("block_number ASC, log_index ASC").
+		Find(&transfers).Error
+	return transfers, err
+}
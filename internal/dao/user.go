@@ -11,17 +11,36 @@ type User struct {
 	Email        string    `json:"email" gorm:"uniqueIndex"`
 	PasswordHash string    `json:"-"`
 	WalletAddr   string    `json:"wallet_addr" gorm:"uniqueIndex"`
+	Role         string    `json:"role" gorm:"column:role;default:user"` // user / operator / admin
 	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
 }
 
 // Dao 用户数据访问对象
 // 推荐在 service 层注入 DB 实例，避免全局 DB
 
-// 创建用户
+// 创建用户，邮箱或钱包为空时写入 NULL，避免唯一索引冲突（钱包登录用户没有邮箱）
 func (r *Dao) CreateUser(user *User) error {
+	var omit []string
+	if user.Email == "" {
+		omit = append(omit, "email")
+	}
+	if user.WalletAddr == "" {
+		omit = append(omit, "wallet_addr")
+	}
+	if len(omit) > 0 {
+		return r.DB.Omit(omit...).Create(user).Error
+	}
 	return r.DB.Create(user).Error
 }
 
+// 为未绑定钱包的用户绑定钱包地址，返回是否绑定成功
+func (r *Dao) LinkUserWallet(userID int64, walletAddr string) (bool, error) {
+	res := r.DB.Model(&User{}).
+		Where("id = ? AND (wallet_addr IS NULL OR wallet_addr = '')", userID).
+		Update("wallet_addr", walletAddr)
+	return res.RowsAffected > 0, res.Error
+}
+
 // 通过邮箱查找用户
 func (r *Dao) GetUserByEmail(email string) (*User, error) {
 	var user User
@@ -68,3 +87,20 @@ func (r *Dao) UserExists(email, walletAddr string) (bool, error) {
 	}
 	return false, nil
 }
+
+// 通过 ID 查找用户
+func (r *Dao) GetUserByID(id int64) (*User, error) {
+	var user User
+	if err := r.DB.Where("id = ?", id).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// 修改用户角色
+func (r *Dao) UpdateUserRole(id int64, role string) error {
+	return r.DB.Model(&User{}).Where("id = ?", id).Update("role", role).Error
+}
@@ -0,0 +1,242 @@
+package dao
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// 投递状态
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusFailed    = "failed" // 重试次数用尽
+)
+
+// WebhookEndpoint 用户注册的 webhook 地址，过滤条件为逗号分隔，空值不过滤
+type WebhookEndpoint struct {
+	ID                  int64     `gorm:"primaryKey;column:id" json:"id"`
+	UserID              string    `gorm:"column:user_id;index" json:"user_id"`
+	URL                 string    `gorm:"column:url" json:"url"`
+	Secret              string    `gorm:"column:secret" json:"-"`
+	Collections         string    `gorm:"column:collections" json:"collections"`
+	Wallets             string    `gorm:"column:wallets" json:"wallets"`
+	Types               string    `gorm:"column:types" json:"types"`
+	Enabled             bool      `gorm:"column:enabled" json:"enabled"`
+	ConsecutiveFailures int       `gorm:"column:consecutive_failures" json:"consecutive_failures"`
+	DisabledReason      string    `gorm:"column:disabled_reason" json:"disabled_reason,omitempty"`
+	DisabledAt          int64     `gorm:"column:disabled_at" json:"disabled_at,omitempty"`
+	CreatedAt           time.Time `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+	UpdatedAt           time.Time `gorm:"autoUpdateTime;column:updated_at" json:"updated_at"`
+}
+
+// WebhookDelivery webhook 投递发件箱，每个事件对每个匹配的 endpoint 一条
+type WebhookDelivery struct {
+	ID             int64     `gorm:"primaryKey;column:id" json:"id"`
+	EndpointID     int64     `gorm:"column:endpoint_id;uniqueIndex:uk_webhook_deliveries_event,priority:1" json:"endpoint_id"`
+	EventID        string    `gorm:"column:event_id;uniqueIndex:uk_webhook_deliveries_event,priority:2" json:"event_id"` // 如 activity:123、floor:45
+	EventType      string    `gorm:"column:event_type" json:"event_type"`
+	Payload        string    `gorm:"type:text;column:payload" json:"payload"`
+	Status         string    `gorm:"column:status;index:idx_webhook_deliveries_due,priority:1" json:"status"`
+	Attempts       int       `gorm:"column:attempts" json:"attempts"`
+	NextAttemptAt  int64     `gorm:"column:next_attempt_at;index:idx_webhook_deliveries_due,priority:2" json:"next_attempt_at"`
+	LastStatusCode int       `gorm:"column:last_status_code" json:"last_status_code,omitempty"`
+	LastError      string    `gorm:"column:last_error" json:"last_error,omitempty"`
+	DeliveredAt    int64     `gorm:"column:delivered_at" json:"delivered_at,omitempty"`
+	CreatedAt      time.Time `gorm:"autoCreateTime;column:created_at" json:"created_at"`
+}
+
+// WebhookCursor 事件扫描进度，source 为事件来源表
+type WebhookCursor struct {
+	Source string `gorm:"primaryKey;column:source"`
+	LastID int64  `gorm:"column:last_id"`
+}
+
+// 创建 endpoint
+func (r *Dao) CreateWebhookEndpoint(e *WebhookEndpoint) error {
+	return r.DB.Create(e).Error
+}
+
+// 查询 endpoint，不存在返回 nil
+func (r *Dao) GetWebhookEndpoint(id int64) (*WebhookEndpoint, error) {
+	var e WebhookEndpoint
+	if err := r.DB.Where("id = ?", id).First(&e).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+// 查询用户的 endpoint
+func (r *Dao) ListWebhookEndpointsByUser(userID string) ([]WebhookEndpoint, error) {
+	var list []WebhookEndpoint
+	err := r.DB.Where("user_id = ?", userID).Order("id ASC").Find(&list).Error
+	return list, err
+}
+
+// 查询启用中的 endpoint
+func (r *Dao) ListEnabledWebhookEndpoints() ([]WebhookEndpoint, error) {
+	var list []WebhookEndpoint
+	err := r.DB.Where("enabled = ?", true).Find(&list).Error
+	return list, err
+}
+
+// 按 URL 查询系统级 endpoint，不存在返回 nil
+func (r *Dao) GetWebhookEndpointByURL(userID, url string) (*WebhookEndpoint, error) {
+	var e WebhookEndpoint
+	if err := r.DB.Where("user_id = ? AND url = ?", userID, url).First(&e).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+// 删除 endpoint 及其投递记录
+func (r *Dao) DeleteWebhookEndpoint(id int64) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("endpoint_id = ?", id).Delete(&WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&WebhookEndpoint{}).Error
+	})
+}
+
+// 启用 endpoint 并清零连续失败次数
+func (r *Dao) EnableWebhookEndpoint(id int64) error {
+	return r.DB.Model(&WebhookEndpoint{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"enabled":              true,
+		"consecutive_failures": 0,
+		"disabled_reason":      "",
+		"disabled_at":          0,
+	}).Error
+}
+
+// 投递成功，清零 endpoint 连续失败次数
+func (r *Dao) ResetWebhookFailures(id int64) error {
+	return r.DB.Model(&WebhookEndpoint{}).Where("id = ? AND consecutive_failures <> 0", id).
+		Update("consecutive_failures", 0).Error
+}
+
+// 投递失败，累加 endpoint 连续失败次数，达到阈值时停用，返回是否本次停用
+func (r *Dao) IncrWebhookFailures(id int64, disableAfter int, reason string) (bool, error) {
+	if err := r.DB.Model(&WebhookEndpoint{}).Where("id = ?", id).
+		Update("consecutive_failures", gorm.Expr("consecutive_failures + 1")).Error; err != nil {
+		return false, err
+	}
+	result := r.DB.Model(&WebhookEndpoint{}).
+		Where("id = ? AND enabled = ? AND consecutive_failures >= ?", id, true, disableAfter).
+		Updates(map[string]interface{}{
+			"enabled":         false,
+			"disabled_reason": reason,
+			"disabled_at":     time.Now().Unix(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// 写入单条投递记录
+func (r *Dao) CreateWebhookDelivery(d *WebhookDelivery) error {
+	return r.DB.Create(d).Error
+}
+
+// 批量写入投递记录，同一事件同一 endpoint 已存在时跳过
+func (r *Dao) CreateWebhookDeliveriesIgnoreConflict(list []WebhookDelivery) error {
+	if len(list) == 0 {
+		return nil
+	}
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&list).Error
+}
+
+// 查询到期待投递的记录，仅包含启用中的 endpoint
+func (r *Dao) ListDueWebhookDeliveries(now int64, limit int) ([]WebhookDelivery, error) {
+	var list []WebhookDelivery
+	err := r.DB.Where("status = ? AND next_attempt_at <= ?", DeliveryStatusPending, now).
+		Where("EXISTS (SELECT 1 FROM webhook_endpoints e WHERE e.id = webhook_deliveries.endpoint_id AND e.enabled = ?)", true).
+		Order("next_attempt_at ASC, id ASC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// 抢占投递记录：next_attempt_at 未被其他实例修改时顺延到 leaseUntil，返回是否抢占成功
+func (r *Dao) ClaimWebhookDelivery(id, nextAttemptAt, leaseUntil int64) (bool, error) {
+	result := r.DB.Model(&WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", id, DeliveryStatusPending, nextAttemptAt).
+		Update("next_attempt_at", leaseUntil)
+	return result.RowsAffected > 0, result.Error
+}
+
+// 更新投递结果
+func (r *Dao) UpdateWebhookDelivery(id int64, fields map[string]interface{}) error {
+	return r.DB.Model(&WebhookDelivery{}).Where("id = ?", id).Updates(fields).Error
+}
+
+// WebhookDeliveryFilter 投递记录查询条件，空值不过滤
+type WebhookDeliveryFilter struct {
+	EndpointID int64
+	Status     string
+	Since      int64 // 创建时间（秒）下限
+}
+
+func (f WebhookDeliveryFilter) apply(db *gorm.DB) *gorm.DB {
+	db = db.Where("endpoint_id = ?", f.EndpointID)
+	if f.Status != "" {
+		db = db.Where("status = ?", f.Status)
+	}
+	if f.Since > 0 {
+		db = db.Where("created_at >= ?", time.Unix(f.Since, 0))
+	}
+	return db
+}
+
+// 分页查询投递记录，按创建倒序
+func (r *Dao) ListWebhookDeliveries(filter WebhookDeliveryFilter, limit, offset int) ([]WebhookDelivery, int64, error) {
+	query := filter.apply(r.DB.Model(&WebhookDelivery{}))
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []WebhookDelivery
+	err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&list).Error
+	return list, total, err
+}
+
+// 重放投递记录：重置为待投递并清零重试次数，ids 非空时仅重放指定记录，返回重放数量
+func (r *Dao) ReplayWebhookDeliveries(filter WebhookDeliveryFilter, ids []int64) (int64, error) {
+	query := filter.apply(r.DB.Model(&WebhookDelivery{}))
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	result := query.Updates(map[string]interface{}{
+		"status":          DeliveryStatusPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now().Unix(),
+		"last_error":      "",
+	})
+	return result.RowsAffected, result.Error
+}
+
+// 删除早于指定时间的已投递记录
+func (r *Dao) DeleteDeliveredWebhookDeliveries(before int64) (int64, error) {
+	result := r.DB.Where("status = ? AND delivered_at < ?", DeliveryStatusDelivered, before).Delete(&WebhookDelivery{})
+	return result.RowsAffected, result.Error
+}
+
+// 查询事件扫描进度，无记录返回 -1
+func (r *Dao) GetWebhookCursor(source string) (int64, error) {
+	var c WebhookCursor
+	if err := r.DB.Where("source = ?", source).First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return -1, nil
+		}
+		return 0, err
+	}
+	return c.LastID, nil
+}
+
+// 保存事件扫描进度
+func (r *Dao) SaveWebhookCursor(source string, lastID int64) error {
+	return r.DB.Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(&WebhookCursor{Source: source, LastID: lastID}).Error
+}
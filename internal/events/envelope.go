@@ -0,0 +1,151 @@
+package events
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SchemaVersion 事件信封版本，字段不兼容变更时递增，并随之切换 topic 后缀
+const SchemaVersion = 1
+
+// 领域事件类型
+const (
+	TypeNFTMinted          = "nft.minted"
+	TypeNFTTransferred     = "nft.transferred"
+	TypeNFTMetadataUpdated = "nft.metadata_updated"
+	TypeOrderCreated       = "order.created"
+	TypeOrderCancelled     = "order.cancelled"
+	TypeOrderFilled        = "order.filled"
+	TypeFloorChanged       = "floor.changed"
+)
+
+// Types 全部事件类型
+var Types = []string{
+	TypeNFTMinted, TypeNFTTransferred, TypeNFTMetadataUpdated,
+	TypeOrderCreated, TypeOrderCancelled, TypeOrderFilled,
+	TypeFloorChanged,
+}
+
+// 编码方式
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+)
+
+// Kafka 消息头
+const (
+	HeaderContentType   = "content-type"
+	HeaderEventType     = "event-type"
+	HeaderSchemaVersion = "schema-version"
+)
+
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+var ErrUnknownEncoding = errors.New("未知的事件编码")
+
+// Envelope 领域事件信封，ID 由事件内容确定，重复投递时不变，消费方据此去重
+// 链下事件（签名订单、地板价变化）没有交易，TxHash 为空，LogIndex 为 -1
+type Envelope struct {
+	Version     int             `json:"version"`
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	ChainID     int64           `json:"chain_id"`
+	BlockNumber uint64          `json:"block_number"`
+	TxHash      string          `json:"tx_hash,omitempty"`
+	LogIndex    int             `json:"log_index"`
+	Timestamp   int64           `json:"timestamp"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+// NFTPayload NFT 铸造、转移与元数据更新事件
+type NFTPayload struct {
+	Collection string `json:"collection"`
+	TokenID    string `json:"token_id"`
+	From       string `json:"from,omitempty"`
+	To         string `json:"to,omitempty"`
+}
+
+// OrderPayload 订单创建、取消与成交事件，Price 为链上原始数值
+// order.filled 同时覆盖公开市场成交（OrderID 为市场订单哈希）与转账推导成交（OrderID 为空），
+// 两者的 Maker 为卖家、Taker 为买家；推导成交随后被市场事件替换时会再发布一条，
+// 消费方可按 tx_hash + collection + token_id 合并
+type OrderPayload struct {
+	OrderID     string `json:"order_id"`
+	Collection  string `json:"collection"`
+	TokenID     string `json:"token_id,omitempty"` // 合集出价为空
+	Maker       string `json:"maker"`
+	Taker       string `json:"taker,omitempty"` // 仅成交事件
+	Price       string `json:"price"`
+	Currency    string `json:"currency"`
+	Marketplace string `json:"marketplace,omitempty"`
+}
+
+// FloorPayload 地板价变化事件，价格已折算为基础币种
+type FloorPayload struct {
+	Collection string `json:"collection"`
+	Price      string `json:"price"` // 空字符串表示合集已无挂单
+	Currency   string `json:"currency"`
+	Trigger    string `json:"trigger"`
+}
+
+// New 构造信封，payload 编码为 JSON
+func New(typ string, payload interface{}) (*Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{Version: SchemaVersion, Type: typ, Payload: data}, nil
+}
+
+// EventID 由能唯一标识事件的字段计算确定性 ID
+func EventID(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// DefaultTopic 事件类型对应的默认 topic，如 nftsync.order.filled.v1
+func DefaultTopic(typ string) string {
+	return fmt.Sprintf("nftsync.%s.v%d", typ, SchemaVersion)
+}
+
+// Topic 优先使用配置覆盖的 topic
+func Topic(overrides map[string]string, typ string) string {
+	if t := overrides[typ]; t != "" {
+		return t
+	}
+	return DefaultTopic(typ)
+}
+
+// Encode 按编码方式序列化信封，返回消息体与 content-type
+func Encode(e *Envelope, encoding string) ([]byte, string, error) {
+	switch encoding {
+	case "", EncodingJSON:
+		data, err := json.Marshal(e)
+		return data, ContentTypeJSON, err
+	case EncodingProtobuf:
+		return MarshalProto(e), ContentTypeProtobuf, nil
+	}
+	return nil, "", fmt.Errorf("%w: %s", ErrUnknownEncoding, encoding)
+}
+
+// Decode 按 content-type 反序列化信封，供下游消费方使用
+func Decode(data []byte, contentType string) (*Envelope, error) {
+	switch contentType {
+	case "", ContentTypeJSON:
+		var e Envelope
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return &e, nil
+	case ContentTypeProtobuf:
+		return UnmarshalProto(data)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownEncoding, contentType)
+}
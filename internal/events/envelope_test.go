@@ -0,0 +1,97 @@
+package events
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func testEnvelopes(t *testing.T) []*Envelope {
+	order, err := New(TypeOrderFilled, OrderPayload{
+		OrderID:    "0xorder",
+		Collection: "0xCollection",
+		TokenID:    "0x01",
+		Maker:      "0xSeller",
+		Taker:      "0xBuyer",
+		Price:      "1000000000000000000",
+		Currency:   "0x0000000000000000000000000000000000000000",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	order.ID = EventID(TypeOrderFilled, "0xtx", "3")
+	order.ChainID = 1
+	order.BlockNumber = 18000000
+	order.TxHash = "0xtx"
+	order.LogIndex = 3
+	order.Timestamp = 1700000000
+
+	// 链下事件：无交易哈希，log_index 为 -1
+	floor, err := New(TypeFloorChanged, FloorPayload{Collection: "0xCollection", Price: "", Currency: "ETH", Trigger: "order_cancelled"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	floor.ID = EventID(TypeFloorChanged, "0xCollection", "7")
+	floor.ChainID = 1
+	floor.LogIndex = -1
+	floor.Timestamp = 1700000001
+	return []*Envelope{order, floor}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	for _, encoding := range []string{EncodingJSON, EncodingProtobuf} {
+		for _, want := range testEnvelopes(t) {
+			data, contentType, err := Encode(want, encoding)
+			if err != nil {
+				t.Fatalf("%s encode %s: %v", encoding, want.Type, err)
+			}
+			got, err := Decode(data, contentType)
+			if err != nil {
+				t.Fatalf("%s decode %s: %v", encoding, want.Type, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%s roundtrip %s:\n got  %+v\n want %+v", encoding, want.Type, got, want)
+			}
+		}
+	}
+}
+
+func TestDecodeProtoSkipsUnknownFields(t *testing.T) {
+	want := testEnvelopes(t)[1]
+	data := MarshalProto(want)
+	data = protowire.AppendTag(data, 100, protowire.BytesType)
+	data = protowire.AppendString(data, "future")
+	data = protowire.AppendTag(data, 101, protowire.VarintType)
+	data = protowire.AppendVarint(data, 42)
+
+	got, err := UnmarshalProto(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeProtoRejectsTruncated(t *testing.T) {
+	data := MarshalProto(testEnvelopes(t)[0])
+	if _, err := UnmarshalProto(data[:len(data)-1]); !errors.Is(err, errInvalidProto) {
+		t.Fatalf("err = %v, want errInvalidProto", err)
+	}
+}
+
+func TestUnknownEncoding(t *testing.T) {
+	e := testEnvelopes(t)[0]
+	if _, _, err := Encode(e, "avro"); !errors.Is(err, ErrUnknownEncoding) {
+		t.Fatalf("encode err = %v", err)
+	}
+	if _, err := Decode([]byte("{}"), "application/avro"); !errors.Is(err, ErrUnknownEncoding) {
+		t.Fatalf("decode err = %v", err)
+	}
+	if data, _, _ := Encode(e, ""); !bytes.HasPrefix(data, []byte("{")) {
+		t.Fatalf("default encoding is not JSON: %s", data)
+	}
+}
@@ -0,0 +1,104 @@
+package events
+
+import (
+	"errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Protobuf 字段编号，与 envelope.proto 保持一致；payload 为 JSON 编码的事件内容
+const (
+	fieldVersion     protowire.Number = 1
+	fieldID          protowire.Number = 2
+	fieldType        protowire.Number = 3
+	fieldChainID     protowire.Number = 4
+	fieldBlockNumber protowire.Number = 5
+	fieldTxHash      protowire.Number = 6
+	fieldLogIndex    protowire.Number = 7 // sint32，链下事件为 -1
+	fieldTimestamp   protowire.Number = 8
+	fieldPayload     protowire.Number = 9
+)
+
+var errInvalidProto = errors.New("事件 protobuf 解码失败")
+
+// MarshalProto 按 envelope.proto 编码信封
+func MarshalProto(e *Envelope) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, fieldVersion, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(e.Version))
+	b = protowire.AppendTag(b, fieldID, protowire.BytesType)
+	b = protowire.AppendString(b, e.ID)
+	b = protowire.AppendTag(b, fieldType, protowire.BytesType)
+	b = protowire.AppendString(b, e.Type)
+	b = protowire.AppendTag(b, fieldChainID, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(e.ChainID))
+	b = protowire.AppendTag(b, fieldBlockNumber, protowire.VarintType)
+	b = protowire.AppendVarint(b, e.BlockNumber)
+	if e.TxHash != "" {
+		b = protowire.AppendTag(b, fieldTxHash, protowire.BytesType)
+		b = protowire.AppendString(b, e.TxHash)
+	}
+	b = protowire.AppendTag(b, fieldLogIndex, protowire.VarintType)
+	b = protowire.AppendVarint(b, protowire.EncodeZigZag(int64(e.LogIndex)))
+	b = protowire.AppendTag(b, fieldTimestamp, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(e.Timestamp))
+	b = protowire.AppendTag(b, fieldPayload, protowire.BytesType)
+	b = protowire.AppendBytes(b, e.Payload)
+	return b
+}
+
+// UnmarshalProto 解码信封，忽略未知字段以兼容后续新增字段
+func UnmarshalProto(b []byte) (*Envelope, error) {
+	e := &Envelope{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, errInvalidProto
+		}
+		b = b[n:]
+		switch {
+		case typ == protowire.VarintType && (num == fieldVersion || num == fieldChainID ||
+			num == fieldBlockNumber || num == fieldLogIndex || num == fieldTimestamp):
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return nil, errInvalidProto
+			}
+			b = b[n:]
+			switch num {
+			case fieldVersion:
+				e.Version = int(v)
+			case fieldChainID:
+				e.ChainID = int64(v)
+			case fieldBlockNumber:
+				e.BlockNumber = v
+			case fieldLogIndex:
+				e.LogIndex = int(protowire.DecodeZigZag(v))
+			case fieldTimestamp:
+				e.Timestamp = int64(v)
+			}
+		case typ == protowire.BytesType && (num == fieldID || num == fieldType ||
+			num == fieldTxHash || num == fieldPayload):
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, errInvalidProto
+			}
+			b = b[n:]
+			switch num {
+			case fieldID:
+				e.ID = string(v)
+			case fieldType:
+				e.Type = string(v)
+			case fieldTxHash:
+				e.TxHash = string(v)
+			case fieldPayload:
+				e.Payload = append([]byte(nil), v...)
+			}
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return nil, errInvalidProto
+			}
+			b = b[n:]
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,189 @@
+package matching
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gavin/nftSync/internal/dao"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Engine 撮合引擎：挂单对单品出价（同一 token）、挂单对合集出价（同一合集，可带属性条件）
+// 出价按价格从高到低、时间从早到晚依次撮合，每个出价取价格最低、时间最早的可成交挂单。
+// 撮合结果由 Store 与订单状态在同一事务中记录，经发件箱发布给结算组件
+type Engine struct {
+	Store OrderStore
+	Fees  FeeCalculator
+	// Equivalent 可互相成交的币种（如 ETH 挂单与 WETH 出价），地址 -> 等价组名
+	Equivalent map[string]string
+}
+
+func NewEngine(store OrderStore, fees FeeCalculator) *Engine {
+	return &Engine{Store: store, Fees: fees, Equivalent: map[string]string{}}
+}
+
+// SetEquivalent 将若干币种设为等价，如 ETH 与 WETH
+func (e *Engine) SetEquivalent(group string, currencies ...string) {
+	for _, c := range currencies {
+		e.Equivalent[common.HexToAddress(c).Hex()] = group
+	}
+}
+
+// MatchCollection 撮合合集内所有交叉订单，返回已成功撮合的结果
+func (e *Engine) MatchCollection(ctx context.Context, collection string) ([]Match, error) {
+	orders, err := e.Store.OpenOrders(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	var listings, bids []dao.Order
+	for _, order := range orders {
+		switch order.OrderType {
+		case dao.OrderTypeItemBid, dao.OrderTypeCollectionBid:
+			bids = append(bids, order)
+		default:
+			listings = append(listings, order)
+		}
+	}
+	if len(listings) == 0 || len(bids) == 0 {
+		return nil, nil
+	}
+	sortByPriority(listings, false)
+	sortByPriority(bids, true)
+
+	traitCache := map[string]map[string]string{}
+	used := make([]bool, len(listings))
+	matches := []Match{}
+	for i := range bids {
+		bid := &bids[i]
+		criteria, err := ParseCriteria(bid.Criteria)
+		if err != nil {
+			log.Printf("[matching] 出价属性条件无效，跳过: orderId=%s, err=%v", bid.OrderID, err)
+			continue
+		}
+		for j := range listings {
+			listing := &listings[j]
+			if used[j] || !e.crosses(listing, bid) {
+				continue
+			}
+			if criteria != nil {
+				traits, err := e.tokenTraits(ctx, traitCache, listing)
+				if err != nil {
+					log.Printf("[matching] 查询 token 属性失败: tokenId=%s, err=%v", listing.TokenID, err)
+					continue
+				}
+				if !matchesCriteria(traits, criteria) {
+					continue
+				}
+			}
+			m := e.newMatch(ctx, listing, bid)
+			ok, err := e.Store.MatchOrders(ctx, &m)
+			if err != nil {
+				return matches, err
+			}
+			if !ok {
+				// 订单状态已被其他流程修改（取消、成交、失效）：出价失效时放弃该出价，挂单留给后续出价；
+				// 否则为挂单失效，挂单不再参与本轮撮合
+				bidListed, err := e.Store.OrderListed(ctx, bid.OrderID)
+				if err != nil {
+					return matches, err
+				}
+				if !bidListed {
+					break
+				}
+				used[j] = true
+				continue
+			}
+			used[j] = true
+			matches = append(matches, m)
+			break
+		}
+	}
+	return matches, nil
+}
+
+// crosses 挂单与出价是否可成交：标的匹配、价格交叉、币种等价且非自成交
+func (e *Engine) crosses(listing, bid *dao.Order) bool {
+	if bid.OrderType == dao.OrderTypeItemBid && listing.TokenID != bid.TokenID {
+		return false
+	}
+	if listing.TokenID == "" || strings.EqualFold(listing.Seller, bid.Seller) {
+		return false
+	}
+	if !e.sameCurrency(listing.Currency, bid.Currency) {
+		return false
+	}
+	return listing.Price.LessThanOrEqual(bid.Price)
+}
+
+func (e *Engine) sameCurrency(a, b string) bool {
+	a, b = common.HexToAddress(a).Hex(), common.HexToAddress(b).Hex()
+	if a == b {
+		return true
+	}
+	ga, okA := e.Equivalent[a]
+	gb, okB := e.Equivalent[b]
+	return okA && okB && ga == gb
+}
+
+func (e *Engine) tokenTraits(ctx context.Context, cache map[string]map[string]string, listing *dao.Order) (map[string]string, error) {
+	if traits, ok := cache[listing.TokenID]; ok {
+		return traits, nil
+	}
+	traits, err := e.Store.TokenTraits(ctx, listing.NFTToken, listing.TokenID)
+	if err != nil {
+		return nil, err
+	}
+	cache[listing.TokenID] = traits
+	return traits, nil
+}
+
+// newMatch 构造撮合结果，成交价取先挂出订单（maker）的价格，币种取出价币种
+func (e *Engine) newMatch(ctx context.Context, listing, bid *dao.Order) Match {
+	price := bid.Price
+	if listing.CreatedAt.Before(bid.CreatedAt) || (listing.CreatedAt.Equal(bid.CreatedAt) && listing.ID < bid.ID) {
+		price = listing.Price
+	}
+	m := Match{
+		ID:             listing.OrderID + ":" + bid.OrderID,
+		Collection:     listing.NFTToken,
+		TokenID:        listing.TokenID,
+		ListingOrderID: listing.OrderID,
+		BidOrderID:     bid.OrderID,
+		BidType:        bid.OrderType,
+		Seller:         listing.Seller,
+		Buyer:          bid.Seller,
+		Price:          price,
+		Currency:       bid.Currency,
+		Exchange:       listing.Exchange,
+		MatchedAt:      time.Now().Unix(),
+		Listing:        *listing,
+		Bid:            *bid,
+	}
+	fees, err := e.Fees.Fees(ctx, &m)
+	if err != nil {
+		log.Printf("[matching] 费用计算失败，按零费用处理: match=%s, err=%v", m.ID, err)
+	}
+	m.ProtocolFee = fees.Protocol
+	m.Royalty = fees.Royalty
+	m.SellerProceeds = price.Sub(fees.Protocol).Sub(fees.Royalty)
+	return m
+}
+
+// sortByPriority 价格-时间优先排序：desc 为 true 时价格从高到低（出价），否则从低到高（挂单）
+func sortByPriority(orders []dao.Order, desc bool) {
+	sort.SliceStable(orders, func(i, j int) bool {
+		a, b := &orders[i], &orders[j]
+		if !a.Price.Equal(b.Price) {
+			if desc {
+				return a.Price.GreaterThan(b.Price)
+			}
+			return a.Price.LessThan(b.Price)
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+}
@@ -0,0 +1,135 @@
+package matching
+
+import (
+	"context"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/shopspring/decimal"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testCollection = "0x00000000000000000000000000000000000000aa"
+
+var testBase = time.Unix(1700000000, 0)
+
+func testOrder(id int64, orderType, tokenID, seller string, price int64, age time.Duration) dao.Order {
+	return dao.Order{
+		ID:        id,
+		OrderID:   orderType + "-" + strconv.FormatInt(id, 10),
+		NFTToken:  testCollection,
+		TokenID:   tokenID,
+		Seller:    seller,
+		Price:     decimal.NewFromInt(price),
+		Currency:  "0x0000000000000000000000000000000000000000",
+		Status:    dao.OrderStatusListed,
+		OrderType: orderType,
+		CreatedAt: testBase.Add(age),
+	}
+}
+
+func newTestEngine(store *MemoryStore) *Engine {
+	return NewEngine(store, NewBpsFeeCalculator(0, 0, nil))
+}
+
+func TestMatchCollectionPriceTimePriority(t *testing.T) {
+	store := NewMemoryStore()
+	// 挂单：token 1 最便宜；token 2 与 token 3 同价，token 3 更早挂出
+	store.AddOrder(testOrder(1, dao.OrderTypeListing, "1", "0xs1", 90, 3*time.Second))
+	store.AddOrder(testOrder(2, dao.OrderTypeListing, "2", "0xs2", 95, 2*time.Second))
+	store.AddOrder(testOrder(3, dao.OrderTypeListing, "3", "0xs3", 95, time.Second))
+	// 合集出价：价格高者优先，同价时更早者优先
+	store.AddOrder(testOrder(10, dao.OrderTypeCollectionBid, "", "0xb1", 100, 5*time.Second))
+	store.AddOrder(testOrder(11, dao.OrderTypeCollectionBid, "", "0xb2", 100, 4*time.Second))
+	store.AddOrder(testOrder(12, dao.OrderTypeCollectionBid, "", "0xb3", 120, 6*time.Second))
+	store.AddOrder(testOrder(13, dao.OrderTypeCollectionBid, "", "0xb4", 80, 0))
+
+	matches, err := newTestEngine(store).MatchCollection(context.Background(), testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ bid, listing, price string }{
+		{"collectionbid-12", "listing-1", "90"}, // 最高出价对最低挂单，成交价取先挂出的挂单价
+		{"collectionbid-11", "listing-3", "95"}, // 同价出价中更早的先成交，同价挂单中更早的先成交
+		{"collectionbid-10", "listing-2", "95"},
+	}
+	if len(matches) != len(want) {
+		t.Fatalf("got %d matches, want %d: %+v", len(matches), len(want), matches)
+	}
+	for i, w := range want {
+		m := matches[i]
+		if m.BidOrderID != w.bid || m.ListingOrderID != w.listing || m.Price.String() != w.price {
+			t.Errorf("match %d = %s/%s@%s, want %s/%s@%s", i, m.BidOrderID, m.ListingOrderID, m.Price, w.bid, w.listing, w.price)
+		}
+	}
+	if got := len(store.Matches()); got != len(want) {
+		t.Fatalf("store recorded %d matches, want %d", got, len(want))
+	}
+	if o, _ := store.Order("collectionbid-13"); o.Status != dao.OrderStatusListed {
+		t.Fatalf("non-crossing bid status = %s", o.Status)
+	}
+}
+
+func TestMatchCollectionTraitCriteria(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetTraits(testCollection, "1", map[string]string{"Background": "Blue", "Eyes": "Laser"})
+	store.SetTraits(testCollection, "2", map[string]string{"Background": "Red", "Eyes": "Laser"})
+	// 较便宜的 token 2 不满足条件，属性出价应成交 token 1
+	store.AddOrder(testOrder(1, dao.OrderTypeListing, "1", "0xs1", 100, 0))
+	store.AddOrder(testOrder(2, dao.OrderTypeListing, "2", "0xs2", 50, 0))
+	bid := testOrder(10, dao.OrderTypeCollectionBid, "", "0xb1", 120, time.Second)
+	bid.Criteria = `{"Background":"Blue","Eyes":"Laser"}`
+	store.AddOrder(bid)
+	unmatched := testOrder(11, dao.OrderTypeCollectionBid, "", "0xb2", 200, time.Second)
+	unmatched.Criteria = `{"Background":"Gold"}`
+	store.AddOrder(unmatched)
+
+	matches, err := newTestEngine(store).MatchCollection(context.Background(), testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("got %d matches, want 1: %+v", len(matches), matches)
+	}
+	if m := matches[0]; m.BidOrderID != "collectionbid-10" || m.ListingOrderID != "listing-1" || m.Buyer != "0xb1" {
+		t.Fatalf("unexpected match %+v", m)
+	}
+	if o, _ := store.Order("listing-2"); o.Status != dao.OrderStatusListed {
+		t.Fatalf("listing without matching traits status = %s", o.Status)
+	}
+	if o, _ := store.Order("collectionbid-11"); o.Status != dao.OrderStatusListed {
+		t.Fatalf("bid without matching token status = %s", o.Status)
+	}
+}
+
+// staleStore 返回撮合开始时的订单快照，随后取消指定订单，模拟撮合期间订单被其他流程修改
+type staleStore struct {
+	*MemoryStore
+	cancel []string
+}
+
+func (s *staleStore) OpenOrders(ctx context.Context, collection string) ([]dao.Order, error) {
+	orders, err := s.MemoryStore.OpenOrders(ctx, collection)
+	s.mu.Lock()
+	for _, id := range s.cancel {
+		s.orders[id].Status = dao.OrderStatusCancelled
+	}
+	s.mu.Unlock()
+	return orders, err
+}
+
+func TestMatchCollectionSkipsCancelledBid(t *testing.T) {
+	store := &staleStore{MemoryStore: NewMemoryStore(), cancel: []string{"collectionbid-10"}}
+	store.AddOrder(testOrder(1, dao.OrderTypeListing, "1", "0xs1", 90, 0))
+	store.AddOrder(testOrder(10, dao.OrderTypeCollectionBid, "", "0xb1", 120, 0))
+	store.AddOrder(testOrder(11, dao.OrderTypeCollectionBid, "", "0xb2", 100, 0))
+
+	matches, err := NewEngine(store, NewBpsFeeCalculator(0, 0, nil)).MatchCollection(context.Background(), testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 已取消的出价不消耗挂单，挂单由下一个出价成交
+	if len(matches) != 1 || matches[0].BidOrderID != "collectionbid-11" || matches[0].ListingOrderID != "listing-1" {
+		t.Fatalf("matches = %+v", matches)
+	}
+}
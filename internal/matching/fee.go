@@ -0,0 +1,40 @@
+package matching
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+)
+
+var bpsBase = decimal.NewFromInt(10000)
+
+// BpsFeeCalculator 按万分比计算协议手续费与版税，版税可按合集覆盖
+type BpsFeeCalculator struct {
+	ProtocolBps       int64
+	DefaultRoyaltyBps int64
+	RoyaltyBps        map[string]int64 // 合集地址 -> 版税万分比
+}
+
+func NewBpsFeeCalculator(protocolBps, defaultRoyaltyBps int64, royaltyBps map[string]int64) *BpsFeeCalculator {
+	normalized := make(map[string]int64, len(royaltyBps))
+	for collection, bps := range royaltyBps {
+		normalized[common.HexToAddress(collection).Hex()] = bps
+	}
+	return &BpsFeeCalculator{ProtocolBps: protocolBps, DefaultRoyaltyBps: defaultRoyaltyBps, RoyaltyBps: normalized}
+}
+
+func (f *BpsFeeCalculator) Fees(ctx context.Context, m *Match) (Fees, error) {
+	royaltyBps, ok := f.RoyaltyBps[common.HexToAddress(m.Collection).Hex()]
+	if !ok {
+		royaltyBps = f.DefaultRoyaltyBps
+	}
+	return Fees{
+		Protocol: bpsOf(m.Price, f.ProtocolBps),
+		Royalty:  bpsOf(m.Price, royaltyBps),
+	}, nil
+}
+
+// bpsOf 按万分比计算金额，向下取整
+func bpsOf(amount decimal.Decimal, bps int64) decimal.Decimal {
+	return amount.Mul(decimal.NewFromInt(bps)).Div(bpsBase).Floor()
+}
@@ -0,0 +1,81 @@
+package matching
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/shopspring/decimal"
+)
+
+// Match 撮合结果，由结算组件在链上执行
+// Price 为成交价（链上原始数值），按价格-时间优先取先挂出订单的价格
+type Match struct {
+	ID             string          `json:"id"`
+	Collection     string          `json:"collection"`
+	TokenID        string          `json:"token_id"`
+	ListingOrderID string          `json:"listing_order_id"`
+	BidOrderID     string          `json:"bid_order_id"`
+	BidType        string          `json:"bid_type"`
+	Seller         string          `json:"seller"`
+	Buyer          string          `json:"buyer"`
+	Price          decimal.Decimal `json:"price"`
+	Currency       string          `json:"currency"`
+	ProtocolFee    decimal.Decimal `json:"protocol_fee"`
+	Royalty        decimal.Decimal `json:"royalty"`
+	SellerProceeds decimal.Decimal `json:"seller_proceeds"`
+	Exchange       string          `json:"exchange"`
+	MatchedAt      int64           `json:"matched_at"`
+
+	Listing dao.Order `json:"-"`
+	Bid     dao.Order `json:"-"`
+}
+
+// OrderStore 撮合所需的订单存储
+type OrderStore interface {
+	// OpenOrders 合集内有效期内的 listed 订单（挂单、单品出价、合集出价）
+	OpenOrders(ctx context.Context, collection string) ([]dao.Order, error)
+	// MatchOrders 原子地将 m 的挂单与出价置为 matched 并记录待发布的撮合结果，
+	// 任一订单已不是 listed 时返回 false 且不做修改
+	MatchOrders(ctx context.Context, m *Match) (bool, error)
+	// OrderListed 订单当前是否仍为 listed，撮合失败时用于判断哪一方已失效
+	OrderListed(ctx context.Context, orderID string) (bool, error)
+	// TokenTraits token 属性（trait_type -> value），用于属性出价
+	TokenTraits(ctx context.Context, collection, tokenID string) (map[string]string, error)
+}
+
+// Fees 成交费用拆分（链上原始数值）
+type Fees struct {
+	Protocol decimal.Decimal
+	Royalty  decimal.Decimal
+}
+
+// FeeCalculator 成交费用计算，可按市场或合集替换实现
+type FeeCalculator interface {
+	Fees(ctx context.Context, m *Match) (Fees, error)
+}
+
+// ParseCriteria 解析属性出价条件，JSON 对象 trait_type -> value，空字符串表示无条件
+func ParseCriteria(criteria string) (map[string]string, error) {
+	if criteria == "" {
+		return nil, nil
+	}
+	var traits map[string]string
+	if err := json.Unmarshal([]byte(criteria), &traits); err != nil {
+		return nil, fmt.Errorf("属性条件格式错误: %w", err)
+	}
+	if len(traits) == 0 {
+		return nil, fmt.Errorf("属性条件不能为空对象")
+	}
+	return traits, nil
+}
+
+// matchesCriteria token 属性是否满足全部条件
+func matchesCriteria(traits, criteria map[string]string) bool {
+	for traitType, value := range criteria {
+		if traits[traitType] != value {
+			return false
+		}
+	}
+	return true
+}
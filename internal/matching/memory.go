@@ -0,0 +1,102 @@
+package matching
+
+import (
+	"context"
+	"github.com/gavin/nftSync/internal/dao"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore 内存订单存储，用于测试与本地调试
+type MemoryStore struct {
+	mu      sync.Mutex
+	orders  map[string]*dao.Order        // OrderID -> 订单
+	traits  map[string]map[string]string // 合集:tokenId -> 属性
+	matches []Match                      // 已记录的撮合结果
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		orders: map[string]*dao.Order{},
+		traits: map[string]map[string]string{},
+	}
+}
+
+// AddOrder 写入订单
+func (s *MemoryStore) AddOrder(order dao.Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders[order.OrderID] = &order
+}
+
+// SetTraits 设置 token 属性
+func (s *MemoryStore) SetTraits(collection, tokenID string, traits map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.traits[traitKey(collection, tokenID)] = traits
+}
+
+// Order 查询订单当前状态
+func (s *MemoryStore) Order(orderID string) (dao.Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[orderID]
+	if !ok {
+		return dao.Order{}, false
+	}
+	return *order, true
+}
+
+func (s *MemoryStore) OpenOrders(ctx context.Context, collection string) ([]dao.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().Unix()
+	res := []dao.Order{}
+	for _, order := range s.orders {
+		if !strings.EqualFold(order.NFTToken, collection) || order.Status != dao.OrderStatusListed {
+			continue
+		}
+		if order.StartTime > now || (order.EndTime != 0 && order.EndTime <= now) {
+			continue
+		}
+		res = append(res, *order)
+	}
+	return res, nil
+}
+
+func (s *MemoryStore) OrderListed(ctx context.Context, orderID string) (bool, error) {
+	order, ok := s.Order(orderID)
+	return ok && order.Status == dao.OrderStatusListed, nil
+}
+
+func (s *MemoryStore) MatchOrders(ctx context.Context, m *Match) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, okL := s.orders[m.ListingOrderID]
+	b, okB := s.orders[m.BidOrderID]
+	if !okL || !okB || l.Status != dao.OrderStatusListed || b.Status != dao.OrderStatusListed {
+		return false, nil
+	}
+	l.Status, l.Buyer = dao.OrderStatusMatched, m.Buyer
+	b.Status, b.Buyer = dao.OrderStatusMatched, m.Buyer
+	s.matches = append(s.matches, *m)
+	return true, nil
+}
+
+// Matches 已记录的撮合结果
+func (s *MemoryStore) Matches() []Match {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Match(nil), s.matches...)
+}
+
+func (s *MemoryStore) TokenTraits(ctx context.Context, collection, tokenID string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.traits[traitKey(collection, tokenID)], nil
+}
+
+func traitKey(collection, tokenID string) string {
+	return strings.ToLower(collection) + ":" + tokenID
+}
@@ -0,0 +1,49 @@
+package matching
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/middleware"
+)
+
+// DaoStore 基于 MySQL 的订单存储
+type DaoStore struct {
+	Dao *dao.Dao
+}
+
+func (s *DaoStore) OpenOrders(ctx context.Context, collection string) ([]dao.Order, error) {
+	return s.Dao.ListOrdersByCollection(collection)
+}
+
+// MatchOrders 订单状态、撮合结果与地板价更新消息在同一事务中写入发件箱，发布失败由中继重试
+func (s *DaoStore) MatchOrders(ctx context.Context, m *Match) (bool, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return false, err
+	}
+	matched := false
+	err = s.Dao.Transaction(func(tx *dao.Dao) error {
+		var err error
+		if matched, err = tx.MatchOrderPair(m.Listing.ID, m.Bid.ID, m.Buyer); err != nil || !matched {
+			return err
+		}
+		return tx.EnqueueOutbox(
+			dao.OutboxMessage{Kind: dao.OutboxKindMatch, MsgKey: m.Collection, Payload: string(data)},
+			dao.FloorPriceOutbox(m.Listing.NFTToken, middleware.FloorTriggerOrderMatched),
+		)
+	})
+	return matched, err
+}
+
+func (s *DaoStore) OrderListed(ctx context.Context, orderID string) (bool, error) {
+	order, err := s.Dao.GetOrderByOrderID(orderID)
+	if err != nil || order == nil {
+		return false, err
+	}
+	return order.Status == dao.OrderStatusListed, nil
+}
+
+func (s *DaoStore) TokenTraits(ctx context.Context, collection, tokenID string) (map[string]string, error) {
+	return s.Dao.GetNFTTraits(collection, tokenID)
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/gavin/nftSync/internal/auth"
+	"github.com/gin-gonic/gin"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// APIKeyHeader 机器客户端通过该请求头携带 API Key
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyIDKey 通过 API Key 认证时 gin.Context 中的 Key ID
+const APIKeyIDKey = "api_key_id"
+
+// APIKeyAuthenticator API Key 校验与配额，由 service 层实现
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*auth.APIKeyPrincipal, error)
+	ConsumeAPIKeyQuota(ctx context.Context, p *auth.APIKeyPrincipal, endpoint string) (*auth.QuotaDecision, error)
+}
+
+// AuthOrAPIKey 携带 X-API-Key 时按 API Key 认证并校验接口分组与配额，否则按 JWT 认证
+func AuthOrAPIKey(m *auth.Manager, keys APIKeyAuthenticator, scope string) gin.HandlerFunc {
+	jwtAuth := AuthMiddleware(m)
+	return func(c *gin.Context) {
+		if c.GetHeader(APIKeyHeader) == "" {
+			jwtAuth(c)
+			return
+		}
+		if apiKeyAuth(c, keys, scope) {
+			c.Next()
+		}
+	}
+}
+
+// OptionalAPIKey 公开接口：携带 X-API-Key 时认证并计入配额，未携带时直接放行
+func OptionalAPIKey(keys APIKeyAuthenticator, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader(APIKeyHeader) == "" || apiKeyAuth(c, keys, scope) {
+			c.Next()
+		}
+	}
+}
+
+// apiKeyAuth 校验 API Key，失败时终止请求并返回 false；用量按路由模板统计
+func apiKeyAuth(c *gin.Context, keys APIKeyAuthenticator, scope string) bool {
+	p, err := keys.AuthenticateAPIKey(c.Request.Context(), c.GetHeader(APIKeyHeader))
+	switch {
+	case errors.Is(err, auth.ErrInvalidAPIKey):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized: invalid api key"})
+		return false
+	case err != nil:
+		log.Printf("[apikey] 校验失败: %v", err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "auth unavailable"})
+		return false
+	}
+	if !p.HasScope(scope) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden: api key scope " + scope + " required"})
+		return false
+	}
+	endpoint := c.FullPath()
+	if endpoint == "" {
+		endpoint = c.Request.URL.Path
+	}
+	d, err := keys.ConsumeAPIKeyQuota(c.Request.Context(), p, c.Request.Method+" "+endpoint)
+	if err != nil {
+		log.Printf("[apikey] 配额检查失败: key=%d, err=%v", p.KeyID, err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "quota unavailable"})
+		return false
+	}
+	c.Header("X-Quota-Limit", strconv.Itoa(d.DailyLimit))
+	c.Header("X-Quota-Remaining", strconv.Itoa(d.DailyRemaining))
+	c.Header("X-Quota-Reset", strconv.FormatInt(d.DailyReset.Unix(), 10))
+	if !d.Allowed {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests: " + d.Reason})
+		return false
+	}
+
+	c.Set("user_id", strconv.FormatInt(p.UserID, 10))
+	c.Set("role", p.Role)
+	c.Set(APIKeyIDKey, p.KeyID)
+	return true
+}
@@ -1,60 +1,56 @@
 package middleware
 
 import (
+	"errors"
+	"github.com/gavin/nftSync/internal/auth"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 	"log"
 	"net/http"
 	"strings"
 )
 
-var jwtSecret = []byte("your-secret-key") // 建议从配置读取
+// ClaimsKey 校验通过的访问令牌声明在 gin.Context 中的键
+const ClaimsKey = "claims"
 
-// 简单 JWT 校验中间件（可扩展）
-func AuthMiddleware() gin.HandlerFunc {
+// BearerToken 读取 Authorization: Bearer 令牌
+func BearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
+
+// AuthMiddleware JWT 校验中间件：签名、算法、exp/nbf/iat、签发方与受众，并检查吊销
+func AuthMiddleware(m *auth.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		//头格式校验
-		tokenStr := c.GetHeader("Authorization")
-		if tokenStr == "" || !strings.HasPrefix(tokenStr, "Bearer ") {
+		tokenStr := BearerToken(c)
+		if tokenStr == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized: missing or invalid token"})
 			return
 		}
-		tokenStr = strings.TrimPrefix(tokenStr, "Bearer ")
 
-		// 解析和校验 JWT
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			// 校验签名算法
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return jwtSecret, nil
-		})
-		if err != nil || !token.Valid {
+		claims, err := m.Verify(c.Request.Context(), tokenStr)
+		switch {
+		case errors.Is(err, auth.ErrTokenRevoked):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized: token revoked"})
+			return
+		case errors.Is(err, auth.ErrInvalidToken):
 			log.Printf("JWT校验失败: %v", err)
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized: invalid token"})
 			return
-		}
-
-		// 校验 claims（过期、签发方、受众等）
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			if exp, ok := claims["exp"].(float64); ok {
-				if int64(exp) < (c.Request.Context().Value("nowUnix").(int64)) {
-					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized: token expired"})
-					return
-				}
-			}
-			// 可选: 校验 iss、aud
-			// if claims["iss"] != "your-issuer" { ... }
-			// if claims["aud"] != "your-audience" { ... }
-
-			// 注入用户信息到 context
-			c.Set("user_id", claims["sub"])
-			c.Set("role", claims["role"])
-		} else {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized: invalid claims"})
+		case err != nil:
+			// 吊销列表不可用时拒绝请求，避免已吊销令牌继续生效
+			log.Printf("JWT吊销检查失败: %v", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "auth unavailable"})
 			return
 		}
 
+		// 注入用户信息到 context
+		c.Set("user_id", claims.Subject)
+		c.Set("role", claims.Role)
+		c.Set(ClaimsKey, claims)
 		c.Next()
 	}
 }
@@ -0,0 +1,16 @@
+package middleware
+
+// 地板价更新的触发事件，通过消息头 trigger 传递
+const (
+	FloorTriggerOrderCreated   = "order_created"
+	FloorTriggerOrderCancelled = "order_cancelled"
+	FloorTriggerOrderFilled    = "order_filled"
+	FloorTriggerOrderExpired   = "order_expired"
+	FloorTriggerOrderActivated = "order_activated" // 预约挂单到达生效时间
+	FloorTriggerOrderInvalid   = "order_invalid"
+	FloorTriggerTransfer       = "transfer"
+	FloorTriggerOrderMatched   = "order_matched"
+)
+
+// FloorTriggerHeader 触发事件消息头
+const FloorTriggerHeader = "trigger"
@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 限流维度
+const (
+	RateLimitKeyIP     = "ip"
+	RateLimitKeyUser   = "user"
+	RateLimitKeyAPIKey = "api_key"
+	RateLimitKeyAuto   = "auto" // API Key > 用户 > IP
+)
+
+const (
+	rateLimitPrefix = "nftsync:ratelimit:"
+	// redisRetryAfter Redis 调用失败后改用本地限流的时长，到期后重新尝试 Redis
+	redisRetryAfter = 5 * time.Second
+)
+
+// gcraScript GCRA 限流，时间取 Redis 服务器时钟（毫秒），保证多实例一致
+// KEYS: 理论到达时间 TAT；ARGV: 突发容量、放行间隔（毫秒）
+// 返回 {是否放行, 剩余次数, 重试等待毫秒, 额度完全恢复毫秒}
+var gcraScript = redis.NewScript(`
+if redis.replicate_commands then redis.replicate_commands() end
+local burst = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then tat = now end
+local new_tat = tat + interval
+local allow_at = new_tat - burst * interval
+if now < allow_at then
+  return {0, 0, allow_at - now, tat - now}
+end
+redis.call('SET', KEYS[1], new_tat, 'PX', new_tat - now)
+return {1, math.floor((now - allow_at) / interval), 0, new_tat - now}
+`)
+
+// rateLimitResult 单次限流判定
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int64
+	retryAfter time.Duration
+	resetAfter time.Duration
+}
+
+// rateLimitRule 归一化后的限流规则
+type rateLimitRule struct {
+	burst    int64
+	interval int64 // 毫秒
+	keyBy    string
+}
+
+// RateLimiter 基于 Redis 的分布式限流，Redis 不可用时退化为进程内限流（各实例独立计数）
+type RateLimiter struct {
+	redis      *redis.Client
+	cfg        config.RateLimitConfig
+	local      *localGCRA
+	redisDown  int64 // Redis 失败后的恢复尝试时间（UnixNano）
+	lastWarned int64
+}
+
+func NewRateLimiter(client *redis.Client, cfg config.RateLimitConfig) *RateLimiter {
+	return &RateLimiter{redis: client, cfg: cfg, local: newLocalGCRA()}
+}
+
+// Group 返回路由分组的限流中间件，须注册在认证中间件之后才能按用户或 API Key 限流；
+// 未启用或规则 rate 为 0 时直接放行
+func (l *RateLimiter) Group(name string) gin.HandlerFunc {
+	rule, ok := l.rule(name)
+	if !ok {
+		return func(c *gin.Context) { c.Next() }
+	}
+	// 突发容量耗尽后完全恢复所需时长即策略窗口
+	policy := fmt.Sprintf("%d;w=%d", rule.burst, ceilSeconds(time.Duration(rule.burst*rule.interval)*time.Millisecond))
+	return func(c *gin.Context) {
+		key := rateLimitPrefix + name + ":" + rateLimitSubject(c, rule.keyBy)
+		res := l.allow(c.Request.Context(), key, rule)
+
+		c.Header("RateLimit-Policy", policy)
+		c.Header("RateLimit-Limit", strconv.FormatInt(rule.burst, 10))
+		c.Header("RateLimit-Remaining", strconv.FormatInt(res.remaining, 10))
+		c.Header("RateLimit-Reset", strconv.FormatInt(ceilSeconds(res.resetAfter), 10))
+		if !res.allowed {
+			c.Header("Retry-After", strconv.FormatInt(ceilSeconds(res.retryAfter), 10))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (l *RateLimiter) rule(name string) (rateLimitRule, bool) {
+	if !l.cfg.Enabled {
+		return rateLimitRule{}, false
+	}
+	r, ok := l.cfg.Groups[name]
+	if !ok {
+		r = l.cfg.Default
+	}
+	if r.Rate <= 0 {
+		return rateLimitRule{}, false
+	}
+	if r.Period <= 0 {
+		r.Period = 60
+	}
+	if r.Burst <= 0 {
+		r.Burst = r.Rate
+	}
+	if r.KeyBy == "" {
+		r.KeyBy = RateLimitKeyAuto
+	}
+	interval := int64(r.Period) * 1000 / int64(r.Rate)
+	if interval <= 0 {
+		interval = 1
+	}
+	return rateLimitRule{burst: int64(r.Burst), interval: interval, keyBy: r.KeyBy}, true
+}
+
+// allow 优先使用 Redis，失败时在 redisRetryAfter 内改用本地限流
+func (l *RateLimiter) allow(ctx context.Context, key string, rule rateLimitRule) rateLimitResult {
+	if l.redis != nil && time.Now().UnixNano() >= atomic.LoadInt64(&l.redisDown) {
+		vals, err := gcraScript.Run(ctx, l.redis, []string{key}, rule.burst, rule.interval).Int64Slice()
+		if err == nil && len(vals) == 4 {
+			return rateLimitResult{
+				allowed:    vals[0] == 1,
+				remaining:  vals[1],
+				retryAfter: time.Duration(vals[2]) * time.Millisecond,
+				resetAfter: time.Duration(vals[3]) * time.Millisecond,
+			}
+		}
+		// 客户端断开导致的失败不视为 Redis 故障
+		if ctx.Err() == nil {
+			atomic.StoreInt64(&l.redisDown, time.Now().Add(redisRetryAfter).UnixNano())
+			l.warn(err)
+		}
+	}
+	return l.local.allow(key, rule, time.Now().UnixMilli())
+}
+
+// warn 限制 Redis 失败日志频率，每分钟最多一条
+func (l *RateLimiter) warn(err error) {
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&l.lastWarned)
+	if now-last < int64(time.Minute) || !atomic.CompareAndSwapInt64(&l.lastWarned, last, now) {
+		return
+	}
+	log.Printf("[ratelimit] Redis 不可用，改用本地限流: %v", err)
+}
+
+// rateLimitSubject 限流主体：API Key 与用户取认证中间件注入的值，缺失时回退到客户端 IP，
+// 客户端 IP 仅在请求来自 rate_limit.trusted_proxies 时采信转发头
+func rateLimitSubject(c *gin.Context, keyBy string) string {
+	if keyBy == RateLimitKeyAPIKey || keyBy == RateLimitKeyAuto {
+		if v, ok := c.Get(APIKeyIDKey); ok && v != nil {
+			return fmt.Sprintf("key:%v", v)
+		}
+	}
+	if keyBy == RateLimitKeyUser || keyBy == RateLimitKeyAuto {
+		if v, ok := c.Get("user_id"); ok && v != nil && fmt.Sprint(v) != "" {
+			return fmt.Sprintf("user:%v", v)
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + time.Second - 1) / time.Second)
+}
+
+// localGCRA 进程内 GCRA，与 gcraScript 算法一致，定期清理已恢复满额的主体
+type localGCRA struct {
+	mu        sync.Mutex
+	tat       map[string]int64 // 毫秒
+	lastSweep int64
+}
+
+func newLocalGCRA() *localGCRA {
+	return &localGCRA{tat: make(map[string]int64)}
+}
+
+func (g *localGCRA) allow(key string, rule rateLimitRule, now int64) rateLimitResult {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now-g.lastSweep > int64(time.Minute/time.Millisecond) {
+		for k, t := range g.tat {
+			if t <= now {
+				delete(g.tat, k)
+			}
+		}
+		g.lastSweep = now
+	}
+
+	tat, ok := g.tat[key]
+	if !ok || tat < now {
+		tat = now
+	}
+	newTat := tat + rule.interval
+	allowAt := newTat - rule.burst*rule.interval
+	if now < allowAt {
+		return rateLimitResult{
+			retryAfter: time.Duration(allowAt-now) * time.Millisecond,
+			resetAfter: time.Duration(tat-now) * time.Millisecond,
+		}
+	}
+	g.tat[key] = newTat
+	return rateLimitResult{
+		allowed:    true,
+		remaining:  (now - allowAt) / rule.interval,
+		resetAfter: time.Duration(newTat-now) * time.Millisecond,
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gavin/nftSync/internal/auth"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// ContextRole 读取 AuthMiddleware 注入的角色
+func ContextRole(c *gin.Context) string {
+	if v, ok := c.Get("role"); ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// RequirePermission 路由权限声明，须注册在 AuthMiddleware 之后；角色以访问令牌中的 role 声明为准，变更在令牌刷新后生效
+func RequirePermission(perm auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.HasPermission(ContextRole(c), perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden: missing permission " + string(perm)})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,165 @@
+package pricefeed
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v2"
+)
+
+// 价格源类型
+const (
+	KindStatic = "static"
+	KindFile   = "file"
+)
+
+// PriceFeed 币种兑基础币种的汇率
+type PriceFeed interface {
+	// BaseCurrency 基础币种合约地址（ETH 为零地址）
+	BaseCurrency() string
+	// Rate 1 单位 currency（已按 decimals 缩放）折合多少基础币种
+	Rate(ctx context.Context, currency string) (decimal.Decimal, error)
+}
+
+// Options 价格源配置
+type Options struct {
+	Kind         string            // static / file
+	BaseCurrency string            // 基础币种地址
+	Rates        map[string]string // static 汇率表：币种地址 -> 汇率
+	File         string            // file 汇率文件路径（yaml，格式同 Rates）
+}
+
+// New 按配置创建价格源
+func New(opts Options) (PriceFeed, error) {
+	switch opts.Kind {
+	case "", KindStatic:
+		return NewStaticFeed(opts.BaseCurrency, opts.Rates)
+	case KindFile:
+		return NewFileFeed(opts.BaseCurrency, opts.File)
+	default:
+		return nil, fmt.Errorf("未知的价格源类型: %s", opts.Kind)
+	}
+}
+
+// normalize 地址统一为 checksum 格式，作为汇率表的 key
+func normalize(addr string) string {
+	return common.HexToAddress(addr).Hex()
+}
+
+func parseRates(raw map[string]string) (map[string]decimal.Decimal, error) {
+	rates := make(map[string]decimal.Decimal, len(raw))
+	for addr, v := range raw {
+		rate, err := decimal.NewFromString(v)
+		if err != nil {
+			return nil, fmt.Errorf("汇率格式错误 %s=%s: %w", addr, v, err)
+		}
+		rates[normalize(addr)] = rate
+	}
+	return rates, nil
+}
+
+// StaticFeed 固定汇率价格源，适用于测试和无外部行情的部署
+type StaticFeed struct {
+	base  string
+	rates map[string]decimal.Decimal
+}
+
+func NewStaticFeed(base string, raw map[string]string) (*StaticFeed, error) {
+	rates, err := parseRates(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticFeed{base: normalize(base), rates: rates}, nil
+}
+
+func (f *StaticFeed) BaseCurrency() string { return f.base }
+
+func (f *StaticFeed) Rate(ctx context.Context, currency string) (decimal.Decimal, error) {
+	currency = normalize(currency)
+	if currency == f.base {
+		return decimal.NewFromInt(1), nil
+	}
+	rate, ok := f.rates[currency]
+	if !ok {
+		return decimal.Zero, fmt.Errorf("币种无汇率: %s", currency)
+	}
+	return rate, nil
+}
+
+// FileFeed 文件汇率价格源，文件修改后自动重新加载
+type FileFeed struct {
+	base    string
+	path    string
+	mu      sync.RWMutex
+	modTime time.Time
+	rates   map[string]decimal.Decimal
+}
+
+func NewFileFeed(base, path string) (*FileFeed, error) {
+	f := &FileFeed{base: normalize(base), path: path}
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FileFeed) BaseCurrency() string { return f.base }
+
+func (f *FileFeed) Rate(ctx context.Context, currency string) (decimal.Decimal, error) {
+	if err := f.reload(); err != nil {
+		return decimal.Zero, err
+	}
+	currency = normalize(currency)
+	if currency == f.base {
+		return decimal.NewFromInt(1), nil
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	rate, ok := f.rates[currency]
+	if !ok {
+		return decimal.Zero, fmt.Errorf("币种无汇率: %s", currency)
+	}
+	return rate, nil
+}
+
+// reload 文件修改时间变化时重新加载汇率
+func (f *FileFeed) reload() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	f.mu.RLock()
+	fresh := info.ModTime().Equal(f.modTime)
+	f.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	var raw map[string]string
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	rates, err := parseRates(raw)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.rates = rates
+	f.modTime = info.ModTime()
+	f.mu.Unlock()
+	return nil
+}
+
+// IsNative 是否原生 ETH
+func IsNative(currency string) bool {
+	return strings.TrimSpace(currency) == "" || common.HexToAddress(currency) == (common.Address{})
+}
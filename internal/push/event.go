@@ -0,0 +1,58 @@
+package push
+
+import (
+	"encoding/json"
+)
+
+// TypeFloorChanged 地板价变化事件类型，其余事件类型与活动类型一致
+const TypeFloorChanged = "floor_changed"
+
+// Event 推送事件，ID 全局递增，由 Broker 发布时分配，客户端断线后凭最后收到的 ID 续传
+type Event struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	Collection string          `json:"collection"`
+	TokenID    string          `json:"token_id,omitempty"`
+	Wallets    []string        `json:"wallets,omitempty"` // 事件涉及的地址，用于按钱包订阅
+	Data       json.RawMessage `json:"data"`
+}
+
+// Filter 订阅条件
+// 合集、token、钱包任一命中即视为目标匹配，均为空时匹配全部；Types 非空时事件类型须在其中
+// 地址与 tokenId 需由调用方统一格式
+type Filter struct {
+	Collections map[string]bool
+	Tokens      map[string]bool // key 为 TokenKey(collection, tokenId)
+	Wallets     map[string]bool
+	Types       map[string]bool
+}
+
+// TokenKey 单个 token 的订阅 key
+func TokenKey(collection, tokenID string) string {
+	return collection + ":" + tokenID
+}
+
+// Match 判断事件是否满足订阅条件
+func (f *Filter) Match(e *Event) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Types) > 0 && !f.Types[e.Type] {
+		return false
+	}
+	if len(f.Collections) == 0 && len(f.Tokens) == 0 && len(f.Wallets) == 0 {
+		return true
+	}
+	if f.Collections[e.Collection] {
+		return true
+	}
+	if e.TokenID != "" && f.Tokens[TokenKey(e.Collection, e.TokenID)] {
+		return true
+	}
+	for _, w := range e.Wallets {
+		if f.Wallets[w] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package push
+
+import (
+	"sync"
+)
+
+// Subscriber 单个连接的订阅，事件经有界队列发送
+// 队列写满时视为慢消费者，订阅被移除并关闭 Dropped，连接应断开，客户端可凭最后事件 ID 续传
+type Subscriber struct {
+	events  chan Event
+	dropped chan struct{}
+	once    sync.Once
+
+	mu     sync.RWMutex
+	filter *Filter
+	skip   map[int64]bool // 续传时已回放的事件
+}
+
+// Events 待发送事件
+func (s *Subscriber) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped 订阅因发送队列写满被移除时关闭
+func (s *Subscriber) Dropped() <-chan struct{} {
+	return s.dropped
+}
+
+// SetFilter 替换订阅条件
+func (s *Subscriber) SetFilter(f *Filter) {
+	s.mu.Lock()
+	s.filter = f
+	s.mu.Unlock()
+}
+
+// Replayed 事件是否已在续传时回放，发送前调用以去重
+func (s *Subscriber) Replayed(e *Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.skip[e.ID] {
+		return false
+	}
+	delete(s.skip, e.ID)
+	return true
+}
+
+func (s *Subscriber) accept(e *Event) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.filter.Match(e)
+}
+
+func (s *Subscriber) drop() {
+	s.once.Do(func() { close(s.dropped) })
+}
+
+// Hub 本实例的订阅管理，Broadcast 不阻塞，单个慢连接不影响其他连接
+type Hub struct {
+	bufferSize int
+
+	mu   sync.RWMutex
+	subs map[*Subscriber]struct{}
+}
+
+func NewHub(bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = 256
+	}
+	return &Hub{bufferSize: bufferSize, subs: make(map[*Subscriber]struct{})}
+}
+
+// Subscribe 注册订阅，连接结束时须调用 Unsubscribe
+func (h *Hub) Subscribe(f *Filter) *Subscriber {
+	s := &Subscriber{
+		events:  make(chan Event, h.bufferSize),
+		dropped: make(chan struct{}),
+		filter:  f,
+	}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// Unsubscribe 移除订阅
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	delete(h.subs, s)
+	h.mu.Unlock()
+}
+
+// Broadcast 将事件投递给匹配的订阅，队列已满的订阅被移除
+func (h *Hub) Broadcast(e Event) {
+	var slow []*Subscriber
+	h.mu.RLock()
+	for s := range h.subs {
+		if !s.accept(&e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			slow = append(slow, s)
+		}
+	}
+	h.mu.RUnlock()
+	for _, s := range slow {
+		h.Unsubscribe(s)
+		s.drop()
+	}
+}
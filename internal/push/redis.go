@@ -0,0 +1,148 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"log"
+	"sort"
+	"strings"
+)
+
+// 推送相关 key
+//
+//	{channel}          pub/sub 频道，多实例扇出
+//	{channel}:seq      事件 ID 计数器
+//	{channel}:history  最近事件列表（新事件在前），供断线续传
+const (
+	seqSuffix     = ":seq"
+	historySuffix = ":history"
+)
+
+// Broker 基于 Redis pub/sub 的事件发布与扇出
+// 发布方分配全局递增 ID 并写入有限长度的历史列表，各 API 实例订阅频道后投递给本地 Hub
+type Broker struct {
+	redis       *redis.Client
+	channel     string
+	historySize int64
+}
+
+func NewBroker(client *redis.Client, channel string, historySize int) *Broker {
+	if channel == "" {
+		channel = "nftsync:push"
+	}
+	if historySize <= 0 {
+		historySize = 1000
+	}
+	return &Broker{redis: client, channel: channel, historySize: int64(historySize)}
+}
+
+// publishScript 原子地分配事件 ID、写入历史并发布，保证历史与频道中的事件按 ID 顺序出现，
+// 并发发布时不会出现较大 ID 先于较小 ID 可见、断线续传漏发的情况
+// KEYS: 计数器、历史列表；ARGV: id 为 0 的事件 JSON、历史长度、频道
+var publishScript = redis.NewScript(`
+local id = redis.call('INCR', KEYS[1])
+local payload = '{"id":' .. id .. string.sub(ARGV[1], 8)
+redis.call('LPUSH', KEYS[2], payload)
+redis.call('LTRIM', KEYS[2], 0, tonumber(ARGV[2]) - 1)
+redis.call('PUBLISH', ARGV[3], payload)
+return id
+`)
+
+// idPlaceholder 事件 JSON 的前缀，ID 为首个字段，由脚本替换为分配的 ID
+const idPlaceholder = `{"id":0,`
+
+// Publish 分配事件 ID 并发布
+func (b *Broker) Publish(ctx context.Context, e *Event) error {
+	e.ID = 0
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(string(payload), idPlaceholder) {
+		return errors.New("push: 事件 JSON 格式异常")
+	}
+	id, err := publishScript.Run(ctx, b.redis, []string{b.channel + seqSuffix, b.channel + historySuffix},
+		string(payload), b.historySize, b.channel).Int64()
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+// Since 返回 ID 大于 lastID 的历史事件（按 ID 升序）
+// complete 为 false 表示历史已被裁剪，lastID 之后的部分事件无法补发
+func (b *Broker) Since(ctx context.Context, lastID int64) ([]Event, bool, error) {
+	raws, err := b.redis.LRange(ctx, b.channel+historySuffix, 0, -1).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	events := make([]Event, 0, len(raws))
+	oldest := int64(0)
+	for _, raw := range raws {
+		var e Event
+		if err := json.Unmarshal([]byte(raw), &e); err != nil {
+			continue
+		}
+		if oldest == 0 || e.ID < oldest {
+			oldest = e.ID
+		}
+		if e.ID > lastID {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	complete := len(raws) < int(b.historySize) || oldest <= lastID+1
+	return events, complete, nil
+}
+
+// Run 订阅频道并投递给本地 Hub，直至 ctx 结束，连接断开时由客户端自动重连
+func (b *Broker) Run(ctx context.Context, hub *Hub) {
+	sub := b.redis.Subscribe(ctx, b.channel)
+	defer sub.Close()
+	ch := sub.Channel()
+	log.Printf("[push] 已订阅推送频道: %s", b.channel)
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				log.Printf("[push] 事件解析失败: %v", err)
+				continue
+			}
+			hub.Broadcast(e)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Resume 注册订阅并补发 lastID 之后的历史事件
+// 先订阅再读取历史，回放过的事件实时到达时由 Subscriber.Replayed 识别跳过，保证不漏不重
+func (b *Broker) Resume(ctx context.Context, hub *Hub, f *Filter, lastID int64) (*Subscriber, []Event, bool, error) {
+	s := hub.Subscribe(f)
+	if lastID <= 0 {
+		return s, nil, true, nil
+	}
+	history, complete, err := b.Since(ctx, lastID)
+	if err != nil {
+		hub.Unsubscribe(s)
+		return nil, nil, false, err
+	}
+	replay := make([]Event, 0, len(history))
+	s.mu.Lock()
+	s.skip = make(map[int64]bool, len(history))
+	for i := range history {
+		s.skip[history[i].ID] = true
+		if f.Match(&history[i]) {
+			replay = append(replay, history[i])
+		}
+	}
+	s.mu.Unlock()
+	return s, replay, complete, nil
+}
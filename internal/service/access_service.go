@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/gavin/nftSync/internal/auth"
+	"github.com/gavin/nftSync/internal/blockchain"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrWalletForbidden = errors.New("无权访问该钱包数据")
+	ErrInvalidRole     = errors.New("角色不存在")
+	ErrUserNotFound    = errors.New("用户不存在")
+)
+
+// CheckWalletAccess 钱包私有数据只对绑定该钱包的用户开放，operator/admin 可访问任意钱包
+func (s *Service) CheckWalletAccess(userID, role, wallet string) error {
+	if auth.HasPermission(role, auth.PermWalletReadAny) {
+		return nil
+	}
+	if !auth.HasPermission(role, auth.PermWalletRead) {
+		return ErrWalletForbidden
+	}
+	id, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		return ErrWalletForbidden
+	}
+	user, err := s.Dao.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.WalletAddr == "" || !strings.EqualFold(user.WalletAddr, wallet) {
+		return ErrWalletForbidden
+	}
+	return nil
+}
+
+// SetUserRole 修改用户角色，新角色在用户下次刷新令牌时生效
+func (s *Service) SetUserRole(userID int64, role string) error {
+	if !auth.ValidRole(role) {
+		return ErrInvalidRole
+	}
+	user, err := s.Dao.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if err := s.Dao.UpdateUserRole(userID, role); err != nil {
+		return err
+	}
+	log.Printf("[auth] 用户角色变更: user=%d, role=%s", userID, role)
+	return nil
+}
+
+// NodeStatusDTO 节点池中单个节点的状态
+type NodeStatusDTO struct {
+	Name        string `json:"name"`
+	BlockNumber uint64 `json:"block_number,omitempty"`
+	LatencyMs   int64  `json:"latency_ms"`
+	Healthy     bool   `json:"healthy"`
+	Error       string `json:"error,omitempty"`
+}
+
+// NodeStatuses 查询各节点最新区块高度与响应耗时
+func (s *Service) NodeStatuses(ctx context.Context) []NodeStatusDTO {
+	statuses := make([]NodeStatusDTO, 0, len(s.MultiNode.Clients))
+	for i, c := range s.MultiNode.Clients {
+		status := NodeStatusDTO{Name: s.MultiNode.NodeNames[i]}
+		callCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		start := time.Now()
+		num, err := blockchain.NewEthClient(c).GetBlockNumber(callCtx)
+		cancel()
+		status.LatencyMs = time.Since(start).Milliseconds()
+		if err != nil {
+			status.Error = err.Error()
+		} else {
+			status.BlockNumber = num.Uint64()
+			status.Healthy = true
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// ReindexOrderBook 从 MySQL 重建订单簿 Redis 索引，collection 为空时重建全部合集
+func (s *Service) ReindexOrderBook(ctx context.Context, collection string) error {
+	if collection == "" {
+		s.OrderBook.RebuildAll(ctx)
+		return nil
+	}
+	return s.OrderBook.Rebuild(ctx, collection)
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/gavin/nftSync/internal/blockchain/marketplace"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/push"
+	"log"
+	"math/big"
+	"strings"
+)
+
+var (
+	ErrInvalidCursor       = errors.New("游标无效")
+	ErrInvalidActivityType = errors.New("不支持的活动类型")
+	ErrInvalidTokenID      = errors.New("tokenId 格式错误")
+)
+
+var activityTypes = map[string]bool{
+	dao.ActivityMint:           true,
+	dao.ActivityTransfer:       true,
+	dao.ActivityBurn:           true,
+	dao.ActivitySale:           true,
+	dao.ActivityOrderCreated:   true,
+	dao.ActivityOrderCancelled: true,
+	dao.ActivityOrderFilled:    true,
+	dao.ActivityMetadataUpdate: true,
+}
+
+// recordActivity 写入活动记录并推送新活动，events 为 true 时同事务写入领域事件，失败仅记录日志，不影响主流程
+func recordActivity(d *dao.Dao, b *push.Broker, events bool, a *dao.Activity) {
+	var created bool
+	err := d.Transaction(func(tx *dao.Dao) error {
+		var err error
+		created, err = writeActivity(tx, events, a)
+		return err
+	})
+	if err != nil {
+		log.Printf("[activity] 活动写入失败: type=%s, tx=%s, err=%v", a.Type, a.TxHash, err)
+		return
+	}
+	if created {
+		publishActivity(b, a)
+	}
+}
+
+// writeActivity 在调用方事务中写入活动，新写入且 events 为 true 时领域事件同事务写入发件箱
+// 返回活动是否为新写入，调用方在事务提交后据此推送
+func writeActivity(tx *dao.Dao, events bool, a *dao.Activity) (bool, error) {
+	created, err := tx.CreateActivityIgnoreConflict(a)
+	if err != nil || !created || !events {
+		return created, err
+	}
+	return true, enqueueActivityEvent(tx, a)
+}
+
+// transferActivityType 按 from/to 是否为零地址区分铸造、销毁与普通转移
+func transferActivityType(from, to string) string {
+	switch {
+	case from == marketplace.ZeroAddress:
+		return dao.ActivityMint
+	case to == marketplace.ZeroAddress:
+		return dao.ActivityBurn
+	default:
+		return dao.ActivityTransfer
+	}
+}
+
+// orderActivity 由订单生成订单类活动，链上事件由调用方补充交易信息
+func orderActivity(typ string, order *dao.Order) *dao.Activity {
+	return &dao.Activity{
+		Type:        typ,
+		Collection:  order.NFTToken,
+		TokenID:     order.TokenID,
+		From:        order.Seller,
+		OrderID:     order.OrderID,
+		Price:       order.Price,
+		Currency:    order.Currency,
+		Marketplace: dao.MarketplaceNative,
+	}
+}
+
+// ActivityDTO 活动输出
+type ActivityDTO struct {
+	Type        string `json:"type"`
+	Collection  string `json:"collection"`
+	TokenID     string `json:"token_id,omitempty"`
+	From        string `json:"from,omitempty"`
+	To          string `json:"to,omitempty"`
+	OrderID     string `json:"order_id,omitempty"`
+	Price       string `json:"price,omitempty"` // 链上原始数值，转移类活动为空
+	Currency    string `json:"currency,omitempty"`
+	Marketplace string `json:"marketplace,omitempty"`
+	TxHash      string `json:"tx_hash,omitempty"`
+	LogIndex    int    `json:"log_index"`
+	BlockNumber uint64 `json:"block_number"`
+	BlockTime   int64  `json:"block_time"`
+}
+
+// ActivityPageDTO 活动分页结果，NextCursor 为空表示没有更多
+type ActivityPageDTO struct {
+	Activities []ActivityDTO `json:"activities"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// ActivityQuery 活动查询条件，Types 为空时返回全部类型
+type ActivityQuery struct {
+	Collection string
+	TokenID    string
+	Wallet     string
+	Types      []string
+}
+
+func toActivityDTO(a *dao.Activity) ActivityDTO {
+	dto := ActivityDTO{
+		Type:        a.Type,
+		Collection:  a.Collection,
+		TokenID:     a.TokenID,
+		From:        a.From,
+		To:          a.To,
+		OrderID:     a.OrderID,
+		Currency:    a.Currency,
+		Marketplace: a.Marketplace,
+		TxHash:      a.TxHash,
+		LogIndex:    a.LogIndex,
+		BlockNumber: a.BlockNumber,
+		BlockTime:   a.BlockTime,
+	}
+	if !a.Price.IsZero() {
+		dto.Price = a.Price.String()
+	}
+	return dto
+}
+
+// encodeActivityCursor 游标为 "时间:区块:日志序号:ID" 的 base64，对调用方不透明
+func encodeActivityCursor(a *dao.Activity) string {
+	raw := fmt.Sprintf("%d:%d:%d:%d", a.BlockTime, a.BlockNumber, a.LogIndex, a.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeActivityCursor(cursor string) (*dao.ActivityCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c dao.ActivityCursor
+	if n, err := fmt.Sscanf(string(raw), "%d:%d:%d:%d", &c.BlockTime, &c.BlockNumber, &c.LogIndex, &c.ID); err != nil || n != 4 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// normalizeTokenID 支持十进制或 0x 十六进制 tokenId，统一为存储使用的 32 字节 hex
+func normalizeTokenID(tokenID string) (string, bool) {
+	id, ok := new(big.Int), false
+	if strings.HasPrefix(tokenID, "0x") || strings.HasPrefix(tokenID, "0X") {
+		id, ok = id.SetString(tokenID[2:], 16)
+	} else {
+		id, ok = id.SetString(tokenID, 10)
+	}
+	if !ok || id.Sign() < 0 {
+		return "", false
+	}
+	return marketplace.TokenIDHex(id), true
+}
+
+// ListActivities 按时间、区块、日志序号倒序查询活动，cursor 为上一页返回的 NextCursor
+func (s *Service) ListActivities(query ActivityQuery, cursor string, limit int) (*ActivityPageDTO, error) {
+	filter := dao.ActivityFilter{}
+	if query.Collection != "" {
+		filter.Collection = normalizeAddr(query.Collection)
+	}
+	if query.Wallet != "" {
+		filter.Wallet = normalizeAddr(query.Wallet)
+	}
+	if query.TokenID != "" {
+		tokenID, ok := normalizeTokenID(query.TokenID)
+		if !ok {
+			return nil, ErrInvalidTokenID
+		}
+		filter.TokenID = tokenID
+	}
+	for _, t := range query.Types {
+		if !activityTypes[t] {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidActivityType, t)
+		}
+		filter.Types = append(filter.Types, t)
+	}
+	var c *dao.ActivityCursor
+	if cursor != "" {
+		var err error
+		if c, err = decodeActivityCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+	list, err := s.Dao.ListActivities(filter, c, limit)
+	if err != nil {
+		return nil, err
+	}
+	page := &ActivityPageDTO{Activities: make([]ActivityDTO, 0, len(list))}
+	for i := range list {
+		page.Activities = append(page.Activities, toActivityDTO(&list[i]))
+	}
+	if len(list) == limit && limit > 0 {
+		page.NextCursor = encodeActivityCursor(&list[len(list)-1])
+	}
+	return page, nil
+}
@@ -0,0 +1,458 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/shopspring/decimal"
+	"log"
+	"time"
+)
+
+// AnalyticsWindows 支持的统计窗口（秒），all 为全部历史
+var AnalyticsWindows = map[string]int64{
+	"1h":  60 * 60,
+	"24h": 24 * 60 * 60,
+	"7d":  7 * 24 * 60 * 60,
+	"30d": 30 * 24 * 60 * 60,
+	"all": 0,
+}
+
+var analyticsWindowOrder = []string{"1h", "24h", "7d", "30d", "all"}
+
+// analyticsBatchSize 扫描成交的分批大小
+const analyticsBatchSize = 2000
+
+// analyticsBucketSize 汇总时间桶大小（秒），统计窗口起点按桶对齐
+const analyticsBucketSize int64 = 5 * 60
+
+// ErrUnsupportedSort 排行榜排序字段不支持
+var ErrUnsupportedSort = errors.New("不支持的排序字段")
+
+// 排行榜可选排序字段 -> 汇总表列名
+var (
+	collectionSortColumns = map[string]string{
+		"volume":         "volume",
+		"sales":          "sales",
+		"avg_price":      "avg_price",
+		"unique_buyers":  "unique_buyers",
+		"unique_sellers": "unique_sellers",
+	}
+	traderSortColumns = map[string]string{
+		"volume":      "volume",
+		"buy_volume":  "buy_volume",
+		"sell_volume": "sell_volume",
+		"buys":        "buys",
+		"sells":       "sells",
+	}
+)
+
+// AnalyticsService 从销售账本增量维护合集与地址的成交统计
+// 成交按 5 分钟时间桶汇总到 trade_rollups/trader_rollups，每轮只重算新成交与标记变更涉及的桶，
+// 再由时间桶汇总出各窗口写入统计表；金额使用成交写入时折算的基础币种价格
+// 默认排除被标记为可疑且未驳回的成交
+type AnalyticsService struct {
+	Dao            *dao.Dao
+	Currencies     *CurrencyResolver
+	IncludeFlagged bool
+
+	refreshed bool // 进程内是否已写过一轮 all 窗口
+}
+
+func NewAnalyticsService(ctx *config.Context) *AnalyticsService {
+	return &AnalyticsService{
+		Dao:            dao.New(ctx.Db),
+		Currencies:     NewCurrencyResolver(ctx),
+		IncludeFlagged: ctx.Config.Analytics.IncludeFlagged,
+	}
+}
+
+// analyticsBucket 时间所在的汇总桶起点
+func analyticsBucket(ts int64) int64 {
+	return ts - ts%analyticsBucketSize
+}
+
+// Refresh 增量更新时间桶并重算各窗口的汇总
+func (as *AnalyticsService) Refresh(ctx context.Context) error {
+	trades, err := as.rollupNewTrades(ctx)
+	if err != nil {
+		return err
+	}
+	retried, err := as.rollupMissingPrices(ctx)
+	if err != nil {
+		return err
+	}
+	flagged := 0
+	if !as.IncludeFlagged {
+		if flagged, err = as.rollupFlagChanges(); err != nil {
+			return err
+		}
+	}
+	changed := trades+retried+flagged > 0
+
+	now := time.Now().Unix()
+	computedAt := time.Now().Truncate(time.Second)
+	for _, key := range analyticsWindowOrder {
+		size := AnalyticsWindows[key]
+		// all 窗口只随成交变化，无新数据时跳过
+		if size == 0 && as.refreshed && !changed {
+			continue
+		}
+		start := int64(0)
+		if size > 0 {
+			start = analyticsBucket(now - size)
+		}
+		collections, err := as.collectionStats(key, start, computedAt)
+		if err != nil {
+			return fmt.Errorf("汇总合集统计失败 window=%s: %w", key, err)
+		}
+		if err := as.Dao.UpsertCollectionStats(key, collections, computedAt); err != nil {
+			return fmt.Errorf("写入合集统计失败 window=%s: %w", key, err)
+		}
+		traders, err := as.traderStats(key, start, computedAt)
+		if err != nil {
+			return fmt.Errorf("汇总地址统计失败 window=%s: %w", key, err)
+		}
+		if err := as.Dao.UpsertTraderStats(key, traders, computedAt); err != nil {
+			return fmt.Errorf("写入地址统计失败 window=%s: %w", key, err)
+		}
+	}
+	as.refreshed = true
+	log.Printf("[analytics] 统计汇总完成: trades=%d, retried=%d, flags=%d", trades, retried, flagged)
+	return nil
+}
+
+// rollupNewTrades 扫描上次进度之后的新成交，逐批重算所在的时间桶并保存进度
+func (as *AnalyticsService) rollupNewTrades(ctx context.Context) (int, error) {
+	cursor, err := as.Dao.GetAnalyticsCursor(dao.AnalyticsCursorTrades)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for {
+		trades, err := as.Dao.ListTradesAfterID(cursor, analyticsBatchSize)
+		if err != nil {
+			return total, err
+		}
+		if len(trades) == 0 {
+			return total, nil
+		}
+		buckets := map[int64]struct{}{}
+		for i := range trades {
+			if !trades[i].PriceBase.Valid {
+				as.fillPriceBase(ctx, &trades[i])
+			}
+			buckets[analyticsBucket(trades[i].BlockTime)] = struct{}{}
+		}
+		if err := as.rebuildBuckets(buckets); err != nil {
+			return total, err
+		}
+		cursor = trades[len(trades)-1].ID
+		if err := as.Dao.SaveAnalyticsCursor(dao.AnalyticsCursorTrades, cursor); err != nil {
+			return total, err
+		}
+		total += len(trades)
+		if len(trades) < analyticsBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// rollupMissingPrices 重试已扫描但写入时折算失败的成交（如价格源暂时不可用、升级前的历史成交）
+// 历史成交缺少写入时的汇率，补写时使用当前汇率；返回补写成功的成交数
+func (as *AnalyticsService) rollupMissingPrices(ctx context.Context) (int, error) {
+	cursor, err := as.Dao.GetAnalyticsCursor(dao.AnalyticsCursorTrades)
+	if err != nil {
+		return 0, err
+	}
+	trades, err := as.Dao.ListTradesMissingPriceBase(cursor, analyticsBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	repriced := 0
+	buckets := map[int64]struct{}{}
+	for i := range trades {
+		if as.fillPriceBase(ctx, &trades[i]) {
+			repriced++
+			buckets[analyticsBucket(trades[i].BlockTime)] = struct{}{}
+		}
+	}
+	return repriced, as.rebuildBuckets(buckets)
+}
+
+// fillPriceBase 补写成交的基础币种价格，失败时记录日志，成交暂不计入统计
+func (as *AnalyticsService) fillPriceBase(ctx context.Context, trade *dao.Trade) bool {
+	price, err := as.Currencies.ToBase(ctx, trade.Currency, trade.Price, trade.PriceScaled)
+	if err != nil {
+		log.Printf("[analytics] 成交价格折算失败: tx=%s, err=%v", trade.TxHash, err)
+		return false
+	}
+	if err := as.Dao.UpdateTradePriceBase(trade.ID, price); err != nil {
+		log.Printf("[analytics] 成交价格写入失败: tx=%s, err=%v", trade.TxHash, err)
+		return false
+	}
+	trade.PriceBase = decimal.NewNullDecimal(price)
+	return true
+}
+
+// rollupFlagChanges 新增或重新审核的可疑标记会改变成交是否计入统计，重算其所在的时间桶
+func (as *AnalyticsService) rollupFlagChanges() (int, error) {
+	flagCursor, err := as.Dao.GetAnalyticsCursor(dao.AnalyticsCursorFlags)
+	if err != nil {
+		return 0, err
+	}
+	reviewCursor, err := as.Dao.GetAnalyticsCursor(dao.AnalyticsCursorFlagReviews)
+	if err != nil {
+		return 0, err
+	}
+	created, err := as.Dao.ListFlaggedTradesAfterID(flagCursor, analyticsBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	reviewed, err := as.Dao.ListFlaggedTradesReviewedSince(reviewCursor)
+	if err != nil {
+		return 0, err
+	}
+	buckets := map[int64]struct{}{}
+	for _, f := range created {
+		buckets[analyticsBucket(f.BlockTime)] = struct{}{}
+		flagCursor = f.FlagID
+	}
+	for _, f := range reviewed {
+		buckets[analyticsBucket(f.BlockTime)] = struct{}{}
+		if f.ReviewedAt > reviewCursor {
+			reviewCursor = f.ReviewedAt
+		}
+	}
+	if err := as.rebuildBuckets(buckets); err != nil {
+		return 0, err
+	}
+	if err := as.Dao.SaveAnalyticsCursor(dao.AnalyticsCursorFlags, flagCursor); err != nil {
+		return 0, err
+	}
+	if err := as.Dao.SaveAnalyticsCursor(dao.AnalyticsCursorFlagReviews, reviewCursor); err != nil {
+		return 0, err
+	}
+	return len(created) + len(reviewed), nil
+}
+
+func (as *AnalyticsService) rebuildBuckets(buckets map[int64]struct{}) error {
+	for bucket := range buckets {
+		if err := as.Dao.RebuildRollupBucket(bucket, analyticsBucketSize, !as.IncludeFlagged); err != nil {
+			return fmt.Errorf("重算统计时间桶失败 bucket=%d: %w", bucket, err)
+		}
+	}
+	return nil
+}
+
+// statsKey 统计行的合集键，全市场保持 *
+func statsKey(collection string) string {
+	if collection == dao.GlobalCollection {
+		return collection
+	}
+	return normalizeAddr(collection)
+}
+
+// collectionStats 由时间桶汇总窗口内的合集统计，补充独立买卖家数与中位数
+func (as *AnalyticsService) collectionStats(window string, start int64, computedAt time.Time) ([]dao.CollectionStats, error) {
+	rows, err := as.Dao.SumTradeRollups(start)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := as.Dao.CountParticipants(start)
+	if err != nil {
+		return nil, err
+	}
+	medians, err := as.Dao.MedianPrices(start, !as.IncludeFlagged)
+	if err != nil {
+		return nil, err
+	}
+	buyers, sellers := map[string]int64{}, map[string]int64{}
+	for _, c := range counts {
+		if c.Role == dao.ParticipantBuyer {
+			buyers[statsKey(c.Collection)] += c.Count
+		} else {
+			sellers[statsKey(c.Collection)] += c.Count
+		}
+	}
+	medianOf := make(map[string]decimal.Decimal, len(medians))
+	for _, m := range medians {
+		medianOf[statsKey(m.Collection)] = m.Median
+	}
+	res := make([]dao.CollectionStats, 0, len(rows))
+	for _, row := range rows {
+		if row.Sales == 0 {
+			continue
+		}
+		key := statsKey(row.Collection)
+		row.Collection = key
+		row.Window = window
+		row.AvgPrice = row.Volume.DivRound(decimal.NewFromInt(row.Sales), 18)
+		row.MedianPrice = medianOf[key]
+		row.UniqueBuyers = buyers[key]
+		row.UniqueSellers = sellers[key]
+		row.WindowStart = start
+		row.LastComputedAt = computedAt
+		res = append(res, row)
+	}
+	return res, nil
+}
+
+// traderStats 由时间桶汇总窗口内的地址统计
+func (as *AnalyticsService) traderStats(window string, start int64, computedAt time.Time) ([]dao.TraderStats, error) {
+	rows, err := as.Dao.SumTraderRollups(start)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := as.Dao.CountTraderCollections(start)
+	if err != nil {
+		return nil, err
+	}
+	collections := make(map[string]int64, len(counts))
+	for _, c := range counts {
+		collections[normalizeAddr(c.Address)] += c.Count
+	}
+	for i := range rows {
+		rows[i].Address = normalizeAddr(rows[i].Address)
+		rows[i].Window = window
+		rows[i].Volume = rows[i].BuyVolume.Add(rows[i].SellVolume)
+		rows[i].Collections = collections[rows[i].Address]
+		rows[i].LastComputedAt = computedAt
+	}
+	return rows, nil
+}
+
+// ValidAnalyticsWindow 校验统计窗口参数
+func ValidAnalyticsWindow(window string) error {
+	if _, ok := AnalyticsWindows[window]; !ok {
+		return fmt.Errorf("不支持的统计窗口: %s，可选 1h/24h/7d/30d/all", window)
+	}
+	return nil
+}
+
+// StatsDTO 单个窗口的成交统计，金额为基础币种
+type StatsDTO struct {
+	Window        string `json:"window"`
+	Volume        string `json:"volume"`
+	Sales         int64  `json:"sales"`
+	AvgPrice      string `json:"avg_price"`
+	MedianPrice   string `json:"median_price"`
+	MinPrice      string `json:"min_price"`
+	MaxPrice      string `json:"max_price"`
+	UniqueBuyers  int64  `json:"unique_buyers"`
+	UniqueSellers int64  `json:"unique_sellers"`
+}
+
+// CollectionStatsDTO 合集（或全市场）各窗口成交统计
+type CollectionStatsDTO struct {
+	Collection     string     `json:"collection"`
+	Currency       string     `json:"currency"`
+	Windows        []StatsDTO `json:"windows"`
+	LastComputedAt int64      `json:"last_computed_at"`
+}
+
+// CollectionRankDTO 合集排行项
+type CollectionRankDTO struct {
+	Rank       int    `json:"rank"`
+	Collection string `json:"collection"`
+	StatsDTO
+}
+
+// TraderRankDTO 地址排行项
+type TraderRankDTO struct {
+	Rank        int    `json:"rank"`
+	Address     string `json:"address"`
+	Volume      string `json:"volume"`
+	BuyVolume   string `json:"buy_volume"`
+	SellVolume  string `json:"sell_volume"`
+	Buys        int64  `json:"buys"`
+	Sells       int64  `json:"sells"`
+	Collections int64  `json:"collections"`
+}
+
+func toStatsDTO(row *dao.CollectionStats) StatsDTO {
+	return StatsDTO{
+		Window:        row.Window,
+		Volume:        row.Volume.String(),
+		Sales:         row.Sales,
+		AvgPrice:      row.AvgPrice.String(),
+		MedianPrice:   row.MedianPrice.String(),
+		MinPrice:      row.MinPrice.String(),
+		MaxPrice:      row.MaxPrice.String(),
+		UniqueBuyers:  row.UniqueBuyers,
+		UniqueSellers: row.UniqueSellers,
+	}
+}
+
+// GetCollectionStats 查询合集各窗口的成交统计，collection 为 dao.GlobalCollection 时返回全市场统计
+// 窗口内无成交时返回零值
+func (s *Service) GetCollectionStats(collection string) (*CollectionStatsDTO, error) {
+	if collection != dao.GlobalCollection {
+		collection = normalizeAddr(collection)
+	}
+	rows, err := s.Dao.ListCollectionStats(collection)
+	if err != nil {
+		return nil, err
+	}
+	byWindow := make(map[string]*dao.CollectionStats, len(rows))
+	res := &CollectionStatsDTO{Collection: collection, Currency: s.Currencies.PriceFeed.BaseCurrency()}
+	for i := range rows {
+		byWindow[rows[i].Window] = &rows[i]
+		if ts := rows[i].LastComputedAt.Unix(); ts > res.LastComputedAt {
+			res.LastComputedAt = ts
+		}
+	}
+	for _, window := range analyticsWindowOrder {
+		row, ok := byWindow[window]
+		if !ok {
+			row = &dao.CollectionStats{Window: window}
+		}
+		res.Windows = append(res.Windows, toStatsDTO(row))
+	}
+	return res, nil
+}
+
+// GetTopCollections 合集排行榜
+func (s *Service) GetTopCollections(window, sortBy string, limit int) ([]CollectionRankDTO, error) {
+	column, ok := collectionSortColumns[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSort, sortBy)
+	}
+	rows, err := s.Dao.ListTopCollections(window, column, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]CollectionRankDTO, 0, len(rows))
+	for i := range rows {
+		res = append(res, CollectionRankDTO{Rank: i + 1, Collection: rows[i].Collection, StatsDTO: toStatsDTO(&rows[i])})
+	}
+	return res, nil
+}
+
+// GetTopTraders 地址排行榜
+func (s *Service) GetTopTraders(window, sortBy string, limit int) ([]TraderRankDTO, error) {
+	column, ok := traderSortColumns[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSort, sortBy)
+	}
+	rows, err := s.Dao.ListTopTraders(window, column, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]TraderRankDTO, 0, len(rows))
+	for i, row := range rows {
+		res = append(res, TraderRankDTO{
+			Rank:        i + 1,
+			Address:     row.Address,
+			Volume:      row.Volume.String(),
+			BuyVolume:   row.BuyVolume.String(),
+			SellVolume:  row.SellVolume.String(),
+			Buys:        row.Buys,
+			Sells:       row.Sells,
+			Collections: row.Collections,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gavin/nftSync/internal/auth"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/go-redis/redis/v8"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAPIKeyNotFound   = errors.New("API Key 不存在")
+	ErrAPIKeyLimit      = errors.New("有效 API Key 数量已达上限")
+	ErrAPIKeyQuotaRange = errors.New("限流或配额超出允许范围")
+)
+
+const (
+	apiKeyAuthPrefix = "nftsync:apikey:auth:"
+	// rejectedField 用量统计中被限流或超配额拒绝的请求数
+	rejectedField = "_rejected"
+)
+
+// apiKeyQuotaScript 原子地检查分钟限流与每日配额，通过时计数并按接口累计用量
+// KEYS: 分钟计数、日计数、日用量 hash；ARGV: 分钟上限、日上限、接口、用量保留秒数
+// 返回 {是否通过, 拒绝原因(1 分钟限流, 2 日配额), 分钟计数, 日计数}
+var apiKeyQuotaScript = redis.NewScript(`
+local m = tonumber(redis.call('GET', KEYS[1]) or '0')
+local d = tonumber(redis.call('GET', KEYS[2]) or '0')
+local reason = 0
+if m >= tonumber(ARGV[1]) then reason = 1 elseif d >= tonumber(ARGV[2]) then reason = 2 end
+if reason > 0 then
+  redis.call('HINCRBY', KEYS[3], '` + rejectedField + `', 1)
+  redis.call('EXPIRE', KEYS[3], ARGV[4])
+  return {0, reason, m, d}
+end
+m = redis.call('INCR', KEYS[1])
+if m == 1 then redis.call('EXPIRE', KEYS[1], 60) end
+d = redis.call('INCR', KEYS[2])
+if d == 1 then redis.call('EXPIRE', KEYS[2], 172800) end
+redis.call('HINCRBY', KEYS[3], ARGV[3], 1)
+redis.call('EXPIRE', KEYS[3], ARGV[4])
+return {1, 0, m, d}
+`)
+
+// APIKeyService API Key 管理、校验与配额
+type APIKeyService struct {
+	Dao    *dao.Dao
+	Redis  *redis.Client
+	Config config.APIKeyConfig
+	now    func() time.Time
+}
+
+func NewAPIKeyService(ctx *config.Context) *APIKeyService {
+	cfg := ctx.Config.APIKeys
+	if cfg.RateLimit <= 0 {
+		cfg.RateLimit = 60
+	}
+	if cfg.DailyQuota <= 0 {
+		cfg.DailyQuota = 10000
+	}
+	if cfg.MaxPerUser <= 0 {
+		cfg.MaxPerUser = 10
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = 60
+	}
+	if cfg.UsageRetention <= 0 {
+		cfg.UsageRetention = 30
+	}
+	return &APIKeyService{Dao: dao.New(ctx.Db), Redis: ctx.Redis, Config: cfg, now: time.Now}
+}
+
+// CreateAPIKeyReq 创建 API Key，限流与配额为 0 时使用默认值
+type CreateAPIKeyReq struct {
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes" binding:"required"`
+	RateLimit  int      `json:"rate_limit"`
+	DailyQuota int      `json:"daily_quota"`
+}
+
+// APIKeyDTO API Key 信息，Key 明文仅在创建时返回
+type APIKeyDTO struct {
+	ID         int64    `json:"id"`
+	Name       string   `json:"name"`
+	Key        string   `json:"key,omitempty"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	RateLimit  int      `json:"rate_limit"`
+	DailyQuota int      `json:"daily_quota"`
+	Revoked    bool     `json:"revoked"`
+	CreatedAt  int64    `json:"created_at"`
+}
+
+// CreateAPIKey 为用户创建 API Key；普通用户的限流与配额不能超过默认值，admin 不限
+func (s *APIKeyService) CreateAPIKey(userID int64, role string, req CreateAPIKeyReq) (*APIKeyDTO, error) {
+	scopes, err := auth.ParseScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if req.RateLimit < 0 || req.DailyQuota < 0 ||
+		(role != auth.RoleAdmin && (req.RateLimit > s.Config.RateLimit || req.DailyQuota > s.Config.DailyQuota)) {
+		return nil, ErrAPIKeyQuotaRange
+	}
+	n, err := s.Dao.CountActiveAPIKeys(userID)
+	if err != nil {
+		return nil, err
+	}
+	if n >= int64(s.Config.MaxPerUser) {
+		return nil, ErrAPIKeyLimit
+	}
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	record := &dao.APIKey{
+		UserID:     userID,
+		Name:       req.Name,
+		Prefix:     prefix,
+		KeyHash:    hash,
+		Scopes:     strings.Join(scopes, ","),
+		RateLimit:  req.RateLimit,
+		DailyQuota: req.DailyQuota,
+	}
+	if err := s.Dao.CreateAPIKey(record); err != nil {
+		return nil, err
+	}
+	log.Printf("[apikey] 创建 API Key: user=%d, key=%d, scopes=%s", userID, record.ID, record.Scopes)
+	dto := s.toDTO(record)
+	dto.Key = key
+	return dto, nil
+}
+
+// ListAPIKeys 用户的全部 API Key
+func (s *APIKeyService) ListAPIKeys(userID int64) ([]APIKeyDTO, error) {
+	keys, err := s.Dao.ListUserAPIKeys(userID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]APIKeyDTO, 0, len(keys))
+	for i := range keys {
+		res = append(res, *s.toDTO(&keys[i]))
+	}
+	return res, nil
+}
+
+// RevokeAPIKey 吊销用户的 API Key，并清除校验缓存使其立即失效
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, userID, id int64) error {
+	record, err := s.Dao.GetUserAPIKey(userID, id)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return ErrAPIKeyNotFound
+	}
+	if err := s.Dao.RevokeAPIKey(id); err != nil {
+		return err
+	}
+	if err := s.Redis.Del(ctx, apiKeyAuthPrefix+record.KeyHash).Err(); err != nil {
+		log.Printf("[apikey] 清除校验缓存失败: key=%d, err=%v", id, err)
+	}
+	log.Printf("[apikey] 吊销 API Key: user=%d, key=%d", userID, id)
+	return nil
+}
+
+// AuthenticateAPIKey 校验明文 Key，结果缓存 cache_ttl 秒；角色取 Key 所属用户当前角色
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, key string) (*auth.APIKeyPrincipal, error) {
+	hash := auth.HashAPIKey(key)
+	cacheKey := apiKeyAuthPrefix + hash
+	if cached, err := s.Redis.Get(ctx, cacheKey).Bytes(); err == nil {
+		var p auth.APIKeyPrincipal
+		if json.Unmarshal(cached, &p) == nil {
+			return &p, nil
+		}
+	}
+	record, err := s.Dao.GetAPIKeyByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil || record.RevokedAt != 0 {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	user, err := s.Dao.GetUserByID(record.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	p := &auth.APIKeyPrincipal{
+		KeyID:      record.ID,
+		UserID:     record.UserID,
+		Role:       userRole(user),
+		Scopes:     strings.Split(record.Scopes, ","),
+		RateLimit:  s.rateLimit(record),
+		DailyQuota: s.dailyQuota(record),
+	}
+	if data, err := json.Marshal(p); err == nil {
+		_ = s.Redis.Set(ctx, cacheKey, data, time.Duration(s.Config.CacheTTL)*time.Second).Err()
+	}
+	return p, nil
+}
+
+// ConsumeAPIKeyQuota 计入一次请求，超过分钟限流或每日配额（UTC 日）时拒绝
+func (s *APIKeyService) ConsumeAPIKeyQuota(ctx context.Context, p *auth.APIKeyPrincipal, endpoint string) (*auth.QuotaDecision, error) {
+	now := s.now().UTC()
+	day := now.Format("20060102")
+	keys := []string{
+		fmt.Sprintf("nftsync:apikey:{%d}:min:%d", p.KeyID, now.Unix()/60),
+		fmt.Sprintf("nftsync:apikey:{%d}:day:%s", p.KeyID, day),
+		usageKey(p.KeyID, day),
+	}
+	retention := s.Config.UsageRetention * 86400
+	res, err := apiKeyQuotaScript.Run(ctx, s.Redis, keys, p.RateLimit, p.DailyQuota, endpoint, retention).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	nextDay := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	d := &auth.QuotaDecision{
+		Allowed:        res[0] == 1,
+		DailyLimit:     p.DailyQuota,
+		DailyRemaining: p.DailyQuota - int(res[3]),
+		DailyReset:     nextDay,
+	}
+	if d.DailyRemaining < 0 {
+		d.DailyRemaining = 0
+	}
+	switch res[1] {
+	case 1:
+		d.Reason = auth.QuotaRateLimited
+		d.RetryAfter = time.Duration(60-now.Unix()%60) * time.Second
+	case 2:
+		d.Reason = auth.QuotaExhausted
+		d.RetryAfter = nextDay.Sub(now)
+	}
+	return d, nil
+}
+
+// APIKeyUsageDTO 单日用量
+type APIKeyUsageDTO struct {
+	Date      string           `json:"date"` // UTC yyyy-mm-dd
+	Total     int64            `json:"total"`
+	Rejected  int64            `json:"rejected"`
+	Endpoints map[string]int64 `json:"endpoints"`
+}
+
+// APIKeyUsageReport API Key 最近若干天的用量，按日期倒序
+type APIKeyUsageReport struct {
+	KeyID      int64            `json:"key_id"`
+	DailyQuota int              `json:"daily_quota"`
+	Days       []APIKeyUsageDTO `json:"days"`
+}
+
+// GetAPIKeyUsage 按接口统计 API Key 每日用量，days 不超过用量保留天数
+func (s *APIKeyService) GetAPIKeyUsage(ctx context.Context, userID, id int64, days int) (*APIKeyUsageReport, error) {
+	record, err := s.Dao.GetUserAPIKey(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	if days <= 0 {
+		days = 7
+	}
+	if days > s.Config.UsageRetention {
+		days = s.Config.UsageRetention
+	}
+	now := s.now().UTC()
+	pipe := s.Redis.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, days)
+	for i := 0; i < days; i++ {
+		cmds[i] = pipe.HGetAll(ctx, usageKey(id, now.AddDate(0, 0, -i).Format("20060102")))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	report := &APIKeyUsageReport{KeyID: id, DailyQuota: s.dailyQuota(record), Days: make([]APIKeyUsageDTO, 0, days)}
+	for i, cmd := range cmds {
+		usage := APIKeyUsageDTO{Date: now.AddDate(0, 0, -i).Format("2006-01-02"), Endpoints: map[string]int64{}}
+		fields, _ := cmd.Result()
+		for endpoint, v := range fields {
+			n, _ := strconv.ParseInt(v, 10, 64)
+			if endpoint == rejectedField {
+				usage.Rejected = n
+				continue
+			}
+			usage.Endpoints[endpoint] = n
+			usage.Total += n
+		}
+		report.Days = append(report.Days, usage)
+	}
+	return report, nil
+}
+
+func (s *APIKeyService) toDTO(k *dao.APIKey) *APIKeyDTO {
+	return &APIKeyDTO{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     strings.Split(k.Scopes, ","),
+		RateLimit:  s.rateLimit(k),
+		DailyQuota: s.dailyQuota(k),
+		Revoked:    k.RevokedAt != 0,
+		CreatedAt:  k.CreatedAt.Unix(),
+	}
+}
+
+func (s *APIKeyService) rateLimit(k *dao.APIKey) int {
+	if k.RateLimit > 0 {
+		return k.RateLimit
+	}
+	return s.Config.RateLimit
+}
+
+func (s *APIKeyService) dailyQuota(k *dao.APIKey) int {
+	if k.DailyQuota > 0 {
+		return k.DailyQuota
+	}
+	return s.Config.DailyQuota
+}
+
+func usageKey(keyID int64, day string) string {
+	return fmt.Sprintf("nftsync:apikey:{%d}:usage:%s", keyID, day)
+}
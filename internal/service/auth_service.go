@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/gavin/nftSync/internal/auth"
+	"github.com/gavin/nftSync/internal/dao"
+	"log"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("刷新令牌无效或已过期")
+	ErrRefreshTokenReused  = errors.New("刷新令牌已被使用，该登录会话已吊销")
+)
+
+const defaultRefreshTTL = 30 * 24 * time.Hour
+
+// TokenPairDTO 登录与刷新返回的令牌
+type TokenPairDTO struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"` // 秒
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in"`
+	Role             string `json:"role"`
+}
+
+// IssueTokens 登录成功后签发访问令牌与新会话的刷新令牌
+func (s *Service) IssueTokens(user *dao.User) (*TokenPairDTO, error) {
+	return s.issueTokens(user, randomToken(16), 0)
+}
+
+// issueTokens 签发令牌对，rotateFrom 非 0 时在同一事务中吊销被轮换的刷新令牌
+func (s *Service) issueTokens(user *dao.User, familyID string, rotateFrom int64) (*TokenPairDTO, error) {
+	role := userRole(user)
+	access, _, err := s.Auth.Issue(strconv.FormatInt(user.ID, 10), role)
+	if err != nil {
+		return nil, err
+	}
+	ttl := s.refreshTTL()
+	refresh := randomToken(32)
+	record := &dao.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(refresh),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	}
+	if rotateFrom == 0 {
+		err = s.Dao.CreateRefreshToken(record)
+	} else {
+		var rotated bool
+		if rotated, err = s.Dao.RotateRefreshToken(rotateFrom, record); err == nil && !rotated {
+			// 并发使用同一刷新令牌，按重放处理
+			s.revokeFamily(familyID)
+			return nil, ErrRefreshTokenReused
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPairDTO{
+		AccessToken:      access,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(s.Auth.AccessTTL().Seconds()),
+		RefreshToken:     refresh,
+		RefreshExpiresIn: int64(ttl.Seconds()),
+		Role:             role,
+	}, nil
+}
+
+// RefreshTokens 以刷新令牌换取新令牌对，旧刷新令牌随即失效；已失效的令牌再次使用时吊销整个会话
+func (s *Service) RefreshTokens(refresh string) (*TokenPairDTO, error) {
+	record, err := s.Dao.GetRefreshTokenByHash(hashToken(refresh))
+	if err != nil {
+		return nil, err
+	}
+	if record == nil || record.ExpiresAt <= time.Now().Unix() {
+		return nil, ErrInvalidRefreshToken
+	}
+	if record.RevokedAt != 0 {
+		if record.ReplacedBy != 0 {
+			// 已轮换的令牌被重放，可能已泄露
+			log.Printf("[auth] 刷新令牌重放: user=%d, family=%s", record.UserID, record.FamilyID)
+			s.revokeFamily(record.FamilyID)
+			return nil, ErrRefreshTokenReused
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	user, err := s.Dao.GetUserByID(record.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	return s.issueTokens(user, record.FamilyID, record.ID)
+}
+
+// Logout 吊销当前访问令牌及刷新令牌所在会话，all 为 true 时吊销该用户全部会话
+func (s *Service) Logout(ctx context.Context, claims *auth.Claims, refresh string, all bool) error {
+	if s.Auth.Denylist != nil {
+		if err := s.Auth.Denylist.Revoke(ctx, claims); err != nil {
+			return err
+		}
+	}
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return nil
+	}
+	if all {
+		return s.Dao.RevokeUserRefreshTokens(userID)
+	}
+	if refresh == "" {
+		return nil
+	}
+	record, err := s.Dao.GetRefreshTokenByHash(hashToken(refresh))
+	if err != nil || record == nil || record.UserID != userID {
+		return err
+	}
+	return s.Dao.RevokeRefreshTokenFamily(record.FamilyID)
+}
+
+func (s *Service) revokeFamily(familyID string) {
+	if err := s.Dao.RevokeRefreshTokenFamily(familyID); err != nil {
+		log.Printf("[auth] 刷新令牌会话吊销失败: family=%s, err=%v", familyID, err)
+	}
+}
+
+func (s *Service) refreshTTL() time.Duration {
+	if ttl := s.Config.Auth.RefreshTTL; ttl > 0 {
+		return time.Duration(ttl) * time.Second
+	}
+	return defaultRefreshTTL
+}
+
+func userRole(user *dao.User) string {
+	if user.Role == "" {
+		return auth.RoleUser
+	}
+	return user.Role
+}
+
+// randomToken 生成 URL 安全的随机令牌
+func randomToken(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hashToken 令牌只以 SHA-256 哈希入库
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"github.com/gavin/nftSync/internal/bus"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/middleware"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryOutbox 进程内发件箱，替代 MySQL 表
+type memoryOutbox struct {
+	mu     sync.Mutex
+	nextID int64
+	msgs   []dao.OutboxMessage
+}
+
+func (o *memoryOutbox) EnqueueOutbox(msgs ...dao.OutboxMessage) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, m := range msgs {
+		o.nextID++
+		m.ID = o.nextID
+		o.msgs = append(o.msgs, m)
+	}
+}
+
+func (o *memoryOutbox) ListPendingOutbox(limit int) ([]dao.OutboxMessage, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var list []dao.OutboxMessage
+	for _, m := range o.msgs {
+		if m.PublishedAt == 0 && len(list) < limit {
+			list = append(list, m)
+		}
+	}
+	return list, nil
+}
+
+func (o *memoryOutbox) MarkOutboxPublished(ids []int64, publishedAt int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, id := range ids {
+		for i := range o.msgs {
+			if o.msgs[i].ID == id {
+				o.msgs[i].PublishedAt = publishedAt
+			}
+		}
+	}
+	return nil
+}
+
+func (o *memoryOutbox) MarkOutboxFailed(id int64, errMsg string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := range o.msgs {
+		if o.msgs[i].ID == id {
+			o.msgs[i].Attempts++
+			o.msgs[i].LastError = errMsg
+		}
+	}
+	return nil
+}
+
+func (o *memoryOutbox) DeletePublishedOutbox(before int64) (int64, error) {
+	return 0, nil
+}
+
+// TestSyncOutboxFloorPricePipeline 同步写入发件箱 -> 中继发布到进程内总线 -> 地板价消费者合并重算，全程无外部 broker
+func TestSyncOutboxFloorPricePipeline(t *testing.T) {
+	publisher, subscriber, err := bus.New(bus.Options{Backend: bus.BackendMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const topic = "floor_price_topic"
+	outbox := &memoryOutbox{}
+	relay := &OutboxRelay{
+		Store:      outbox,
+		Publisher:  publisher,
+		FloorTopic: topic,
+		Config:     config.OutboxConfig{BatchSize: 100},
+	}
+
+	type recompute struct{ collection, trigger string }
+	updates := make(chan recompute, 10)
+	fps := &FloorPriceService{
+		Subscriber: subscriber,
+		Topic:      topic,
+		Debounce:   20 * time.Millisecond,
+		MaxDelay:   200 * time.Millisecond,
+		update: func(collection, trigger string) error {
+			updates <- recompute{collection, trigger}
+			return nil
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go fps.StartConsumer(ctx)
+
+	// 同步路径在业务事务中写入的发件箱消息：同一合集连续三条，另一合集一条
+	outbox.EnqueueOutbox(
+		dao.FloorPriceOutbox("0xaaa", middleware.FloorTriggerOrderCreated),
+		dao.FloorPriceOutbox("0xaaa", middleware.FloorTriggerTransfer),
+		dao.FloorPriceOutbox("0xbbb", middleware.FloorTriggerOrderCreated),
+		dao.FloorPriceOutbox("0xaaa", middleware.FloorTriggerOrderFilled),
+	)
+	n, err := relay.RelayOnce(ctx)
+	if err != nil || n != 4 {
+		t.Fatalf("RelayOnce = %d, %v", n, err)
+	}
+	if pending, _ := outbox.ListPendingOutbox(100); len(pending) != 0 {
+		t.Fatalf("%d outbox messages still pending", len(pending))
+	}
+
+	got := map[string]string{}
+	for len(got) < 2 {
+		select {
+		case u := <-updates:
+			if _, dup := got[u.collection]; dup {
+				t.Fatalf("collection %s recomputed twice", u.collection)
+			}
+			got[u.collection] = u.trigger
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout, recomputed: %v", got)
+		}
+	}
+	// 合并后的重算使用最近一次触发事件
+	if got["0xaaa"] != middleware.FloorTriggerOrderFilled || got["0xbbb"] != middleware.FloorTriggerOrderCreated {
+		t.Fatalf("unexpected triggers: %v", got)
+	}
+}
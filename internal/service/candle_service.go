@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/shopspring/decimal"
+	"log"
+)
+
+// CandleIntervals 支持的 K 线周期（秒）
+var CandleIntervals = map[string]int64{
+	"5m": 5 * 60,
+	"1h": 60 * 60,
+	"1d": 24 * 60 * 60,
+	"1w": 7 * 24 * 60 * 60,
+}
+
+var candleIntervalOrder = []string{"5m", "1h", "1d", "1w"}
+
+// weekOffset Unix 纪元为周四，周线按周一 00:00 UTC 对齐
+const weekOffset = 3 * 24 * 60 * 60
+
+// bucketStart 计算时间戳所在周期的起始时间
+func bucketStart(ts int64, interval string) int64 {
+	size := CandleIntervals[interval]
+	if interval == "1w" {
+		return (ts+weekOffset)/size*size - weekOffset
+	}
+	return ts / size * size
+}
+
+// CandleService 基于销售账本增量构建 K 线
+// 每笔新成交按其基础币种价格并入所在各周期的 K 线；推导成交被事件成交替换时按账本重算所在周期
+type CandleService struct {
+	Dao        *dao.Dao
+	Currencies *CurrencyResolver
+}
+
+func NewCandleService(ctx *config.Context) *CandleService {
+	return &CandleService{
+		Dao:        dao.New(ctx.Db),
+		Currencies: NewCurrencyResolver(ctx),
+	}
+}
+
+// pricedTrade 已折算为基础币种的成交
+type pricedTrade struct {
+	time  int64
+	price decimal.Decimal
+}
+
+// OnTrade 新成交并入其所在各周期的 K 线，调用方需保证同一成交只计入一次
+// 未能折算基础币种价格的成交跳过，可通过回填补齐
+func (cs *CandleService) OnTrade(trade *dao.Trade) error {
+	if !trade.PriceBase.Valid {
+		return nil
+	}
+	price := trade.PriceBase.Decimal
+	for _, interval := range candleIntervalOrder {
+		candle := &dao.SaleCandle{
+			Collection:  trade.Collection,
+			Interval:    interval,
+			BucketStart: bucketStart(trade.BlockTime, interval),
+			Open:        price,
+			High:        price,
+			Low:         price,
+			Close:       price,
+			Volume:      price,
+			Count:       1,
+			OpenTime:    trade.BlockTime,
+			CloseTime:   trade.BlockTime,
+		}
+		if err := cs.Dao.MergeCandle(candle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rebuild 按账本重算时间点所在各周期的 K 线，用于成交被替换等无法增量扣除的场景
+// 5m K 线由该周期内的成交重算，更大周期由其包含的 5m K 线合并，不再加载整周成交
+func (cs *CandleService) Rebuild(ctx context.Context, collection string, ts int64) error {
+	start := bucketStart(ts, "5m")
+	trades, err := cs.Dao.ListTradesInRange(collection, start, start+CandleIntervals["5m"])
+	if err != nil {
+		return err
+	}
+	if err := cs.saveCandle(collection, "5m", start, aggregateCandles(collection, "5m", cs.priceTrades(ctx, trades))[start]); err != nil {
+		return err
+	}
+	base := CandleIntervals["5m"]
+	for _, interval := range candleIntervalOrder[1:] {
+		start := bucketStart(ts, interval)
+		size := CandleIntervals[interval]
+		parts, err := cs.Dao.ListCandles(collection, "5m", start, start+size, int(size/base))
+		if err != nil {
+			return err
+		}
+		if err := cs.saveCandle(collection, interval, start, mergeCandles(collection, interval, start, parts)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveCandle 覆盖写入 K 线，周期内已无成交时删除
+func (cs *CandleService) saveCandle(collection, interval string, start int64, candle *dao.SaleCandle) error {
+	if candle == nil {
+		return cs.Dao.DeleteCandle(collection, interval, start)
+	}
+	return cs.Dao.UpsertCandle(candle)
+}
+
+// mergeCandles 按时间正序的小周期 K 线合并为一根大周期 K 线，无数据返回 nil
+func mergeCandles(collection, interval string, start int64, parts []dao.SaleCandle) *dao.SaleCandle {
+	if len(parts) == 0 {
+		return nil
+	}
+	c := parts[0]
+	c.ID = 0
+	c.Collection, c.Interval, c.BucketStart = collection, interval, start
+	for _, p := range parts[1:] {
+		if p.High.GreaterThan(c.High) {
+			c.High = p.High
+		}
+		if p.Low.LessThan(c.Low) {
+			c.Low = p.Low
+		}
+		c.Close, c.CloseTime = p.Close, p.CloseTime
+		c.Volume = c.Volume.Add(p.Volume)
+		c.Count += p.Count
+	}
+	return &c
+}
+
+// Backfill 从销售账本按周全量回填合集 K 线，用于历史数据补齐或修复，返回写入的 K 线数量
+func (cs *CandleService) Backfill(ctx context.Context, collection string) (int, error) {
+	minTime, maxTime, err := cs.Dao.GetTradeTimeRange(collection)
+	if err != nil {
+		return 0, err
+	}
+	if minTime == 0 && maxTime == 0 {
+		return 0, nil
+	}
+	written := 0
+	week := CandleIntervals["1w"]
+	for start := bucketStart(minTime, "1w"); start <= maxTime; start += week {
+		trades, err := cs.Dao.ListTradesInRange(collection, start, start+week)
+		if err != nil {
+			return written, err
+		}
+		if len(trades) == 0 {
+			continue
+		}
+		priced := cs.priceTrades(ctx, trades)
+		for _, interval := range candleIntervalOrder {
+			for _, candle := range aggregateCandles(collection, interval, priced) {
+				if err := cs.Dao.UpsertCandle(candle); err != nil {
+					return written, err
+				}
+				written++
+			}
+		}
+	}
+	log.Printf("[candle] K线回填完成: collection=%s, candles=%d", collection, written)
+	return written, nil
+}
+
+// priceTrades 成交价格取写入时折算的基础币种价格，缺失时按当前汇率折算，折算失败的成交跳过
+func (cs *CandleService) priceTrades(ctx context.Context, trades []dao.Trade) []pricedTrade {
+	priced := make([]pricedTrade, 0, len(trades))
+	for i := range trades {
+		if trades[i].PriceBase.Valid {
+			priced = append(priced, pricedTrade{time: trades[i].BlockTime, price: trades[i].PriceBase.Decimal})
+			continue
+		}
+		price, err := cs.Currencies.ToBase(ctx, trades[i].Currency, trades[i].Price, trades[i].PriceScaled)
+		if err != nil {
+			log.Printf("[candle] 成交价格折算失败: tx=%s, err=%v", trades[i].TxHash, err)
+			continue
+		}
+		priced = append(priced, pricedTrade{time: trades[i].BlockTime, price: price})
+	}
+	return priced
+}
+
+// aggregateCandles 按周期聚合成交（输入需按成交顺序排列），返回 起始时间 -> K线
+func aggregateCandles(collection, interval string, trades []pricedTrade) map[int64]*dao.SaleCandle {
+	candles := map[int64]*dao.SaleCandle{}
+	for _, t := range trades {
+		start := bucketStart(t.time, interval)
+		c, ok := candles[start]
+		if !ok {
+			candles[start] = &dao.SaleCandle{
+				Collection:  collection,
+				Interval:    interval,
+				BucketStart: start,
+				Open:        t.price,
+				High:        t.price,
+				Low:         t.price,
+				Close:       t.price,
+				Volume:      t.price,
+				Count:       1,
+				OpenTime:    t.time,
+				CloseTime:   t.time,
+			}
+			continue
+		}
+		if t.price.GreaterThan(c.High) {
+			c.High = t.price
+		}
+		if t.price.LessThan(c.Low) {
+			c.Low = t.price
+		}
+		c.Close, c.CloseTime = t.price, t.time
+		c.Volume = c.Volume.Add(t.price)
+		c.Count++
+	}
+	return candles
+}
+
+// ValidCandleInterval 校验 K 线周期参数
+func ValidCandleInterval(interval string) error {
+	if _, ok := CandleIntervals[interval]; !ok {
+		return fmt.Errorf("不支持的周期: %s，可选 5m/1h/1d/1w", interval)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gavin/nftSync/internal/blockchain"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/middleware"
+	"github.com/gavin/nftSync/internal/pricefeed"
+	"github.com/shopspring/decimal"
+	"sync"
+	"time"
+)
+
+const CurrencyCacheTTL = 24 * time.Hour
+
+// 进程内币种缓存，币种元数据不可变，无需过期
+var currencyMemCache sync.Map
+
+// nativeCurrency 原生 ETH
+var nativeCurrency = dao.Currency{Address: common.Address{}.Hex(), Symbol: "ETH", Decimals: 18}
+
+// CurrencyResolver 解析支付币种的 symbol/decimals
+// 查询顺序：进程内缓存 -> Redis -> MySQL -> 链上 ERC20 调用
+type CurrencyResolver struct {
+	Dao       *dao.Dao
+	Cache     *middleware.Cache
+	MultiNode *config.MultiNodeEthClient
+	PriceFeed pricefeed.PriceFeed
+}
+
+func NewCurrencyResolver(ctx *config.Context) *CurrencyResolver {
+	return &CurrencyResolver{
+		Dao:       dao.New(ctx.Db),
+		Cache:     middleware.NewRedis(ctx.Redis),
+		MultiNode: ctx.MultiNode,
+		PriceFeed: ctx.PriceFeed,
+	}
+}
+
+// Resolve 查询币种元数据
+func (r *CurrencyResolver) Resolve(ctx context.Context, address string) (*dao.Currency, error) {
+	if pricefeed.IsNative(address) {
+		return &nativeCurrency, nil
+	}
+	address = common.HexToAddress(address).Hex()
+	if v, ok := currencyMemCache.Load(address); ok {
+		return v.(*dao.Currency), nil
+	}
+	cacheKey := fmt.Sprintf("currency:%s", address)
+	if cacheVal, err := r.Cache.GetCache(ctx, cacheKey); err == nil && cacheVal != "" {
+		var currency dao.Currency
+		if jsonErr := json.Unmarshal([]byte(cacheVal), &currency); jsonErr == nil {
+			currencyMemCache.Store(address, &currency)
+			return &currency, nil
+		}
+	}
+	currency, err := r.Dao.GetCurrency(address)
+	if err != nil {
+		return nil, err
+	}
+	if currency == nil {
+		currency, err = r.fetchOnChain(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.Dao.SaveCurrency(currency); err != nil {
+			return nil, err
+		}
+	}
+	if data, jsonErr := json.Marshal(currency); jsonErr == nil {
+		r.Cache.SetCache(ctx, cacheKey, string(data), CurrencyCacheTTL)
+	}
+	currencyMemCache.Store(address, currency)
+	return currency, nil
+}
+
+// fetchOnChain 通过节点池查询 ERC20 元数据，主节点失败时依次尝试其他节点
+func (r *CurrencyResolver) fetchOnChain(ctx context.Context, address string) (*dao.Currency, error) {
+	var err error
+	for _, cli := range r.MultiNode.Clients {
+		var symbol string
+		var decimals uint8
+		symbol, decimals, err = blockchain.NewEthClient(cli).GetERC20Metadata(ctx, address)
+		if err == nil {
+			return &dao.Currency{Address: address, Symbol: symbol, Decimals: decimals}, nil
+		}
+	}
+	return nil, fmt.Errorf("币种元数据查询失败 %s: %w", address, err)
+}
+
+// Scale 原始数值按币种 decimals 缩放
+func (r *CurrencyResolver) Scale(ctx context.Context, address string, raw decimal.Decimal) (decimal.Decimal, error) {
+	currency, err := r.Resolve(ctx, address)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return raw.Shift(-int32(currency.Decimals)), nil
+}
+
+// Normalize 缩放后的金额折算为基础币种
+func (r *CurrencyResolver) Normalize(ctx context.Context, address string, scaled decimal.Decimal) (decimal.Decimal, error) {
+	if r.PriceFeed == nil {
+		return decimal.Zero, fmt.Errorf("未配置价格源")
+	}
+	rate, err := r.PriceFeed.Rate(ctx, address)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return scaled.Mul(rate), nil
+}
+
+// ToBase 金额折算为基础币种，历史数据未记录缩放值时按原始数值实时缩放
+func (r *CurrencyResolver) ToBase(ctx context.Context, address string, raw, scaled decimal.Decimal) (decimal.Decimal, error) {
+	if scaled.IsZero() && !raw.IsZero() {
+		var err error
+		if scaled, err = r.Scale(ctx, address, raw); err != nil {
+			return decimal.Zero, err
+		}
+	}
+	return r.Normalize(ctx, address, scaled)
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"encoding/json"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/events"
+	"strconv"
+)
+
+// activityEventTypes 活动类型 -> 领域事件类型，销毁视为转移到零地址
+// 公开市场成交与转账推导成交发布为 order.filled；自有订单合约的成交已由 order_filled 活动发布，sale 活动不重复发布
+var activityEventTypes = map[string]string{
+	dao.ActivitySale:           events.TypeOrderFilled,
+	dao.ActivityMint:           events.TypeNFTMinted,
+	dao.ActivityTransfer:       events.TypeNFTTransferred,
+	dao.ActivityBurn:           events.TypeNFTTransferred,
+	dao.ActivityMetadataUpdate: events.TypeNFTMetadataUpdated,
+	dao.ActivityOrderCreated:   events.TypeOrderCreated,
+	dao.ActivityOrderCancelled: events.TypeOrderCancelled,
+	dao.ActivityOrderFilled:    events.TypeOrderFilled,
+}
+
+// activityEvent 活动记录转换为领域事件信封，不需要发布的活动返回 nil
+func activityEvent(a *dao.Activity) (*events.Envelope, error) {
+	typ, ok := activityEventTypes[a.Type]
+	if !ok || (a.Type == dao.ActivitySale && a.Marketplace == dao.MarketplaceNative) {
+		return nil, nil
+	}
+	var payload interface{}
+	if a.OrderID != "" || a.Type == dao.ActivitySale {
+		payload = events.OrderPayload{
+			OrderID:     a.OrderID,
+			Collection:  a.Collection,
+			TokenID:     a.TokenID,
+			Maker:       a.From,
+			Taker:       a.To,
+			Price:       a.Price.String(),
+			Currency:    a.Currency,
+			Marketplace: a.Marketplace,
+		}
+	} else {
+		payload = events.NFTPayload{Collection: a.Collection, TokenID: a.TokenID, From: a.From, To: a.To}
+	}
+	e, err := events.New(typ, payload)
+	if err != nil {
+		return nil, err
+	}
+	// 与活动表唯一键一致，同一链上日志重复同步时 ID 不变
+	e.ID = events.EventID(typ, a.TxHash, strconv.Itoa(a.LogIndex), a.Collection, a.TokenID, a.OrderID)
+	e.BlockNumber = a.BlockNumber
+	e.TxHash = a.TxHash
+	e.LogIndex = a.LogIndex
+	e.Timestamp = a.BlockTime
+	return e, nil
+}
+
+// floorEvent 地板价变动历史转换为领域事件信封
+func floorEvent(h *dao.FloorPriceHistory) (*events.Envelope, error) {
+	e, err := events.New(events.TypeFloorChanged, events.FloorPayload{
+		Collection: h.Collection,
+		Price:      h.Price,
+		Currency:   h.Currency,
+		Trigger:    h.Trigger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	e.ID = events.EventID(events.TypeFloorChanged, h.Collection, strconv.FormatInt(h.ID, 10))
+	e.LogIndex = dao.ActivityOffchainLogIndex
+	e.Timestamp = h.Timestamp
+	return e, nil
+}
+
+// eventOutbox 信封写入发件箱，以合集地址为消息 key，chain_id 与编码由中继发布时确定
+func eventOutbox(e *events.Envelope, collection string) (dao.OutboxMessage, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return dao.OutboxMessage{}, err
+	}
+	headers, _ := json.Marshal(map[string]string{events.HeaderEventType: e.Type})
+	return dao.OutboxMessage{
+		Kind:    dao.OutboxKindEvent,
+		MsgKey:  collection,
+		Payload: string(data),
+		Headers: string(headers),
+	}, nil
+}
+
+// enqueueActivityEvent 在活动写入事务中追加领域事件
+func enqueueActivityEvent(tx *dao.Dao, a *dao.Activity) error {
+	e, err := activityEvent(a)
+	if err != nil || e == nil {
+		return err
+	}
+	msg, err := eventOutbox(e, a.Collection)
+	if err != nil {
+		return err
+	}
+	return tx.EnqueueOutbox(msg)
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/events"
+	"github.com/shopspring/decimal"
+)
+
+func TestSaleActivityEvents(t *testing.T) {
+	sale := &dao.Activity{
+		Type:        dao.ActivitySale,
+		Collection:  "0xCollection",
+		TokenID:     "0x01",
+		From:        "0xSeller",
+		To:          "0xBuyer",
+		Price:       decimal.NewFromInt(100),
+		Marketplace: "opensea",
+		TxHash:      "0xtx",
+		LogIndex:    5,
+	}
+	e, err := activityEvent(sale)
+	if err != nil || e == nil {
+		t.Fatalf("marketplace sale: event=%v err=%v", e, err)
+	}
+	if e.Type != events.TypeOrderFilled {
+		t.Fatalf("type = %s", e.Type)
+	}
+	var payload events.OrderPayload
+	if err := json.Unmarshal(e.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Maker != "0xSeller" || payload.Taker != "0xBuyer" || payload.Price != "100" {
+		t.Fatalf("payload = %+v", payload)
+	}
+
+	// 自有订单合约的成交由 order_filled 活动发布
+	native := *sale
+	native.Marketplace = dao.MarketplaceNative
+	if e, err := activityEvent(&native); err != nil || e != nil {
+		t.Fatalf("native sale: event=%v err=%v", e, err)
+	}
+}
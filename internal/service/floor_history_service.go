@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+const maxFloorHistoryPoints = 500
+
+// FloorPointDTO 地板价变动点
+type FloorPointDTO struct {
+	Price     string `json:"price"` // 空字符串表示此时合集已无挂单
+	Trigger   string `json:"trigger"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// CandleDTO 成交 K 线
+type CandleDTO struct {
+	Time   int64  `json:"time"`
+	Open   string `json:"open"`
+	High   string `json:"high"`
+	Low    string `json:"low"`
+	Close  string `json:"close"`
+	Volume string `json:"volume"`
+	Count  int64  `json:"count"`
+}
+
+// FloorHistoryDTO 地板价历史与成交 K 线
+type FloorHistoryDTO struct {
+	Interval string          `json:"interval"`
+	Currency string          `json:"currency"` // 基础币种
+	Floor    []FloorPointDTO `json:"floor"`
+	Candles  []CandleDTO     `json:"candles"`
+}
+
+// GetFloorHistory 查询地板价变动与 K 线，from/to 为空时返回最近 maxFloorHistoryPoints 个周期
+func (s *Service) GetFloorHistory(collection, interval string, from, to int64) (*FloorHistoryDTO, error) {
+	if err := ValidCandleInterval(interval); err != nil {
+		return nil, err
+	}
+	if to <= 0 {
+		to = time.Now().Unix()
+	}
+	if from <= 0 || from >= to {
+		from = to - CandleIntervals[interval]*maxFloorHistoryPoints
+	}
+	floors, err := s.Dao.ListFloorPriceHistory(collection, from, to, maxFloorHistoryPoints)
+	if err != nil {
+		return nil, err
+	}
+	candles, err := s.Dao.ListCandles(collection, interval, bucketStart(from, interval), to, maxFloorHistoryPoints)
+	if err != nil {
+		return nil, err
+	}
+	res := &FloorHistoryDTO{
+		Interval: interval,
+		Floor:    make([]FloorPointDTO, 0, len(floors)),
+		Candles:  make([]CandleDTO, 0, len(candles)),
+	}
+	if s.Currencies.PriceFeed != nil {
+		res.Currency = s.Currencies.PriceFeed.BaseCurrency()
+	}
+	for _, f := range floors {
+		res.Floor = append(res.Floor, FloorPointDTO{Price: f.Price, Trigger: f.Trigger, Timestamp: f.Timestamp})
+	}
+	for _, c := range candles {
+		res.Candles = append(res.Candles, CandleDTO{
+			Time:   c.BucketStart,
+			Open:   c.Open.String(),
+			High:   c.High.String(),
+			Low:    c.Low.String(),
+			Close:  c.Close.String(),
+			Volume: c.Volume.String(),
+			Count:  c.Count,
+		})
+	}
+	return res, nil
+}
+
+// BackfillCandles 从销售账本回填合集 K 线
+func (s *Service) BackfillCandles(ctx context.Context, collection string) (int, error) {
+	cs := &CandleService{Dao: s.Dao, Currencies: s.Currencies}
+	return cs.Backfill(ctx, collection)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"github.com/gavin/nftSync/internal/bus"
+	"log"
+	"time"
+)
+
+const (
+	minFloorFlushTick = 50 * time.Millisecond
+	floorRetryDelay   = 5 * time.Second // 重算失败后的重试间隔
+)
+
+// floorPending 合集待重算的合并状态
+type floorPending struct {
+	trigger   string         // 最近一次触发事件
+	msgs      []*bus.Message // 合并的消息，重算完成后确认
+	firstSeen time.Time
+	lastSeen  time.Time
+	retryAt   time.Time // 重算失败后的下次重试时间
+}
+
+// floorCoalescer 地板价消息合并：同一合集的连续更新只重算一次，
+// 静默 debounce 后或首条消息等待超过 maxDelay 时到期
+type floorCoalescer struct {
+	debounce time.Duration
+	maxDelay time.Duration
+	pending  map[string]*floorPending
+}
+
+func newFloorCoalescer(debounce, maxDelay time.Duration) *floorCoalescer {
+	return &floorCoalescer{
+		debounce: debounce,
+		maxDelay: maxDelay,
+		pending:  map[string]*floorPending{},
+	}
+}
+
+func (c *floorCoalescer) add(collection string, msg *bus.Message, now time.Time) {
+	p := c.pending[collection]
+	if p == nil {
+		p = &floorPending{firstSeen: now}
+		c.pending[collection] = p
+	}
+	p.trigger = floorTrigger(msg)
+	p.msgs = append(p.msgs, msg)
+	p.lastSeen = now
+}
+
+// retry 重算失败的合集放回待处理，消息保持未确认，retryAt 之前不会到期
+func (c *floorCoalescer) retry(collection string, p *floorPending, retryAt time.Time) {
+	p.retryAt = retryAt
+	c.pending[collection] = p
+}
+
+// due 取出到期的合集，all 为 true 时取出全部
+func (c *floorCoalescer) due(now time.Time, all bool) map[string]*floorPending {
+	out := map[string]*floorPending{}
+	for collection, p := range c.pending {
+		if !all && now.Before(p.retryAt) {
+			continue
+		}
+		if all || now.Sub(p.lastSeen) >= c.debounce || now.Sub(p.firstSeen) >= c.maxDelay {
+			out[collection] = p
+			delete(c.pending, collection)
+		}
+	}
+	return out
+}
+
+// handleMessages 处理一个有序单元的地板价消息；消息以合集为 key，同一合集只出现在一个单元，各单元可并行重算。
+// 重算成功后才确认，确认进度由总线后端转换为位点提交，重启后未处理的消息会重新消费；
+// 重算失败的合集保持未确认并在 floorRetryDelay 后重试，其后的位点也不会提交
+func (fps *FloorPriceService) handleMessages(ctx context.Context, msgs <-chan *bus.Message) {
+	c := newFloorCoalescer(fps.Debounce, fps.MaxDelay)
+	update := fps.UpdateFloorPrice
+	if fps.update != nil {
+		update = fps.update
+	}
+	tick := fps.Debounce / 2
+	if tick < minFloorFlushTick {
+		tick = minFloorFlushTick
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	flush := func(now time.Time, all bool) {
+		for collection, p := range c.due(now, all) {
+			if len(p.msgs) > 1 {
+				log.Printf("[floor_price] 合并地板价更新消息: %s, count=%d, trigger=%s", collection, len(p.msgs), p.trigger)
+			}
+			if err := update(collection, p.trigger); err != nil {
+				log.Printf("[floor_price] 地板价重算失败，稍后重试: %s, err=%v", collection, err)
+				c.retry(collection, p, now.Add(floorRetryDelay))
+				continue
+			}
+			for _, msg := range p.msgs {
+				msg.Ack()
+			}
+		}
+	}
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				// 单元被回收（再均衡或退出），处理完已收到的消息再返回
+				flush(time.Now(), true)
+				return
+			}
+			c.add(string(msg.Value), msg, time.Now())
+		case now := <-ticker.C:
+			flush(now, false)
+		case <-ctx.Done():
+			flush(time.Now(), true)
+			return
+		}
+	}
+}
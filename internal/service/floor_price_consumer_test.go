@@ -0,0 +1,35 @@
+package service
+
+import (
+	"github.com/gavin/nftSync/internal/bus"
+	"testing"
+	"time"
+)
+
+func TestFloorCoalescerRetryKeepsMessagesPending(t *testing.T) {
+	c := newFloorCoalescer(100*time.Millisecond, time.Second)
+	now := time.Unix(1000, 0)
+	c.add("0xaaa", &bus.Message{Value: []byte("0xaaa")}, now)
+	c.add("0xaaa", &bus.Message{Value: []byte("0xaaa")}, now.Add(50*time.Millisecond))
+
+	if due := c.due(now.Add(100*time.Millisecond), false); len(due) != 0 {
+		t.Fatalf("due before debounce: %v", due)
+	}
+	now = now.Add(200 * time.Millisecond)
+	due := c.due(now, false)
+	p := due["0xaaa"]
+	if p == nil || len(p.msgs) != 2 {
+		t.Fatalf("due = %v", due)
+	}
+
+	// 重算失败：放回后到重试时间前不再到期，新消息继续合并
+	c.retry("0xaaa", p, now.Add(floorRetryDelay))
+	c.add("0xaaa", &bus.Message{Value: []byte("0xaaa")}, now.Add(time.Second))
+	if due := c.due(now.Add(2*time.Second), false); len(due) != 0 {
+		t.Fatalf("due before retry: %v", due)
+	}
+	due = c.due(now.Add(floorRetryDelay), false)
+	if p := due["0xaaa"]; p == nil || len(p.msgs) != 3 {
+		t.Fatalf("due after retry = %v", due)
+	}
+}
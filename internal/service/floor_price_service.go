@@ -2,63 +2,169 @@ package service
 
 import (
 	"context"
-	"github.com/IBM/sarama"
+	"errors"
+	"fmt"
+	"github.com/gavin/nftSync/internal/bus"
 	"github.com/gavin/nftSync/internal/config"
 	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/middleware"
+	"github.com/gavin/nftSync/internal/push"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 	"log"
+	"time"
 )
 
 // FloorPriceService 负责消费地板价更新消息并更新地板价
 type FloorPriceService struct {
-	Dao                *dao.Dao
-	FloorPriceConsumer sarama.PartitionConsumer
+	Dao        *dao.Dao
+	Subscriber bus.Subscriber
+	Topic      string
+	Debounce   time.Duration // 同一合集静默多久后重算
+	MaxDelay   time.Duration // 持续有更新时的最长延迟
+	Currencies *CurrencyResolver
+	OrderBook  *OrderBookIndex
+	Push       *push.Broker // 未启用推送时为 nil
+	Events     bool         // 是否发布领域事件
+
+	update func(collection, trigger string) error // 替换重算逻辑，测试使用
 }
 
 func NewFloorPriceService(bizCtx *config.Context) *FloorPriceService {
+	cfg := bizCtx.Config.FloorPriceKafka
+	if cfg.Debounce <= 0 {
+		cfg.Debounce = 500
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 5000
+	}
 	return &FloorPriceService{
-		Dao:                dao.New(bizCtx.Db),
-		FloorPriceConsumer: bizCtx.FloorPriceConsumer,
+		Dao:        dao.New(bizCtx.Db),
+		Subscriber: bizCtx.Subscriber,
+		Topic:      cfg.Topic,
+		Debounce:   time.Duration(cfg.Debounce) * time.Millisecond,
+		MaxDelay:   time.Duration(cfg.MaxDelay) * time.Millisecond,
+		Currencies: NewCurrencyResolver(bizCtx),
+		OrderBook:  NewOrderBookIndex(bizCtx),
+		Push:       NewPushBroker(bizCtx),
+		Events:     bizCtx.Config.Events.Enabled,
 	}
 }
 
-func (fps *FloorPriceService) StartKafkaConsumer(ctx context.Context) {
-	for {
-		select {
-		case msg := <-fps.FloorPriceConsumer.Messages():
-			collection := string(msg.Value)
-			log.Printf("[floor_price] 收到地板价更新消息: %s", collection)
-			fps.UpdateFloorPrice(collection)
-		case err := <-fps.FloorPriceConsumer.Errors():
-			log.Printf("[floor_price] Kafka消费错误: %v", err)
-		case <-ctx.Done():
-			fps.FloorPriceConsumer.Close()
-			return
-		}
+// StartConsumer 消费地板价消息直到 ctx 结束，Kafka 后端下覆盖全部分区并随再均衡调整
+func (fps *FloorPriceService) StartConsumer(ctx context.Context) {
+	if err := fps.Subscriber.Subscribe(ctx, fps.Topic, fps.handleMessages); err != nil {
+		log.Printf("[floor_price] 地板价消息消费退出: %v", err)
 	}
 }
 
-// UpdateFloorPrice 计算并更新地板价
-func (fps *FloorPriceService) UpdateFloorPrice(collection string) {
-	// 查询该合集所有挂单，取最低价
-	orders, err := fps.Dao.ListOrdersByCollection(collection)
+// floorTrigger 从消息头读取触发事件，旧消息无消息头
+func floorTrigger(msg *bus.Message) string {
+	if trigger := msg.Headers[middleware.FloorTriggerHeader]; trigger != "" {
+		return trigger
+	}
+	return "unknown"
+}
+
+// UpdateFloorPrice 计算并更新地板价，地板价变化时记录变动历史；查询或写入失败时返回错误，由消费者重试
+// 优先读取 Redis 订单簿索引，索引不可用时回退为 MySQL 全量扫描。
+// 最后一个挂单取消、成交或过期后清除地板价，变动历史、领域事件与推送中价格为空字符串
+func (fps *FloorPriceService) UpdateFloorPrice(collection string, trigger string) error {
+	ctx := context.Background()
+	minPrice, found, err := fps.OrderBook.Floor(ctx, collection)
 	if err != nil {
-		log.Printf("[floor_price] 查询订单失败: %v", err)
-		return
+		log.Printf("[floor_price] 订单簿索引查询失败，回退MySQL: %v", err)
+		minPrice, found, err = fps.scanFloor(ctx, collection)
+		if err != nil {
+			return fmt.Errorf("查询订单失败: %w", err)
+		}
+	}
+	// 与当前地板价比较，未变化时不写入
+	prev, err := fps.Dao.GetFloorPrice(collection)
+	hasPrev := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("查询当前地板价失败: %w", err)
+	}
+	if !found {
+		if !hasPrev {
+			return nil
+		}
+		log.Printf("[floor_price] 合集无挂单，清除地板价: %s", collection)
+	} else if prevPrice, perr := decimal.NewFromString(prev); hasPrev && perr == nil && prevPrice.Equal(minPrice) {
+		return nil
+	}
+	price := ""
+	if found {
+		price = minPrice.String()
 	}
-	var minPrice string
-	for i, order := range orders {
-		if i == 0 || order.Price.LessThan(orders[i-1].Price) {
-			minPrice = order.Price.String()
+	baseCurrency := fps.Currencies.PriceFeed.BaseCurrency()
+	history := dao.FloorPriceHistory{
+		Collection: collection,
+		Price:      price,
+		Currency:   baseCurrency,
+		Trigger:    trigger,
+		Timestamp:  time.Now().Unix(),
+	}
+	// 地板价、变动历史与领域事件同事务写入，失败时整体重试
+	err = fps.Dao.Transaction(func(tx *dao.Dao) error {
+		var err error
+		if found {
+			err = tx.UpdateFloorPrice(collection, price, baseCurrency)
+		} else {
+			err = tx.ClearFloorPrice(collection)
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.CreateFloorPriceHistory(&history); err != nil || !fps.Events {
+			return err
+		}
+		e, err := floorEvent(&history)
+		if err != nil {
+			return err
 		}
+		msg, err := eventOutbox(e, collection)
+		if err != nil {
+			return err
+		}
+		return tx.EnqueueOutbox(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("地板价更新失败: %w", err)
 	}
-	if minPrice == "" {
-		log.Printf("[floor_price] 合集无挂单: %s", collection)
-		return
+	log.Printf("[floor_price] 地板价已更新: %s -> %s", collection, history.Price)
+	publishFloorChange(fps.Push, FloorChangeDTO{
+		Collection: collection,
+		Price:      history.Price,
+		Currency:   baseCurrency,
+		Trigger:    trigger,
+		Timestamp:  history.Timestamp,
+	})
+	return nil
+}
+
+// scanFloor 全量扫描合集挂单计算地板价
+// 挂单可能使用不同币种计价，统一按价格源折算为基础币种后取最低价
+func (fps *FloorPriceService) scanFloor(ctx context.Context, collection string) (decimal.Decimal, bool, error) {
+	orders, err := fps.Dao.ListOrdersByCollection(collection)
+	if err != nil {
+		return decimal.Zero, false, err
 	}
-	// 更新地板价
-	if err := fps.Dao.UpdateFloorPrice(collection, minPrice); err != nil {
-		log.Printf("[floor_price] 地板价更新失败: %v", err)
-	} else {
-		log.Printf("[floor_price] 地板价已更新: %s -> %s", collection, minPrice)
+	var minPrice decimal.Decimal
+	found := false
+	for _, order := range orders {
+		if order.OrderType != "" && order.OrderType != dao.OrderTypeListing {
+			continue
+		}
+		price, err := fps.Currencies.ToBase(ctx, order.Currency, order.Price, order.PriceScaled)
+		if err != nil {
+			log.Printf("[floor_price] 挂单价格折算失败: orderId=%s, err=%v", order.OrderID, err)
+			continue
+		}
+		if !found || price.LessThan(minPrice) {
+			minPrice = price
+			found = true
+		}
 	}
+	return minPrice, found, nil
 }
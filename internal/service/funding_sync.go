@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gavin/nftSync/internal/blockchain"
+	"github.com/gavin/nftSync/internal/blockchain/marketplace"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/shopspring/decimal"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	defaultFundingMaxBlocks = 100
+	fundingAddressBatch     = 200 // 单次日志查询的接收地址数
+)
+
+// FundingIndexer 索引转入近期买卖双方及其一层资金来源的独立 ETH/WETH 转账，供刷量检测追溯资金来源。
+// ETH 只记录无 calldata 的普通转账；WETH 转账所在交易包含 NFT 转移时视为成交结算，不作为资金来源。
+// 从启动时的安全区块开始向前索引，启动前的历史转账不回补
+type FundingIndexer struct {
+	MultiNode     *config.MultiNodeEthClient
+	Dao           *dao.Dao
+	WETH          string
+	Lookback      int64 // 关注地址取最近多久内成交的买卖双方（秒）
+	MaxBlocks     int64 // 每轮最多处理的区块数
+	ConfirmBlocks int64
+
+	lastBlock *big.Int
+}
+
+func NewFundingIndexer(ctx *config.Context) *FundingIndexer {
+	cfg := ctx.Config.WashTrading
+	lookback := cfg.Lookback
+	if lookback <= 0 {
+		lookback = defaultWashLookback
+	}
+	maxBlocks := cfg.FundingMaxBlocks
+	if maxBlocks <= 0 {
+		maxBlocks = defaultFundingMaxBlocks
+	}
+	return &FundingIndexer{
+		MultiNode:     ctx.MultiNode,
+		Dao:           dao.New(ctx.Db),
+		WETH:          ctx.Config.WETHAddress,
+		Lookback:      lookback,
+		MaxBlocks:     maxBlocks,
+		ConfirmBlocks: int64(ctx.Config.Sync.ConfirmBlocks),
+	}
+}
+
+// SyncOnce 处理下一段已确认区块，失败时不推进进度，下一轮重试（重复写入自动跳过）
+func (f *FundingIndexer) SyncOnce(ctx context.Context) {
+	latest := getLatestBlock(f.MultiNode, ctx)
+	if latest == nil {
+		log.Printf("[funding] 无法获取最新区块")
+		return
+	}
+	safe := new(big.Int).Sub(latest, big.NewInt(f.ConfirmBlocks))
+	if f.lastBlock == nil {
+		f.lastBlock = new(big.Int).Sub(safe, big.NewInt(1))
+	}
+	start := new(big.Int).Add(f.lastBlock, big.NewInt(1))
+	if safe.Cmp(start) < 0 {
+		return
+	}
+	end := new(big.Int).Add(start, big.NewInt(f.MaxBlocks-1))
+	if end.Cmp(safe) > 0 {
+		end = safe
+	}
+	watched, err := f.watchedAddresses()
+	if err != nil {
+		log.Printf("[funding] 关注地址查询失败: %v", err)
+		return
+	}
+	if len(watched) > 0 {
+		if err := f.indexRange(ctx, start, end, watched); err != nil {
+			log.Printf("[funding] 资金转账索引失败: blocks=%v-%v, err=%v", start, end, err)
+			return
+		}
+	}
+	f.lastBlock.Set(end)
+}
+
+// watchedAddresses 近期买卖双方及其已索引的直接资金来源
+func (f *FundingIndexer) watchedAddresses() (map[common.Address]struct{}, error) {
+	traders, err := f.Dao.ListTraderAddresses(time.Now().Unix() - f.Lookback)
+	if err != nil {
+		return nil, err
+	}
+	watched := map[common.Address]struct{}{}
+	for _, addr := range traders {
+		if common.IsHexAddress(addr) && !strings.EqualFold(addr, marketplace.ZeroAddress) {
+			watched[common.HexToAddress(addr)] = struct{}{}
+		}
+	}
+	if len(traders) == 0 {
+		return watched, nil
+	}
+	funders, err := f.Dao.ListFunders(traders)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range funders {
+		if common.IsHexAddress(e.From) {
+			watched[common.HexToAddress(e.From)] = struct{}{}
+		}
+	}
+	return watched, nil
+}
+
+// indexRange 逐块扫描普通 ETH 转账，并按接收地址拉取 WETH 转账
+func (f *FundingIndexer) indexRange(ctx context.Context, start, end *big.Int, watched map[common.Address]struct{}) error {
+	var list []dao.FundTransfer
+	blockTimes := map[uint64]int64{}
+	for n := start.Uint64(); n <= end.Uint64(); n++ {
+		var block *types.Block
+		if err := callNodes(f.MultiNode, func(cli *blockchain.EthClient) error {
+			var err error
+			block, err = cli.GetBlockByNumber(ctx, n)
+			return err
+		}); err != nil {
+			return err
+		}
+		blockTimes[n] = int64(block.Time())
+		for _, tx := range block.Transactions() {
+			if tx.To() == nil || tx.Value().Sign() <= 0 || len(tx.Data()) > 0 {
+				continue
+			}
+			if _, ok := watched[*tx.To()]; !ok {
+				continue
+			}
+			sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+			if err != nil || sender == *tx.To() {
+				continue
+			}
+			list = append(list, dao.FundTransfer{
+				From:        sender.Hex(),
+				To:          tx.To().Hex(),
+				Token:       marketplace.ZeroAddress,
+				Value:       decimal.NewFromBigInt(tx.Value(), 0),
+				TxHash:      tx.Hash().Hex(),
+				LogIndex:    dao.FundTransferNativeLogIndex,
+				BlockNumber: n,
+				BlockTime:   int64(block.Time()),
+			})
+		}
+	}
+	weth, err := f.wethTransfers(ctx, start, end, watched, blockTimes)
+	if err != nil {
+		return err
+	}
+	list = append(list, weth...)
+	if len(list) > 0 {
+		log.Printf("[funding] 资金转账已索引: blocks=%v-%v, count=%d", start, end, len(list))
+	}
+	return f.Dao.CreateFundTransfersIgnoreConflict(list)
+}
+
+// wethTransfers 拉取转入关注地址的 WETH 转账，排除包含 NFT 转移的成交结算交易
+func (f *FundingIndexer) wethTransfers(ctx context.Context, start, end *big.Int, watched map[common.Address]struct{}, blockTimes map[uint64]int64) ([]dao.FundTransfer, error) {
+	if f.WETH == "" {
+		return nil, nil
+	}
+	recipients := make([]common.Address, 0, len(watched))
+	for addr := range watched {
+		recipients = append(recipients, addr)
+	}
+	var logs []types.Log
+	for i := 0; i < len(recipients); i += fundingAddressBatch {
+		batch := recipients[i:min(i+fundingAddressBatch, len(recipients))]
+		var part []types.Log
+		if err := callNodes(f.MultiNode, func(cli *blockchain.EthClient) error {
+			var err error
+			part, err = cli.FetchERC20TransfersTo(ctx, f.WETH, batch, start, end)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		logs = append(logs, part...)
+	}
+
+	settlement := map[common.Hash]bool{} // 交易是否为成交结算，同一交易只查一次回执
+	var list []dao.FundTransfer
+	for i := range logs {
+		vLog := &logs[i]
+		if vLog.Removed {
+			continue
+		}
+		isSettlement, ok := settlement[vLog.TxHash]
+		if !ok {
+			var receipt *types.Receipt
+			if err := callNodes(f.MultiNode, func(cli *blockchain.EthClient) error {
+				var err error
+				receipt, err = cli.GetTransactionReceipt(ctx, vLog.TxHash.Hex())
+				return err
+			}); err != nil {
+				return nil, err
+			}
+			isSettlement = blockchain.HasNFTTransfer(receipt.Logs)
+			settlement[vLog.TxHash] = isSettlement
+		}
+		if isSettlement {
+			continue
+		}
+		transfers := blockchain.ParseERC20Transfers([]*types.Log{vLog})
+		if len(transfers) == 0 || strings.EqualFold(transfers[0].From, transfers[0].To) {
+			continue
+		}
+		list = append(list, dao.FundTransfer{
+			From:        transfers[0].From,
+			To:          transfers[0].To,
+			Token:       transfers[0].Token,
+			Value:       decimal.NewFromBigInt(transfers[0].Value, 0),
+			TxHash:      vLog.TxHash.Hex(),
+			LogIndex:    int(vLog.Index),
+			BlockNumber: vLog.BlockNumber,
+			BlockTime:   blockTimes[vLog.BlockNumber],
+		})
+	}
+	return list, nil
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gavin/nftSync/internal/blockchain"
+	"github.com/gavin/nftSync/internal/blockchain/marketplace"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/shopspring/decimal"
+	"log"
+	"math/big"
+)
+
+// SyncMarketplaceSalesPolling 轮询公开市场（Seaport/Blur/LooksRare）成交事件，解码为标准化成交记录
+func (s *MultiNodeSyncService) SyncMarketplaceSalesPolling(ctx context.Context, bizCtx *config.Context) {
+	if len(s.Decoders) == 0 {
+		return
+	}
+	ethClient := blockchain.NewEthClient(s.MultiNode.Clients[0])
+	latestBlock, err := ethClient.GetBlockNumber(ctx)
+	if err != nil {
+		log.Printf("[marketplace] 主节点获取最新区块失败: %v", err)
+		return
+	}
+	safeBlock := new(big.Int).Sub(latestBlock, big.NewInt(int64(bizCtx.Config.Sync.ConfirmBlocks)))
+	if s.lastSaleBlock.Sign() == 0 {
+		// 首次同步：从配置的起始区块开始，未配置时只同步此后的新成交
+		if start := bizCtx.Config.Sync.StartBlock; start > 0 {
+			s.lastSaleBlock.SetInt64(start - 1)
+		} else {
+			s.lastSaleBlock.Sub(safeBlock, big.NewInt(1))
+		}
+	}
+	startBlock := new(big.Int).Add(s.lastSaleBlock, big.NewInt(1))
+	if safeBlock.Cmp(startBlock) < 0 {
+		log.Println("[marketplace] 无新区块达到安全确认高度，无需轮询")
+		return
+	}
+	maxRange := bizCtx.Config.Sync.MaxBlockRange
+	if maxRange <= 0 {
+		maxRange = 2000
+	}
+	// 按区块跨度分段拉取，每段全部合约成功后才推进游标；失败的区段下一轮重新扫描，重复成交由唯一索引去重
+	for startBlock.Cmp(safeBlock) <= 0 {
+		endBlock := new(big.Int).Add(startBlock, big.NewInt(maxRange-1))
+		if endBlock.Cmp(safeBlock) > 0 {
+			endBlock.Set(safeBlock)
+		}
+		if !s.syncMarketplaceRange(ctx, startBlock, endBlock) {
+			log.Printf("[marketplace] 区块 %v-%v 同步未完成，游标停留在 %v", startBlock, endBlock, s.lastSaleBlock)
+			return
+		}
+		s.lastSaleBlock.Set(endBlock)
+		startBlock = new(big.Int).Add(endBlock, big.NewInt(1))
+	}
+	log.Printf("[marketplace] 市场成交同步完成，已安全同步到区块 %v", safeBlock)
+}
+
+// syncMarketplaceRange 同步区块区间内所有市场合约的成交，任一合约日志拉取失败时返回 false
+func (s *MultiNodeSyncService) syncMarketplaceRange(ctx context.Context, startBlock, endBlock *big.Int) bool {
+	ok := true
+	for _, decoder := range s.Decoders {
+		for _, contract := range decoder.Contracts() {
+			logs, err := s.fetchLogsWithFallback(ctx, contract.Hex(), startBlock, endBlock, decoder)
+			if err != nil {
+				log.Printf("[marketplace] 所有节点成交事件拉取失败: market=%s, contract=%s, err=%v", decoder.Name(), contract.Hex(), err)
+				ok = false
+				continue
+			}
+			sales, err := decoder.Decode(logs)
+			if err != nil {
+				log.Printf("[marketplace] 部分成交事件解码失败: market=%s, err=%v", decoder.Name(), err)
+			}
+			for i := range sales {
+				s.handleSale(ctx, &sales[i])
+			}
+		}
+	}
+	return ok
+}
+
+// fetchLogsWithFallback 主节点优先拉取日志，失败时依次尝试其他节点
+func (s *MultiNodeSyncService) fetchLogsWithFallback(ctx context.Context, contract string, startBlock, endBlock *big.Int, decoder marketplace.Decoder) ([]types.Log, error) {
+	var logs []types.Log
+	var err error
+	for _, cli := range s.MultiNode.Clients {
+		logs, err = blockchain.NewEthClient(cli).FetchOrderEvents(ctx, contract, startBlock, endBlock, decoder.Topics())
+		if err == nil {
+			return logs, nil
+		}
+	}
+	return nil, err
+}
+
+// handleSale 标准化成交记录写入销售账本
+func (s *MultiNodeSyncService) handleSale(ctx context.Context, sale *marketplace.Sale) {
+	blockTime := int64(0)
+	block, err := blockchain.NewEthClient(s.MultiNode.Clients[0]).GetBlockByNumber(ctx, sale.BlockNumber)
+	if err == nil {
+		blockTime = int64(block.Time())
+	}
+	// 解码器无法从事件确定的一方（如 Seaport matchOrders 的 recipient 为零地址）按交易发送方补全：
+	// 购买挂单时发送方为买家，接受出价时发送方为卖家
+	if sale.Buyer == marketplace.ZeroAddress || sale.Seller == marketplace.ZeroAddress {
+		var sender common.Address
+		err := callNodes(s.MultiNode, func(cli *blockchain.EthClient) error {
+			var err error
+			_, sender, err = cli.GetTransaction(ctx, sale.TxHash)
+			return err
+		})
+		if err != nil {
+			log.Printf("[marketplace] 查询成交交易发送方失败，跳过: tx=%s, err=%v", sale.TxHash, err)
+			return
+		}
+		if sale.Buyer == marketplace.ZeroAddress {
+			sale.Buyer = sender.Hex()
+		} else {
+			sale.Seller = sender.Hex()
+		}
+	}
+	trade := dao.Trade{
+		OrderID:     sale.OrderHash,
+		Marketplace: sale.Marketplace,
+		Collection:  sale.Collection,
+		TokenID:     sale.TokenID,
+		Seller:      sale.Seller,
+		Buyer:       sale.Buyer,
+		Price:       decimal.NewFromBigInt(sale.Price, 0),
+		Currency:    sale.Currency,
+		Fee:         decimal.NewFromBigInt(sale.Fee, 0),
+		Royalty:     decimal.Zero, // 由版税核对按收款地址填写
+		TxHash:      sale.TxHash,
+		LogIndex:    sale.LogIndex,
+		BlockNumber: sale.BlockNumber,
+		BlockTime:   blockTime,
+		Source:      dao.TradeSourceMarketplace,
+	}
+	trade.PriceScaled = s.scalePrice(ctx, trade.Currency, trade.Price)
+	if err := s.saveTrade(ctx, &trade, true, sale.Payouts); err != nil {
+		log.Printf("[marketplace] 成交记录写入失败: %v", err)
+		return
+	}
+	log.Printf("[marketplace] 成交已同步: market=%s, collection=%s, tokenId=%s, price=%s, tx=%s",
+		sale.Marketplace, sale.Collection, sale.TokenID, sale.Price, sale.TxHash)
+}
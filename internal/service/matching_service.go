@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"github.com/gavin/nftSync/internal/blockchain/marketplace"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/matching"
+	"log"
+)
+
+// MatchingService 定时撮合各合集的交叉订单，撮合结果经发件箱发布给结算组件
+type MatchingService struct {
+	Dao       *dao.Dao
+	Engine    *matching.Engine
+	OrderBook *OrderBookIndex
+}
+
+func NewMatchingService(ctx *config.Context) *MatchingService {
+	bizDao := dao.New(ctx.Db)
+	cfg := ctx.Config.Matching
+	engine := matching.NewEngine(
+		&matching.DaoStore{Dao: bizDao},
+		&royaltyFeeCalculator{
+			Base:      matching.NewBpsFeeCalculator(cfg.ProtocolFeeBps, cfg.DefaultRoyaltyBps, cfg.RoyaltyBps),
+			Royalties: NewRoyaltyResolver(ctx),
+		},
+	)
+	// ETH 挂单可与 WETH 出价成交
+	if ctx.Config.WETHAddress != "" {
+		engine.SetEquivalent("ETH", marketplace.ZeroAddress, ctx.Config.WETHAddress)
+	}
+	return &MatchingService{
+		Dao:       bizDao,
+		Engine:    engine,
+		OrderBook: NewOrderBookIndex(ctx),
+	}
+}
+
+// MatchAll 撮合所有存在有效订单的合集
+func (ms *MatchingService) MatchAll(ctx context.Context) {
+	collections, err := ms.Dao.ListCollectionsWithActiveOrders()
+	if err != nil {
+		log.Printf("[matching] 查询合集失败: %v", err)
+		return
+	}
+	total := 0
+	for _, collection := range collections {
+		matches, err := ms.Engine.MatchCollection(ctx, collection)
+		if err != nil {
+			log.Printf("[matching] 合集撮合失败: collection=%s, err=%v", collection, err)
+		}
+		for i := range matches {
+			ms.afterMatch(ctx, &matches[i])
+		}
+		total += len(matches)
+	}
+	if total > 0 {
+		log.Printf("[matching] 本轮撮合完成: matches=%d", total)
+	}
+}
+
+// afterMatch 已撮合订单移出订单簿索引
+func (ms *MatchingService) afterMatch(ctx context.Context, m *matching.Match) {
+	log.Printf("[matching] 订单已撮合: listing=%s, bid=%s, price=%s", m.ListingOrderID, m.BidOrderID, m.Price)
+	for _, order := range []*dao.Order{&m.Listing, &m.Bid} {
+		if err := ms.OrderBook.Remove(ctx, order); err != nil {
+			log.Printf("[orderbook] 订单移出索引失败: orderId=%s, err=%v", order.OrderID, err)
+		}
+	}
+}
@@ -2,31 +2,156 @@ package service
 
 import (
 	"fmt"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/gavin/nftSync/internal/blockchain"
+	"github.com/gavin/nftSync/internal/blockchain/marketplace"
 	"github.com/gavin/nftSync/internal/config"
 	"github.com/gavin/nftSync/internal/dao"
-	"github.com/gavin/nftSync/internal/middleware"
+	"github.com/gavin/nftSync/internal/push"
+	"github.com/shopspring/decimal"
 	"golang.org/x/net/context"
+	"log"
 	"math/big"
 	"sync"
 )
 
 type MultiNodeSyncService struct {
-	MultiNode          *config.MultiNodeEthClient
-	lastSyncedBlock    *big.Int
-	Dao                *dao.Dao
-	FloorPriceProducer *middleware.KafkaProducer
+	MultiNode       *config.MultiNodeEthClient
+	lastSyncedBlock *big.Int
+	Dao             *dao.Dao
+	Decoders        []marketplace.Decoder // 公开市场事件解码器
+	lastSaleBlock   *big.Int
+	Currencies      *CurrencyResolver
+	Candles         *CandleService
+	OrderBook       *OrderBookIndex
+	Royalties       *RoyaltyResolver
+	Push            *push.Broker // 未启用推送时为 nil
+	Events          bool         // 是否发布领域事件
 }
 
 func NewMultiNodeSyncService(ctx *config.Context) *MultiNodeSyncService {
 	return &MultiNodeSyncService{
-		MultiNode:          ctx.MultiNode,
-		lastSyncedBlock:    big.NewInt(0),
-		Dao:                dao.New(ctx.Db),
-		FloorPriceProducer: ctx.FloorPriceProducer,
+		MultiNode:       ctx.MultiNode,
+		lastSyncedBlock: big.NewInt(0),
+		Dao:             dao.New(ctx.Db),
+		Decoders:        newMarketplaceDecoders(ctx.Config.Marketplaces),
+		lastSaleBlock:   big.NewInt(0),
+		Currencies:      NewCurrencyResolver(ctx),
+		Candles:         NewCandleService(ctx),
+		OrderBook:       NewOrderBookIndex(ctx),
+		Royalties:       NewRoyaltyResolver(ctx),
+		Push:            NewPushBroker(ctx),
+		Events:          ctx.Config.Events.Enabled,
 	}
 }
 
+// scalePrice 原始价格按币种缩放，失败时记录日志并返回0，不阻断同步
+func (m *MultiNodeSyncService) scalePrice(ctx context.Context, currency string, raw decimal.Decimal) decimal.Decimal {
+	scaled, err := m.Currencies.Scale(ctx, currency, raw)
+	if err != nil {
+		log.Printf("[currency] 价格缩放失败: currency=%s, err=%v", currency, err)
+		return decimal.Zero
+	}
+	return scaled
+}
+
+// saveTrade 核对版税后写入成交记录并更新 K 线；fromEvent 为 true 时覆盖同交易的推导成交
+// payouts 为市场成交中支付给第三方的款项，用于核对实付版税，其他来源传 nil
+func (m *MultiNodeSyncService) saveTrade(ctx context.Context, trade *dao.Trade, fromEvent bool, payouts map[common.Address]*big.Int) error {
+	m.Royalties.Apply(ctx, trade, payouts)
+	// 按当前汇率固化基础币种价格，失败时留空由统计汇总补写
+	if base, err := m.Currencies.ToBase(ctx, trade.Currency, trade.Price, trade.PriceScaled); err == nil {
+		trade.PriceBase = decimal.NewNullDecimal(base)
+	} else {
+		log.Printf("[currency] 成交价格折算失败: tx=%s, err=%v", trade.TxHash, err)
+	}
+	activity := &dao.Activity{
+		Type:        dao.ActivitySale,
+		Collection:  trade.Collection,
+		TokenID:     trade.TokenID,
+		From:        trade.Seller,
+		To:          trade.Buyer,
+		OrderID:     trade.OrderID,
+		Price:       trade.Price,
+		Currency:    trade.Currency,
+		Marketplace: trade.Marketplace,
+		TxHash:      trade.TxHash,
+		LogIndex:    int(trade.LogIndex),
+		BlockNumber: trade.BlockNumber,
+		BlockTime:   trade.BlockTime,
+	}
+	// 成交与成交活动同事务写入：重复处理的成交不再写活动，避免重复推送与事件；
+	// 事件成交替换推导成交时，在同一事务内删除推导成交的活动并写入新活动
+	var inserted, replaced, created bool
+	err := m.Dao.Transaction(func(tx *dao.Dao) error {
+		var err error
+		if fromEvent {
+			inserted, replaced, err = tx.SaveEventTrade(trade)
+		} else {
+			inserted, err = tx.CreateTradeIgnoreConflict(trade)
+		}
+		if err != nil || (!inserted && !replaced) {
+			return err
+		}
+		if replaced {
+			if err := tx.DeleteSaleActivities(trade.TxHash, trade.Collection, trade.TokenID); err != nil {
+				return err
+			}
+		}
+		created, err = writeActivity(tx, m.Events, activity)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if created {
+		publishActivity(m.Push, activity)
+	}
+	// 重复处理的成交不再计入 K 线；替换推导成交时其价格已计入，需按账本重算所在周期
+	if replaced {
+		err = m.Candles.Rebuild(ctx, trade.Collection, trade.BlockTime)
+	} else if inserted {
+		err = m.Candles.OnTrade(trade)
+	}
+	if err != nil {
+		log.Printf("[candle] K线更新失败: collection=%s, err=%v", trade.Collection, err)
+	}
+	return nil
+}
+
+// indexOrder 订单写入订单簿索引，失败时由漂移校验兜底
+func (m *MultiNodeSyncService) indexOrder(ctx context.Context, order *dao.Order) {
+	if err := m.OrderBook.Add(ctx, order); err != nil {
+		log.Printf("[orderbook] 订单写入索引失败: orderId=%s, err=%v", order.OrderID, err)
+	}
+}
+
+// unindexOrder 订单移出订单簿索引
+func (m *MultiNodeSyncService) unindexOrder(ctx context.Context, order *dao.Order) {
+	if err := m.OrderBook.Remove(ctx, order); err != nil {
+		log.Printf("[orderbook] 订单移出索引失败: orderId=%s, err=%v", order.OrderID, err)
+	}
+}
+
+// newMarketplaceDecoders 按配置创建市场解码器，配置错误的市场跳过
+func newMarketplaceDecoders(markets []config.MarketplaceConfig) []marketplace.Decoder {
+	decoders := []marketplace.Decoder{}
+	for _, m := range markets {
+		decoder, err := marketplace.NewDecoder(m.Protocol, marketplace.Options{
+			Name:          m.Name,
+			Contracts:     m.Contracts,
+			FeeRecipients: m.FeeRecipients,
+			FeeBps:        m.FeeBps,
+		})
+		if err != nil {
+			log.Printf("[marketplace] 市场解码器创建失败: name=%s, err=%v", m.Name, err)
+			continue
+		}
+		decoders = append(decoders, decoder)
+	}
+	return decoders
+}
+
 // MultiNodeTransferEvent 采集结果结构体
 type MultiNodeTransferEvent struct {
 	Event       blockchain.TransferEvent
@@ -3,7 +3,10 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/gavin/nftSync/internal/blockchain"
+	"github.com/gavin/nftSync/internal/blockchain/marketplace"
 	"github.com/gavin/nftSync/internal/config"
 	"github.com/gavin/nftSync/internal/dao"
 	"gorm.io/gorm"
@@ -11,7 +14,6 @@ import (
 	"log"
 	"math/big"
 	"net/http"
-	"strconv"
 	"strings"
 )
 
@@ -51,14 +53,18 @@ func (s *MultiNodeSyncService) SyncMintEventsPolling(ctx context.Context, bizCtx
 		return
 	}
 	nftContracts := bizCtx.Config.NFTContracts
+	txs := map[string]*transferTx{} // 本轮推导成交的交易与回执缓存
 	for _, contract := range nftContracts {
 		multiEvents := s.FetchTransferEventsAllNodes(contract, startBlock, safeBlock, ctx)
 		for _, mevt := range multiEvents {
+			s.recordTransfer(mevt.Event)
 			if !isMintEvent(mevt.Event) {
+				s.processTransferEvent(ctx, mevt.Event, bizCtx.Config.WETHAddress, txs)
 				continue
 			}
 			processMintEvent(mevt, contract, s, ctx)
 		}
+		s.syncMetadataUpdates(ctx, contract, startBlock, safeBlock)
 	}
 	s.lastSyncedBlock.Set(safeBlock)
 	log.Printf("轮询补全完成，已安全同步到区块 %v", safeBlock)
@@ -86,29 +92,12 @@ func getLatestBlock(multiNode *config.MultiNodeEthClient, ctx context.Context) *
 // 处理铸造事件，交叉验证、分叉检测、持久化
 func processMintEvent(mevt MultiNodeTransferEvent, contract string, s *MultiNodeSyncService, ctx context.Context) {
 	confirmed := mevt.Confidence >= len(s.MultiNode.Clients)
-	tokenIdBig, err := strconv.ParseInt(mevt.Event.TokenID, 0, 64)
-	if err != nil {
-		log.Printf("tokenId解析失败: %v", err)
-		return
-	}
-	tokenURI, err := blockchain.NewEthClient(s.MultiNode.Clients[0]).GetTokenURI(ctx, contract, big.NewInt(tokenIdBig))
-	if err != nil {
-		log.Printf("tokenURI获取失败: %v", err)
-		return
-	}
-	meta, err := fetchMetadata(tokenURI)
+	tokenURI, meta, err := fetchTokenMetadata(ctx, blockchain.NewEthClient(s.MultiNode.Clients[0]), contract, common.HexToHash(mevt.Event.TokenID).Big())
 	if err != nil {
 		log.Printf("元数据获取失败: %v", err)
 		return
 	}
-	items := []dao.Item{}
-	for _, attr := range meta.Attributes {
-		items = append(items, dao.Item{
-			Name:      meta.Name,
-			TraitType: attr.TraitType,
-			Value:     attr.Value,
-		})
-	}
+	items := metadataItems(meta)
 	metaJson, _ := json.Marshal(meta)
 	nft := dao.NFT{
 		TokenID:     mevt.Event.TokenID,
@@ -124,6 +113,18 @@ func processMintEvent(mevt MultiNodeTransferEvent, contract string, s *MultiNode
 	}
 	log.Printf("铸造NFT: %+v, Items: %+v", nft, nft.Items)
 	if s.Dao.DB != nil {
+		// 重新处理已入库的 token 时，tokenURI 或元数据变化记为元数据更新活动
+		if old, err := s.Dao.GetNFTDetail(nft.Contract, nft.TokenID); err == nil && (old.TokenURI != nft.TokenURI || old.Metadata != nft.Metadata) {
+			recordActivity(s.Dao, s.Push, s.Events, &dao.Activity{
+				Type:        dao.ActivityMetadataUpdate,
+				Collection:  nft.Contract,
+				TokenID:     nft.TokenID,
+				TxHash:      mevt.Event.TxHash,
+				LogIndex:    int(mevt.Event.LogIndex),
+				BlockNumber: mevt.Event.BlockNumber,
+				BlockTime:   mevt.Event.BlockTime,
+			})
+		}
 		err := s.Dao.DB.Transaction(func(tx *gorm.DB) error {
 			return s.Dao.SaveOrUpdateNFT(&nft)
 		})
@@ -135,6 +136,101 @@ func processMintEvent(mevt MultiNodeTransferEvent, contract string, s *MultiNode
 	}
 }
 
+// fetchTokenMetadata 查询 tokenURI 并拉取元数据
+func fetchTokenMetadata(ctx context.Context, cli *blockchain.EthClient, contract string, tokenID *big.Int) (string, *Metadata, error) {
+	tokenURI, err := cli.GetTokenURI(ctx, contract, tokenID)
+	if err != nil {
+		return "", nil, fmt.Errorf("tokenURI获取失败: %w", err)
+	}
+	meta, err := fetchMetadata(tokenURI)
+	if err != nil {
+		return "", nil, err
+	}
+	return tokenURI, meta, nil
+}
+
+// metadataItems 元数据属性转为属性记录
+func metadataItems(meta *Metadata) []dao.Item {
+	items := []dao.Item{}
+	for _, attr := range meta.Attributes {
+		items = append(items, dao.Item{
+			Name:      meta.Name,
+			TraitType: attr.TraitType,
+			Value:     attr.Value,
+		})
+	}
+	return items
+}
+
+// syncMetadataUpdates 处理 EIP-4906 MetadataUpdate/BatchMetadataUpdate 事件：
+// 刷新已入库 token 的元数据与属性，并按事件位置记录元数据更新活动；尚未入库的 token 在铸造同步时获取最新元数据
+func (s *MultiNodeSyncService) syncMetadataUpdates(ctx context.Context, contract string, startBlock, endBlock *big.Int) {
+	var events []blockchain.MetadataUpdateEvent
+	err := callNodes(s.MultiNode, func(cli *blockchain.EthClient) (err error) {
+		events, err = cli.FetchMetadataUpdateEvents(ctx, contract, startBlock, endBlock)
+		return err
+	})
+	if err != nil {
+		log.Printf("[metadata] 元数据更新事件拉取失败: contract=%s, err=%v", contract, err)
+		return
+	}
+	var stored []string // 批量更新时按需加载合约下已入库的 tokenId
+	for i := range events {
+		evt := &events[i]
+		var targets []string
+		if evt.FromTokenID.Cmp(evt.ToTokenID) == 0 {
+			targets = []string{marketplace.TokenIDHex(evt.FromTokenID)}
+		} else {
+			if stored == nil {
+				if stored, err = s.Dao.ListNFTTokenIDs(evt.Contract); err != nil {
+					log.Printf("[metadata] tokenId 查询失败: contract=%s, err=%v", evt.Contract, err)
+					return
+				}
+			}
+			for _, id := range stored {
+				if v := common.HexToHash(id).Big(); v.Cmp(evt.FromTokenID) >= 0 && v.Cmp(evt.ToTokenID) <= 0 {
+					targets = append(targets, id)
+				}
+			}
+		}
+		for _, tokenID := range targets {
+			s.refreshMetadata(ctx, evt, tokenID)
+		}
+	}
+}
+
+// refreshMetadata 重新拉取单个 token 的元数据并记录元数据更新活动，token 未入库时跳过
+func (s *MultiNodeSyncService) refreshMetadata(ctx context.Context, evt *blockchain.MetadataUpdateEvent, tokenID string) {
+	nft, err := s.Dao.GetNFTDetail(evt.Contract, tokenID)
+	if err != nil {
+		return
+	}
+	var tokenURI string
+	var meta *Metadata
+	err = callNodes(s.MultiNode, func(cli *blockchain.EthClient) (err error) {
+		tokenURI, meta, err = fetchTokenMetadata(ctx, cli, evt.Contract, common.HexToHash(tokenID).Big())
+		return err
+	})
+	if err != nil {
+		log.Printf("[metadata] 元数据刷新失败: contract=%s, tokenId=%s, err=%v", evt.Contract, tokenID, err)
+		return
+	}
+	metaJson, _ := json.Marshal(meta)
+	if err := s.Dao.UpdateNFTMetadata(nft.ID, tokenURI, string(metaJson), metadataItems(meta)); err != nil {
+		log.Printf("[metadata] 元数据保存失败: contract=%s, tokenId=%s, err=%v", evt.Contract, tokenID, err)
+		return
+	}
+	recordActivity(s.Dao, s.Push, s.Events, &dao.Activity{
+		Type:        dao.ActivityMetadataUpdate,
+		Collection:  evt.Contract,
+		TokenID:     tokenID,
+		TxHash:      evt.TxHash,
+		LogIndex:    int(evt.LogIndex),
+		BlockNumber: evt.BlockNumber,
+		BlockTime:   evt.BlockTime,
+	})
+}
+
 // Attribute 表示 NFT 元数据中的单个属性
 // 例如：{"trait_type": "Color", "value": "Red"}
 type Attribute struct {
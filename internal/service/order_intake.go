@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gavin/nftSync/internal/blockchain"
+	"github.com/gavin/nftSync/internal/blockchain/marketplace"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/matching"
+	"github.com/gavin/nftSync/internal/middleware"
+	"github.com/gavin/nftSync/internal/pricefeed"
+	"github.com/shopspring/decimal"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrOrderRejected    = errors.New("订单校验未通过")
+	ErrDuplicateNonce   = errors.New("nonce已使用")
+	ErrOrderNotFound    = errors.New("订单不存在")
+	ErrInvalidSignature = errors.New("签名无效")
+)
+
+// SubmitSignedOrder 接收链下 EIP-712 签名订单
+// 校验签名者与 maker 一致、挂单方持有 NFT 并已授权交易合约、出价方余额与授权额度充足，通过后以 listed 状态入库
+func (s *Service) SubmitSignedOrder(ctx context.Context, msg blockchain.SignedOrderMessage, signature string) (*OrderDTO, error) {
+	parsed, err := parseSignedOrder(msg)
+	if err != nil {
+		return nil, err
+	}
+	domain := s.orderDomain(s.Config.OrderSigning.Exchange)
+	signer, digest, err := blockchain.RecoverTypedDataSigner(blockchain.OrderTypedData(domain, msg), signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if signer != parsed.maker {
+		return nil, fmt.Errorf("%w: 签名者与 maker 不一致", ErrInvalidSignature)
+	}
+	if err := s.checkOrderAssets(ctx, parsed, domain.VerifyingContract); err != nil {
+		return nil, err
+	}
+
+	order := dao.Order{
+		OrderID:   digest.Hex(),
+		NFTToken:  parsed.collection.Hex(),
+		Seller:    parsed.maker.Hex(),
+		Price:     decimal.NewFromBigInt(parsed.price, 0),
+		Currency:  parsed.currency.Hex(),
+		Status:    dao.OrderStatusListed,
+		OrderType: parsed.orderType,
+		Exchange:  common.HexToAddress(domain.VerifyingContract).Hex(),
+		StartTime: parsed.startTime,
+		EndTime:   parsed.endTime,
+		Nonce:     parsed.nonce.String(),
+		Signature: signature,
+		Criteria:  msg.Criteria,
+	}
+	if parsed.orderType != dao.OrderTypeCollectionBid {
+		order.TokenID = marketplace.TokenIDHex(parsed.tokenID)
+	}
+	if order.PriceScaled, err = s.Currencies.Scale(ctx, order.Currency, order.Price); err != nil {
+		return nil, err
+	}
+	var created bool
+	err = s.Dao.Transaction(func(tx *dao.Dao) error {
+		var err error
+		if created, err = tx.CreateSignedOrder(&order); err != nil || !created {
+			return err
+		}
+		return tx.EnqueueOutbox(dao.FloorPriceOutbox(order.NFTToken, middleware.FloorTriggerOrderCreated))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrDuplicateNonce
+	}
+	log.Printf("[order_intake] 签名订单已入库: orderId=%s, maker=%s, type=%s", order.OrderID, order.Seller, order.OrderType)
+	if err := s.OrderBook.Add(ctx, &order); err != nil {
+		log.Printf("[orderbook] 订单写入索引失败: orderId=%s, err=%v", order.OrderID, err)
+	}
+	s.recordOffchainActivity(dao.ActivityOrderCreated, &order)
+	return s.ToOrderDTO(&order), nil
+}
+
+// CancelSignedOrder 取消链下签名订单，撤单签名者须为订单 maker
+func (s *Service) CancelSignedOrder(ctx context.Context, orderId, signature string) error {
+	order, err := s.Dao.GetOrderByOrderID(orderId)
+	if err != nil {
+		return err
+	}
+	if order == nil {
+		return ErrOrderNotFound
+	}
+	if order.Signature == "" {
+		return fmt.Errorf("%w: 链上订单需通过交易合约取消", ErrOrderRejected)
+	}
+	signer, _, err := blockchain.RecoverTypedDataSigner(
+		blockchain.CancelTypedData(s.orderDomain(order.Exchange), order.OrderID), signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if !strings.EqualFold(signer.Hex(), order.Seller) {
+		return fmt.Errorf("%w: 签名者不是订单 maker", ErrInvalidSignature)
+	}
+	var ok bool
+	err = s.Dao.Transaction(func(tx *dao.Dao) error {
+		var err error
+		if ok, err = tx.CancelListedOrder(order.OrderID); err != nil || !ok {
+			return err
+		}
+		return tx.EnqueueOutbox(dao.FloorPriceOutbox(order.NFTToken, middleware.FloorTriggerOrderCancelled))
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: 订单状态为 %s，无法取消", ErrOrderRejected, order.Status)
+	}
+	log.Printf("[order_intake] 签名订单已取消: orderId=%s", order.OrderID)
+	if err := s.OrderBook.Remove(ctx, order); err != nil {
+		log.Printf("[orderbook] 订单移出索引失败: orderId=%s, err=%v", order.OrderID, err)
+	}
+	s.recordOffchainActivity(dao.ActivityOrderCancelled, order)
+	return nil
+}
+
+func (s *Service) orderDomain(exchange string) blockchain.OrderDomain {
+	cfg := s.Config.OrderSigning
+	return blockchain.OrderDomain{
+		Name:              cfg.Name,
+		Version:           cfg.Version,
+		ChainID:           cfg.ChainID,
+		VerifyingContract: exchange,
+	}
+}
+
+// recordOffchainActivity 写入链下签名订单活动，没有区块与日志，按写入时间参与排序
+func (s *Service) recordOffchainActivity(typ string, order *dao.Order) {
+	a := orderActivity(typ, order)
+	a.LogIndex = dao.ActivityOffchainLogIndex
+	a.BlockTime = time.Now().Unix()
+	recordActivity(s.Dao, s.Push, s.Events, a)
+}
+
+// parsedOrder 解析后的签名订单
+type parsedOrder struct {
+	maker      common.Address
+	collection common.Address
+	currency   common.Address
+	tokenID    *big.Int
+	price      *big.Int
+	nonce      *big.Int
+	startTime  int64
+	endTime    int64
+	orderType  string
+}
+
+// parseSignedOrder 校验签名订单字段
+func parseSignedOrder(msg blockchain.SignedOrderMessage) (*parsedOrder, error) {
+	for name, addr := range map[string]string{"maker": msg.Maker, "collection": msg.Collection, "currency": msg.Currency} {
+		if !common.IsHexAddress(addr) {
+			return nil, fmt.Errorf("%w: %s 地址格式错误", ErrOrderRejected, name)
+		}
+	}
+	p := &parsedOrder{
+		maker:      common.HexToAddress(msg.Maker),
+		collection: common.HexToAddress(msg.Collection),
+		currency:   common.HexToAddress(msg.Currency),
+	}
+	var startTime, endTime *big.Int
+	var err error
+	if p.tokenID, err = parseUint("token_id", msg.TokenID); err != nil {
+		return nil, err
+	}
+	if p.price, err = parseUint("price", msg.Price); err != nil {
+		return nil, err
+	}
+	if p.nonce, err = parseUint("nonce", msg.Nonce); err != nil {
+		return nil, err
+	}
+	if startTime, err = parseUint("start_time", msg.StartTime); err != nil {
+		return nil, err
+	}
+	if endTime, err = parseUint("end_time", msg.EndTime); err != nil {
+		return nil, err
+	}
+	if !startTime.IsInt64() || !endTime.IsInt64() {
+		return nil, fmt.Errorf("%w: 有效期超出范围", ErrOrderRejected)
+	}
+	p.startTime, p.endTime = startTime.Int64(), endTime.Int64()
+
+	if p.price.Sign() == 0 {
+		return nil, fmt.Errorf("%w: 价格须大于0", ErrOrderRejected)
+	}
+	if p.endTime != 0 && (p.endTime <= time.Now().Unix() || p.endTime <= p.startTime) {
+		return nil, fmt.Errorf("%w: 订单已过期或有效期无效", ErrOrderRejected)
+	}
+	switch {
+	case msg.IsCollectionBid && !msg.IsBid:
+		return nil, fmt.Errorf("%w: 合集出价须同时为出价", ErrOrderRejected)
+	case msg.IsCollectionBid:
+		p.orderType = dao.OrderTypeCollectionBid
+	case msg.IsBid:
+		p.orderType = dao.OrderTypeItemBid
+	default:
+		p.orderType = dao.OrderTypeListing
+	}
+	if msg.Criteria != "" {
+		if p.orderType != dao.OrderTypeCollectionBid {
+			return nil, fmt.Errorf("%w: 仅合集出价支持属性条件", ErrOrderRejected)
+		}
+		if _, err := matching.ParseCriteria(msg.Criteria); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrOrderRejected, err)
+		}
+	}
+	// 出价由交易合约划转 ERC20，不支持原生 ETH
+	if msg.IsBid && pricefeed.IsNative(p.currency.Hex()) {
+		return nil, fmt.Errorf("%w: 出价须使用 ERC20 币种", ErrOrderRejected)
+	}
+	return p, nil
+}
+
+// parseUint 解析 uint256 十进制字符串
+func parseUint(name, value string) (*big.Int, error) {
+	v, ok := new(big.Int).SetString(value, 10)
+	if !ok || v.Sign() < 0 || v.BitLen() > 256 {
+		return nil, fmt.Errorf("%w: %s 须为 uint256 十进制整数", ErrOrderRejected, name)
+	}
+	return v, nil
+}
+
+// checkOrderAssets 通过节点池校验 maker 的资产：挂单校验持有与授权，出价校验余额与授权额度
+func (s *Service) checkOrderAssets(ctx context.Context, p *parsedOrder, exchange string) error {
+	maker := p.maker.Hex()
+	if p.orderType == dao.OrderTypeListing {
+		var owner common.Address
+		if err := callNodes(s.MultiNode, func(cli *blockchain.EthClient) (err error) {
+			owner, err = cli.GetOwnerOf(ctx, p.collection.Hex(), p.tokenID)
+			return err
+		}); err != nil {
+			return err
+		}
+		if owner != p.maker {
+			return fmt.Errorf("%w: maker 未持有该 NFT", ErrOrderRejected)
+		}
+		var approved bool
+		if err := callNodes(s.MultiNode, func(cli *blockchain.EthClient) (err error) {
+			approved, err = cli.IsApprovedForAll(ctx, p.collection.Hex(), maker, exchange)
+			return err
+		}); err != nil {
+			return err
+		}
+		if !approved {
+			return fmt.Errorf("%w: maker 未授权交易合约", ErrOrderRejected)
+		}
+		return nil
+	}
+	var balance, allowance *big.Int
+	if err := callNodes(s.MultiNode, func(cli *blockchain.EthClient) (err error) {
+		balance, err = cli.GetERC20Balance(ctx, p.currency.Hex(), maker)
+		return err
+	}); err != nil {
+		return err
+	}
+	if balance.Cmp(p.price) < 0 {
+		return fmt.Errorf("%w: 出价币种余额不足", ErrOrderRejected)
+	}
+	if err := callNodes(s.MultiNode, func(cli *blockchain.EthClient) (err error) {
+		allowance, err = cli.GetERC20Allowance(ctx, p.currency.Hex(), maker, exchange)
+		return err
+	}); err != nil {
+		return err
+	}
+	if allowance.Cmp(p.price) < 0 {
+		return fmt.Errorf("%w: 出价币种授权额度不足", ErrOrderRejected)
+	}
+	return nil
+}
+
+// callNodes 依次在节点池中执行链上调用，直到有节点成功
+func callNodes(multi *config.MultiNodeEthClient, fn func(cli *blockchain.EthClient) error) error {
+	err := errors.New("无可用节点")
+	for i, c := range multi.Clients {
+		if err = fn(blockchain.NewEthClient(c)); err == nil {
+			return nil
+		}
+		log.Printf("[node_pool] 节点调用失败: node=%s, err=%v", multi.NodeNames[i], err)
+	}
+	return fmt.Errorf("链上查询失败: %w", err)
+}
@@ -1,7 +1,9 @@
 package service
 
 import (
+	"context"
 	"github.com/gavin/nftSync/internal/dao"
+	"github.com/shopspring/decimal"
 	"time"
 )
 
@@ -30,16 +32,21 @@ func (s *Service) ListUserOrders(owner string) ([]OrderDTO, error) {
 // OrderDTO 用于安全输出订单信息
 // 可根据实际业务裁剪字段
 type OrderDTO struct {
-	ID        int64     `json:"id"`
-	NFTID     int64     `json:"nft_id"`
-	NFTToken  string    `json:"nft_token"`
-	Seller    string    `json:"seller"`
-	Buyer     string    `json:"buyer"`
-	Price     string    `json:"price"` // decimal.Decimal转string
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Fee       string    `json:"fee"` // decimal.Decimal转string
+	ID          int64     `json:"id"`
+	NFTID       int64     `json:"nft_id"`
+	NFTToken    string    `json:"nft_token"`
+	TokenID     string    `json:"token_id,omitempty"`
+	Seller      string    `json:"seller"`
+	Buyer       string    `json:"buyer"`
+	Price       string    `json:"price"` // decimal.Decimal转string，链上原始数值
+	PriceScaled string    `json:"price_scaled"`
+	Currency    string    `json:"currency"`
+	Status      string    `json:"status"`
+	OrderType   string    `json:"order_type"`
+	EndTime     int64     `json:"end_time"` // 0表示永不过期
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Fee         string    `json:"fee"` // decimal.Decimal转string
 }
 
 // ToOrderDTO 将 dao.Order 转换为 OrderDTO
@@ -48,34 +55,48 @@ func (s *Service) ToOrderDTO(order *dao.Order) *OrderDTO {
 		return nil
 	}
 	return &OrderDTO{
-		ID:        order.ID,
-		NFTID:     order.NFTID,
-		NFTToken:  order.NFTToken,
-		Seller:    order.Seller,
-		Buyer:     order.Buyer,
-		Price:     order.Price.String(), // decimal.Decimal转string
-		Status:    order.Status,
-		CreatedAt: order.CreatedAt,
-		UpdatedAt: order.UpdatedAt,
-		Fee:       order.Fee.String(), // decimal.Decimal转string
+		ID:          order.ID,
+		NFTID:       order.NFTID,
+		NFTToken:    order.NFTToken,
+		TokenID:     order.TokenID,
+		Seller:      order.Seller,
+		Buyer:       order.Buyer,
+		Price:       order.Price.String(), // decimal.Decimal转string
+		PriceScaled: order.PriceScaled.String(),
+		Currency:    order.Currency,
+		Status:      order.Status,
+		OrderType:   order.OrderType,
+		EndTime:     order.EndTime,
+		CreatedAt:   order.CreatedAt,
+		UpdatedAt:   order.UpdatedAt,
+		Fee:         order.Fee.String(), // decimal.Decimal转string
 	}
 }
 
-// 订单统计 DTO
-// 可根据实际业务裁剪字段
+// 订单统计 DTO，成交额已折算为基础币种
 type OrderStatsDTO struct {
-	Total       int64   `json:"total"`
-	TotalAmount float64 `json:"total_amount"`
+	Total       int64  `json:"total"`
+	TotalAmount string `json:"total_amount"`
+	Currency    string `json:"currency"`
 }
 
-// GetOrderStats 查询订单统计（生产级）
-func (s *Service) GetOrderStats() (*OrderStatsDTO, error) {
-	stats, err := s.Dao.GetOrderStats()
+// GetOrderStats 统计自有订单合约的已成交订单，各币种成交额折算为基础币种后汇总
+// 全市场成交统计见 GetCollectionStats
+func (s *Service) GetOrderStats(ctx context.Context) (*OrderStatsDTO, error) {
+	rows, err := s.Dao.SumCompletedOrdersByCurrency()
 	if err != nil {
 		return nil, err
 	}
-	return &OrderStatsDTO{
-		Total:       stats.Total,
-		TotalAmount: stats.TotalAmount,
-	}, nil
+	stats := &OrderStatsDTO{Currency: s.Currencies.PriceFeed.BaseCurrency()}
+	total := decimal.Zero
+	for _, row := range rows {
+		amount, err := s.Currencies.Normalize(ctx, row.Currency, row.Amount)
+		if err != nil {
+			return nil, err
+		}
+		stats.Total += row.Total
+		total = total.Add(amount)
+	}
+	stats.TotalAmount = total.String()
+	return stats, nil
 }
@@ -7,8 +7,10 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/gavin/nftSync/internal/blockchain"
+	"github.com/gavin/nftSync/internal/blockchain/marketplace"
 	"github.com/gavin/nftSync/internal/config"
 	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/middleware"
 	"github.com/shopspring/decimal"
 	"log"
 	"math/big"
@@ -18,6 +20,14 @@ import (
 
 var orderCreatedEventABI = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"orderId","type":"bytes32"},{"indexed":false,"name":"seller","type":"address"},{"indexed":false,"name":"nftToken","type":"address"},{"indexed":false,"name":"tokenId","type":"uint256"},{"indexed":false,"name":"price","type":"uint256"},{"indexed":false,"name":"fee","type":"uint256"},{"indexed":false,"name":"isBid","type":"bool"},{"indexed":false,"name":"isCollectionBid","type":"bool"}],"name":"OrderCreated","type":"event"}]`
 
+// 带有效期的订单创建事件（合约升级后），在原字段后追加 startTime/endTime/nonce
+var orderCreatedV2EventABI = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"orderId","type":"bytes32"},{"indexed":false,"name":"seller","type":"address"},{"indexed":false,"name":"nftToken","type":"address"},{"indexed":false,"name":"tokenId","type":"uint256"},{"indexed":false,"name":"price","type":"uint256"},{"indexed":false,"name":"fee","type":"uint256"},{"indexed":false,"name":"isBid","type":"bool"},{"indexed":false,"name":"isCollectionBid","type":"bool"},{"indexed":false,"name":"startTime","type":"uint256"},{"indexed":false,"name":"endTime","type":"uint256"},{"indexed":false,"name":"nonce","type":"uint256"}],"name":"OrderCreated","type":"event"}]`
+
+// orderCreatedV1DataLen 旧版订单创建事件 data 长度（8个静态字段）
+const orderCreatedV1DataLen = 8 * 32
+
+var orderFilledEventABI = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"sellOrderId","type":"bytes32"},{"indexed":true,"name":"buyOrderId","type":"bytes32"},{"indexed":false,"name":"seller","type":"address"},{"indexed":false,"name":"buyer","type":"address"},{"indexed":false,"name":"tokenId","type":"uint256"},{"indexed":false,"name":"price","type":"uint256"},{"indexed":false,"name":"fee","type":"uint256"}],"name":"OrderFilled","type":"event"}]`
+
 // SyncOrderEventsPolling 主节点优先订单同步（生产级，面向对象）
 func (s *MultiNodeSyncService) SyncOrderEventsPolling(ctx context.Context, bizCtx *config.Context) *big.Int {
 	mainClient := s.MultiNode.Clients[0]
@@ -37,14 +47,16 @@ func (s *MultiNodeSyncService) SyncOrderEventsPolling(ctx context.Context, bizCt
 	orderContracts := bizCtx.Config.OrderContracts
 
 	// 事件topic hash，与eth.go保持一致
-	orderCreatedSig := "OrderCreated(address,address,uint256,uint256,uint256,bool,bool)"
+	orderCreatedSig := "OrderCreated(bytes32,address,address,uint256,uint256,uint256,bool,bool)"
+	orderCreatedV2Sig := "OrderCreated(bytes32,address,address,uint256,uint256,uint256,bool,bool,uint256,uint256,uint256)"
 	orderCancelledSig := "OrderCancelled(bytes32,address)"
 	orderFilledSig := "OrderFilled(bytes32,bytes32,address,address,uint256,uint256,uint256)"
 	createdTopic := crypto.Keccak256Hash([]byte(orderCreatedSig))
+	createdV2Topic := crypto.Keccak256Hash([]byte(orderCreatedV2Sig))
 	cancelledTopic := crypto.Keccak256Hash([]byte(orderCancelledSig))
 	filledTopic := crypto.Keccak256Hash([]byte(orderFilledSig))
 
-	topics := []common.Hash{createdTopic, cancelledTopic, filledTopic}
+	topics := []common.Hash{createdTopic, createdV2Topic, cancelledTopic, filledTopic}
 	for _, contract := range orderContracts {
 		logs, err := ethClient.FetchOrderEvents(ctx, contract, startBlock, safeBlock, topics)
 		if err != nil {
@@ -66,18 +78,21 @@ func (s *MultiNodeSyncService) SyncOrderEventsPolling(ctx context.Context, bizCt
 				continue
 			}
 			topic0 := vLog.Topics[0]
+			if topic0 != createdTopic && topic0 != createdV2Topic && topic0 != cancelledTopic && topic0 != filledTopic {
+				continue
+			}
+			blockTime := int64(0)
+			block, err := ethClient.GetBlockByNumber(ctx, vLog.BlockNumber)
+			if err == nil {
+				blockTime = int64(block.Time())
+			}
 			switch topic0 {
-			case createdTopic:
-				blockTime := int64(0)
-				block, err := ethClient.GetBlockByNumber(ctx, vLog.BlockNumber)
-				if err == nil {
-					blockTime = int64(block.Time())
-				}
-				s.handleOrderCreated(vLog, blockTime)
+			case createdTopic, createdV2Topic:
+				s.handleOrderCreated(ctx, vLog, blockTime)
 			case cancelledTopic:
-				s.handleOrderCancelled(vLog)
+				s.handleOrderCancelled(vLog, blockTime)
 			case filledTopic:
-				s.handleOrderFilled(vLog)
+				s.handleOrderFilled(ctx, vLog, blockTime)
 			}
 		}
 	}
@@ -85,31 +100,38 @@ func (s *MultiNodeSyncService) SyncOrderEventsPolling(ctx context.Context, bizCt
 	return safeBlock
 }
 
-// 订单创建事件处理
-func (s *MultiNodeSyncService) handleOrderCreated(vLog types.Log, blockTime int64) {
-	orderCreatedABI, err := abi.JSON(strings.NewReader(orderCreatedEventABI))
+// 订单创建事件处理，按 data 长度区分是否携带有效期字段
+func (s *MultiNodeSyncService) handleOrderCreated(ctx context.Context, vLog types.Log, blockTime int64) {
+	eventABI := orderCreatedEventABI
+	if len(vLog.Data) > orderCreatedV1DataLen {
+		eventABI = orderCreatedV2EventABI
+	}
+	orderCreatedABI, err := abi.JSON(strings.NewReader(eventABI))
 	if err != nil {
 		log.Printf("[order_sync] 订单事件ABI解析失败: %v", err)
 		return
 	}
 
 	var createdLog struct {
-		orderId         [32]byte
-		seller          common.Address
-		nftToken        common.Address
-		tokenId         *big.Int
-		price           *big.Int
-		fee             *big.Int
-		isBid           bool
-		isCollectionBid bool
+		OrderId         [32]byte
+		Seller          common.Address
+		NftToken        common.Address
+		TokenId         *big.Int
+		Price           *big.Int
+		Fee             *big.Int
+		IsBid           bool
+		IsCollectionBid bool
+		StartTime       *big.Int // 旧版事件为 nil
+		EndTime         *big.Int
+		Nonce           *big.Int
 	}
 	if err := orderCreatedABI.UnpackIntoInterface(&createdLog, "OrderCreated", vLog.Data); err != nil {
 		log.Printf("[order_sync] 订单事件ABI解包失败: %v", err)
 		return
 	}
 	var orderType string
-	if createdLog.isBid {
-		if createdLog.isCollectionBid {
+	if createdLog.IsBid {
+		if createdLog.IsCollectionBid {
 			orderType = dao.OrderTypeCollectionBid
 		} else {
 			orderType = dao.OrderTypeItemBid
@@ -118,35 +140,48 @@ func (s *MultiNodeSyncService) handleOrderCreated(vLog types.Log, blockTime int6
 		orderType = dao.OrderTypeListing
 	}
 	order := dao.Order{
-		OrderID:     common.BytesToHash(createdLog.orderId[:]).Hex(),
-		NFTToken:    createdLog.nftToken.Hex(),
-		Seller:      createdLog.seller.Hex(),
+		OrderID:     common.BytesToHash(createdLog.OrderId[:]).Hex(),
+		NFTToken:    createdLog.NftToken.Hex(),
+		Seller:      createdLog.Seller.Hex(),
 		Status:      dao.OrderStatusListed,
 		TxHash:      vLog.TxHash.Hex(),
 		BlockNumber: vLog.BlockNumber,
 		BlockTime:   blockTime,
 		CreatedAt:   time.Unix(blockTime, 0),
 		UpdatedAt:   time.Unix(blockTime, 0),
-		Price:       decimal.NewFromBigInt(createdLog.price, 0),
-		Fee:         decimal.NewFromBigInt(createdLog.fee, 0),
+		Price:       decimal.NewFromBigInt(createdLog.Price, 0),
+		Fee:         decimal.NewFromBigInt(createdLog.Fee, 0),
+		Currency:    marketplace.ZeroAddress, // 自有订单合约仅支持 ETH 计价
 		OrderType:   orderType,
 	}
-	if err := s.Dao.CreateOrderIgnoreConflict(&order); err != nil {
+	order.PriceScaled = s.scalePrice(ctx, order.Currency, order.Price)
+	if orderType != dao.OrderTypeCollectionBid {
+		order.TokenID = marketplace.TokenIDHex(createdLog.TokenId)
+	}
+	order.Exchange = vLog.Address.Hex()
+	if createdLog.StartTime != nil {
+		order.StartTime = createdLog.StartTime.Int64()
+		order.EndTime = createdLog.EndTime.Int64()
+		order.Nonce = createdLog.Nonce.String()
+	}
+	// 订单与地板价更新消息在同一事务中写入
+	err = s.Dao.Transaction(func(tx *dao.Dao) error {
+		if err := tx.CreateOrderIgnoreConflict(&order); err != nil {
+			return err
+		}
+		return tx.EnqueueOutbox(dao.FloorPriceOutbox(order.NFTToken, middleware.FloorTriggerOrderCreated))
+	})
+	if err != nil {
 		log.Printf("[order_sync] 新订单插入失败: %v", err)
 	} else {
 		log.Printf("[order_sync] 新订单已同步: %s, orderId: %s", order.TxHash, order.OrderID)
-		// 发送地板价更新消息
-		if s.FloorPriceProducer != nil {
-			err := s.FloorPriceProducer.SendFloorPriceUpdateMsg(order.NFTToken)
-			if err != nil {
-				log.Printf("[order_sync] 地板价消息发送失败: %v", err)
-			}
-		}
+		s.indexOrder(ctx, &order)
+		s.recordOrderActivity(dao.ActivityOrderCreated, &order, "", vLog, blockTime)
 	}
 }
 
 // 订单取消事件处理
-func (s *MultiNodeSyncService) handleOrderCancelled(vLog types.Log) {
+func (s *MultiNodeSyncService) handleOrderCancelled(vLog types.Log, blockTime int64) {
 	if len(vLog.Topics) < 2 {
 		log.Printf("[order_sync] 取消事件topics不足: txHash=%s", vLog.TxHash.Hex())
 		return
@@ -161,37 +196,57 @@ func (s *MultiNodeSyncService) handleOrderCancelled(vLog types.Log) {
 		log.Printf("[order_sync] 取消事件未找到订单: orderId=%s", orderId)
 		return
 	}
-	if err := s.Dao.UpdateOrderStatusByOrderID(orderId, dao.OrderStatusCancelled); err != nil {
+	err = s.Dao.Transaction(func(tx *dao.Dao) error {
+		if err := tx.UpdateOrderStatusByOrderID(orderId, dao.OrderStatusCancelled); err != nil {
+			return err
+		}
+		return tx.EnqueueOutbox(dao.FloorPriceOutbox(order.NFTToken, middleware.FloorTriggerOrderCancelled))
+	})
+	if err != nil {
 		log.Printf("[order_sync] 取消订单更新失败: %v", err)
 	} else {
 		log.Printf("[order_sync] 取消订单已同步: orderId=%s", orderId)
-		// 发送地板价更新消息
-		if s.FloorPriceProducer != nil && order != nil {
-			err := s.FloorPriceProducer.SendFloorPriceUpdateMsg(order.NFTToken)
-			if err != nil {
-				log.Printf("[order_sync] 地板价消息发送失败: %v", err)
-			}
-		}
+		s.unindexOrder(context.Background(), order)
+		s.recordOrderActivity(dao.ActivityOrderCancelled, order, "", vLog, blockTime)
 	}
 }
 
-// 订单成交事件处理
-func (s *MultiNodeSyncService) handleOrderFilled(vLog types.Log) {
+// 订单成交事件处理：更新订单状态并写入成交记录
+func (s *MultiNodeSyncService) handleOrderFilled(ctx context.Context, vLog types.Log, blockTime int64) {
 	if len(vLog.Topics) < 3 {
 		log.Printf("[order_sync] 成交事件topics不足: txHash=%s", vLog.TxHash.Hex())
 		return
 	}
+	orderFilledABI, err := abi.JSON(strings.NewReader(orderFilledEventABI))
+	if err != nil {
+		log.Printf("[order_sync] 成交事件ABI解析失败: %v", err)
+		return
+	}
+	var filledLog struct {
+		Seller  common.Address
+		Buyer   common.Address
+		TokenId *big.Int
+		Price   *big.Int
+		Fee     *big.Int
+	}
+	if err := orderFilledABI.UnpackIntoInterface(&filledLog, "OrderFilled", vLog.Data); err != nil {
+		log.Printf("[order_sync] 成交事件ABI解包失败: %v", err)
+		return
+	}
 	sellerOrderId := vLog.Topics[1].Hex()
 	buyerOrderId := vLog.Topics[2].Hex()
+	buyer := filledLog.Buyer.Hex()
 	// 卖家订单状态更新
 	sellerOrder, err := s.Dao.GetOrderByOrderID(sellerOrderId)
 	if err != nil {
 		log.Printf("[order_sync] 查询卖家订单失败: %v", err)
 	} else if sellerOrder != nil {
-		if err := s.Dao.UpdateOrderStatusByOrderID(sellerOrderId, dao.OrderStatusCompleted); err != nil {
+		if err := s.fillOrder(sellerOrder, buyer); err != nil {
 			log.Printf("[order_sync] 卖家订单状态更新失败: %v", err)
 		} else {
 			log.Printf("[order_sync] 卖家订单已完成: orderId=%s", sellerOrderId)
+			s.unindexOrder(ctx, sellerOrder)
+			s.recordOrderActivity(dao.ActivityOrderFilled, sellerOrder, buyer, vLog, blockTime)
 		}
 	} else {
 		log.Printf("[order_sync] 卖家订单不存在: orderId=%s", sellerOrderId)
@@ -201,27 +256,65 @@ func (s *MultiNodeSyncService) handleOrderFilled(vLog types.Log) {
 	if err != nil {
 		log.Printf("[order_sync] 查询买家订单失败: %v", err)
 	} else if buyerOrder != nil {
-		if err := s.Dao.UpdateOrderStatusByOrderID(buyerOrderId, dao.OrderStatusCompleted); err != nil {
+		if err := s.fillOrder(buyerOrder, buyer); err != nil {
 			log.Printf("[order_sync] 买家订单状态更新失败: %v", err)
 		} else {
 			log.Printf("[order_sync] 买家订单已完成: orderId=%s", buyerOrderId)
+			s.unindexOrder(ctx, buyerOrder)
+			s.recordOrderActivity(dao.ActivityOrderFilled, buyerOrder, buyer, vLog, blockTime)
 		}
 	} else {
 		log.Printf("[order_sync] 买家订单不存在: orderId=%s", buyerOrderId)
 	}
-	// 发送地板价更新消息（假设 NFTToken 可从订单查得，实际可根据业务调整）
-	if s.FloorPriceProducer != nil {
-		if sellerOrder != nil {
-			err := s.FloorPriceProducer.SendFloorPriceUpdateMsg(sellerOrder.NFTToken)
-			if err != nil {
-				log.Printf("[order_sync] 地板价消息发送失败: %v", err)
-			}
+	// 写入成交记录，合集地址取自已同步的订单
+	var collection string
+	if sellerOrder != nil {
+		collection = sellerOrder.NFTToken
+	} else if buyerOrder != nil {
+		collection = buyerOrder.NFTToken
+	}
+	if collection != "" {
+		trade := dao.Trade{
+			OrderID:     sellerOrderId,
+			Marketplace: dao.MarketplaceNative,
+			Collection:  collection,
+			TokenID:     marketplace.TokenIDHex(filledLog.TokenId),
+			Seller:      filledLog.Seller.Hex(),
+			Buyer:       buyer,
+			Price:       decimal.NewFromBigInt(filledLog.Price, 0),
+			Currency:    marketplace.ZeroAddress,
+			Fee:         decimal.NewFromBigInt(filledLog.Fee, 0),
+			Royalty:     decimal.Zero,
+			TxHash:      vLog.TxHash.Hex(),
+			LogIndex:    vLog.Index,
+			BlockNumber: vLog.BlockNumber,
+			BlockTime:   blockTime,
+			Source:      dao.TradeSourceOrder,
 		}
-		if buyerOrder != nil {
-			err := s.FloorPriceProducer.SendFloorPriceUpdateMsg(buyerOrder.NFTToken)
-			if err != nil {
-				log.Printf("[order_sync] 地板价消息发送失败: %v", err)
-			}
+		trade.PriceScaled = s.scalePrice(ctx, trade.Currency, trade.Price)
+		if err := s.saveTrade(ctx, &trade, true, nil); err != nil {
+			log.Printf("[order_sync] 成交记录写入失败: %v", err)
 		}
 	}
 }
+
+// fillOrder 标记订单成交，并在同一事务中写入地板价更新消息
+func (s *MultiNodeSyncService) fillOrder(order *dao.Order, buyer string) error {
+	return s.Dao.Transaction(func(tx *dao.Dao) error {
+		if err := tx.UpdateOrderFilledByOrderID(order.OrderID, buyer); err != nil {
+			return err
+		}
+		return tx.EnqueueOutbox(dao.FloorPriceOutbox(order.NFTToken, middleware.FloorTriggerOrderFilled))
+	})
+}
+
+// recordOrderActivity 写入链上订单事件活动，to 为成交对手方，其余事件为空
+func (s *MultiNodeSyncService) recordOrderActivity(typ string, order *dao.Order, to string, vLog types.Log, blockTime int64) {
+	a := orderActivity(typ, order)
+	a.To = to
+	a.TxHash = vLog.TxHash.Hex()
+	a.LogIndex = int(vLog.Index)
+	a.BlockNumber = vLog.BlockNumber
+	a.BlockTime = blockTime
+	recordActivity(s.Dao, s.Push, s.Events, a)
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"github.com/gavin/nftSync/internal/blockchain"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/middleware"
+	"log"
+	"strings"
+	"time"
+)
+
+const orderValidateBatchSize = 200
+
+// OrderValidatorService 定时校验挂单有效性：过期、卖家不再持有、撤销授权
+// 未到生效时间的订单不进入订单簿索引，每轮将期间到达生效时间的订单写入索引
+type OrderValidatorService struct {
+	Dao            *dao.Dao
+	MultiNode      *config.MultiNodeEthClient
+	OrderBook      *OrderBookIndex
+	lastActivation int64 // 已处理到的生效时间，此前生效的订单由启动时的索引重建覆盖
+}
+
+func NewOrderValidatorService(ctx *config.Context) *OrderValidatorService {
+	return &OrderValidatorService{
+		Dao:            dao.New(ctx.Db),
+		MultiNode:      ctx.MultiNode,
+		OrderBook:      NewOrderBookIndex(ctx),
+		lastActivation: time.Now().Unix(),
+	}
+}
+
+// ValidateOrders 执行一轮校验，状态变更与地板价更新消息在同一事务中写入
+func (v *OrderValidatorService) ValidateOrders(ctx context.Context) {
+	v.activateOrders(ctx)
+
+	// 过期订单（挂单与出价），每个合集一条地板价更新消息
+	var expired []dao.Order
+	err := v.Dao.Transaction(func(tx *dao.Dao) error {
+		var err error
+		if expired, err = tx.ExpireOrders(time.Now().Unix()); err != nil {
+			return err
+		}
+		seen := map[string]bool{}
+		var msgs []dao.OutboxMessage
+		for _, o := range expired {
+			if !seen[o.NFTToken] {
+				seen[o.NFTToken] = true
+				msgs = append(msgs, dao.FloorPriceOutbox(o.NFTToken, middleware.FloorTriggerOrderExpired))
+			}
+		}
+		return tx.EnqueueOutbox(msgs...)
+	})
+	if err != nil {
+		log.Printf("[order_validator] 过期订单标记失败: %v", err)
+		expired = nil
+	}
+	for i := range expired {
+		v.unindex(ctx, &expired[i])
+	}
+	if len(expired) > 0 {
+		log.Printf("[order_validator] 已标记过期订单: %d", len(expired))
+	}
+
+	// 挂单持有与授权校验，同一轮内授权结果按 (合集, 卖家, 交易合约) 复用
+	approvals := map[string]bool{}
+	var afterID int64
+	invalidCount := 0
+	for {
+		orders, err := v.Dao.ListActiveListings(afterID, orderValidateBatchSize)
+		if err != nil {
+			log.Printf("[order_validator] 查询有效挂单失败: %v", err)
+			break
+		}
+		if len(orders) == 0 {
+			break
+		}
+		for i := range orders {
+			order := &orders[i]
+			afterID = order.ID
+			reason := v.checkListing(ctx, order, approvals)
+			if reason == "" {
+				continue
+			}
+			var ok bool
+			err := v.Dao.Transaction(func(tx *dao.Dao) error {
+				var err error
+				if ok, err = tx.InvalidateOrder(order.ID, dao.OrderStatusInvalid); err != nil || !ok {
+					return err
+				}
+				return tx.EnqueueOutbox(dao.FloorPriceOutbox(order.NFTToken, middleware.FloorTriggerOrderInvalid))
+			})
+			if err != nil {
+				log.Printf("[order_validator] 挂单失效标记失败: orderId=%s, err=%v", order.OrderID, err)
+				continue
+			}
+			if ok {
+				invalidCount++
+				v.unindex(ctx, order)
+				log.Printf("[order_validator] 挂单已失效: orderId=%s, reason=%s", order.OrderID, reason)
+			}
+		}
+	}
+	if invalidCount > 0 {
+		log.Printf("[order_validator] 已标记失效挂单: %d", invalidCount)
+	}
+
+}
+
+// activateOrders 将上一轮之后到达生效时间的订单写入索引，每个合集一条地板价更新消息
+func (v *OrderValidatorService) activateOrders(ctx context.Context) {
+	now := time.Now().Unix()
+	orders, err := v.Dao.ListOrdersActivatedBetween(v.lastActivation, now)
+	if err != nil {
+		log.Printf("[order_validator] 查询到达生效时间的订单失败: %v", err)
+		return
+	}
+	seen := map[string]bool{}
+	var msgs []dao.OutboxMessage
+	for i := range orders {
+		if err := v.OrderBook.Add(ctx, &orders[i]); err != nil {
+			log.Printf("[order_validator] 订单写入索引失败: orderId=%s, err=%v", orders[i].OrderID, err)
+		}
+		if !seen[orders[i].NFTToken] {
+			seen[orders[i].NFTToken] = true
+			msgs = append(msgs, dao.FloorPriceOutbox(orders[i].NFTToken, middleware.FloorTriggerOrderActivated))
+		}
+	}
+	if err := v.Dao.EnqueueOutbox(msgs...); err != nil {
+		log.Printf("[order_validator] 地板价更新消息写入失败: %v", err)
+		return
+	}
+	v.lastActivation = now
+	if len(orders) > 0 {
+		log.Printf("[order_validator] 已生效订单写入索引: %d", len(orders))
+	}
+}
+
+// unindex 订单移出订单簿索引
+func (v *OrderValidatorService) unindex(ctx context.Context, order *dao.Order) {
+	if err := v.OrderBook.Remove(ctx, order); err != nil {
+		log.Printf("[order_validator] 订单移出索引失败: orderId=%s, err=%v", order.OrderID, err)
+	}
+}
+
+// checkListing 返回挂单失效原因，有效时返回空字符串；链上查询失败时不判定失效
+func (v *OrderValidatorService) checkListing(ctx context.Context, order *dao.Order, approvals map[string]bool) string {
+	// 按转移历史判断卖家是否仍持有
+	if order.TokenID != "" {
+		transfer, err := v.Dao.GetLatestTransfer(order.NFTToken, order.TokenID)
+		if err != nil {
+			log.Printf("[order_validator] 查询转移记录失败: %v", err)
+		} else if transfer != nil && !strings.EqualFold(transfer.To, order.Seller) {
+			return "卖家已不再持有"
+		}
+	}
+	// 校验卖家对交易合约的全量授权
+	if order.Exchange == "" {
+		return ""
+	}
+	key := order.NFTToken + ":" + order.Seller + ":" + order.Exchange
+	approved, ok := approvals[key]
+	if !ok {
+		var err error
+		approved, err = blockchain.NewEthClient(v.MultiNode.Clients[0]).
+			IsApprovedForAll(ctx, order.NFTToken, order.Seller, order.Exchange)
+		if err != nil {
+			log.Printf("[order_validator] 授权查询失败: orderId=%s, err=%v", order.OrderID, err)
+			return ""
+		}
+		approvals[key] = approved
+	}
+	if !approved {
+		return "卖家已撤销授权"
+	}
+	return ""
+}
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/go-redis/redis/v8"
+	"github.com/shopspring/decimal"
+	"log"
+	"time"
+)
+
+// 订单簿索引 key，score 为折算为基础币种的价格（float64，仅用于排序），member 为 OrderID，
+// 精确价格存于合集的价格 hash，读取时以 hash 为准
+//
+//	orderbook:ask:{collection}              合集全部挂单
+//	orderbook:ask:{collection}:{tokenId}    单个 token 的挂单
+//	orderbook:bid:{collection}              合集出价
+//	orderbook:bid:{collection}:{tokenId}    单品出价
+//	orderbook:price:{collection}            OrderID -> 折算后的精确价格
+const (
+	orderBookAskPrefix   = "orderbook:ask:"
+	orderBookBidPrefix   = "orderbook:bid:"
+	orderBookPricePrefix = "orderbook:price:"
+	orderBookTmpPrefix   = "orderbook:tmp:" // 重建时的临时 key 前缀
+	orderBookTmpTTL      = 5 * time.Minute  // 重建中断时临时 key 自动过期
+)
+
+// OrderBookEntry 订单簿索引条目
+type OrderBookEntry struct {
+	OrderID string
+	Price   decimal.Decimal
+}
+
+// OrderBookIndex 基于 Redis ZSET 的增量订单簿索引
+// 地板价、前 N 挂单、深度查询均为 O(log n)，与 MySQL 不一致时可从 MySQL 重建
+type OrderBookIndex struct {
+	Redis      *redis.Client
+	Dao        *dao.Dao
+	Currencies *CurrencyResolver
+}
+
+func NewOrderBookIndex(ctx *config.Context) *OrderBookIndex {
+	return &OrderBookIndex{
+		Redis:      ctx.Redis,
+		Dao:        dao.New(ctx.Db),
+		Currencies: NewCurrencyResolver(ctx),
+	}
+}
+
+// normalizeAddr 地址统一为 checksum 格式，避免大小写不同导致 key 不一致
+func normalizeAddr(addr string) string {
+	return common.HexToAddress(addr).Hex()
+}
+
+func askKey(collection string) string {
+	return orderBookAskPrefix + normalizeAddr(collection)
+}
+
+func tokenAskKey(collection, tokenID string) string {
+	return orderBookAskPrefix + normalizeAddr(collection) + ":" + tokenID
+}
+
+func collectionBidKey(collection string) string {
+	return orderBookBidPrefix + normalizeAddr(collection)
+}
+
+func itemBidKey(collection, tokenID string) string {
+	return orderBookBidPrefix + normalizeAddr(collection) + ":" + tokenID
+}
+
+func priceKey(collection string) string {
+	return orderBookPricePrefix + normalizeAddr(collection)
+}
+
+// orderKeys 订单所属的索引 key
+func orderKeys(order *dao.Order) []string {
+	switch order.OrderType {
+	case dao.OrderTypeItemBid:
+		return []string{itemBidKey(order.NFTToken, order.TokenID)}
+	case dao.OrderTypeCollectionBid:
+		return []string{collectionBidKey(order.NFTToken)}
+	default:
+		keys := []string{askKey(order.NFTToken)}
+		if order.TokenID != "" {
+			keys = append(keys, tokenAskKey(order.NFTToken, order.TokenID))
+		}
+		return keys
+	}
+}
+
+// isIndexable 仅索引有效期内的 listed 订单；属性出价只能成交部分 token，不计入合集出价
+// 未到生效时间的订单由 OrderValidatorService 在到达生效时间后写入
+func isIndexable(order *dao.Order, now int64) bool {
+	if order.Status != dao.OrderStatusListed || order.Criteria != "" {
+		return false
+	}
+	return order.StartTime <= now && (order.EndTime == 0 || order.EndTime > now)
+}
+
+// indexPrice 可索引订单折算为基础币种的价格，不可索引时返回 false
+func (idx *OrderBookIndex) indexPrice(ctx context.Context, order *dao.Order, now int64) (decimal.Decimal, bool, error) {
+	if !isIndexable(order, now) {
+		return decimal.Zero, false, nil
+	}
+	price, err := idx.Currencies.ToBase(ctx, order.Currency, order.Price, order.PriceScaled)
+	if err != nil {
+		return decimal.Zero, false, err
+	}
+	return price, true, nil
+}
+
+// Add 订单写入索引
+func (idx *OrderBookIndex) Add(ctx context.Context, order *dao.Order) error {
+	price, ok, err := idx.indexPrice(ctx, order, time.Now().Unix())
+	if err != nil || !ok {
+		return err
+	}
+	pipe := idx.Redis.TxPipeline()
+	for _, key := range orderKeys(order) {
+		pipe.ZAdd(ctx, key, &redis.Z{Score: price.InexactFloat64(), Member: order.OrderID})
+	}
+	pipe.HSet(ctx, priceKey(order.NFTToken), order.OrderID, price.String())
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Remove 订单移出索引（取消、成交、过期、失效）
+func (idx *OrderBookIndex) Remove(ctx context.Context, order *dao.Order) error {
+	pipe := idx.Redis.TxPipeline()
+	for _, key := range orderKeys(order) {
+		pipe.ZRem(ctx, key, order.OrderID)
+	}
+	pipe.HDel(ctx, priceKey(order.NFTToken), order.OrderID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Floor 合集地板价，无挂单时返回 false
+func (idx *OrderBookIndex) Floor(ctx context.Context, collection string) (decimal.Decimal, bool, error) {
+	entries, err := idx.rangeEntries(ctx, collection, askKey(collection), 1, false)
+	if err != nil || len(entries) == 0 {
+		return decimal.Zero, false, err
+	}
+	return entries[0].Price, true, nil
+}
+
+// TopListings 合集价格最低的 n 个挂单
+func (idx *OrderBookIndex) TopListings(ctx context.Context, collection string, n int64) ([]OrderBookEntry, error) {
+	return idx.rangeEntries(ctx, collection, askKey(collection), n, false)
+}
+
+// Asks 合集挂单（价格升序，最多 n 条），用于深度聚合
+func (idx *OrderBookIndex) Asks(ctx context.Context, collection string, n int64) ([]OrderBookEntry, error) {
+	return idx.rangeEntries(ctx, collection, askKey(collection), n, false)
+}
+
+// Bids 合集出价（价格降序，最多 n 条），用于深度聚合
+func (idx *OrderBookIndex) Bids(ctx context.Context, collection string, n int64) ([]OrderBookEntry, error) {
+	return idx.rangeEntries(ctx, collection, collectionBidKey(collection), n, true)
+}
+
+// TokenListings 单个 token 的挂单（价格升序，最多 n 条）
+func (idx *OrderBookIndex) TokenListings(ctx context.Context, collection, tokenID string, n int64) ([]OrderBookEntry, error) {
+	return idx.rangeEntries(ctx, collection, tokenAskKey(collection, tokenID), n, false)
+}
+
+// ItemBids 单个 token 的单品出价（价格降序，最多 n 条）
+func (idx *OrderBookIndex) ItemBids(ctx context.Context, collection, tokenID string, n int64) ([]OrderBookEntry, error) {
+	return idx.rangeEntries(ctx, collection, itemBidKey(collection, tokenID), n, true)
+}
+
+// rangeEntries 按价格取前 n 条，reverse 为 true 时价格降序；价格取自价格 hash，缺失时退回 score
+func (idx *OrderBookIndex) rangeEntries(ctx context.Context, collection, key string, n int64, reverse bool) ([]OrderBookEntry, error) {
+	var zs []redis.Z
+	var err error
+	if reverse {
+		zs, err = idx.Redis.ZRevRangeWithScores(ctx, key, 0, n-1).Result()
+	} else {
+		zs, err = idx.Redis.ZRangeWithScores(ctx, key, 0, n-1).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(zs) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, 0, len(zs))
+	for _, z := range zs {
+		ids = append(ids, fmt.Sprint(z.Member))
+	}
+	prices, err := idx.Redis.HMGet(ctx, priceKey(collection), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]OrderBookEntry, 0, len(zs))
+	for i, z := range zs {
+		price := decimal.NewFromFloat(z.Score)
+		if v, ok := prices[i].(string); ok {
+			if exact, err := decimal.NewFromString(v); err == nil {
+				price = exact
+			}
+		}
+		entries = append(entries, OrderBookEntry{OrderID: ids[i], Price: price})
+	}
+	return entries, nil
+}
+
+// Rebuild 从 MySQL 重建合集索引：先写入临时 key，再在事务中 RENAME 替换并删除已无订单的旧 key，
+// 重建期间读取方始终看到完整的旧索引或新索引
+func (idx *OrderBookIndex) Rebuild(ctx context.Context, collection string) error {
+	orders, err := idx.Dao.ListOrdersByCollection(collection)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	sets := map[string][]*redis.Z{}
+	prices := map[string]interface{}{}
+	for i := range orders {
+		order := &orders[i]
+		price, ok, err := idx.indexPrice(ctx, order, now)
+		if err != nil {
+			log.Printf("[orderbook] 订单写入索引失败: orderId=%s, err=%v", order.OrderID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		for _, key := range orderKeys(order) {
+			sets[key] = append(sets[key], &redis.Z{Score: price.InexactFloat64(), Member: order.OrderID})
+		}
+		prices[order.OrderID] = price.String()
+	}
+
+	// 现有的全部索引 key（含 token 级别）
+	existing := []string{askKey(collection), collectionBidKey(collection), priceKey(collection)}
+	for _, pattern := range []string{tokenAskKey(collection, "*"), itemBidKey(collection, "*")} {
+		iter := idx.Redis.Scan(ctx, 0, pattern, 500).Iterator()
+		for iter.Next(ctx) {
+			existing = append(existing, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+	}
+
+	tmpPrefix := fmt.Sprintf("%s%d:", orderBookTmpPrefix, time.Now().UnixNano())
+	build := idx.Redis.Pipeline()
+	for key, zs := range sets {
+		build.ZAdd(ctx, tmpPrefix+key, zs...)
+		build.Expire(ctx, tmpPrefix+key, orderBookTmpTTL)
+	}
+	if len(prices) > 0 {
+		build.HSet(ctx, tmpPrefix+priceKey(collection), prices)
+		build.Expire(ctx, tmpPrefix+priceKey(collection), orderBookTmpTTL)
+	}
+	if _, err := build.Exec(ctx); err != nil {
+		return err
+	}
+
+	swap := idx.Redis.TxPipeline()
+	for _, key := range existing {
+		if _, ok := sets[key]; !ok && (key != priceKey(collection) || len(prices) == 0) {
+			swap.Del(ctx, key)
+		}
+	}
+	renamed := make([]string, 0, len(sets)+1)
+	for key := range sets {
+		renamed = append(renamed, key)
+	}
+	if len(prices) > 0 {
+		renamed = append(renamed, priceKey(collection))
+	}
+	for _, key := range renamed {
+		swap.Rename(ctx, tmpPrefix+key, key)
+		swap.Persist(ctx, key) // RENAME 会带上临时 key 的过期时间
+	}
+	if _, err := swap.Exec(ctx); err != nil {
+		return err
+	}
+	log.Printf("[orderbook] 索引已重建: collection=%s, orders=%d", collection, len(prices))
+	return nil
+}
+
+// RebuildAll 启动时重建所有存在有效订单的合集
+func (idx *OrderBookIndex) RebuildAll(ctx context.Context) {
+	collections, err := idx.Dao.ListCollectionsWithActiveOrders()
+	if err != nil {
+		log.Printf("[orderbook] 查询合集失败: %v", err)
+		return
+	}
+	for _, collection := range collections {
+		if err := idx.Rebuild(ctx, collection); err != nil {
+			log.Printf("[orderbook] 索引重建失败: collection=%s, err=%v", collection, err)
+		}
+	}
+}
+
+// CheckDrift 比较索引与 MySQL 中可索引订单数（口径与 Add 一致，不含属性出价、未生效及无法折算价格的订单），不一致时重建
+func (idx *OrderBookIndex) CheckDrift(ctx context.Context) {
+	collections, err := idx.Dao.ListCollectionsWithActiveOrders()
+	if err != nil {
+		log.Printf("[orderbook] 查询合集失败: %v", err)
+		return
+	}
+	for _, collection := range collections {
+		dbCount, err := idx.countIndexable(ctx, collection)
+		if err != nil {
+			log.Printf("[orderbook] 统计有效订单失败: %v", err)
+			continue
+		}
+		indexCount, err := idx.count(ctx, collection)
+		if err != nil {
+			log.Printf("[orderbook] 统计索引订单失败: %v", err)
+			continue
+		}
+		if dbCount == indexCount {
+			continue
+		}
+		log.Printf("[orderbook] 检测到索引漂移: collection=%s, db=%d, index=%d", collection, dbCount, indexCount)
+		if err := idx.Rebuild(ctx, collection); err != nil {
+			log.Printf("[orderbook] 索引重建失败: collection=%s, err=%v", collection, err)
+		}
+	}
+}
+
+// countIndexable MySQL 中会被写入索引的订单数
+func (idx *OrderBookIndex) countIndexable(ctx context.Context, collection string) (int64, error) {
+	orders, err := idx.Dao.ListOrdersByCollection(collection)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().Unix()
+	total := int64(0)
+	for i := range orders {
+		if _, ok, err := idx.indexPrice(ctx, &orders[i], now); err == nil && ok {
+			total++
+		}
+	}
+	return total, nil
+}
+
+// count 合集索引中的订单数（挂单 + 合集出价 + 单品出价）
+func (idx *OrderBookIndex) count(ctx context.Context, collection string) (int64, error) {
+	total := int64(0)
+	for _, key := range []string{askKey(collection), collectionBidKey(collection)} {
+		n, err := idx.Redis.ZCard(ctx, key).Result()
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	iter := idx.Redis.Scan(ctx, 0, itemBidKey(collection, "*"), 500).Iterator()
+	for iter.Next(ctx) {
+		n, err := idx.Redis.ZCard(ctx, iter.Val()).Result()
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, iter.Err()
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/shopspring/decimal"
+	"log"
+	"sort"
+	"time"
+)
+
+// orderBookScanLimit 深度聚合时最多读取的订单数
+const orderBookScanLimit = 1000
+
+// 订单簿数据来源
+const (
+	OrderBookSourceIndex = "index"
+	OrderBookSourceDB    = "db"
+)
+
+// PriceLevelDTO 价格档位，价格为基础币种
+type PriceLevelDTO struct {
+	Price string `json:"price"`
+	Count int    `json:"count"`
+}
+
+// OrderBookDTO 合集订单簿深度，卖盘按价格升序，买盘（合集出价）按价格降序
+type OrderBookDTO struct {
+	Collection string          `json:"collection"`
+	Currency   string          `json:"currency"`
+	Asks       []PriceLevelDTO `json:"asks"`
+	Bids       []PriceLevelDTO `json:"bids"`
+	Source     string          `json:"source"`
+}
+
+// BookOrderDTO 订单及其折算为基础币种的价格
+type BookOrderDTO struct {
+	Order     *OrderDTO `json:"order"`
+	PriceBase string    `json:"price_base"`
+}
+
+// BestOfferDTO 单个 token 的最高出价，Best 为单品出价与合集出价中较高者
+type BestOfferDTO struct {
+	Best          *BookOrderDTO `json:"best,omitempty"`
+	ItemBid       *BookOrderDTO `json:"item_bid,omitempty"`
+	CollectionBid *BookOrderDTO `json:"collection_bid,omitempty"`
+	Currency      string        `json:"currency"`
+	Source        string        `json:"source"`
+}
+
+// ListingDTO 单个 token 的最低有效挂单
+type ListingDTO struct {
+	Listing  *BookOrderDTO `json:"listing,omitempty"`
+	Currency string        `json:"currency"`
+	Source   string        `json:"source"`
+}
+
+// pricedOrder 已折算为基础币种的订单
+type pricedOrder struct {
+	order *dao.Order
+	price decimal.Decimal
+}
+
+// GetOrderBook 查询合集订单簿深度，levels 为每侧最多返回的档位数
+func (s *Service) GetOrderBook(ctx context.Context, collection string, levels int) (*OrderBookDTO, error) {
+	book := &OrderBookDTO{Collection: collection, Currency: s.Currencies.PriceFeed.BaseCurrency()}
+	asks, askErr := s.OrderBook.Asks(ctx, collection, orderBookScanLimit)
+	bids, bidErr := s.OrderBook.Bids(ctx, collection, orderBookScanLimit)
+	if askErr == nil && bidErr == nil {
+		book.Asks = entryLevels(asks, levels)
+		book.Bids = entryLevels(bids, levels)
+		book.Source = OrderBookSourceIndex
+		return book, nil
+	}
+	log.Printf("[orderbook] 订单簿索引查询失败，回退MySQL: asks=%v, bids=%v", askErr, bidErr)
+
+	orders, err := s.Dao.ListOrdersByCollection(collection)
+	if err != nil {
+		return nil, err
+	}
+	var askOrders, bidOrders []dao.Order
+	for _, order := range orders {
+		switch order.OrderType {
+		case dao.OrderTypeCollectionBid:
+			if order.Criteria == "" {
+				bidOrders = append(bidOrders, order)
+			}
+		case dao.OrderTypeItemBid:
+		default:
+			askOrders = append(askOrders, order)
+		}
+	}
+	book.Asks = pricedLevels(s.priceOrders(ctx, askOrders, false), levels)
+	book.Bids = pricedLevels(s.priceOrders(ctx, bidOrders, true), levels)
+	book.Source = OrderBookSourceDB
+	return book, nil
+}
+
+// GetBestOffer 查询单个 token 的最高出价，合集出价同样可成交该 token
+func (s *Service) GetBestOffer(ctx context.Context, collection, tokenID string) (*BestOfferDTO, error) {
+	res := &BestOfferDTO{Currency: s.Currencies.PriceFeed.BaseCurrency(), Source: OrderBookSourceIndex}
+	itemBid, itemOK := s.indexedBest(ctx, func() ([]OrderBookEntry, error) {
+		return s.OrderBook.ItemBids(ctx, collection, tokenID, 10)
+	})
+	collectionBid, collectionOK := s.indexedBest(ctx, func() ([]OrderBookEntry, error) {
+		return s.OrderBook.Bids(ctx, collection, 10)
+	})
+	if !itemOK || !collectionOK {
+		orders, err := s.Dao.ListActiveBidsByToken(collection, tokenID)
+		if err != nil {
+			return nil, err
+		}
+		var itemBids, collectionBids []dao.Order
+		for _, order := range orders {
+			if order.OrderType == dao.OrderTypeItemBid {
+				itemBids = append(itemBids, order)
+			} else {
+				collectionBids = append(collectionBids, order)
+			}
+		}
+		itemBid = firstPriced(s.priceOrders(ctx, itemBids, true))
+		collectionBid = firstPriced(s.priceOrders(ctx, collectionBids, true))
+		res.Source = OrderBookSourceDB
+	}
+	res.ItemBid = s.toBookOrderDTO(itemBid)
+	res.CollectionBid = s.toBookOrderDTO(collectionBid)
+	res.Best = res.ItemBid
+	if collectionBid != nil && (itemBid == nil || collectionBid.price.GreaterThan(itemBid.price)) {
+		res.Best = res.CollectionBid
+	}
+	return res, nil
+}
+
+// GetCheapestListing 查询单个 token 当前最低的有效挂单
+func (s *Service) GetCheapestListing(ctx context.Context, collection, tokenID string) (*ListingDTO, error) {
+	res := &ListingDTO{Currency: s.Currencies.PriceFeed.BaseCurrency(), Source: OrderBookSourceIndex}
+	listing, ok := s.indexedBest(ctx, func() ([]OrderBookEntry, error) {
+		return s.OrderBook.TokenListings(ctx, collection, tokenID, 10)
+	})
+	if !ok {
+		orders, err := s.Dao.ListActiveListingsByToken(collection, tokenID)
+		if err != nil {
+			return nil, err
+		}
+		listing = firstPriced(s.priceOrders(ctx, orders, false))
+		res.Source = OrderBookSourceDB
+	}
+	res.Listing = s.toBookOrderDTO(listing)
+	return res, nil
+}
+
+// indexedBest 按索引顺序返回第一个仍然有效的订单
+// 索引查询失败，或索引中的订单均已失效（索引滞后）时返回 false，由调用方回退 MySQL
+func (s *Service) indexedBest(ctx context.Context, query func() ([]OrderBookEntry, error)) (*pricedOrder, bool) {
+	entries, err := query()
+	if err != nil {
+		log.Printf("[orderbook] 订单簿索引查询失败，回退MySQL: %v", err)
+		return nil, false
+	}
+	if len(entries) == 0 {
+		return nil, true
+	}
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.OrderID)
+	}
+	orders, err := s.Dao.GetOrdersByOrderIDs(ids)
+	if err != nil {
+		log.Printf("[orderbook] 查询订单失败，回退MySQL: %v", err)
+		return nil, false
+	}
+	byID := make(map[string]*dao.Order, len(orders))
+	for i := range orders {
+		byID[orders[i].OrderID] = &orders[i]
+	}
+	now := time.Now().Unix()
+	for _, e := range entries {
+		if order, ok := byID[e.OrderID]; ok && isIndexable(order, now) {
+			return &pricedOrder{order: order, price: e.Price}, true
+		}
+	}
+	return nil, false
+}
+
+// priceOrders 订单价格折算为基础币种并排序，desc 为 true 时价格降序；折算失败的订单跳过
+func (s *Service) priceOrders(ctx context.Context, orders []dao.Order, desc bool) []pricedOrder {
+	priced := make([]pricedOrder, 0, len(orders))
+	for i := range orders {
+		price, err := s.Currencies.ToBase(ctx, orders[i].Currency, orders[i].Price, orders[i].PriceScaled)
+		if err != nil {
+			log.Printf("[orderbook] 订单价格折算失败: orderId=%s, err=%v", orders[i].OrderID, err)
+			continue
+		}
+		priced = append(priced, pricedOrder{order: &orders[i], price: price})
+	}
+	sort.SliceStable(priced, func(i, j int) bool {
+		if desc {
+			return priced[i].price.GreaterThan(priced[j].price)
+		}
+		return priced[i].price.LessThan(priced[j].price)
+	})
+	return priced
+}
+
+func firstPriced(priced []pricedOrder) *pricedOrder {
+	if len(priced) == 0 {
+		return nil
+	}
+	return &priced[0]
+}
+
+func (s *Service) toBookOrderDTO(p *pricedOrder) *BookOrderDTO {
+	if p == nil {
+		return nil
+	}
+	return &BookOrderDTO{Order: s.ToOrderDTO(p.order), PriceBase: p.price.String()}
+}
+
+// entryLevels 已排序的索引条目按价格聚合为档位
+func entryLevels(entries []OrderBookEntry, levels int) []PriceLevelDTO {
+	prices := make([]decimal.Decimal, 0, len(entries))
+	for _, e := range entries {
+		prices = append(prices, e.Price)
+	}
+	return aggregateLevels(prices, levels)
+}
+
+// pricedLevels 已排序的订单按价格聚合为档位
+func pricedLevels(priced []pricedOrder, levels int) []PriceLevelDTO {
+	prices := make([]decimal.Decimal, 0, len(priced))
+	for _, p := range priced {
+		prices = append(prices, p.price)
+	}
+	return aggregateLevels(prices, levels)
+}
+
+// aggregateLevels 相同价格合并为一个档位，最多返回 levels 个档位
+func aggregateLevels(prices []decimal.Decimal, levels int) []PriceLevelDTO {
+	res := []PriceLevelDTO{}
+	var last decimal.Decimal
+	for i, price := range prices {
+		if i > 0 && price.Equal(last) {
+			res[len(res)-1].Count++
+			continue
+		}
+		if len(res) >= levels {
+			break
+		}
+		res = append(res, PriceLevelDTO{Price: price.String(), Count: 1})
+		last = price
+	}
+	return res
+}
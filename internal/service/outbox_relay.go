@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gavin/nftSync/internal/bus"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/events"
+	"github.com/go-redis/redis/v8"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	outboxLockKey      = "nftsync:outbox:relay"
+	outboxCleanupEvery = time.Hour
+	outboxMaxErrorLen  = 512
+)
+
+// ErrOutboxLeaseLost 中继租约已被其他实例持有
+var ErrOutboxLeaseLost = errors.New("中继租约已丢失")
+
+// outboxLeaseScript 获取或续期租约：key 不存在时设置，属于当前实例时续期，原子执行
+// KEYS: 租约 key；ARGV: 实例标识、租约时长（毫秒）
+var outboxLeaseScript = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if not owner then
+  redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+  return 1
+end
+if owner == ARGV[1] then
+  redis.call('PEXPIRE', KEYS[1], ARGV[2])
+  return 1
+end
+return 0
+`)
+
+// OutboxStore 中继读写发件箱所需的存储操作，由 *dao.Dao 实现
+type OutboxStore interface {
+	ListPendingOutbox(limit int) ([]dao.OutboxMessage, error)
+	MarkOutboxPublished(ids []int64, publishedAt int64) error
+	MarkOutboxFailed(id int64, errMsg string) error
+	DeletePublishedOutbox(before int64) (int64, error)
+}
+
+// OutboxRelay 将发件箱消息发布到消息总线，至少一次语义：
+// 发布成功后才标记，标记失败时下一轮重发，消费方按合集重算地板价，重复消息无副作用。
+// 同一 key 的消息按 ID 顺序发布，某条失败时本轮跳过该 key 的后续消息，保证分区内有序。
+// 多实例部署时仅租约持有者发布，发布每条消息前确认租约仍有效并按需续期，单条发布不会超出租约期限；
+// Redis 为空时视为单实例部署，不加锁。
+type OutboxRelay struct {
+	Store       OutboxStore
+	Redis       *redis.Client
+	Publisher   bus.Publisher
+	FloorTopic  string // 地板价更新消息 topic
+	MatchTopic  string // 撮合结果 topic
+	NotifyTopic string // 外部事件通知 topic（notify.mq_topic）
+	Config      config.OutboxConfig
+	Events      config.EventsConfig
+
+	owner       string
+	leaseUntil  time.Time // 租约到期时间（本地时钟，已扣除安全余量）
+	renewAt     time.Time // 下次续期时间
+	lastCleanup time.Time
+}
+
+func NewOutboxRelay(ctx *config.Context) *OutboxRelay {
+	cfg := ctx.Config.Outbox
+	if cfg.Interval <= 0 {
+		cfg.Interval = 500
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = 30
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 86400
+	}
+	events := ctx.Config.Events
+	if events.ChainID == 0 {
+		events.ChainID = ctx.Config.OrderSigning.ChainID
+	}
+	host, _ := os.Hostname()
+	return &OutboxRelay{
+		Store:       dao.New(ctx.Db),
+		Redis:       ctx.Redis,
+		Publisher:   ctx.Publisher,
+		FloorTopic:  ctx.Config.FloorPriceKafka.Topic,
+		MatchTopic:  ctx.Config.Matching.Topic,
+		NotifyTopic: ctx.Config.Notify.MQTopic,
+		Config:      cfg,
+		Events:      events,
+		owner:       fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano()),
+	}
+}
+
+// Run 持有租约时发布一轮并定期清理已发布消息
+func (r *OutboxRelay) Run(ctx context.Context) {
+	if err := r.ensureLease(ctx); err != nil {
+		if !errors.Is(err, ErrOutboxLeaseLost) {
+			log.Printf("[outbox] 获取中继租约失败: %v", err)
+		}
+		return
+	}
+	for {
+		n, err := r.RelayOnce(ctx)
+		if err != nil {
+			log.Printf("[outbox] 消息发布失败: %v", err)
+			break
+		}
+		// 本轮未取满说明已追平
+		if n < r.Config.BatchSize {
+			break
+		}
+	}
+	if time.Since(r.lastCleanup) >= outboxCleanupEvery {
+		r.lastCleanup = time.Now()
+		before := time.Now().Unix() - r.Config.Retention
+		if n, err := r.Store.DeletePublishedOutbox(before); err != nil {
+			log.Printf("[outbox] 已发布消息清理失败: %v", err)
+		} else if n > 0 {
+			log.Printf("[outbox] 已清理发布消息: %d", n)
+		}
+	}
+}
+
+// ensureLease 确认仍持有中继租约，距上次续期超过租约时长的 1/3 时原子续期；
+// 租约被其他实例持有时返回 ErrOutboxLeaseLost
+func (r *OutboxRelay) ensureLease(ctx context.Context) error {
+	if r.Redis == nil {
+		return nil
+	}
+	now := time.Now()
+	if now.Before(r.renewAt) {
+		return nil
+	}
+	ttl := time.Duration(r.Config.LockTTL) * time.Second
+	ok, err := outboxLeaseScript.Run(ctx, r.Redis, []string{outboxLockKey}, r.owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		r.leaseUntil, r.renewAt = time.Time{}, time.Time{}
+		return err
+	}
+	if ok != 1 {
+		r.leaseUntil, r.renewAt = time.Time{}, time.Time{}
+		return ErrOutboxLeaseLost
+	}
+	// 预留 1/3 租约时长作为时钟与网络延迟余量
+	r.leaseUntil = now.Add(ttl * 2 / 3)
+	r.renewAt = now.Add(ttl / 3)
+	return nil
+}
+
+// publishWithinLease 在租约有效期内发布单条消息，超时视为发布失败
+func (r *OutboxRelay) publishWithinLease(ctx context.Context, m *dao.OutboxMessage) error {
+	if r.Redis == nil {
+		return r.publish(ctx, m)
+	}
+	pubCtx, cancel := context.WithDeadline(ctx, r.leaseUntil)
+	defer cancel()
+	return r.publish(pubCtx, m)
+}
+
+// RelayOnce 按 ID 顺序发布一批待发布消息，返回本批读取条数
+func (r *OutboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	msgs, err := r.Store.ListPendingOutbox(r.Config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	blocked := map[string]bool{} // 本轮发布失败的 key
+	published := make([]int64, 0, len(msgs))
+	var leaseErr error
+	for i := range msgs {
+		m := &msgs[i]
+		blockKey := m.Kind + ":" + m.MsgKey
+		if blocked[blockKey] {
+			continue
+		}
+		// 租约丢失后不再发布，已发布的消息仍需标记
+		if leaseErr = r.ensureLease(ctx); leaseErr != nil {
+			break
+		}
+		if err := r.publishWithinLease(ctx, m); err != nil {
+			blocked[blockKey] = true
+			r.markFailed(m, err)
+			continue
+		}
+		published = append(published, m.ID)
+	}
+	if err := r.Store.MarkOutboxPublished(published, time.Now().Unix()); err != nil {
+		return len(msgs), err
+	}
+	if leaseErr != nil {
+		return len(msgs), fmt.Errorf("中继租约校验失败: %w", leaseErr)
+	}
+	if len(blocked) > 0 {
+		// 存在失败的 key 时不继续追赶，等待下一轮重试
+		return len(msgs), fmt.Errorf("%d 个 key 发布失败，下轮重试", len(blocked))
+	}
+	return len(msgs), nil
+}
+
+// publish 发布单条消息，领域事件按配置的编码与 topic 发布
+func (r *OutboxRelay) publish(ctx context.Context, m *dao.OutboxMessage) error {
+	switch m.Kind {
+	case dao.OutboxKindFloorPrice:
+		return r.Publisher.Publish(ctx, &bus.Message{Topic: r.FloorTopic, Key: m.MsgKey, Value: []byte(m.Payload), Headers: m.HeaderMap()})
+	case dao.OutboxKindMatch:
+		return r.Publisher.Publish(ctx, &bus.Message{Topic: r.MatchTopic, Key: m.MsgKey, Value: []byte(m.Payload), Headers: m.HeaderMap()})
+	case dao.OutboxKindNotify:
+		return r.Publisher.Publish(ctx, &bus.Message{Topic: r.NotifyTopic, Key: m.MsgKey, Value: []byte(m.Payload), Headers: m.HeaderMap()})
+	case dao.OutboxKindEvent:
+	default:
+		return fmt.Errorf("未知的消息类别 %s", m.Kind)
+	}
+	e, err := events.Decode([]byte(m.Payload), events.ContentTypeJSON)
+	if err != nil {
+		return err
+	}
+	e.ChainID = r.Events.ChainID
+	data, contentType, err := events.Encode(e, r.Events.Encoding)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{
+		events.HeaderContentType:   contentType,
+		events.HeaderEventType:     e.Type,
+		events.HeaderSchemaVersion: strconv.Itoa(e.Version),
+	}
+	return r.Publisher.Publish(ctx, &bus.Message{Topic: events.Topic(r.Events.Topics, e.Type), Key: m.MsgKey, Value: data, Headers: headers})
+}
+
+func (r *OutboxRelay) markFailed(m *dao.OutboxMessage, err error) {
+	msg := err.Error()
+	if len(msg) > outboxMaxErrorLen {
+		msg = msg[:outboxMaxErrorLen]
+	}
+	if err := r.Store.MarkOutboxFailed(m.ID, msg); err != nil {
+		log.Printf("[outbox] 失败记录更新失败: id=%d, err=%v", m.ID, err)
+	}
+}
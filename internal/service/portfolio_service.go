@@ -0,0 +1,400 @@
+package service
+
+import (
+	"context"
+	"github.com/gavin/nftSync/internal/blockchain/marketplace"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/shopspring/decimal"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+// 持仓取得方式
+const (
+	AcquiredViaMint     = "mint"
+	AcquiredViaPurchase = "purchase"
+	AcquiredViaTransfer = "transfer" // 无成交的转入（赠送、跨钱包转移）
+	AcquiredViaUnknown  = "unknown"  // 转移记录缺失，成本未知
+)
+
+const (
+	defaultPortfolioDays = 30
+	maxPortfolioDays     = 365
+	daySeconds           = 24 * 60 * 60
+)
+
+// PortfolioTokenDTO 持仓 token，金额为基础币种
+type PortfolioTokenDTO struct {
+	TokenID        string `json:"token_id"`
+	AcquiredVia    string `json:"acquired_via"`
+	AcquiredAt     int64  `json:"acquired_at,omitempty"`
+	CostBasis      string `json:"cost_basis,omitempty"` // 成本未知时为空
+	LastSalePrice  string `json:"last_sale_price,omitempty"`
+	FloorValue     string `json:"floor_value"`
+	UnrealizedPnL  string `json:"unrealized_pnl,omitempty"` // 按地板价估值，成本未知时为空
+	UnrealizedLast string `json:"unrealized_pnl_last_sale,omitempty"`
+}
+
+// SoldTokenDTO 已卖出 token 的已实现盈亏
+type SoldTokenDTO struct {
+	TokenID     string `json:"token_id"`
+	CostBasis   string `json:"cost_basis,omitempty"`
+	Proceeds    string `json:"proceeds"`               // 成交价无法折算时为空
+	RealizedPnL string `json:"realized_pnl,omitempty"` // 成本或卖出所得未知时为空
+	SoldAt      int64  `json:"sold_at"`
+	TxHash      string `json:"tx_hash"`
+}
+
+// PortfolioCollectionDTO 按合集汇总的持仓
+type PortfolioCollectionDTO struct {
+	Collection    string              `json:"collection"`
+	Tokens        int                 `json:"tokens"`
+	FloorPrice    string              `json:"floor_price"`
+	FloorValue    string              `json:"floor_value"`
+	LastSaleValue string              `json:"last_sale_value"` // 无成交记录的 token 不计入
+	CostBasis     string              `json:"cost_basis"`      // 仅统计成本已知的 token
+	UnrealizedPnL string              `json:"unrealized_pnl"`  // 成本已知 token 的地板价估值减成本
+	RealizedPnL   string              `json:"realized_pnl"`
+	Holdings      []PortfolioTokenDTO `json:"holdings"`
+	Sold          []SoldTokenDTO      `json:"sold,omitempty"`
+}
+
+// PortfolioPointDTO 每日持仓价值（当日结束时的持仓按当时地板价估值）
+type PortfolioPointDTO struct {
+	Date      string `json:"date"`
+	Timestamp int64  `json:"timestamp"`
+	Tokens    int    `json:"tokens"`
+	Value     string `json:"value"`
+}
+
+// PortfolioDTO 钱包持仓估值与盈亏
+type PortfolioDTO struct {
+	Address       string                   `json:"address"`
+	Currency      string                   `json:"currency"`
+	Tokens        int                      `json:"tokens"`
+	FloorValue    string                   `json:"floor_value"`
+	LastSaleValue string                   `json:"last_sale_value"`
+	CostBasis     string                   `json:"cost_basis"`
+	UnrealizedPnL string                   `json:"unrealized_pnl"`
+	RealizedPnL   string                   `json:"realized_pnl"`
+	Collections   []PortfolioCollectionDTO `json:"collections"`
+	Series        []PortfolioPointDTO      `json:"series"`
+}
+
+// holdingLot 单个 token 的一段持有
+type holdingLot struct {
+	collection string // 原始地址，用于查询
+	tokenID    string
+	via        string
+	acquiredAt int64
+	disposedAt int64 // 仍持有时为 math.MaxInt64
+	cost       decimal.Decimal
+	costKnown  bool
+}
+
+// portfolioTotals 金额累计
+type portfolioTotals struct {
+	floorValue, lastSaleValue, cost, unrealized, realized decimal.Decimal
+}
+
+func (t *portfolioTotals) add(o portfolioTotals) {
+	t.floorValue = t.floorValue.Add(o.floorValue)
+	t.lastSaleValue = t.lastSaleValue.Add(o.lastSaleValue)
+	t.cost = t.cost.Add(o.cost)
+	t.unrealized = t.unrealized.Add(o.unrealized)
+	t.realized = t.realized.Add(o.realized)
+}
+
+func tokenKey(collection, tokenID string) string {
+	return normalizeAddr(collection) + ":" + tokenID
+}
+
+func tradeKey(txHash, collection, tokenID string) string {
+	return txHash + ":" + tokenKey(collection, tokenID)
+}
+
+// GetPortfolio 钱包持仓估值与盈亏
+// 成本按单个 token 逐笔认定：买入成交价为成本，铸造与无成交转入成本为 0；卖出成交计入已实现盈亏，赠出不计盈亏
+// 成交手续费与版税未计入成本
+func (s *Service) GetPortfolio(ctx context.Context, address string, days int) (*PortfolioDTO, error) {
+	if days <= 0 {
+		days = defaultPortfolioDays
+	}
+	if days > maxPortfolioDays {
+		days = maxPortfolioDays
+	}
+	wallet := normalizeAddr(address)
+	nfts, err := s.Dao.GetNFTListByOwner(wallet)
+	if err != nil {
+		return nil, err
+	}
+	transfers, err := s.Dao.ListTransfersByWallet(wallet)
+	if err != nil {
+		return nil, err
+	}
+	trades, err := s.Dao.ListTradesByWallet(wallet)
+	if err != nil {
+		return nil, err
+	}
+	// 无法折算的成交（如未定价的 ERC20）仍视为买卖，但成本或卖出所得未知，不计入盈亏
+	buys, sells := map[string]decimal.NullDecimal{}, map[string]decimal.NullDecimal{}
+	for i := range trades {
+		var price decimal.NullDecimal
+		if base, err := s.Currencies.ToBase(ctx, trades[i].Currency, trades[i].Price, trades[i].PriceScaled); err == nil {
+			price = decimal.NewNullDecimal(base)
+		} else {
+			log.Printf("[portfolio] 成交价格折算失败: tx=%s, currency=%s, err=%v", trades[i].TxHash, trades[i].Currency, err)
+		}
+		key := tradeKey(trades[i].TxHash, trades[i].Collection, trades[i].TokenID)
+		if normalizeAddr(trades[i].Buyer) == wallet {
+			buys[key] = price
+		}
+		if normalizeAddr(trades[i].Seller) == wallet {
+			sells[key] = price
+		}
+	}
+
+	// 按转移记录重建持有区间
+	open := map[string]*holdingLot{}
+	lots := []*holdingLot{}
+	sold := map[string][]SoldTokenDTO{} // 合集 -> 卖出记录
+	realized := map[string]decimal.Decimal{}
+	for _, t := range transfers {
+		from, to := normalizeAddr(t.From), normalizeAddr(t.To)
+		if from == to {
+			continue
+		}
+		key := tokenKey(t.Collection, t.TokenID)
+		txKey := tradeKey(t.TxHash, t.Collection, t.TokenID)
+		if to == wallet {
+			lot := &holdingLot{collection: t.Collection, tokenID: t.TokenID, via: AcquiredViaTransfer,
+				acquiredAt: t.BlockTime, disposedAt: math.MaxInt64, costKnown: true}
+			if from == normalizeAddr(marketplace.ZeroAddress) {
+				lot.via = AcquiredViaMint
+			}
+			if price, ok := buys[txKey]; ok {
+				lot.via, lot.cost, lot.costKnown = AcquiredViaPurchase, price.Decimal, price.Valid
+			}
+			open[key] = lot
+			lots = append(lots, lot)
+			continue
+		}
+		lot := open[key]
+		delete(open, key)
+		if lot != nil {
+			lot.disposedAt = t.BlockTime
+		}
+		proceeds, ok := sells[txKey]
+		if !ok {
+			continue
+		}
+		coll := normalizeAddr(t.Collection)
+		item := SoldTokenDTO{TokenID: t.TokenID, SoldAt: t.BlockTime, TxHash: t.TxHash}
+		if proceeds.Valid {
+			item.Proceeds = proceeds.Decimal.String()
+		}
+		if lot != nil && lot.costKnown && proceeds.Valid {
+			pnl := proceeds.Decimal.Sub(lot.cost)
+			item.CostBasis, item.RealizedPnL = lot.cost.String(), pnl.String()
+			realized[coll] = realized[coll].Add(pnl)
+		}
+		sold[coll] = append(sold[coll], item)
+	}
+
+	// 当前持仓以 NFT 表为准，缺少转移记录的 token 视为一直持有、成本未知
+	holdings := map[string][]*holdingLot{}
+	rawCollections := map[string]string{}
+	for i := range nfts {
+		key := tokenKey(nfts[i].Contract, nfts[i].TokenID)
+		lot := open[key]
+		if lot == nil {
+			lot = &holdingLot{collection: nfts[i].Contract, tokenID: nfts[i].TokenID, via: AcquiredViaUnknown, disposedAt: math.MaxInt64}
+			lots = append(lots, lot)
+		}
+		coll := normalizeAddr(nfts[i].Contract)
+		holdings[coll] = append(holdings[coll], lot)
+		rawCollections[coll] = nfts[i].Contract
+	}
+	for _, lot := range lots {
+		if _, ok := rawCollections[normalizeAddr(lot.collection)]; !ok {
+			rawCollections[normalizeAddr(lot.collection)] = lot.collection
+		}
+	}
+
+	floors, err := s.currentFloors(rawCollections)
+	if err != nil {
+		return nil, err
+	}
+	res := &PortfolioDTO{Address: wallet, Currency: s.Currencies.PriceFeed.BaseCurrency()}
+	var total portfolioTotals
+	for coll, raw := range rawCollections {
+		if len(holdings[coll]) == 0 && len(sold[coll]) == 0 {
+			continue
+		}
+		dto, totals, err := s.portfolioCollection(ctx, raw, holdings[coll], floors[coll])
+		if err != nil {
+			return nil, err
+		}
+		totals.realized = realized[coll]
+		dto.RealizedPnL = totals.realized.String()
+		dto.Sold = sold[coll]
+		total.add(totals)
+		res.Tokens += dto.Tokens
+		res.Collections = append(res.Collections, *dto)
+	}
+	sort.Slice(res.Collections, func(i, j int) bool {
+		return res.Collections[i].Tokens > res.Collections[j].Tokens
+	})
+	res.FloorValue = total.floorValue.String()
+	res.LastSaleValue = total.lastSaleValue.String()
+	res.CostBasis = total.cost.String()
+	res.UnrealizedPnL = total.unrealized.String()
+	res.RealizedPnL = total.realized.String()
+	res.Series, err = s.portfolioSeries(lots, rawCollections, days)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// currentFloors 查询合集当前地板价，合集地址 -> 地板价
+func (s *Service) currentFloors(rawCollections map[string]string) (map[string]decimal.Decimal, error) {
+	floors := map[string]decimal.Decimal{}
+	if len(rawCollections) == 0 {
+		return floors, nil
+	}
+	raws := make([]string, 0, len(rawCollections))
+	for _, raw := range rawCollections {
+		raws = append(raws, raw)
+	}
+	list, err := s.Dao.ListFloorPrices(raws)
+	if err != nil {
+		return nil, err
+	}
+	for _, fp := range list {
+		if price, err := decimal.NewFromString(fp.Price); err == nil {
+			floors[normalizeAddr(fp.Collection)] = price
+		}
+	}
+	return floors, nil
+}
+
+// portfolioCollection 单个合集的持仓估值
+func (s *Service) portfolioCollection(ctx context.Context, collection string, lots []*holdingLot, floor decimal.Decimal) (*PortfolioCollectionDTO, portfolioTotals, error) {
+	var totals portfolioTotals
+	dto := &PortfolioCollectionDTO{
+		Collection: normalizeAddr(collection),
+		Tokens:     len(lots),
+		FloorPrice: floor.String(),
+		Holdings:   make([]PortfolioTokenDTO, 0, len(lots)),
+	}
+	lastSales := map[string]decimal.NullDecimal{}
+	if len(lots) > 0 {
+		tokenIDs := make([]string, 0, len(lots))
+		for _, lot := range lots {
+			tokenIDs = append(tokenIDs, lot.tokenID)
+		}
+		trades, err := s.Dao.ListTradesByTokens(collection, tokenIDs)
+		if err != nil {
+			return nil, totals, err
+		}
+		for i := range trades {
+			if _, ok := lastSales[trades[i].TokenID]; ok {
+				continue
+			}
+			// 最近成交无法折算时最近成交价视为未知，不回退到更早的成交
+			var price decimal.NullDecimal
+			if base, err := s.Currencies.ToBase(ctx, trades[i].Currency, trades[i].Price, trades[i].PriceScaled); err == nil {
+				price = decimal.NewNullDecimal(base)
+			} else {
+				log.Printf("[portfolio] 成交价格折算失败: tx=%s, currency=%s, err=%v", trades[i].TxHash, trades[i].Currency, err)
+			}
+			lastSales[trades[i].TokenID] = price
+		}
+	}
+	for _, lot := range lots {
+		item := PortfolioTokenDTO{TokenID: lot.tokenID, AcquiredVia: lot.via, AcquiredAt: lot.acquiredAt, FloorValue: floor.String()}
+		totals.floorValue = totals.floorValue.Add(floor)
+		lastSale := lastSales[lot.tokenID]
+		last, hasLast := lastSale.Decimal, lastSale.Valid
+		if hasLast {
+			item.LastSalePrice = last.String()
+			totals.lastSaleValue = totals.lastSaleValue.Add(last)
+		}
+		if lot.costKnown {
+			item.CostBasis = lot.cost.String()
+			item.UnrealizedPnL = floor.Sub(lot.cost).String()
+			totals.cost = totals.cost.Add(lot.cost)
+			totals.unrealized = totals.unrealized.Add(floor.Sub(lot.cost))
+			if hasLast {
+				item.UnrealizedLast = last.Sub(lot.cost).String()
+			}
+		}
+		dto.Holdings = append(dto.Holdings, item)
+	}
+	dto.FloorValue = totals.floorValue.String()
+	dto.LastSaleValue = totals.lastSaleValue.String()
+	dto.CostBasis = totals.cost.String()
+	dto.UnrealizedPnL = totals.unrealized.String()
+	return dto, totals, nil
+}
+
+// floorSeries 合集地板价变动，按时间正序
+type floorSeries []dao.FloorPriceHistory
+
+// at 时间点生效的地板价
+func (f floorSeries) at(ts int64) decimal.Decimal {
+	i := sort.Search(len(f), func(i int) bool { return f[i].Timestamp > ts })
+	if i == 0 {
+		return decimal.Zero
+	}
+	price, err := decimal.NewFromString(f[i-1].Price)
+	if err != nil {
+		return decimal.Zero
+	}
+	return price
+}
+
+// portfolioSeries 最近 days 天每日结束时的持仓价值，按当时地板价估值，最后一个点为当前时间
+func (s *Service) portfolioSeries(lots []*holdingLot, rawCollections map[string]string, days int) ([]PortfolioPointDTO, error) {
+	now := time.Now().Unix()
+	todayStart := now / daySeconds * daySeconds
+	start := todayStart - int64(days-1)*daySeconds
+	floors := make(map[string]floorSeries, len(rawCollections))
+	for coll, raw := range rawCollections {
+		prev, err := s.Dao.GetFloorPriceAt(raw, start)
+		if err != nil {
+			return nil, err
+		}
+		series := floorSeries{}
+		if prev != nil {
+			series = append(series, *prev)
+		}
+		history, err := s.Dao.ListFloorPriceHistory(raw, start, now+1, 100000)
+		if err != nil {
+			return nil, err
+		}
+		floors[coll] = append(series, history...)
+	}
+	points := make([]PortfolioPointDTO, 0, days)
+	for day := start; day <= todayStart; day += daySeconds {
+		ts := day + daySeconds - 1
+		if ts > now {
+			ts = now
+		}
+		point := PortfolioPointDTO{Date: time.Unix(day, 0).UTC().Format("2006-01-02"), Timestamp: ts}
+		value := decimal.Zero
+		for _, lot := range lots {
+			if lot.acquiredAt > ts || lot.disposedAt <= ts {
+				continue
+			}
+			point.Tokens++
+			value = value.Add(floors[normalizeAddr(lot.collection)].at(ts))
+		}
+		point.Value = value.String()
+		points = append(points, point)
+	}
+	return points, nil
+}
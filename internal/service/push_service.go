@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/push"
+	"log"
+	"strings"
+)
+
+// NewPushBroker 创建推送事件发布方，未启用推送时返回 nil
+func NewPushBroker(ctx *config.Context) *push.Broker {
+	cfg := ctx.Config.Push
+	if !cfg.Enabled {
+		return nil
+	}
+	return push.NewBroker(ctx.Redis, cfg.Channel, cfg.HistorySize)
+}
+
+// FloorChangeDTO 地板价变化推送内容
+type FloorChangeDTO struct {
+	Collection string `json:"collection"`
+	Price      string `json:"price"` // 基础币种数值，空字符串表示合集已无挂单
+	Currency   string `json:"currency"`
+	Trigger    string `json:"trigger"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+// publishActivity 活动入库后推送，失败仅记录日志
+func publishActivity(b *push.Broker, a *dao.Activity) {
+	if b == nil {
+		return
+	}
+	data, err := json.Marshal(toActivityDTO(a))
+	if err != nil {
+		return
+	}
+	e := push.Event{
+		Type:       a.Type,
+		Collection: a.Collection,
+		TokenID:    a.TokenID,
+		Data:       data,
+	}
+	for _, w := range []string{a.From, a.To} {
+		if w != "" {
+			e.Wallets = append(e.Wallets, w)
+		}
+	}
+	if err := b.Publish(context.Background(), &e); err != nil {
+		log.Printf("[push] 活动推送失败: type=%s, tx=%s, err=%v", a.Type, a.TxHash, err)
+	}
+}
+
+// publishFloorChange 地板价变化后推送，失败仅记录日志
+func publishFloorChange(b *push.Broker, change FloorChangeDTO) {
+	if b == nil {
+		return
+	}
+	data, err := json.Marshal(change)
+	if err != nil {
+		return
+	}
+	e := push.Event{Type: push.TypeFloorChanged, Collection: change.Collection, Data: data}
+	if err := b.Publish(context.Background(), &e); err != nil {
+		log.Printf("[push] 地板价推送失败: collection=%s, err=%v", change.Collection, err)
+	}
+}
+
+// PushQuery 推送订阅条件，tokens 格式为 collection:tokenId
+type PushQuery struct {
+	Collections []string `json:"collections"`
+	Tokens      []string `json:"tokens"`
+	Wallets     []string `json:"wallets"`
+	Types       []string `json:"types"`
+}
+
+// BuildPushFilter 校验订阅条件并统一地址与 tokenId 格式
+func BuildPushFilter(q PushQuery) (*push.Filter, error) {
+	f := &push.Filter{
+		Collections: map[string]bool{},
+		Tokens:      map[string]bool{},
+		Wallets:     map[string]bool{},
+		Types:       map[string]bool{},
+	}
+	for _, c := range q.Collections {
+		f.Collections[normalizeAddr(c)] = true
+	}
+	for _, t := range q.Tokens {
+		collection, tokenID, ok := splitTokenKey(t)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidTokenID, t)
+		}
+		f.Tokens[push.TokenKey(collection, tokenID)] = true
+	}
+	for _, w := range q.Wallets {
+		f.Wallets[normalizeAddr(w)] = true
+	}
+	for _, t := range q.Types {
+		if !activityTypes[t] && t != push.TypeFloorChanged {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidActivityType, t)
+		}
+		f.Types[t] = true
+	}
+	return f, nil
+}
+
+func splitTokenKey(key string) (string, string, bool) {
+	collection, rawID, found := strings.Cut(key, ":")
+	if !found {
+		return "", "", false
+	}
+	tokenID, ok := normalizeTokenID(rawID)
+	return normalizeAddr(collection), tokenID, ok
+}
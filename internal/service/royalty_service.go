@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gavin/nftSync/internal/blockchain"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/matching"
+	"github.com/gavin/nftSync/internal/pricefeed"
+	"github.com/shopspring/decimal"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// royaltyToleranceBps 实付版税不低于应付的 99% 视为足额，容忍各市场的取整差异
+const royaltyToleranceBps = 9900
+
+// ERC-2981 版税比例缓存时长；查询失败时短暂缓存错误，避免节点异常期间每笔成交都发起调用
+const (
+	royaltyRateTTL  = time.Hour
+	royaltyRetryTTL = time.Minute
+)
+
+// royaltyRefPrice 查询版税比例使用的参考成交价，royaltyInfo 按该价格返回的金额即为比例
+var royaltyRefPrice = new(big.Int).Exp(big.NewInt(10), big.NewInt(22), nil)
+
+// 合约是否支持 ERC-2981，合约接口不可变，进程内缓存
+var erc2981Support sync.Map
+
+// 合集 ERC-2981 版税比例缓存：合集地址 -> *royaltyRate
+var erc2981Rates sync.Map
+
+// royaltyRate 按参考价查询的版税收款地址与金额，合约不支持 ERC-2981 时 unsupported 为 true
+type royaltyRate struct {
+	receiver    string
+	amount      *big.Int
+	unsupported bool
+	err         error
+	expiresAt   time.Time
+}
+
+// RoyaltyQuote 应付版税
+type RoyaltyQuote struct {
+	Receiver string
+	Amount   *big.Int
+	Source   string
+}
+
+// RoyaltyResolver 版税解析：优先 ERC-2981 royaltyInfo，合约未实现时使用版税登记
+// 登记项 override 为 true 时优先于链上配置
+// ERC-2981 按合集缓存版税比例与收款地址，同步成交时不逐笔调用 royaltyInfo；
+// 按 token 区分版税的合约以首次查询的 token 为准，缓存过期后刷新
+type RoyaltyResolver struct {
+	MultiNode *config.MultiNodeEthClient
+	Registry  map[string]config.RoyaltyOverride // 合集地址 -> 版税登记
+}
+
+func NewRoyaltyResolver(ctx *config.Context) *RoyaltyResolver {
+	registry := make(map[string]config.RoyaltyOverride, len(ctx.Config.Royalties))
+	for _, r := range ctx.Config.Royalties {
+		registry[common.HexToAddress(r.Collection).Hex()] = r
+	}
+	return &RoyaltyResolver{MultiNode: ctx.MultiNode, Registry: registry}
+}
+
+// Resolve 计算成交价对应的应付版税，合集未设置版税时返回 nil
+func (r *RoyaltyResolver) Resolve(ctx context.Context, collection string, tokenID, price *big.Int) (*RoyaltyQuote, error) {
+	collection = common.HexToAddress(collection).Hex()
+	entry, registered := r.Registry[collection]
+	if registered && entry.Override {
+		return registryQuote(entry, price), nil
+	}
+	rate := r.erc2981Rate(ctx, collection, tokenID)
+	if rate.err == nil && !rate.unsupported {
+		amount := new(big.Int).Mul(price, rate.amount)
+		amount.Div(amount, royaltyRefPrice)
+		if amount.Sign() == 0 {
+			return nil, nil
+		}
+		return &RoyaltyQuote{Receiver: rate.receiver, Amount: amount, Source: dao.RoyaltySourceERC2981}, nil
+	}
+	if rate.err != nil {
+		if !registered {
+			return nil, rate.err
+		}
+		log.Printf("[royalty] royaltyInfo 调用失败，使用版税登记: collection=%s, err=%v", collection, rate.err)
+	}
+	if registered {
+		return registryQuote(entry, price), nil
+	}
+	return nil, nil
+}
+
+func registryQuote(entry config.RoyaltyOverride, price *big.Int) *RoyaltyQuote {
+	amount := new(big.Int).Mul(price, big.NewInt(entry.Bps))
+	amount.Div(amount, big.NewInt(10000))
+	return &RoyaltyQuote{Receiver: common.HexToAddress(entry.Receiver).Hex(), Amount: amount, Source: dao.RoyaltySourceRegistry}
+}
+
+// erc2981Rate 查询并缓存合集的版税比例，成功结果缓存 royaltyRateTTL，失败缓存 royaltyRetryTTL
+func (r *RoyaltyResolver) erc2981Rate(ctx context.Context, collection string, tokenID *big.Int) *royaltyRate {
+	if v, ok := erc2981Rates.Load(collection); ok {
+		if rate := v.(*royaltyRate); time.Now().Before(rate.expiresAt) {
+			return rate
+		}
+	}
+	rate := &royaltyRate{expiresAt: time.Now().Add(royaltyRateTTL)}
+	supported, err := r.supportsERC2981(ctx, collection)
+	switch {
+	case err != nil:
+		rate.err = err
+	case !supported:
+		rate.unsupported = true
+	default:
+		var receiver common.Address
+		rate.err = callNodes(r.MultiNode, func(cli *blockchain.EthClient) (err error) {
+			receiver, rate.amount, err = cli.GetRoyaltyInfo(ctx, collection, tokenID, royaltyRefPrice)
+			return err
+		})
+		rate.receiver = receiver.Hex()
+	}
+	if rate.err != nil {
+		rate.expiresAt = time.Now().Add(royaltyRetryTTL)
+	}
+	erc2981Rates.Store(collection, rate)
+	return rate
+}
+
+// supportsERC2981 查询并缓存合约对 ERC-2981 的支持，未实现 ERC-165 的合约视为不支持
+// 节点全部不可用时返回错误且不缓存
+func (r *RoyaltyResolver) supportsERC2981(ctx context.Context, collection string) (bool, error) {
+	if v, ok := erc2981Support.Load(collection); ok {
+		return v.(bool), nil
+	}
+	supported := false
+	err := callNodes(r.MultiNode, func(cli *blockchain.EthClient) (err error) {
+		supported, err = cli.SupportsERC2981(ctx, collection)
+		return err
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "execution reverted") {
+			erc2981Support.Store(collection, false)
+			return false, nil
+		}
+		return false, err
+	}
+	erc2981Support.Store(collection, supported)
+	return supported, nil
+}
+
+// Apply 计算成交的应付版税并与实付版税比较，结果写入成交记录
+// 市场成交的实付版税为 payouts 中支付给版税收款地址的部分，其他第三方收款不计入；
+// 推导成交与自有订单合约成交没有版税明细，实付版税从同交易中支付币种流向版税收款地址的 ERC20 转账统计，
+// 原生 ETH 支付的内部转账不可见，状态记为 unknown
+func (r *RoyaltyResolver) Apply(ctx context.Context, trade *dao.Trade, payouts map[common.Address]*big.Int) {
+	tokenID := common.HexToHash(trade.TokenID).Big()
+	quote, err := r.Resolve(ctx, trade.Collection, tokenID, trade.Price.BigInt())
+	if err != nil {
+		log.Printf("[royalty] 版税解析失败: collection=%s, tokenId=%s, err=%v", trade.Collection, trade.TokenID, err)
+		trade.RoyaltyStatus = dao.RoyaltyStatusUnknown
+		return
+	}
+	if quote == nil {
+		trade.ExpectedRoyalty = decimal.Zero
+		trade.RoyaltyStatus = dao.RoyaltyStatusNone
+		return
+	}
+	trade.ExpectedRoyalty = decimal.NewFromBigInt(quote.Amount, 0)
+	trade.RoyaltyReceiver = quote.Receiver
+	trade.RoyaltySource = quote.Source
+	if trade.Source == dao.TradeSourceMarketplace {
+		paid := new(big.Int)
+		if amount := payouts[common.HexToAddress(quote.Receiver)]; amount != nil {
+			paid = amount
+		}
+		trade.Royalty = decimal.NewFromBigInt(paid, 0)
+	}
+	// 推导成交与自有订单合约成交（OrderFilled 不含版税明细）均按交易回执统计实付版税
+	if (trade.Source == dao.TradeSourceTransfer || trade.Source == dao.TradeSourceOrder) && trade.Royalty.IsZero() {
+		if pricefeed.IsNative(trade.Currency) {
+			trade.RoyaltyStatus = dao.RoyaltyStatusUnknown
+			return
+		}
+		paid, err := r.paidInTx(ctx, trade.TxHash, trade.Currency, quote.Receiver)
+		if err != nil {
+			log.Printf("[royalty] 查询交易回执失败: tx=%s, err=%v", trade.TxHash, err)
+			trade.RoyaltyStatus = dao.RoyaltyStatusUnknown
+			return
+		}
+		trade.Royalty = decimal.NewFromBigInt(paid, 0)
+	}
+	trade.RoyaltyStatus = royaltyStatus(trade.ExpectedRoyalty, trade.Royalty)
+}
+
+// paidInTx 统计交易中支付币种转入版税收款地址的金额
+func (r *RoyaltyResolver) paidInTx(ctx context.Context, txHash, currency, receiver string) (*big.Int, error) {
+	var transfers []blockchain.ERC20Transfer
+	err := callNodes(r.MultiNode, func(cli *blockchain.EthClient) error {
+		receipt, err := cli.GetTransactionReceipt(ctx, txHash)
+		if err != nil {
+			return err
+		}
+		transfers = blockchain.ParseERC20Transfers(receipt.Logs)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	paid := new(big.Int)
+	for _, t := range transfers {
+		if strings.EqualFold(t.Token, currency) && strings.EqualFold(t.To, receiver) {
+			paid.Add(paid, t.Value)
+		}
+	}
+	return paid, nil
+}
+
+// royaltyStatus 比较应付与实付版税
+func royaltyStatus(expected, paid decimal.Decimal) string {
+	switch {
+	case expected.IsZero():
+		return dao.RoyaltyStatusNone
+	case paid.IsZero():
+		return dao.RoyaltyStatusSkipped
+	case paid.Mul(decimal.NewFromInt(10000)).GreaterThanOrEqual(expected.Mul(decimal.NewFromInt(royaltyToleranceBps))):
+		return dao.RoyaltyStatusPaid
+	default:
+		return dao.RoyaltyStatusUnderpaid
+	}
+}
+
+// royaltyFeeCalculator 撮合费用计算：协议手续费按配置，版税优先取 ERC-2981 与版税登记
+type royaltyFeeCalculator struct {
+	Base      *matching.BpsFeeCalculator
+	Royalties *RoyaltyResolver
+}
+
+func (f *royaltyFeeCalculator) Fees(ctx context.Context, m *matching.Match) (matching.Fees, error) {
+	fees, err := f.Base.Fees(ctx, m)
+	if err != nil {
+		return fees, err
+	}
+	quote, err := f.Royalties.Resolve(ctx, m.Collection, common.HexToHash(m.TokenID).Big(), m.Price.BigInt())
+	if err != nil {
+		log.Printf("[royalty] 版税解析失败，使用默认版税: collection=%s, err=%v", m.Collection, err)
+		return fees, nil
+	}
+	if quote != nil {
+		fees.Royalty = decimal.NewFromBigInt(quote.Amount, 0)
+	}
+	return fees, nil
+}
+
+// RoyaltyMarketplaceDTO 单个市场的版税执行情况，金额为基础币种
+type RoyaltyMarketplaceDTO struct {
+	Marketplace     string `json:"marketplace"`
+	Sales           int64  `json:"sales"`
+	Paid            int64  `json:"paid"`
+	Underpaid       int64  `json:"underpaid"`
+	Skipped         int64  `json:"skipped"`
+	NoRoyalty       int64  `json:"no_royalty"`
+	Unknown         int64  `json:"unknown"`
+	ExpectedRoyalty string `json:"expected_royalty"`
+	PaidRoyalty     string `json:"paid_royalty"`
+	Shortfall       string `json:"shortfall"` // 少付与未付的版税合计
+}
+
+// RoyaltyReportDTO 合集版税执行报告
+type RoyaltyReportDTO struct {
+	Collection   string                  `json:"collection"`
+	Currency     string                  `json:"currency"`
+	Marketplaces []RoyaltyMarketplaceDTO `json:"marketplaces"`
+}
+
+// royaltyTotals 单个市场的金额累计
+type royaltyTotals struct {
+	dto       RoyaltyMarketplaceDTO
+	expected  decimal.Decimal
+	paid      decimal.Decimal
+	shortfall decimal.Decimal
+}
+
+// GetRoyaltyReport 按市场汇总合集的版税执行情况
+func (s *Service) GetRoyaltyReport(ctx context.Context, collection string) (*RoyaltyReportDTO, error) {
+	rows, err := s.Dao.RoyaltyReportByMarketplace(collection)
+	if err != nil {
+		return nil, err
+	}
+	byMarket := map[string]*royaltyTotals{}
+	for _, row := range rows {
+		t, ok := byMarket[row.Marketplace]
+		if !ok {
+			t = &royaltyTotals{dto: RoyaltyMarketplaceDTO{Marketplace: row.Marketplace}}
+			byMarket[row.Marketplace] = t
+		}
+		t.dto.Sales += row.Sales
+		switch row.RoyaltyStatus {
+		case dao.RoyaltyStatusPaid:
+			t.dto.Paid += row.Sales
+		case dao.RoyaltyStatusUnderpaid:
+			t.dto.Underpaid += row.Sales
+		case dao.RoyaltyStatusSkipped:
+			t.dto.Skipped += row.Sales
+		case dao.RoyaltyStatusNone:
+			t.dto.NoRoyalty += row.Sales
+		default:
+			// 未解析版税的历史成交同样计入 unknown
+			t.dto.Unknown += row.Sales
+		}
+		expected, err := s.Currencies.ToBase(ctx, row.Currency, row.Expected, decimal.Zero)
+		if err != nil {
+			return nil, err
+		}
+		paid, err := s.Currencies.ToBase(ctx, row.Currency, row.Paid, decimal.Zero)
+		if err != nil {
+			return nil, err
+		}
+		t.expected = t.expected.Add(expected)
+		t.paid = t.paid.Add(paid)
+		if row.RoyaltyStatus == dao.RoyaltyStatusUnderpaid || row.RoyaltyStatus == dao.RoyaltyStatusSkipped {
+			t.shortfall = t.shortfall.Add(expected.Sub(paid))
+		}
+	}
+	report := &RoyaltyReportDTO{
+		Collection:   collection,
+		Currency:     s.Currencies.PriceFeed.BaseCurrency(),
+		Marketplaces: make([]RoyaltyMarketplaceDTO, 0, len(byMarket)),
+	}
+	for _, t := range byMarket {
+		t.dto.ExpectedRoyalty = t.expected.String()
+		t.dto.PaidRoyalty = t.paid.String()
+		t.dto.Shortfall = t.shortfall.String()
+		report.Marketplaces = append(report.Marketplaces, t.dto)
+	}
+	sort.Slice(report.Marketplaces, func(i, j int) bool {
+		return report.Marketplaces[i].Sales > report.Marketplaces[j].Sales
+	})
+	return report, nil
+}
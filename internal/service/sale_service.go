@@ -0,0 +1,71 @@
+package service
+
+import (
+	"github.com/gavin/nftSync/internal/dao"
+)
+
+// SaleDTO 用于安全输出成交记录
+type SaleDTO struct {
+	Marketplace string `json:"marketplace,omitempty"`
+	Collection  string `json:"collection"`
+	TokenID     string `json:"token_id"`
+	Seller      string `json:"seller"`
+	Buyer       string `json:"buyer"`
+	Price       string `json:"price"` // decimal.Decimal转string，链上原始数值
+	PriceScaled string `json:"price_scaled"`
+	Currency    string `json:"currency"`
+	Fee         string `json:"fee"`
+	Royalty     string `json:"royalty"`
+	TxHash      string `json:"tx_hash"`
+	LogIndex    uint   `json:"log_index"`
+	BlockNumber uint64 `json:"block_number"`
+	BlockTime   int64  `json:"block_time"`
+	Source      string `json:"source"`
+}
+
+// ToSaleDTO 将 dao.Trade 转换为 SaleDTO
+func ToSaleDTO(trade *dao.Trade) SaleDTO {
+	return SaleDTO{
+		Marketplace: trade.Marketplace,
+		Collection:  trade.Collection,
+		TokenID:     trade.TokenID,
+		Seller:      trade.Seller,
+		Buyer:       trade.Buyer,
+		Price:       trade.Price.String(),
+		PriceScaled: trade.PriceScaled.String(),
+		Currency:    trade.Currency,
+		Fee:         trade.Fee.String(),
+		Royalty:     trade.Royalty.String(),
+		TxHash:      trade.TxHash,
+		LogIndex:    trade.LogIndex,
+		BlockNumber: trade.BlockNumber,
+		BlockTime:   trade.BlockTime,
+		Source:      trade.Source,
+	}
+}
+
+func toSaleDTOList(trades []dao.Trade) []SaleDTO {
+	res := make([]SaleDTO, 0, len(trades))
+	for i := range trades {
+		res = append(res, ToSaleDTO(&trades[i]))
+	}
+	return res
+}
+
+// ListTokenSales 查询单个 token 的成交历史
+func (s *Service) ListTokenSales(collection, tokenID string, limit, offset int) ([]SaleDTO, error) {
+	trades, err := s.Dao.ListTradesByToken(collection, tokenID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return toSaleDTOList(trades), nil
+}
+
+// ListCollectionSales 查询合集成交历史
+func (s *Service) ListCollectionSales(collection string, limit, offset int) ([]SaleDTO, error) {
+	trades, err := s.Dao.ListTradesByCollection(collection, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return toSaleDTOList(trades), nil
+}
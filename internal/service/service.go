@@ -1,14 +1,25 @@
 package service
 
 import (
+	"github.com/gavin/nftSync/internal/auth"
 	"github.com/gavin/nftSync/internal/config"
 	"github.com/gavin/nftSync/internal/dao"
 	"github.com/gavin/nftSync/internal/middleware"
+	"github.com/gavin/nftSync/internal/push"
+	"time"
 )
 
 type Service struct {
-	Dao   *dao.Dao
-	Cache *middleware.Cache
+	Dao        *dao.Dao
+	Cache      *middleware.Cache
+	Currencies *CurrencyResolver
+	OrderBook  *OrderBookIndex
+	Config     *config.AppConfig
+	MultiNode  *config.MultiNodeEthClient
+	Push       *push.Broker // 未启用推送时为 nil
+	Events     bool         // 是否发布领域事件
+	Auth       *auth.Manager
+	Nonces     *auth.NonceStore // SIWE 一次性 nonce
 }
 
 func NewService(ctx *config.Context) *Service {
@@ -16,7 +27,15 @@ func NewService(ctx *config.Context) *Service {
 	cache := middleware.NewRedis(ctx.Redis)
 
 	return &Service{
-		Dao:   bizDao,
-		Cache: cache,
+		Dao:        bizDao,
+		Cache:      cache,
+		Currencies: NewCurrencyResolver(ctx),
+		OrderBook:  NewOrderBookIndex(ctx),
+		Config:     ctx.Config,
+		MultiNode:  ctx.MultiNode,
+		Auth:       ctx.Auth,
+		Nonces:     auth.NewNonceStore(ctx.Redis, time.Duration(ctx.Config.Auth.SIWE.NonceTTL)*time.Second),
+		Push:       NewPushBroker(ctx),
+		Events:     ctx.Config.Events.Enabled,
 	}
 }
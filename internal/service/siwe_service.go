@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gavin/nftSync/internal/auth"
+	"github.com/gavin/nftSync/internal/blockchain"
+	"github.com/gavin/nftSync/internal/dao"
+	"log"
+	"time"
+)
+
+var (
+	ErrSIWESignature = errors.New("签名与钱包地址不匹配")
+	ErrSIWENonce     = errors.New("nonce 无效或已使用")
+	ErrWalletLinked  = errors.New("钱包地址已绑定其他账户")
+	ErrUserHasWallet = errors.New("账户已绑定其他钱包")
+)
+
+// SIWENonceDTO 登录前获取的一次性 nonce 及消息需使用的域配置
+type SIWENonceDTO struct {
+	Nonce     string `json:"nonce"`
+	ExpiresIn int64  `json:"expires_in"` // 秒
+	ChainID   int64  `json:"chain_id"`
+}
+
+// SIWENonce 签发一次性 nonce
+func (s *Service) SIWENonce(ctx context.Context) (*SIWENonceDTO, error) {
+	nonce, err := s.Nonces.Issue(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &SIWENonceDTO{
+		Nonce:     nonce,
+		ExpiresIn: int64(s.Nonces.TTL().Seconds()),
+		ChainID:   s.siweChainID(),
+	}, nil
+}
+
+// SIWELogin 校验 EIP-4361 消息与签名并返回钱包对应的用户；linkUserID 非 0 时将钱包绑定到该已登录用户，否则按钱包查找或创建用户
+func (s *Service) SIWELogin(ctx context.Context, message, signature string, linkUserID int64) (*dao.User, error) {
+	msg, err := auth.ParseSIWEMessage(message)
+	if err != nil {
+		return nil, err
+	}
+	cfg := s.Config.Auth.SIWE
+	maxAge := time.Duration(cfg.MaxAge) * time.Second
+	if maxAge <= 0 {
+		maxAge = s.Nonces.TTL()
+	}
+	leeway := time.Duration(s.Config.Auth.Leeway) * time.Second
+	if leeway <= 0 {
+		leeway = 30 * time.Second
+	}
+	if err := msg.Validate(cfg.Domains, s.siweChainID(), time.Now(), leeway, maxAge); err != nil {
+		return nil, err
+	}
+	sig, err := hexutil.Decode(signature)
+	if err != nil {
+		return nil, ErrSIWESignature
+	}
+	if err := s.verifySIWESignature(ctx, msg, message, sig); err != nil {
+		return nil, err
+	}
+	// 签名校验通过后再消费 nonce，避免无效请求耗尽合法 nonce
+	ok, err := s.Nonces.Consume(ctx, msg.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrSIWENonce
+	}
+
+	wallet := msg.Address.Hex()
+	if linkUserID != 0 {
+		return s.linkWallet(linkUserID, wallet)
+	}
+	user, err := s.Dao.GetUserByWallet(wallet)
+	if err != nil || user != nil {
+		return user, err
+	}
+	user = &dao.User{WalletAddr: wallet, Role: auth.RoleUser}
+	if err := s.Dao.CreateUser(user); err != nil {
+		// 并发首次登录时唯一索引冲突，读取已创建的用户
+		if existing, _ := s.Dao.GetUserByWallet(wallet); existing != nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	log.Printf("[auth] SIWE 创建钱包用户: user=%d, wallet=%s", user.ID, wallet)
+	return user, nil
+}
+
+// verifySIWESignature EOA 签名通过 ecrecover 校验，恢复地址不一致且钱包为合约时按 ERC-1271 校验
+func (s *Service) verifySIWESignature(ctx context.Context, msg *auth.SIWEMessage, message string, sig []byte) error {
+	if signer, err := auth.RecoverSIWESigner(message, sig); err == nil && signer == msg.Address {
+		return nil
+	}
+	wallet := msg.Address.Hex()
+	var valid bool
+	if err := callNodes(s.MultiNode, func(cli *blockchain.EthClient) error {
+		isContract, err := cli.IsContract(ctx, wallet)
+		if err != nil || !isContract {
+			valid = false
+			return err
+		}
+		valid, err = cli.IsValidERC1271Signature(ctx, wallet, auth.SIWEHash(message), sig)
+		return err
+	}); err != nil {
+		return err
+	}
+	if !valid {
+		return ErrSIWESignature
+	}
+	return nil
+}
+
+// linkWallet 将钱包绑定到已登录用户，钱包已属于该用户时直接返回
+func (s *Service) linkWallet(userID int64, wallet string) (*dao.User, error) {
+	owner, err := s.Dao.GetUserByWallet(wallet)
+	if err != nil {
+		return nil, err
+	}
+	if owner != nil {
+		if owner.ID != userID {
+			return nil, ErrWalletLinked
+		}
+		return owner, nil
+	}
+	linked, err := s.Dao.LinkUserWallet(userID, wallet)
+	if err != nil {
+		return nil, err
+	}
+	if !linked {
+		return nil, ErrUserHasWallet
+	}
+	log.Printf("[auth] SIWE 绑定钱包: user=%d, wallet=%s", userID, wallet)
+	return s.Dao.GetUserByID(userID)
+}
+
+func (s *Service) siweChainID() int64 {
+	if id := s.Config.Auth.SIWE.ChainID; id > 0 {
+		return id
+	}
+	return s.Config.OrderSigning.ChainID
+}
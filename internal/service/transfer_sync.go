@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gavin/nftSync/internal/blockchain"
+	"github.com/gavin/nftSync/internal/blockchain/marketplace"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/middleware"
+	"github.com/shopspring/decimal"
+	"log"
+	"math/big"
+	"strings"
+)
+
+// 判断是否为销毁事件（Transfer to=0x0）
+func isBurnEvent(evt blockchain.TransferEvent) bool {
+	return evt.To == marketplace.ZeroAddress
+}
+
+// recordTransfer 写入转移记录（铸造、转移、销毁均记录）
+func (s *MultiNodeSyncService) recordTransfer(evt blockchain.TransferEvent) {
+	transfer := dao.Transfer{
+		Collection:  evt.Contract,
+		TokenID:     evt.TokenID,
+		From:        evt.From,
+		To:          evt.To,
+		TxHash:      evt.TxHash,
+		LogIndex:    evt.LogIndex,
+		BlockNumber: evt.BlockNumber,
+		BlockTime:   evt.BlockTime,
+	}
+	if err := s.Dao.CreateTransferIgnoreConflict(&transfer); err != nil {
+		log.Printf("[transfer_sync] 转移记录写入失败: %v", err)
+	}
+	recordActivity(s.Dao, s.Push, s.Events, &dao.Activity{
+		Type:        transferActivityType(evt.From, evt.To),
+		Collection:  evt.Contract,
+		TokenID:     evt.TokenID,
+		From:        evt.From,
+		To:          evt.To,
+		TxHash:      evt.TxHash,
+		LogIndex:    int(evt.LogIndex),
+		BlockNumber: evt.BlockNumber,
+		BlockTime:   evt.BlockTime,
+	})
+}
+
+// transferTx 推导成交所需的交易与回执，同一轮同步内按交易哈希缓存：批量购买的多个 Transfer 只查询一次
+type transferTx struct {
+	tx      *types.Transaction
+	sender  common.Address
+	receipt *types.Receipt
+}
+
+// processTransferEvent 处理非铸造转移：更新持有人，并尝试从同交易的 ETH/WETH 流向推导成交
+func (s *MultiNodeSyncService) processTransferEvent(ctx context.Context, evt blockchain.TransferEvent, wethAddr string, txs map[string]*transferTx) {
+	if err := s.Dao.UpdateNFTOwner(evt.Contract, evt.TokenID, evt.To); err != nil {
+		log.Printf("[transfer_sync] NFT持有人更新失败: %v", err)
+	}
+	// 原持有人的挂单随转移失效，触发地板价重算
+	var orders []dao.Order
+	err := s.Dao.Transaction(func(tx *dao.Dao) error {
+		var err error
+		if orders, err = tx.InvalidateListingsOnTransfer(evt.Contract, evt.TokenID, evt.To); err != nil || len(orders) == 0 {
+			return err
+		}
+		return tx.EnqueueOutbox(dao.FloorPriceOutbox(evt.Contract, middleware.FloorTriggerTransfer))
+	})
+	if err != nil {
+		log.Printf("[transfer_sync] 挂单失效标记失败: %v", err)
+		orders = nil
+	}
+	for i := range orders {
+		s.unindexOrder(ctx, &orders[i])
+	}
+	if isBurnEvent(evt) {
+		return
+	}
+	trade, err := s.deriveTransferSale(ctx, evt, wethAddr, txs)
+	if err != nil {
+		log.Printf("[transfer_sync] 推导成交失败: tx=%s, err=%v", evt.TxHash, err)
+		return
+	}
+	if trade == nil {
+		return
+	}
+	if err := s.saveTrade(ctx, trade, false, nil); err != nil {
+		log.Printf("[transfer_sync] 推导成交写入失败: %v", err)
+	} else {
+		log.Printf("[transfer_sync] 推导成交已写入: tx=%s, tokenId=%s, price=%s", trade.TxHash, trade.TokenID, trade.Price)
+	}
+}
+
+// deriveTransferSale 无市场事件的成交推导
+// 买家为交易发送方且附带 ETH 时以 tx.value 为价格；否则统计买家在同交易中转出的 WETH。
+// 同一交易中买家收到多个 NFT 时均分价格（铸造不计入）。铸造与无支付流向的转移不视为成交，返回 nil。
+func (s *MultiNodeSyncService) deriveTransferSale(ctx context.Context, evt blockchain.TransferEvent, wethAddr string, txs map[string]*transferTx) (*dao.Trade, error) {
+	if isMintEvent(evt) {
+		return nil, nil
+	}
+	exists, err := s.Dao.TradeExists(evt.TxHash, evt.Contract, evt.TokenID)
+	if err != nil || exists {
+		return nil, err
+	}
+	t, err := s.loadTransferTx(ctx, evt.TxHash, txs)
+	if err != nil {
+		return nil, err
+	}
+	tx, sender, receipt := t.tx, t.sender, t.receipt
+	price := new(big.Int)
+	currency := marketplace.ZeroAddress
+	if tx.Value().Sign() > 0 && strings.EqualFold(sender.Hex(), evt.To) {
+		price.Set(tx.Value())
+	} else if wethAddr != "" {
+		for _, t := range blockchain.ParseERC20Transfers(receipt.Logs) {
+			if strings.EqualFold(t.Token, wethAddr) && strings.EqualFold(t.From, evt.To) {
+				price.Add(price, t.Value)
+				currency = t.Token
+			}
+		}
+	}
+	if price.Sign() == 0 {
+		return nil, nil
+	}
+
+	nftCount := 0
+	for _, t := range blockchain.ParseERC721Transfers(receipt.Logs) {
+		if strings.EqualFold(t.To, evt.To) && !isMintEvent(t) {
+			nftCount++
+		}
+	}
+	if nftCount > 1 {
+		price.Div(price, big.NewInt(int64(nftCount)))
+	}
+
+	trade := &dao.Trade{
+		Collection:  evt.Contract,
+		TokenID:     evt.TokenID,
+		Seller:      evt.From,
+		Buyer:       evt.To,
+		Price:       decimal.NewFromBigInt(price, 0),
+		Currency:    currency,
+		Fee:         decimal.Zero,
+		Royalty:     decimal.Zero,
+		TxHash:      evt.TxHash,
+		LogIndex:    evt.LogIndex,
+		BlockNumber: evt.BlockNumber,
+		BlockTime:   evt.BlockTime,
+		Source:      dao.TradeSourceTransfer,
+	}
+	trade.PriceScaled = s.scalePrice(ctx, trade.Currency, trade.Price)
+	return trade, nil
+}
+
+// loadTransferTx 查询交易与回执，命中缓存时不再请求节点
+func (s *MultiNodeSyncService) loadTransferTx(ctx context.Context, txHash string, txs map[string]*transferTx) (*transferTx, error) {
+	if t, ok := txs[txHash]; ok {
+		return t, nil
+	}
+	t := &transferTx{}
+	err := callNodes(s.MultiNode, func(cli *blockchain.EthClient) error {
+		var err error
+		if t.tx, t.sender, err = cli.GetTransaction(ctx, txHash); err != nil {
+			return err
+		}
+		t.receipt, err = cli.GetTransactionReceipt(ctx, txHash)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if txs != nil {
+		txs[txHash] = t
+	}
+	return t, nil
+}
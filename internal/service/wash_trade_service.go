@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gavin/nftSync/internal/blockchain/marketplace"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/shopspring/decimal"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 刷量检测默认阈值
+const (
+	defaultWashLookback         = 7 * 24 * 60 * 60
+	defaultWashFundingHops      = 2
+	defaultWashMaxFunderFanout  = 500
+	defaultWashRoundTripWindow  = 30 * 24 * 60 * 60
+	defaultWashMaxLoopAddresses = 3
+	defaultWashDeviationHigh    = 5
+	defaultWashDeviationLow     = 0.2
+	defaultWashFlipSeconds      = 10 * 60
+)
+
+var (
+	ErrFlagNotFound      = errors.New("标记不存在")
+	ErrInvalidFlagStatus = errors.New("审核状态只能为 confirmed 或 dismissed")
+)
+
+// WashTradeDetector 扫描销售账本标记可疑成交：自成交、共同资金来源、循环成交、价格偏离地板价、快速转手
+// 标记按 成交+原因 去重，重复检测不会覆盖人工审核结果
+type WashTradeDetector struct {
+	Dao        *dao.Dao
+	Currencies *CurrencyResolver
+	Config     config.WashTradingConfig
+	ignore     map[string]struct{} // 不参与共同资金判定的地址
+}
+
+func NewWashTradeDetector(ctx *config.Context) *WashTradeDetector {
+	cfg := ctx.Config.WashTrading
+	if cfg.Lookback <= 0 {
+		cfg.Lookback = defaultWashLookback
+	}
+	if cfg.FundingHops <= 0 {
+		cfg.FundingHops = defaultWashFundingHops
+	}
+	if cfg.MaxFunderFanout <= 0 {
+		cfg.MaxFunderFanout = defaultWashMaxFunderFanout
+	}
+	if cfg.RoundTripWindow <= 0 {
+		cfg.RoundTripWindow = defaultWashRoundTripWindow
+	}
+	if cfg.MaxLoopAddresses <= 0 {
+		cfg.MaxLoopAddresses = defaultWashMaxLoopAddresses
+	}
+	if cfg.DeviationHigh <= 0 {
+		cfg.DeviationHigh = defaultWashDeviationHigh
+	}
+	if cfg.DeviationLow <= 0 {
+		cfg.DeviationLow = defaultWashDeviationLow
+	}
+	if cfg.FlipSeconds <= 0 {
+		cfg.FlipSeconds = defaultWashFlipSeconds
+	}
+	ignore := map[string]struct{}{normalizeAddr(marketplace.ZeroAddress): {}}
+	for _, addr := range cfg.IgnoreFunders {
+		ignore[normalizeAddr(addr)] = struct{}{}
+	}
+	for _, m := range ctx.Config.Marketplaces {
+		for _, addr := range m.Contracts {
+			ignore[normalizeAddr(addr)] = struct{}{}
+		}
+	}
+	return &WashTradeDetector{
+		Dao:        dao.New(ctx.Db),
+		Currencies: NewCurrencyResolver(ctx),
+		Config:     cfg,
+		ignore:     ignore,
+	}
+}
+
+// washRun 单轮检测的查询缓存
+type washRun struct {
+	funders map[string]map[string]int // 地址 -> 资金来源地址 -> 层数
+	fanout  map[string]int64
+	flagged map[string]struct{} // 成交ID:原因，同一轮内去重
+	flags   []dao.TradeFlag
+}
+
+func (r *washRun) flag(trade *dao.Trade, reason, detail string) {
+	key := fmt.Sprintf("%d:%s", trade.ID, reason)
+	if _, ok := r.flagged[key]; ok {
+		return
+	}
+	r.flagged[key] = struct{}{}
+	r.flags = append(r.flags, dao.TradeFlag{
+		TradeID:    trade.ID,
+		Reason:     reason,
+		Collection: normalizeAddr(trade.Collection),
+		TokenID:    trade.TokenID,
+		TxHash:     trade.TxHash,
+		Detail:     detail,
+		Status:     dao.FlagStatusPending,
+	})
+}
+
+// Detect 检测最近 Lookback 内的成交，返回本轮命中的标记数量（含此前已写入的标记）
+// 循环成交需要更早的成交作为上下文，额外加载 RoundTripWindow 范围
+func (d *WashTradeDetector) Detect(ctx context.Context) (int, error) {
+	now := time.Now().Unix()
+	since := now - d.Config.Lookback
+	trades, err := d.loadTrades(since - d.Config.RoundTripWindow)
+	if err != nil {
+		return 0, err
+	}
+	run := &washRun{funders: map[string]map[string]int{}, fanout: map[string]int64{}, flagged: map[string]struct{}{}}
+	byToken := map[string][]*dao.Trade{}
+	for i := range trades {
+		key := normalizeAddr(trades[i].Collection) + ":" + trades[i].TokenID
+		byToken[key] = append(byToken[key], &trades[i])
+	}
+	for _, history := range byToken {
+		sort.Slice(history, func(i, j int) bool {
+			if history[i].BlockNumber != history[j].BlockNumber {
+				return history[i].BlockNumber < history[j].BlockNumber
+			}
+			return history[i].LogIndex < history[j].LogIndex
+		})
+		for j, trade := range history {
+			if trade.BlockTime < since {
+				continue
+			}
+			d.checkSelfTrade(run, trade)
+			d.checkPriceDeviation(ctx, run, trade)
+			d.checkHistory(run, history[:j+1])
+		}
+	}
+	if err := d.Dao.CreateTradeFlagsIgnoreConflict(run.flags); err != nil {
+		return 0, err
+	}
+	if len(run.flags) > 0 {
+		log.Printf("[wash] 检测完成: trades=%d, flags=%d", len(trades), len(run.flags))
+	}
+	return len(run.flags), nil
+}
+
+func (d *WashTradeDetector) loadTrades(since int64) ([]dao.Trade, error) {
+	var all []dao.Trade
+	afterID := int64(0)
+	for {
+		trades, err := d.Dao.ListTradesSince(since, afterID, analyticsBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, trades...)
+		if len(trades) < analyticsBatchSize {
+			return all, nil
+		}
+		afterID = trades[len(trades)-1].ID
+	}
+}
+
+// checkSelfTrade 买卖双方相同，或资金来自同一地址（含一方直接为另一方注资）
+func (d *WashTradeDetector) checkSelfTrade(run *washRun, trade *dao.Trade) {
+	buyer, seller := normalizeAddr(trade.Buyer), normalizeAddr(trade.Seller)
+	if buyer == seller {
+		run.flag(trade, dao.FlagReasonSelfTrade, "buyer equals seller")
+		return
+	}
+	buyerFunders, err := d.fundersOf(run, buyer)
+	if err != nil {
+		log.Printf("[wash] 资金来源查询失败: address=%s, err=%v", buyer, err)
+		return
+	}
+	sellerFunders, err := d.fundersOf(run, seller)
+	if err != nil {
+		log.Printf("[wash] 资金来源查询失败: address=%s, err=%v", seller, err)
+		return
+	}
+	if hop, ok := buyerFunders[seller]; ok {
+		run.flag(trade, dao.FlagReasonCommonFunder, fmt.Sprintf("seller funded buyer within %d hops", hop))
+		return
+	}
+	if hop, ok := sellerFunders[buyer]; ok {
+		run.flag(trade, dao.FlagReasonCommonFunder, fmt.Sprintf("buyer funded seller within %d hops", hop))
+		return
+	}
+	for funder, bh := range buyerFunders {
+		if sh, ok := sellerFunders[funder]; ok {
+			run.flag(trade, dao.FlagReasonCommonFunder, fmt.Sprintf("common funder %s (buyer %d hops, seller %d hops)", funder, bh, sh))
+			return
+		}
+	}
+}
+
+// fundersOf 沿已索引的 ETH/WETH 转账逐层追溯资金来源，公共资金源（忽略列表或转出对象过多）不继续追溯
+func (d *WashTradeDetector) fundersOf(run *washRun, address string) (map[string]int, error) {
+	if funders, ok := run.funders[address]; ok {
+		return funders, nil
+	}
+	funders := map[string]int{}
+	frontier := []string{address}
+	for hop := 1; hop <= d.Config.FundingHops && len(frontier) > 0; hop++ {
+		edges, err := d.Dao.ListFunders(frontier)
+		if err != nil {
+			return nil, err
+		}
+		next := []string{}
+		for _, e := range edges {
+			from := normalizeAddr(e.From)
+			if _, seen := funders[from]; seen || from == address {
+				continue
+			}
+			public, err := d.isPublicFunder(run, from)
+			if err != nil {
+				return nil, err
+			}
+			if public {
+				continue
+			}
+			funders[from] = hop
+			next = append(next, from)
+		}
+		frontier = next
+	}
+	run.funders[address] = funders
+	return funders, nil
+}
+
+func (d *WashTradeDetector) isPublicFunder(run *washRun, address string) (bool, error) {
+	if _, ok := d.ignore[address]; ok {
+		return true, nil
+	}
+	count, ok := run.fanout[address]
+	if !ok {
+		var err error
+		if count, err = d.Dao.CountFundedAddresses(address); err != nil {
+			return false, err
+		}
+		run.fanout[address] = count
+	}
+	return count > d.Config.MaxFunderFanout, nil
+}
+
+// checkPriceDeviation 成交价相对成交时地板价的偏离超过阈值
+func (d *WashTradeDetector) checkPriceDeviation(ctx context.Context, run *washRun, trade *dao.Trade) {
+	floor, err := d.Dao.GetFloorPriceAt(trade.Collection, trade.BlockTime)
+	if err != nil || floor == nil {
+		return
+	}
+	floorPrice, err := decimal.NewFromString(floor.Price)
+	if err != nil || !floorPrice.IsPositive() {
+		return
+	}
+	price, err := d.Currencies.ToBase(ctx, trade.Currency, trade.Price, trade.PriceScaled)
+	if err != nil {
+		return
+	}
+	ratio := price.Div(floorPrice)
+	if ratio.GreaterThanOrEqual(decimal.NewFromFloat(d.Config.DeviationHigh)) ||
+		ratio.LessThanOrEqual(decimal.NewFromFloat(d.Config.DeviationLow)) {
+		run.flag(trade, dao.FlagReasonPriceDeviation, fmt.Sprintf("price %s is %sx floor %s", price.StringFixed(6), ratio.StringFixed(2), floorPrice.String()))
+	}
+}
+
+// checkHistory 基于 token 成交历史检测快速转手与循环成交，history 末尾为当前成交
+func (d *WashTradeDetector) checkHistory(run *washRun, history []*dao.Trade) {
+	cur := history[len(history)-1]
+	if len(history) < 2 {
+		return
+	}
+	prev := history[len(history)-2]
+	if strings.EqualFold(prev.Buyer, cur.Seller) && cur.BlockTime-prev.BlockTime <= d.Config.FlipSeconds {
+		run.flag(cur, dao.FlagReasonRapidFlip, fmt.Sprintf("resold %ds after purchase", cur.BlockTime-prev.BlockTime))
+	}
+	// token 回到窗口内较早的卖家手中，且循环只涉及少数地址时，循环内成交全部标记
+	addresses := map[string]struct{}{normalizeAddr(cur.Buyer): {}, normalizeAddr(cur.Seller): {}}
+	for i := len(history) - 2; i >= 0; i-- {
+		t := history[i]
+		if cur.BlockTime-t.BlockTime > d.Config.RoundTripWindow {
+			return
+		}
+		addresses[normalizeAddr(t.Buyer)] = struct{}{}
+		addresses[normalizeAddr(t.Seller)] = struct{}{}
+		if len(addresses) > d.Config.MaxLoopAddresses {
+			return
+		}
+		if strings.EqualFold(t.Seller, cur.Buyer) {
+			detail := fmt.Sprintf("token returned to %s after %d sales among %d addresses", normalizeAddr(cur.Buyer), len(history)-i, len(addresses))
+			for _, loop := range history[i:] {
+				run.flag(loop, dao.FlagReasonRoundTrip, detail)
+			}
+			return
+		}
+	}
+}
+
+// TradeFlagListDTO 可疑成交标记分页结果
+type TradeFlagListDTO struct {
+	Total int64           `json:"total"`
+	Flags []dao.TradeFlag `json:"flags"`
+}
+
+// ListTradeFlags 分页查询可疑成交标记
+func (s *Service) ListTradeFlags(filter dao.TradeFlagFilter, limit, offset int) (*TradeFlagListDTO, error) {
+	if filter.Collection != "" {
+		filter.Collection = normalizeAddr(filter.Collection)
+	}
+	flags, total, err := s.Dao.ListTradeFlags(filter, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &TradeFlagListDTO{Total: total, Flags: flags}, nil
+}
+
+// ReviewTradeFlag 人工审核标记，dismissed 的成交在下一轮统计汇总时重新计入
+func (s *Service) ReviewTradeFlag(id int64, status, reviewer, note string) (*dao.TradeFlag, error) {
+	if status != dao.FlagStatusConfirmed && status != dao.FlagStatusDismissed {
+		return nil, ErrInvalidFlagStatus
+	}
+	flag, err := s.Dao.GetTradeFlag(id)
+	if err != nil {
+		return nil, err
+	}
+	if flag == nil {
+		return nil, ErrFlagNotFound
+	}
+	if err := s.Dao.ReviewTradeFlag(id, status, reviewer, note); err != nil {
+		return nil, err
+	}
+	return s.Dao.GetTradeFlag(id)
+}
@@ -0,0 +1,680 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gavin/nftSync/internal/config"
+	"github.com/gavin/nftSync/internal/dao"
+	"github.com/gavin/nftSync/internal/push"
+	"github.com/gavin/nftSync/internal/webhook"
+	"io"
+	"log"
+	mrand "math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrWebhookNotFound   = errors.New("webhook 不存在")
+	ErrInvalidWebhookURL = errors.New("webhook 地址无效")
+)
+
+// 系统级 webhook（notify.webhook_url）的所属用户
+const systemWebhookUser = "system"
+
+// webhook 事件来源，即扫描进度的 source
+const (
+	webhookSourceActivity = "activities"
+	webhookSourceFloor    = "floor_price_histories"
+)
+
+const (
+	webhookScanBatch    = 500
+	webhookDeliverBatch = 100
+	webhookWorkers      = 8
+	webhookCleanupEvery = time.Hour
+	webhookScanGrace    = 30 * time.Second // 扫描宽限期，需大于写入活动的事务耗时
+)
+
+// WebhookPayload 投递请求体，ID 在重试与重放时保持不变
+type WebhookPayload struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Timestamp int64           `json:"timestamp"` // 事件发生时间（秒）
+	Data      json.RawMessage `json:"data"`
+}
+
+// WebhookEndpointReq 注册 webhook 参数，过滤条件为空时接收全部事件
+type WebhookEndpointReq struct {
+	URL         string   `json:"url"`
+	Collections []string `json:"collections"`
+	Wallets     []string `json:"wallets"`
+	Types       []string `json:"types"`
+}
+
+// WebhookEndpointDTO webhook 输出，Secret 仅在创建时返回
+type WebhookEndpointDTO struct {
+	ID                  int64    `json:"id"`
+	URL                 string   `json:"url"`
+	Secret              string   `json:"secret,omitempty"`
+	Collections         []string `json:"collections"`
+	Wallets             []string `json:"wallets"`
+	Types               []string `json:"types"`
+	Enabled             bool     `json:"enabled"`
+	ConsecutiveFailures int      `json:"consecutive_failures"`
+	DisabledReason      string   `json:"disabled_reason,omitempty"`
+	DisabledAt          int64    `json:"disabled_at,omitempty"`
+	CreatedAt           int64    `json:"created_at"`
+}
+
+// WebhookReplayReq 重放参数，DeliveryIDs 为空时按状态与时间批量重放
+type WebhookReplayReq struct {
+	DeliveryIDs []int64 `json:"delivery_ids"`
+	Status      string  `json:"status"` // 默认 failed
+	Since       int64   `json:"since"`  // 投递记录创建时间下限（秒）
+}
+
+func splitList(v string) []string {
+	if v == "" {
+		return []string{}
+	}
+	return strings.Split(v, ",")
+}
+
+func toWebhookEndpointDTO(e *dao.WebhookEndpoint) WebhookEndpointDTO {
+	return WebhookEndpointDTO{
+		ID:                  e.ID,
+		URL:                 e.URL,
+		Collections:         splitList(e.Collections),
+		Wallets:             splitList(e.Wallets),
+		Types:               splitList(e.Types),
+		Enabled:             e.Enabled,
+		ConsecutiveFailures: e.ConsecutiveFailures,
+		DisabledReason:      e.DisabledReason,
+		DisabledAt:          e.DisabledAt,
+		CreatedAt:           e.CreatedAt.Unix(),
+	}
+}
+
+// webhookFilter 由 endpoint 过滤条件构造订阅过滤器，与实时推送的匹配规则一致
+func webhookFilter(e *dao.WebhookEndpoint) (*push.Filter, error) {
+	return BuildPushFilter(PushQuery{
+		Collections: splitList(e.Collections),
+		Wallets:     splitList(e.Wallets),
+		Types:       splitList(e.Types),
+	})
+}
+
+// checkWebhookURL 用户注册的地址须为 http(s) 且解析到公网地址，投递时拨号再次检查
+func checkWebhookURL(raw string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := webhook.CheckURL(ctx, raw); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhookURL, err)
+	}
+	return nil
+}
+
+// validSystemWebhookURL 系统级 webhook 由运维配置，允许内网地址
+func validSystemWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// CreateWebhook 注册 webhook，过滤条件统一格式后保存
+func (s *Service) CreateWebhook(userID string, req WebhookEndpointReq) (*WebhookEndpointDTO, error) {
+	if err := checkWebhookURL(req.URL); err != nil {
+		return nil, err
+	}
+	filter, err := BuildPushFilter(PushQuery{Collections: req.Collections, Wallets: req.Wallets, Types: req.Types})
+	if err != nil {
+		return nil, err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	endpoint := dao.WebhookEndpoint{
+		UserID:      userID,
+		URL:         req.URL,
+		Secret:      secret,
+		Collections: joinKeys(filter.Collections),
+		Wallets:     joinKeys(filter.Wallets),
+		Types:       joinKeys(filter.Types),
+		Enabled:     true,
+	}
+	if err := s.Dao.CreateWebhookEndpoint(&endpoint); err != nil {
+		return nil, err
+	}
+	dto := toWebhookEndpointDTO(&endpoint)
+	dto.Secret = secret
+	return &dto, nil
+}
+
+func joinKeys(m map[string]bool) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return strings.Join(keys, ",")
+}
+
+// ownedWebhook 查询用户自己的 webhook，不存在或不属于该用户时返回 ErrWebhookNotFound
+func (s *Service) ownedWebhook(userID string, id int64) (*dao.WebhookEndpoint, error) {
+	endpoint, err := s.Dao.GetWebhookEndpoint(id)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint == nil || endpoint.UserID != userID {
+		return nil, ErrWebhookNotFound
+	}
+	return endpoint, nil
+}
+
+// ListWebhooks 查询用户的 webhook
+func (s *Service) ListWebhooks(userID string) ([]WebhookEndpointDTO, error) {
+	list, err := s.Dao.ListWebhookEndpointsByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]WebhookEndpointDTO, 0, len(list))
+	for i := range list {
+		res = append(res, toWebhookEndpointDTO(&list[i]))
+	}
+	return res, nil
+}
+
+// DeleteWebhook 删除 webhook 及其投递记录
+func (s *Service) DeleteWebhook(userID string, id int64) error {
+	if _, err := s.ownedWebhook(userID, id); err != nil {
+		return err
+	}
+	return s.Dao.DeleteWebhookEndpoint(id)
+}
+
+// EnableWebhook 重新启用被自动停用的 webhook，积压的待投递记录随后继续投递
+func (s *Service) EnableWebhook(userID string, id int64) error {
+	if _, err := s.ownedWebhook(userID, id); err != nil {
+		return err
+	}
+	return s.Dao.EnableWebhookEndpoint(id)
+}
+
+// ListWebhookDeliveries 分页查询投递记录
+func (s *Service) ListWebhookDeliveries(userID string, id int64, status string, limit, offset int) ([]dao.WebhookDelivery, int64, error) {
+	if _, err := s.ownedWebhook(userID, id); err != nil {
+		return nil, 0, err
+	}
+	return s.Dao.ListWebhookDeliveries(dao.WebhookDeliveryFilter{EndpointID: id, Status: status}, limit, offset)
+}
+
+// ReplayWebhookDeliveries 将投递记录重置为待投递，返回重放数量
+func (s *Service) ReplayWebhookDeliveries(userID string, id int64, req WebhookReplayReq) (int64, error) {
+	if _, err := s.ownedWebhook(userID, id); err != nil {
+		return 0, err
+	}
+	filter := dao.WebhookDeliveryFilter{EndpointID: id, Status: req.Status, Since: req.Since}
+	if filter.Status == "" && len(req.DeliveryIDs) == 0 {
+		filter.Status = dao.DeliveryStatusFailed
+	}
+	return s.Dao.ReplayWebhookDeliveries(filter, req.DeliveryIDs)
+}
+
+// PingWebhook 写入一条 ping 投递，用于验证接收方与签名配置
+func (s *Service) PingWebhook(userID string, id int64) (*dao.WebhookDelivery, error) {
+	if _, err := s.ownedWebhook(userID, id); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	p := WebhookPayload{
+		ID:        "ping:" + strconv.FormatInt(now.UnixNano(), 10),
+		Type:      "ping",
+		Timestamp: now.Unix(),
+		Data:      json.RawMessage("{}"),
+	}
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	delivery := dao.WebhookDelivery{
+		EndpointID:    id,
+		EventID:       p.ID,
+		EventType:     p.Type,
+		Payload:       string(payload),
+		Status:        dao.DeliveryStatusPending,
+		NextAttemptAt: now.Unix(),
+	}
+	if err := s.Dao.CreateWebhookDelivery(&delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// WebhookDispatcher webhook 事件入队与投递
+// 事件来源为活动表与地板价变动表，按 ID 增量扫描写入投递发件箱，扫描进度持久化并保留宽限期，重启后不丢不重
+// 投递失败按指数退避重试，endpoint 连续失败达到阈值后自动停用
+type WebhookDispatcher struct {
+	Dao          *dao.Dao
+	Config       config.NotifyConfig
+	Client       *http.Client // 用户 webhook，拒绝连接内网地址
+	SystemClient *http.Client // 系统级 webhook，允许内网地址
+	lastCleanup  time.Time
+}
+
+func NewWebhookDispatcher(ctx *config.Context) *WebhookDispatcher {
+	cfg := ctx.Config.Notify
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.BackoffBase <= 0 {
+		cfg.BackoffBase = 10
+	}
+	if cfg.BackoffMax <= 0 {
+		cfg.BackoffMax = 3600
+	}
+	if cfg.DisableAfter <= 0 {
+		cfg.DisableAfter = 20
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 7 * 86400
+	}
+	return &WebhookDispatcher{
+		Dao:          dao.New(ctx.Db),
+		Config:       cfg,
+		Client:       webhook.NewClient(time.Duration(cfg.Timeout)*time.Second, false),
+		SystemClient: webhook.NewClient(time.Duration(cfg.Timeout)*time.Second, true),
+	}
+}
+
+// EnsureSystemEndpoint 按 notify.webhook_url 注册系统级 webhook，接收全部事件
+func (d *WebhookDispatcher) EnsureSystemEndpoint() error {
+	if d.Config.WebhookURL == "" {
+		return nil
+	}
+	if !validSystemWebhookURL(d.Config.WebhookURL) {
+		return ErrInvalidWebhookURL
+	}
+	existing, err := d.Dao.GetWebhookEndpointByURL(systemWebhookUser, d.Config.WebhookURL)
+	if err != nil || existing != nil {
+		return err
+	}
+	return d.Dao.CreateWebhookEndpoint(&dao.WebhookEndpoint{
+		UserID:  systemWebhookUser,
+		URL:     d.Config.WebhookURL,
+		Secret:  d.Config.WebhookSecret,
+		Enabled: true,
+	})
+}
+
+// Run 执行一轮：新事件入队、投递到期记录、定期清理已投递记录
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	if err := d.Enqueue(ctx); err != nil {
+		log.Printf("[webhook] 事件入队失败: %v", err)
+	}
+	d.Deliver(ctx)
+	if time.Since(d.lastCleanup) >= webhookCleanupEvery {
+		d.lastCleanup = time.Now()
+		before := time.Now().Unix() - d.Config.Retention
+		if n, err := d.Dao.DeleteDeliveredWebhookDeliveries(before); err != nil {
+			log.Printf("[webhook] 投递记录清理失败: %v", err)
+		} else if n > 0 {
+			log.Printf("[webhook] 已清理投递记录: %d", n)
+		}
+	}
+}
+
+// webhookTarget 启用中的 endpoint 及其过滤器
+type webhookTarget struct {
+	id     int64
+	filter *push.Filter
+}
+
+// Enqueue 扫描新事件并为匹配的 endpoint 写入投递记录，endpoint 停用期间的事件不入队
+func (d *WebhookDispatcher) Enqueue(ctx context.Context) error {
+	endpoints, err := d.Dao.ListEnabledWebhookEndpoints()
+	if err != nil {
+		return err
+	}
+	targets := make([]webhookTarget, 0, len(endpoints))
+	for i := range endpoints {
+		f, err := webhookFilter(&endpoints[i])
+		if err != nil {
+			log.Printf("[webhook] endpoint 过滤条件无效: id=%d, err=%v", endpoints[i].ID, err)
+			continue
+		}
+		targets = append(targets, webhookTarget{id: endpoints[i].ID, filter: f})
+	}
+	if err := d.enqueueActivities(targets); err != nil {
+		return err
+	}
+	return d.enqueueFloorChanges(targets)
+}
+
+// startCursor 读取扫描进度，首次运行从当前最大 ID 开始，不回溯历史事件
+func (d *WebhookDispatcher) startCursor(source string, maxID func() (int64, error)) (int64, error) {
+	cursor, err := d.Dao.GetWebhookCursor(source)
+	if err != nil || cursor >= 0 {
+		return cursor, err
+	}
+	if cursor, err = maxID(); err != nil {
+		return 0, err
+	}
+	return cursor, d.Dao.SaveWebhookCursor(source, cursor)
+}
+
+// webhookEvent 待入队的事件，written 为写入时间，用于判断宽限期
+type webhookEvent struct {
+	id      int64
+	written time.Time
+	event   push.Event
+	payload WebhookPayload
+}
+
+func (d *WebhookDispatcher) enqueueActivities(targets []webhookTarget) error {
+	return d.enqueueSource(webhookSourceActivity, targets, d.Dao.MaxActivityID, func(afterID int64) ([]webhookEvent, error) {
+		list, err := d.Dao.ListActivitiesAfterID(afterID, webhookScanBatch)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]webhookEvent, 0, len(list))
+		for i := range list {
+			a := &list[i]
+			we := webhookEvent{id: a.ID, written: a.CreatedAt}
+			if data, err := json.Marshal(toActivityDTO(a)); err == nil {
+				we.event = push.Event{Type: a.Type, Collection: a.Collection, TokenID: a.TokenID}
+				for _, w := range []string{a.From, a.To} {
+					if w != "" {
+						we.event.Wallets = append(we.event.Wallets, w)
+					}
+				}
+				we.payload = WebhookPayload{ID: "activity:" + strconv.FormatInt(a.ID, 10), Type: a.Type, Timestamp: a.BlockTime, Data: data}
+			}
+			out = append(out, we)
+		}
+		return out, nil
+	})
+}
+
+func (d *WebhookDispatcher) enqueueFloorChanges(targets []webhookTarget) error {
+	return d.enqueueSource(webhookSourceFloor, targets, d.Dao.MaxFloorPriceHistoryID, func(afterID int64) ([]webhookEvent, error) {
+		list, err := d.Dao.ListFloorPriceHistoryAfterID(afterID, webhookScanBatch)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]webhookEvent, 0, len(list))
+		for i := range list {
+			h := &list[i]
+			we := webhookEvent{id: h.ID, written: time.Unix(h.Timestamp, 0)}
+			data, err := json.Marshal(FloorChangeDTO{
+				Collection: h.Collection,
+				Price:      h.Price,
+				Currency:   h.Currency,
+				Trigger:    h.Trigger,
+				Timestamp:  h.Timestamp,
+			})
+			if err == nil {
+				we.event = push.Event{Type: push.TypeFloorChanged, Collection: h.Collection}
+				we.payload = WebhookPayload{ID: "floor:" + strconv.FormatInt(h.ID, 10), Type: we.event.Type, Timestamp: h.Timestamp, Data: data}
+			}
+			out = append(out, we)
+		}
+		return out, nil
+	})
+}
+
+// enqueueSource 按 ID 扫描事件来源并写入投递记录
+// 事件由多个协程在事务中写入，自增 ID 可能乱序提交：游标只推进到写入时间早于宽限期的连续前缀，
+// 宽限期内的事件每轮重新扫描，投递记录由 (endpoint_id, event_id) 唯一索引去重，较小 ID 晚提交时不会被跳过。
+// 配置了 notify.mq_topic 时，事件在游标越过它的同一事务中写入发件箱，每个事件恰好发布一次。
+func (d *WebhookDispatcher) enqueueSource(source string, targets []webhookTarget, maxID func() (int64, error),
+	next func(afterID int64) ([]webhookEvent, error)) error {
+	cursor, err := d.startCursor(source, maxID)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-webhookScanGrace)
+	scanned, settled := cursor, true
+	for {
+		list, err := next(scanned)
+		if err != nil || len(list) == 0 {
+			return err
+		}
+		var deliveries []dao.WebhookDelivery
+		var notices []dao.OutboxMessage
+		for i := range list {
+			we := &list[i]
+			if we.payload.ID != "" {
+				deliveries = appendDeliveries(deliveries, targets, &we.event, we.payload)
+			}
+			if !settled || !we.written.Before(cutoff) {
+				settled = false
+				continue
+			}
+			cursor = we.id
+			if d.Config.MQTopic != "" && we.payload.ID != "" {
+				if msg, err := notifyOutbox(&we.event, we.payload); err == nil {
+					notices = append(notices, msg)
+				}
+			}
+		}
+		err = d.Dao.Transaction(func(tx *dao.Dao) error {
+			if err := tx.CreateWebhookDeliveriesIgnoreConflict(deliveries); err != nil {
+				return err
+			}
+			if err := tx.EnqueueOutbox(notices...); err != nil {
+				return err
+			}
+			return tx.SaveWebhookCursor(source, cursor)
+		})
+		if err != nil {
+			return err
+		}
+		scanned = list[len(list)-1].id
+		if len(list) < webhookScanBatch {
+			return nil
+		}
+	}
+}
+
+// notifyOutbox 事件通知消息，发布到 notify.mq_topic，按合集保证有序，消息体与 webhook 请求体一致
+func notifyOutbox(e *push.Event, payload WebhookPayload) (dao.OutboxMessage, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return dao.OutboxMessage{}, err
+	}
+	headers, _ := json.Marshal(map[string]string{webhook.HeaderEvent: payload.Type, webhook.HeaderEventID: payload.ID})
+	return dao.OutboxMessage{
+		Kind:    dao.OutboxKindNotify,
+		MsgKey:  e.Collection,
+		Payload: string(body),
+		Headers: string(headers),
+	}, nil
+}
+
+// appendDeliveries 为匹配事件的每个 endpoint 生成一条投递记录
+func appendDeliveries(list []dao.WebhookDelivery, targets []webhookTarget, e *push.Event, payload WebhookPayload) []dao.WebhookDelivery {
+	var body []byte
+	now := time.Now().Unix()
+	for _, t := range targets {
+		if !t.filter.Match(e) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(payload); err != nil {
+				return list
+			}
+		}
+		list = append(list, dao.WebhookDelivery{
+			EndpointID:    t.id,
+			EventID:       payload.ID,
+			EventType:     payload.Type,
+			Payload:       string(body),
+			Status:        dao.DeliveryStatusPending,
+			NextAttemptAt: now,
+		})
+	}
+	return list
+}
+
+// Deliver 并发投递到期记录，每条记录先抢占再发送，多实例运行时不重复投递
+func (d *WebhookDispatcher) Deliver(ctx context.Context) {
+	now := time.Now().Unix()
+	due, err := d.Dao.ListDueWebhookDeliveries(now, webhookDeliverBatch)
+	if err != nil {
+		log.Printf("[webhook] 待投递记录查询失败: %v", err)
+		return
+	}
+	if len(due) == 0 {
+		return
+	}
+	endpoints := map[int64]*dao.WebhookEndpoint{}
+	for _, item := range due {
+		if _, ok := endpoints[item.EndpointID]; ok {
+			continue
+		}
+		endpoint, err := d.Dao.GetWebhookEndpoint(item.EndpointID)
+		if err != nil {
+			log.Printf("[webhook] endpoint 查询失败: id=%d, err=%v", item.EndpointID, err)
+		}
+		endpoints[item.EndpointID] = endpoint
+	}
+
+	jobs := make(chan *dao.WebhookDelivery)
+	var wg sync.WaitGroup
+	for i := 0; i < webhookWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				// 空闲 worker 取到记录时才抢占，租约从抢占时刻起算，覆盖一次请求超时；
+				// 实例崩溃时记录在租约到期后被重新投递
+				lease := time.Now().Unix() + int64(d.Config.Timeout)*2
+				ok, err := d.Dao.ClaimWebhookDelivery(item.ID, item.NextAttemptAt, lease)
+				if err != nil || !ok {
+					continue
+				}
+				d.deliverOne(ctx, endpoints[item.EndpointID], item)
+			}
+		}()
+	}
+	for i := range due {
+		item := &due[i]
+		if endpoints[item.EndpointID] == nil {
+			continue
+		}
+		jobs <- item
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// deliverOne 发送一次投递并记录结果
+func (d *WebhookDispatcher) deliverOne(ctx context.Context, endpoint *dao.WebhookEndpoint, item *dao.WebhookDelivery) {
+	code, err := d.send(ctx, endpoint, item)
+	attempts := item.Attempts + 1
+	if err == nil {
+		if err := d.Dao.UpdateWebhookDelivery(item.ID, map[string]interface{}{
+			"status":           dao.DeliveryStatusDelivered,
+			"attempts":         attempts,
+			"last_status_code": code,
+			"last_error":       "",
+			"delivered_at":     time.Now().Unix(),
+		}); err != nil {
+			log.Printf("[webhook] 投递结果更新失败: id=%d, err=%v", item.ID, err)
+		}
+		if err := d.Dao.ResetWebhookFailures(endpoint.ID); err != nil {
+			log.Printf("[webhook] endpoint 失败计数重置失败: id=%d, err=%v", endpoint.ID, err)
+		}
+		return
+	}
+
+	fields := map[string]interface{}{
+		"attempts":         attempts,
+		"last_status_code": code,
+		"last_error":       err.Error(),
+	}
+	if attempts >= d.Config.MaxAttempts {
+		fields["status"] = dao.DeliveryStatusFailed
+		log.Printf("[webhook] 投递失败且重试次数用尽: id=%d, endpoint=%d, err=%v", item.ID, endpoint.ID, err)
+	} else {
+		fields["next_attempt_at"] = time.Now().Unix() + d.backoff(attempts)
+	}
+	if err := d.Dao.UpdateWebhookDelivery(item.ID, fields); err != nil {
+		log.Printf("[webhook] 投递结果更新失败: id=%d, err=%v", item.ID, err)
+	}
+	reason := fmt.Sprintf("连续投递失败 %d 次，最后错误: %v", d.Config.DisableAfter, err)
+	disabled, derr := d.Dao.IncrWebhookFailures(endpoint.ID, d.Config.DisableAfter, reason)
+	if derr != nil {
+		log.Printf("[webhook] endpoint 失败计数更新失败: id=%d, err=%v", endpoint.ID, derr)
+	} else if disabled {
+		log.Printf("[webhook] endpoint 已自动停用: id=%d, url=%s", endpoint.ID, endpoint.URL)
+	}
+}
+
+// backoff 第 attempts 次失败后的重试间隔（秒）：base * 2^(attempts-1)，不超过上限，附加 10% 以内随机抖动
+func (d *WebhookDispatcher) backoff(attempts int) int64 {
+	delay := int64(d.Config.BackoffBase)
+	for i := 1; i < attempts && delay < int64(d.Config.BackoffMax); i++ {
+		delay *= 2
+	}
+	if delay > int64(d.Config.BackoffMax) {
+		delay = int64(d.Config.BackoffMax)
+	}
+	return delay + mrand.Int63n(delay/10+1)
+}
+
+// send 发送签名请求，2xx 视为成功
+func (d *WebhookDispatcher) send(ctx context.Context, endpoint *dao.WebhookEndpoint, item *dao.WebhookDelivery) (int, error) {
+	body := []byte(item.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "nftSync-Webhook/1.0")
+	req.Header.Set(webhook.HeaderEvent, item.EventType)
+	req.Header.Set(webhook.HeaderEventID, item.EventID)
+	req.Header.Set(webhook.HeaderDelivery, strconv.FormatInt(item.ID, 10))
+	req.Header.Set(webhook.HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(webhook.HeaderSignature, webhook.Sign(endpoint.Secret, ts, body))
+	client := d.Client
+	if endpoint.UserID == systemWebhookUser {
+		client = d.SystemClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// 响应体不落库，避免经投递记录接口读取内网服务响应
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, fmt.Errorf("HTTP %d", resp.StatusCode)
+}
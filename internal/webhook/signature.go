@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 投递请求头
+const (
+	HeaderEvent     = "X-NftSync-Event"     // 事件类型
+	HeaderEventID   = "X-NftSync-Event-Id"  // 事件唯一标识，重试与重放时不变，接收方据此去重
+	HeaderDelivery  = "X-NftSync-Delivery"  // 投递记录 ID
+	HeaderTimestamp = "X-NftSync-Timestamp" // 签名时间（秒）
+	HeaderSignature = "X-NftSync-Signature" // sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))
+)
+
+const signaturePrefix = "sha256="
+
+var (
+	ErrMissingSignature = errors.New("缺少签名")
+	ErrInvalidSignature = errors.New("签名不匹配")
+	ErrStaleTimestamp   = errors.New("签名时间超出容忍范围")
+)
+
+// Sign 计算请求签名，签名内容包含时间戳以防重放
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 接收方校验签名，tolerance 为允许的时间偏差，0 表示不校验时间
+func Verify(secret, timestampHeader, signatureHeader string, body []byte, tolerance time.Duration) error {
+	if timestampHeader == "" || !strings.HasPrefix(signatureHeader, signaturePrefix) {
+		return ErrMissingSignature
+	}
+	ts, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return ErrMissingSignature
+	}
+	if tolerance > 0 {
+		if d := time.Since(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+			return ErrStaleTimestamp
+		}
+	}
+	if !hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signatureHeader)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}